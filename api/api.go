@@ -3,9 +3,11 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/url"
+	"time"
 
 	dpurl "github.com/ONSdigital/dp-image-api/url"
 
@@ -14,45 +16,86 @@ import (
 	"github.com/ONSdigital/dp-image-api/config"
 	"github.com/ONSdigital/dp-image-api/event"
 	"github.com/ONSdigital/dp-image-api/schema"
+	"github.com/ONSdigital/dp-image-api/signedurl"
 	kafka "github.com/ONSdigital/dp-kafka/v3"
 	"github.com/ONSdigital/log.go/v2/log"
 	"github.com/gorilla/mux"
 )
 
+// lockRetryAfterSeconds is the Retry-After header value of the responses to requests that timed out waiting for an image lock
+const lockRetryAfterSeconds = "1"
+
+// maxImageUpdateAttempts is the number of times that a conditional image update is attempted,
+// when the image keeps being modified by other requests between being read and being updated
+const maxImageUpdateAttempts = 3
+
 // API provides a struct to wrap the api around
 type API struct {
-	Router             *mux.Router
-	mongoDB            MongoServer
-	auth               AuthHandler
-	uploadProducer     *event.AvroProducer
-	publishedProducer  *event.AvroProducer
-	urlBuilder         *dpurl.Builder
-	downloadServiceURL string
-	apiUrl             *url.URL
-	enableURLRewriting bool
+	Router               *mux.Router
+	mongoDB              MongoServer
+	auth                 AuthHandler
+	uploadProducer       *event.AvroProducer
+	publishedProducer    *event.AvroProducer
+	withdrawnProducer    *event.AvroProducer
+	stateProducer        *event.AvroProducer
+	replayer             *EventReplayer
+	urlBuilder           *dpurl.Builder
+	apiUrl               *url.URL
+	downloadServiceURL   *url.URL
+	enableURLRewriting   bool
+	isPublishing         bool
+	deadLetterMinBackoff time.Duration
+	deadLetterMaxBackoff time.Duration
+	imageLockTimeout     time.Duration
+	cacheMaxAge          time.Duration
+	previewSigner        *signedurl.Signer
+	previewURLExpiry     time.Duration
 }
 
 // Setup creates the API struct and its endpoints with corresponding handlers
-func Setup(ctx context.Context, cfg *config.Config, r *mux.Router, auth AuthHandler, mongoDB MongoServer, uploadedKafkaProducer, publishedKafkaProducer kafka.IProducer, builder *dpurl.Builder) *API {
+func Setup(ctx context.Context, cfg *config.Config, r *mux.Router, auth AuthHandler, mongoDB MongoServer, uploadedKafkaProducer, publishedKafkaProducer, withdrawnKafkaProducer, stateChangedKafkaProducer kafka.IProducer, builder *dpurl.Builder) *API {
 	apiURL, err := url.Parse(cfg.APIURL)
 	if err != nil {
 		log.Error(ctx, "could not parse image api url", err, log.Data{"url": cfg.APIURL})
 		return nil
 	}
+	downloadServiceURL, err := url.Parse(cfg.DownloadServiceURL)
+	if err != nil {
+		log.Error(ctx, "could not parse download service url", err, log.Data{"url": cfg.DownloadServiceURL})
+		return nil
+	}
 
 	api := &API{
-		Router:             r,
-		auth:               auth,
-		mongoDB:            mongoDB,
-		urlBuilder:         builder,
-		downloadServiceURL: cfg.DownloadServiceURL,
-		enableURLRewriting: cfg.EnableURLRewriting,
-		apiUrl:             apiURL,
+		Router:               r,
+		auth:                 auth,
+		mongoDB:              mongoDB,
+		urlBuilder:           builder,
+		enableURLRewriting:   cfg.EnableURLRewriting,
+		apiUrl:               apiURL,
+		downloadServiceURL:   downloadServiceURL,
+		isPublishing:         cfg.IsPublishing,
+		deadLetterMinBackoff: cfg.DeadLetterMinBackoff,
+		deadLetterMaxBackoff: cfg.DeadLetterMaxBackoff,
+		imageLockTimeout:     cfg.ImageLockTimeout,
+		cacheMaxAge:          cfg.CacheControlMaxAge,
+		previewURLExpiry:     cfg.PreviewURLExpiry,
 	}
 
 	if cfg.IsPublishing {
-		api.uploadProducer = event.NewAvroProducer(uploadedKafkaProducer.Channels().Output, schema.ImageUploadedEvent)
-		api.publishedProducer = event.NewAvroProducer(publishedKafkaProducer.Channels().Output, schema.ImagePublishedEvent)
+		if cfg.PreviewURLSigningKey != "" {
+			api.previewSigner = signedurl.NewSigner([]byte(cfg.PreviewURLSigningKey))
+		}
+		api.uploadProducer = newEventProducer(cfg, uploadedKafkaProducer, schema.ImageUploadedEvent)
+		api.publishedProducer = newEventProducer(cfg, publishedKafkaProducer, schema.ImagePublishedEvent)
+		api.withdrawnProducer = newEventProducer(cfg, withdrawnKafkaProducer, schema.ImageWithdrawnEvent)
+		api.stateProducer = newEventProducer(cfg, stateChangedKafkaProducer, schema.ImageStateChangedEvent)
+		api.replayer = &EventReplayer{
+			mongoDB:           mongoDB,
+			uploadProducer:    api.uploadProducer,
+			publishedProducer: api.publishedProducer,
+			urlBuilder:        builder,
+			rate:              cfg.EventReplayRate,
+		}
 		r.HandleFunc("/images", auth.Require(dpauth.Permissions{Read: true}, api.GetImagesHandler)).Methods(http.MethodGet)
 		r.HandleFunc("/images", auth.Require(dpauth.Permissions{Create: true}, api.CreateImageHandler)).Methods(http.MethodPost)
 		r.HandleFunc("/images/{id}", auth.Require(dpauth.Permissions{Read: true}, api.GetImageHandler)).Methods(http.MethodGet)
@@ -61,16 +104,45 @@ func Setup(ctx context.Context, cfg *config.Config, r *mux.Router, auth AuthHand
 		r.HandleFunc("/images/{id}/downloads", auth.Require(dpauth.Permissions{Update: true}, api.CreateDownloadHandler)).Methods(http.MethodPost)
 		r.HandleFunc("/images/{id}/downloads/{variant}", auth.Require(dpauth.Permissions{Read: true}, api.GetDownloadHandler)).Methods(http.MethodGet)
 		r.HandleFunc("/images/{id}/downloads/{variant}", auth.Require(dpauth.Permissions{Update: true}, api.UpdateDownloadHandler)).Methods(http.MethodPut)
+		r.HandleFunc("/images/{id}/downloads/{variant}/preview-url", auth.Require(dpauth.Permissions{Read: true}, api.GetDownloadPreviewURLHandler)).Methods(http.MethodGet)
 		r.HandleFunc("/images/{id}/publish", auth.Require(dpauth.Permissions{Update: true}, api.PublishImageHandler)).Methods(http.MethodPost)
+		r.HandleFunc("/images/{id}/publish", auth.Require(dpauth.Permissions{Update: true}, api.CancelScheduledPublishHandler)).Methods(http.MethodDelete)
+		r.HandleFunc("/images/{id}/withdraw", auth.Require(dpauth.Permissions{Update: true}, api.WithdrawImageHandler)).Methods(http.MethodPost)
+		r.HandleFunc("/images/{id}/revisions", auth.Require(dpauth.Permissions{Read: true}, api.GetRevisionsHandler)).Methods(http.MethodGet)
+		r.HandleFunc("/images/{id}/revisions", auth.Require(dpauth.Permissions{Update: true}, api.CreateRevisionHandler)).Methods(http.MethodPost)
+		r.HandleFunc("/images/{id}/versions/{version}", auth.Require(dpauth.Permissions{Read: true}, api.GetVersionHandler)).Methods(http.MethodGet)
+		r.HandleFunc("/images/{id}/versions/{version}/downloads/{variant}", auth.Require(dpauth.Permissions{Read: true}, api.GetVersionDownloadHandler)).Methods(http.MethodGet)
+		r.HandleFunc("/admin/dead-letters", auth.Require(dpauth.Permissions{Read: true}, api.GetDeadLettersHandler)).Methods(http.MethodGet)
+		r.HandleFunc("/admin/dead-letters/{id}/replay", auth.Require(dpauth.Permissions{Update: true}, api.ReplayDeadLetterHandler)).Methods(http.MethodPost)
+		r.HandleFunc("/admin/replay-events", auth.Require(dpauth.Permissions{Update: true}, api.ReplayEventsHandler)).Methods(http.MethodPost)
+		r.HandleFunc("/admin/export", auth.Require(dpauth.Permissions{Read: true}, api.ExportHandler)).Methods(http.MethodGet)
+		r.HandleFunc(ImportPath, auth.Require(dpauth.Permissions{Create: true, Update: true}, api.ImportHandler)).Methods(http.MethodPost)
+		r.HandleFunc("/admin/locks", auth.Require(dpauth.Permissions{Read: true}, api.GetImageLocksHandler)).Methods(http.MethodGet)
 	} else {
 		r.HandleFunc("/images", api.GetImagesHandler).Methods(http.MethodGet)
 		r.HandleFunc("/images/{id}", api.GetImageHandler).Methods(http.MethodGet)
 		r.HandleFunc("/images/{id}/downloads", api.GetDownloadsHandler).Methods(http.MethodGet)
 		r.HandleFunc("/images/{id}/downloads/{variant}", api.GetDownloadHandler).Methods(http.MethodGet)
+		r.HandleFunc("/images/{id}/revisions", api.GetRevisionsHandler).Methods(http.MethodGet)
+		r.HandleFunc("/images/{id}/versions/{version}", api.GetVersionHandler).Methods(http.MethodGet)
+		r.HandleFunc("/images/{id}/versions/{version}/downloads/{variant}", api.GetVersionDownloadHandler).Methods(http.MethodGet)
 	}
 	return api
 }
 
+// newEventProducer creates an event producer for the provided kafka producer, which encodes the events
+// as CloudEvents structured JSON or binary mode values if configured to do so, or with the provided avro schema otherwise
+func newEventProducer(cfg *config.Config, kafkaProducer kafka.IProducer, avroSchema event.Marshaller) *event.AvroProducer {
+	marshaller := avroSchema
+	switch event.Encoding(cfg.EventEncoding) {
+	case event.EncodingCloudEventsStructured:
+		marshaller = event.NewCloudEventsMarshaller(cfg.EventSource)
+	case event.EncodingCloudEventsBinary:
+		marshaller = event.NewCloudEventsBinaryMarshaller(cfg.EventSource)
+	}
+	return event.NewAvroProducer(kafkaProducer.Channels().Output, marshaller, cfg.KafkaSendTimeout, kafkaProducer.IsInitialised)
+}
+
 // Close is called during graceful shutdown to give the API an opportunity to perform any required disposal task
 func (*API) Close(ctx context.Context) error {
 	log.Info(ctx, "graceful shutdown of api complete")
@@ -103,7 +175,7 @@ func ReadJSONBody(_ context.Context, body io.ReadCloser, v interface{}) error {
 	// Get Body bytes
 	payload, err := io.ReadAll(body)
 	if err != nil {
-		return apierrors.ErrUnableToReadMessage
+		return bodyReadError(err)
 	}
 
 	// Unmarshal body bytes to model
@@ -114,13 +186,26 @@ func ReadJSONBody(_ context.Context, body io.ReadCloser, v interface{}) error {
 	return nil
 }
 
+// bodyReadError returns the api error corresponding to a failure to read a request body,
+// which is ErrRequestBodyTooLarge if the body exceeded the maximum request body size
+func bodyReadError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return apierrors.ErrRequestBodyTooLarge
+	}
+	return apierrors.ErrUnableToReadMessage
+}
+
 // handleError is a utility function that maps api errors to an http status code and sets the provided responseWriter accordingly
 func handleError(ctx context.Context, w http.ResponseWriter, err error, data log.Data) {
 	var status int
 	if err != nil {
 		switch err {
 		case apierrors.ErrImageNotFound,
-			apierrors.ErrVariantNotFound:
+			apierrors.ErrVariantNotFound,
+			apierrors.ErrImageNoScheduledPublish,
+			apierrors.ErrImageVersionNotFound,
+			apierrors.ErrDeadLetterNotFound:
 			status = http.StatusNotFound
 		case apierrors.ErrUnableToReadMessage,
 			apierrors.ErrUnableToParseJSON,
@@ -129,19 +214,41 @@ func handleError(ctx context.Context, w http.ResponseWriter, err error, data log
 			apierrors.ErrImageInvalidState,
 			apierrors.ErrImageDownloadTypeMismatch,
 			apierrors.ErrImageDownloadInvalidState,
+			apierrors.ErrImageDownloadInvalidSha256,
+			apierrors.ErrImageWithdrawalNoReason,
+			apierrors.ErrImageVersionInvalid,
 			apierrors.ErrImageIDMismatch,
-			apierrors.ErrVariantIDMismatch:
+			apierrors.ErrVariantIDMismatch,
+			apierrors.ErrReplayInvalidState,
+			apierrors.ErrReplayInvalidTimeRange,
+			apierrors.ErrInvalidLinksURL:
 			status = http.StatusBadRequest
 		case apierrors.ErrImageAlreadyPublished,
 			apierrors.ErrImageAlreadyCompleted,
 			apierrors.ErrImageStateTransitionNotAllowed,
+			apierrors.ErrImageWithdrawalNotAllowed,
 			apierrors.ErrImageBadInitialState,
 			apierrors.ErrImageNotImporting,
 			apierrors.ErrImageNotPublished,
+			apierrors.ErrImageNotCompleted,
 			apierrors.ErrVariantAlreadyExists,
 			apierrors.ErrVariantStateTransitionNotAllowed,
-			apierrors.ErrImageDownloadBadInitialState:
+			apierrors.ErrImageDownloadBadInitialState,
+			apierrors.ErrVariantNotPreviewable:
 			status = http.StatusForbidden
+		case apierrors.ErrImageUpdateConflict:
+			status = http.StatusConflict
+		case apierrors.ErrRequestBodyTooLarge:
+			status = http.StatusRequestEntityTooLarge
+		case apierrors.ErrPreviewURLsDisabled:
+			status = http.StatusNotImplemented
+		case event.ErrSendTimeout,
+			event.ErrProducerNotInitialised:
+			status = http.StatusServiceUnavailable
+		case apierrors.ErrImageLockTimeout:
+			// the image lock is normally released as soon as the request holding it completes
+			w.Header().Set("Retry-After", lockRetryAfterSeconds)
+			status = http.StatusServiceUnavailable
 		default:
 			status = http.StatusInternalServerError
 		}
@@ -35,7 +35,7 @@ func TestSetup(t *testing.T) {
 				return func(http.ResponseWriter, *http.Request) {}
 			},
 		}
-		urlBuilder := url.NewBuilder("")
+		urlBuilder := url.NewBuilder("", "")
 
 		Convey("When created in Publishing mode", func() {
 			cfg := &config.Config{IsPublishing: true}
@@ -43,13 +43,27 @@ func TestSetup(t *testing.T) {
 				ChannelsFunc: func() *kafka.ProducerChannels {
 					return &kafka.ProducerChannels{}
 				},
+				IsInitialisedFunc: func() bool { return true },
 			}
 			publishedKafkaProducer := &kafkatest.IProducerMock{
 				ChannelsFunc: func() *kafka.ProducerChannels {
 					return &kafka.ProducerChannels{}
 				},
+				IsInitialisedFunc: func() bool { return true },
 			}
-			imageAPI := api.Setup(ctx, cfg, r, authHandlerMock, &mock.MongoServerMock{}, uploadedKafkaProducer, publishedKafkaProducer, urlBuilder)
+			withdrawnKafkaProducer := &kafkatest.IProducerMock{
+				ChannelsFunc: func() *kafka.ProducerChannels {
+					return &kafka.ProducerChannels{}
+				},
+				IsInitialisedFunc: func() bool { return true },
+			}
+			stateChangedKafkaProducer := &kafkatest.IProducerMock{
+				ChannelsFunc: func() *kafka.ProducerChannels {
+					return &kafka.ProducerChannels{}
+				},
+				IsInitialisedFunc: func() bool { return true },
+			}
+			imageAPI := api.Setup(ctx, cfg, r, authHandlerMock, &mock.MongoServerMock{}, uploadedKafkaProducer, publishedKafkaProducer, withdrawnKafkaProducer, stateChangedKafkaProducer, urlBuilder)
 
 			Convey("Then the following routes should have been added", func() {
 				So(hasRoute(imageAPI.Router, "/images", http.MethodGet), ShouldBeTrue)
@@ -60,11 +74,24 @@ func TestSetup(t *testing.T) {
 				So(hasRoute(imageAPI.Router, "/images/{id}/downloads", http.MethodPost), ShouldBeTrue)
 				So(hasRoute(imageAPI.Router, "/images/{id}/downloads/{variant}", http.MethodGet), ShouldBeTrue)
 				So(hasRoute(imageAPI.Router, "/images/{id}/downloads/{variant}", http.MethodPut), ShouldBeTrue)
+				So(hasRoute(imageAPI.Router, "/images/{id}/downloads/{variant}/preview-url", http.MethodGet), ShouldBeTrue)
 				So(hasRoute(imageAPI.Router, "/images/{id}/publish", http.MethodPost), ShouldBeTrue)
+				So(hasRoute(imageAPI.Router, "/images/{id}/publish", http.MethodDelete), ShouldBeTrue)
+				So(hasRoute(imageAPI.Router, "/images/{id}/withdraw", http.MethodPost), ShouldBeTrue)
+				So(hasRoute(imageAPI.Router, "/images/{id}/revisions", http.MethodGet), ShouldBeTrue)
+				So(hasRoute(imageAPI.Router, "/images/{id}/revisions", http.MethodPost), ShouldBeTrue)
+				So(hasRoute(imageAPI.Router, "/images/{id}/versions/{version}", http.MethodGet), ShouldBeTrue)
+				So(hasRoute(imageAPI.Router, "/images/{id}/versions/{version}/downloads/{variant}", http.MethodGet), ShouldBeTrue)
+				So(hasRoute(imageAPI.Router, "/admin/dead-letters", http.MethodGet), ShouldBeTrue)
+				So(hasRoute(imageAPI.Router, "/admin/dead-letters/{id}/replay", http.MethodPost), ShouldBeTrue)
+				So(hasRoute(imageAPI.Router, "/admin/replay-events", http.MethodPost), ShouldBeTrue)
+				So(hasRoute(imageAPI.Router, "/admin/export", http.MethodGet), ShouldBeTrue)
+				So(hasRoute(imageAPI.Router, "/admin/import", http.MethodPost), ShouldBeTrue)
+				So(hasRoute(imageAPI.Router, "/admin/locks", http.MethodGet), ShouldBeTrue)
 			})
 
 			Convey("And auth handler is called once per route with the expected permissions", func() {
-				So(authHandlerMock.RequireCalls(), ShouldHaveLength, 9)
+				So(authHandlerMock.RequireCalls(), ShouldHaveLength, 22)
 				So(authHandlerMock.RequireCalls()[0].Required, ShouldResemble, dpauth.Permissions{
 					Create: false, Read: true, Update: false, Delete: false}) // permissions for GET /images
 				So(authHandlerMock.RequireCalls()[1].Required, ShouldResemble, dpauth.Permissions{
@@ -82,7 +109,29 @@ func TestSetup(t *testing.T) {
 				So(authHandlerMock.RequireCalls()[7].Required, ShouldResemble, dpauth.Permissions{
 					Create: false, Read: false, Update: true, Delete: false}) // permissions for PUT /images/{id}/downloads/{variant}
 				So(authHandlerMock.RequireCalls()[8].Required, ShouldResemble, dpauth.Permissions{
+					Create: false, Read: true, Update: false, Delete: false}) // permissions for GET /images/{id}/downloads/{variant}/preview-url
+				So(authHandlerMock.RequireCalls()[9].Required, ShouldResemble, dpauth.Permissions{
 					Create: false, Read: false, Update: true, Delete: false}) // permissions for POST /images/{id}/publish
+				So(authHandlerMock.RequireCalls()[10].Required, ShouldResemble, dpauth.Permissions{
+					Create: false, Read: false, Update: true, Delete: false}) // permissions for DELETE /images/{id}/publish
+				So(authHandlerMock.RequireCalls()[11].Required, ShouldResemble, dpauth.Permissions{
+					Create: false, Read: false, Update: true, Delete: false}) // permissions for POST /images/{id}/withdraw
+				So(authHandlerMock.RequireCalls()[12].Required, ShouldResemble, dpauth.Permissions{
+					Create: false, Read: true, Update: false, Delete: false}) // permissions for GET /images/{id}/revisions
+				So(authHandlerMock.RequireCalls()[13].Required, ShouldResemble, dpauth.Permissions{
+					Create: false, Read: false, Update: true, Delete: false}) // permissions for POST /images/{id}/revisions
+				So(authHandlerMock.RequireCalls()[16].Required, ShouldResemble, dpauth.Permissions{
+					Create: false, Read: true, Update: false, Delete: false}) // permissions for GET /admin/dead-letters
+				So(authHandlerMock.RequireCalls()[17].Required, ShouldResemble, dpauth.Permissions{
+					Create: false, Read: false, Update: true, Delete: false}) // permissions for POST /admin/dead-letters/{id}/replay
+				So(authHandlerMock.RequireCalls()[18].Required, ShouldResemble, dpauth.Permissions{
+					Create: false, Read: false, Update: true, Delete: false}) // permissions for POST /admin/replay-events
+				So(authHandlerMock.RequireCalls()[19].Required, ShouldResemble, dpauth.Permissions{
+					Create: false, Read: true, Update: false, Delete: false}) // permissions for GET /admin/export
+				So(authHandlerMock.RequireCalls()[20].Required, ShouldResemble, dpauth.Permissions{
+					Create: true, Read: false, Update: true, Delete: false}) // permissions for POST /admin/import
+				So(authHandlerMock.RequireCalls()[21].Required, ShouldResemble, dpauth.Permissions{
+					Create: false, Read: true, Update: false, Delete: false}) // permissions for GET /admin/locks
 			})
 		})
 
@@ -90,7 +139,9 @@ func TestSetup(t *testing.T) {
 			cfg := &config.Config{IsPublishing: false}
 			uploadedKafkaProducer := &kafkatest.IProducerMock{}
 			publishedKafkaProducer := &kafkatest.IProducerMock{}
-			imageAPI := api.Setup(ctx, cfg, r, authHandlerMock, &mock.MongoServerMock{}, uploadedKafkaProducer, publishedKafkaProducer, urlBuilder)
+			withdrawnKafkaProducer := &kafkatest.IProducerMock{}
+			stateChangedKafkaProducer := &kafkatest.IProducerMock{}
+			imageAPI := api.Setup(ctx, cfg, r, authHandlerMock, &mock.MongoServerMock{}, uploadedKafkaProducer, publishedKafkaProducer, withdrawnKafkaProducer, stateChangedKafkaProducer, urlBuilder)
 
 			Convey("Then only the get routes should have been added", func() {
 				So(hasRoute(imageAPI.Router, "/images", http.MethodGet), ShouldBeTrue)
@@ -101,7 +152,20 @@ func TestSetup(t *testing.T) {
 				So(hasRoute(imageAPI.Router, "/images/{id}/downloads", http.MethodPost), ShouldBeFalse)
 				So(hasRoute(imageAPI.Router, "/images/{id}/downloads/{variant}", http.MethodGet), ShouldBeTrue)
 				So(hasRoute(imageAPI.Router, "/images/{id}/downloads/{variant}", http.MethodPut), ShouldBeFalse)
+				So(hasRoute(imageAPI.Router, "/images/{id}/downloads/{variant}/preview-url", http.MethodGet), ShouldBeFalse)
 				So(hasRoute(imageAPI.Router, "/images/{id}/publish", http.MethodPut), ShouldBeFalse)
+				So(hasRoute(imageAPI.Router, "/images/{id}/publish", http.MethodDelete), ShouldBeFalse)
+				So(hasRoute(imageAPI.Router, "/images/{id}/withdraw", http.MethodPost), ShouldBeFalse)
+				So(hasRoute(imageAPI.Router, "/images/{id}/revisions", http.MethodGet), ShouldBeTrue)
+				So(hasRoute(imageAPI.Router, "/images/{id}/revisions", http.MethodPost), ShouldBeFalse)
+				So(hasRoute(imageAPI.Router, "/images/{id}/versions/{version}", http.MethodGet), ShouldBeTrue)
+				So(hasRoute(imageAPI.Router, "/images/{id}/versions/{version}/downloads/{variant}", http.MethodGet), ShouldBeTrue)
+				So(hasRoute(imageAPI.Router, "/admin/dead-letters", http.MethodGet), ShouldBeFalse)
+				So(hasRoute(imageAPI.Router, "/admin/dead-letters/{id}/replay", http.MethodPost), ShouldBeFalse)
+				So(hasRoute(imageAPI.Router, "/admin/replay-events", http.MethodPost), ShouldBeFalse)
+				So(hasRoute(imageAPI.Router, "/admin/export", http.MethodGet), ShouldBeFalse)
+				So(hasRoute(imageAPI.Router, "/admin/import", http.MethodPost), ShouldBeFalse)
+				So(hasRoute(imageAPI.Router, "/admin/locks", http.MethodGet), ShouldBeFalse)
 			})
 
 			Convey("And no auth permissions are required", func() {
@@ -117,8 +181,10 @@ func TestClose(t *testing.T) {
 		ctx := context.Background()
 		uploadedKafkaProducer := &kafkatest.IProducerMock{}
 		publishedKafkaProducer := &kafkatest.IProducerMock{}
-		urlBuilder := url.NewBuilder("")
-		a := api.Setup(ctx, &config.Config{}, r, &mock.AuthHandlerMock{}, &mock.MongoServerMock{}, uploadedKafkaProducer, publishedKafkaProducer, urlBuilder)
+		withdrawnKafkaProducer := &kafkatest.IProducerMock{}
+		stateChangedKafkaProducer := &kafkatest.IProducerMock{}
+		urlBuilder := url.NewBuilder("", "")
+		a := api.Setup(ctx, &config.Config{}, r, &mock.AuthHandlerMock{}, &mock.MongoServerMock{}, uploadedKafkaProducer, publishedKafkaProducer, withdrawnKafkaProducer, stateChangedKafkaProducer, urlBuilder)
 
 		Convey("When the api is closed any dependencies are closed also", func() {
 			err := a.Close(ctx)
@@ -128,12 +194,32 @@ func TestClose(t *testing.T) {
 	})
 }
 
-// GetAPIWithMocks also used in other tests
-func GetAPIWithMocks(cfg *config.Config, mongoDBMock *mock.MongoServerMock, authHandlerMock *mock.AuthHandlerMock, uploadedKafkaProducerMock, publishedKafkaProducerMock kafka.IProducer) *api.API {
+// GetAPIWithMocks also used in other tests. Image state changed events are discarded.
+func GetAPIWithMocks(cfg *config.Config, mongoDBMock *mock.MongoServerMock, authHandlerMock *mock.AuthHandlerMock, uploadedKafkaProducerMock, publishedKafkaProducerMock, withdrawnKafkaProducerMock kafka.IProducer) *api.API {
+	return GetAPIWithStateChangedProducer(cfg, mongoDBMock, authHandlerMock, uploadedKafkaProducerMock, publishedKafkaProducerMock, withdrawnKafkaProducerMock, newDiscardKafkaProducer())
+}
+
+// GetAPIWithStateChangedProducer is like GetAPIWithMocks, but with the provided producer for image state changed events
+func GetAPIWithStateChangedProducer(cfg *config.Config, mongoDBMock *mock.MongoServerMock, authHandlerMock *mock.AuthHandlerMock, uploadedKafkaProducerMock, publishedKafkaProducerMock, withdrawnKafkaProducerMock, stateChangedKafkaProducerMock kafka.IProducer) *api.API {
 	mu.Lock()
 	defer mu.Unlock()
-	urlBuilder := url.NewBuilder("http://example.com")
-	return api.Setup(testContext, cfg, mux.NewRouter(), authHandlerMock, mongoDBMock, uploadedKafkaProducerMock, publishedKafkaProducerMock, urlBuilder)
+	urlBuilder := url.NewBuilderFromConfig(cfg).WithAPIURL("http://example.com")
+	return api.Setup(testContext, cfg, mux.NewRouter(), authHandlerMock, mongoDBMock, uploadedKafkaProducerMock, publishedKafkaProducerMock, withdrawnKafkaProducerMock, stateChangedKafkaProducerMock, urlBuilder)
+}
+
+// newDiscardKafkaProducer returns a kafka producer mock that consumes and discards any message sent to its output channel
+func newDiscardKafkaProducer() *kafkatest.IProducerMock {
+	output := make(chan []byte)
+	go func() {
+		for range output {
+		}
+	}()
+	return &kafkatest.IProducerMock{
+		ChannelsFunc: func() *kafka.ProducerChannels {
+			return &kafka.ProducerChannels{Output: output}
+		},
+		IsInitialisedFunc: func() bool { return true },
+	}
 }
 
 func hasRoute(r *mux.Router, path, method string) bool {
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ONSdigital/dp-image-api/models"
+)
+
+// Cache-Control values of web mode responses that must not be cached, or that must be revalidated before being reused
+const (
+	cacheControlNoStore    = "no-store"
+	cacheControlRevalidate = "no-cache"
+)
+
+// cacheControl returns the Cache-Control of a web mode response about an image in the provided state.
+// Completed images can be cached for the configured max age, whereas images in other states are not cached, as they are expected to change.
+func (api *API) cacheControl(state string) string {
+	if state == models.StateCompleted.String() {
+		return api.cacheControlPublic()
+	}
+	return cacheControlNoStore
+}
+
+// cacheControlPublic returns the Cache-Control of web mode responses that can be cached for the configured max age
+func (api *API) cacheControlPublic() string {
+	return fmt.Sprintf("public, max-age=%d", int64(api.cacheMaxAge.Seconds()))
+}
+
+// writeCacheHeaders sets the Cache-Control and Last-Modified headers of a web mode response, and nothing in publishing mode.
+// If the resource was not modified since the If-Modified-Since date of the request, 304 Not Modified is written and true is returned,
+// in which case the caller must not write anything else.
+func (api *API) writeCacheHeaders(w http.ResponseWriter, req *http.Request, cacheControl string, lastModified *time.Time) (notModified bool) {
+	if api.isPublishing {
+		return false
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+
+	if lastModified == nil || lastModified.IsZero() {
+		return false
+	}
+	// http dates have a precision of one second
+	modified := lastModified.UTC().Truncate(time.Second)
+	w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil || modified.After(since) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// lastModified returns the latest last updated date of the provided images, or nil if it is unknown for any of them
+func lastModified(images []models.Image) *time.Time {
+	var latest *time.Time
+	for i := range images {
+		if images[i].LastUpdated == nil {
+			return nil
+		}
+		if latest == nil || images[i].LastUpdated.After(*latest) {
+			latest = images[i].LastUpdated
+		}
+	}
+	return latest
+}
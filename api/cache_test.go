@@ -0,0 +1,155 @@
+package api_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	dpauth "github.com/ONSdigital/dp-authorisation/auth"
+	"github.com/ONSdigital/dp-image-api/api/mock"
+	"github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/dp-image-api/config"
+	"github.com/ONSdigital/dp-image-api/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var (
+	testLastUpdated1 = time.Date(2020, time.April, 26, 8, 5, 52, 123456789, time.UTC)
+	testLastUpdated2 = time.Date(2020, time.April, 27, 10, 0, 0, 0, time.UTC)
+)
+
+// serveCachedGet serves a GET request for the provided path, with the provided If-Modified-Since header if it is not empty
+func serveCachedGet(h http.Handler, path, ifModifiedSince string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "http://localhost:24700"+path, http.NoBody)
+	if ifModifiedSince != "" {
+		r.Header.Set("If-Modified-Since", ifModifiedSince)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestCacheHeaders(t *testing.T) {
+	completedImage := func() *models.Image {
+		image := dbFullImageWithDownloads(models.StateCompleted, dbDownload(models.StateDownloadCompleted))
+		image.LastUpdated = &testLastUpdated1
+		return image
+	}
+	publishedImage := func() *models.Image {
+		image := dbFullImageWithDownloads(models.StatePublished, dbDownload(models.StateDownloadPublished))
+		image.ID = testImageID1
+		image.LastUpdated = &testLastUpdated2
+		return image
+	}
+	mongoDBMock := &mock.MongoServerMock{
+		GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+			switch id {
+			case testImageID2:
+				return completedImage(), nil
+			case testImageID1:
+				return publishedImage(), nil
+			default:
+				return nil, apierrors.ErrImageNotFound
+			}
+		},
+		GetImagesFunc: func(ctx context.Context, collectionID string) ([]models.Image, error) {
+			return []models.Image{*completedImage(), *publishedImage()}, nil
+		},
+		GetImageVersionFunc: func(ctx context.Context, imageID string, version int) (*models.Version, error) {
+			return completedImage().NewVersion(testLastUpdated1), nil
+		},
+	}
+	authHandlerMock := &mock.AuthHandlerMock{
+		RequireFunc: func(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+			return handler
+		},
+	}
+	lastModified1 := "Sun, 26 Apr 2020 08:05:52 GMT"
+	lastModified2 := "Mon, 27 Apr 2020 10:00:00 GMT"
+
+	Convey("Given an image API in web mode caching completed images for a day", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		cfg.IsPublishing = false
+		cfg.CacheControlMaxAge = 24 * time.Hour
+		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+		Convey("Then a completed image, its downloads and its versions can be cached, and are served with their last modification date", func() {
+			for _, path := range []string{
+				fmt.Sprintf("/images/%s", testImageID2),
+				fmt.Sprintf("/images/%s/downloads", testImageID2),
+				fmt.Sprintf("/images/%s/downloads/%s", testImageID2, testVariantOriginal),
+				fmt.Sprintf("/images/%s/versions/1", testImageID2),
+				fmt.Sprintf("/images/%s/versions/1/downloads/%s", testImageID2, testVariantOriginal),
+			} {
+				w := serveCachedGet(imageAPI.Router, path, "")
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Cache-Control"), ShouldEqual, "public, max-age=86400")
+				So(w.Header().Get("Last-Modified"), ShouldEqual, lastModified1)
+			}
+		})
+
+		Convey("Then a published image that is not completed yet, and its downloads, are not cached", func() {
+			for _, path := range []string{
+				fmt.Sprintf("/images/%s", testImageID1),
+				fmt.Sprintf("/images/%s/downloads", testImageID1),
+				fmt.Sprintf("/images/%s/downloads/%s", testImageID1, testVariantOriginal),
+			} {
+				w := serveCachedGet(imageAPI.Router, path, "")
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Cache-Control"), ShouldEqual, "no-store")
+				So(w.Header().Get("Last-Modified"), ShouldEqual, lastModified2)
+			}
+		})
+
+		Convey("Then the list of images is revalidated, and served with the latest modification date of its images", func() {
+			w := serveCachedGet(imageAPI.Router, "/images", "")
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get("Cache-Control"), ShouldEqual, "no-cache")
+			So(w.Header().Get("Last-Modified"), ShouldEqual, lastModified2)
+		})
+
+		Convey("Then 304 Not Modified is returned without body if the resource was not modified since the provided date", func() {
+			for path, ifModifiedSince := range map[string]string{
+				fmt.Sprintf("/images/%s", testImageID2):                                   lastModified1,
+				fmt.Sprintf("/images/%s/downloads/%s", testImageID2, testVariantOriginal): lastModified2,
+				fmt.Sprintf("/images/%s/versions/1", testImageID2):                        lastModified1,
+				"/images": lastModified2,
+			} {
+				w := serveCachedGet(imageAPI.Router, path, ifModifiedSince)
+				So(w.Code, ShouldEqual, http.StatusNotModified)
+				So(w.Body.Len(), ShouldEqual, 0)
+				So(w.Header().Get("Last-Modified"), ShouldNotBeEmpty)
+			}
+		})
+
+		Convey("Then the resource is returned if it was modified since the provided date, or if the date is invalid", func() {
+			So(serveCachedGet(imageAPI.Router, fmt.Sprintf("/images/%s", testImageID2), "Sun, 26 Apr 2020 08:05:51 GMT").Code, ShouldEqual, http.StatusOK)
+			So(serveCachedGet(imageAPI.Router, "/images", lastModified1).Code, ShouldEqual, http.StatusOK)
+			So(serveCachedGet(imageAPI.Router, fmt.Sprintf("/images/%s", testImageID2), "invalid").Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("Then a nonexistent variant is still not found", func() {
+			w := serveCachedGet(imageAPI.Router, fmt.Sprintf("/images/%s/downloads/inexistent", testImageID2), lastModified1)
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+			So(w.Header().Get("Cache-Control"), ShouldBeEmpty)
+		})
+	})
+
+	Convey("Given an image API in publishing mode", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		cfg.IsPublishing = true
+		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+		Convey("Then no caching header is set, and conditional requests are ignored", func() {
+			w := serveCachedGet(imageAPI.Router, fmt.Sprintf("/images/%s", testImageID2), lastModified1)
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get("Cache-Control"), ShouldBeEmpty)
+			So(w.Header().Get("Last-Modified"), ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/dp-image-api/event"
+	"github.com/ONSdigital/dp-image-api/models"
+	dpreq "github.com/ONSdigital/dp-net/v3/request"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/gorilla/mux"
+)
+
+// GetDeadLettersHandler is a handler that returns all the events that could not be sent to kafka
+func (api *API) GetDeadLettersHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	logdata := log.Data{
+		"request-id": ctx.Value(dpreq.RequestIdKey),
+	}
+
+	items, err := api.mongoDB.GetDeadLetters(ctx)
+	if err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+	if items == nil {
+		items = []models.DeadLetter{}
+	}
+	deadLetters := models.DeadLetters{
+		Items:      items,
+		Count:      len(items),
+		TotalCount: len(items),
+		Limit:      len(items),
+	}
+
+	if err := WriteJSONBody(deadLetters, w, http.StatusOK); err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+	log.Info(ctx, "successfully retrieved dead letters", logdata)
+}
+
+// ReplayDeadLetterHandler is a handler that sends the event of a dead letter to kafka again
+func (api *API) ReplayDeadLetterHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	vars := mux.Vars(req)
+	id := vars["id"]
+	logdata := log.Data{
+		"request-id":     ctx.Value(dpreq.RequestIdKey),
+		"dead-letter-id": id,
+	}
+
+	deadLetter, err := api.mongoDB.GetDeadLetter(ctx, id)
+	if err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+	logdata["event_type"] = deadLetter.EventType
+	logdata["image-id"] = deadLetter.ImageID
+
+	if err := api.ReplayDeadLetter(ctx, deadLetter); err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+
+	// Replay handler does not return any content on success
+	w.WriteHeader(http.StatusNoContent)
+	log.Info(ctx, "successfully replayed dead letter", logdata)
+}
+
+// ReplayDeadLetter sends the event of the provided dead letter again. The dead letter is deleted if the event is sent,
+// otherwise the failed attempt is recorded and the next attempt is scheduled with an exponential backoff.
+func (api *API) ReplayDeadLetter(ctx context.Context, deadLetter *models.DeadLetter) error {
+	logdata := log.Data{"dead-letter-id": deadLetter.ID, "event_type": deadLetter.EventType, "image-id": deadLetter.ImageID}
+
+	if err := api.sendDeadLetter(ctx, deadLetter); err != nil {
+		deadLetter.RecordFailedAttempt(err, time.Now().UTC(), api.deadLetterMinBackoff, api.deadLetterMaxBackoff)
+		if updateErr := api.mongoDB.UpdateDeadLetterAttempt(ctx, deadLetter); updateErr != nil {
+			log.Error(ctx, "failed to record dead letter attempt", updateErr, logdata)
+		}
+		return err
+	}
+
+	return api.mongoDB.DeleteDeadLetter(ctx, deadLetter.ID)
+}
+
+// sendDeadLetter decodes the event of the provided dead letter and sends it with the producer corresponding to its event type,
+// keeping the occurrence ID of the original event
+func (api *API) sendDeadLetter(ctx context.Context, deadLetter *models.DeadLetter) error {
+	switch deadLetter.EventType {
+	case models.DeadLetterImageUploaded:
+		e := &event.ImageUploaded{ID: deadLetter.EventID}
+		if err := json.Unmarshal(deadLetter.Payload, e); err != nil {
+			return err
+		}
+		return api.uploadProducer.ImageUploaded(ctx, e)
+	case models.DeadLetterImagePublished:
+		e := &event.ImagePublished{ID: deadLetter.EventID}
+		if err := json.Unmarshal(deadLetter.Payload, e); err != nil {
+			return err
+		}
+		return api.publishedProducer.ImagePublished(ctx, e)
+	case models.DeadLetterImageWithdrawn:
+		e := &event.ImageWithdrawn{ID: deadLetter.EventID}
+		if err := json.Unmarshal(deadLetter.Payload, e); err != nil {
+			return err
+		}
+		return api.withdrawnProducer.ImageWithdrawn(ctx, e)
+	default:
+		return apierrors.ErrDeadLetterInvalidEventType
+	}
+}
+
+// deadLetter stores the provided event as a dead letter if it could not be delivered to kafka, so that it is retried later.
+// It returns nil if the event has been stored, or the provided send error if it is not a delivery failure or it could not be stored.
+func (api *API) deadLetter(ctx context.Context, eventType, imageID string, e interface{}, sendErr error, logdata log.Data) error {
+	if sendErr != event.ErrSendTimeout && sendErr != event.ErrProducerNotInitialised {
+		return sendErr
+	}
+
+	eventID, err := event.EnsureID(e)
+	if err != nil {
+		log.Error(ctx, "failed to identify dead letter event", err, logdata)
+		return sendErr
+	}
+	deadLetter, err := models.NewDeadLetter(NewID(), eventID, eventType, imageID, e, sendErr, time.Now().UTC(), api.deadLetterMinBackoff)
+	if err != nil {
+		log.Error(ctx, "failed to create dead letter", err, logdata)
+		return sendErr
+	}
+	if err := api.mongoDB.CreateDeadLetter(ctx, deadLetter); err != nil {
+		log.Error(ctx, "failed to store dead letter", err, logdata)
+		return sendErr
+	}
+
+	log.Warn(ctx, "event could not be sent and has been stored as a dead letter", log.Data{"dead-letter-id": deadLetter.ID, "event_type": eventType, "send_error": sendErr.Error()})
+	return nil
+}
@@ -0,0 +1,299 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	dpauth "github.com/ONSdigital/dp-authorisation/auth"
+	"github.com/ONSdigital/dp-image-api/api/mock"
+	"github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/dp-image-api/config"
+	"github.com/ONSdigital/dp-image-api/event"
+	"github.com/ONSdigital/dp-image-api/models"
+	"github.com/ONSdigital/dp-image-api/schema"
+	"github.com/ONSdigital/dp-net/v3/handlers"
+	dpreq "github.com/ONSdigital/dp-net/v3/request"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	testDeadLetterID = "deadLetterID"
+	testEventID      = "4e4b1a4c-2b55-4b8a-9f55-8a3e6b7e2f10"
+)
+
+var testDeadLetterCreatedAt = time.Date(2020, time.April, 26, 8, 5, 52, 0, time.UTC)
+
+func dbDeadLetter(eventType string, payload string) *models.DeadLetter {
+	return &models.DeadLetter{
+		ID:          testDeadLetterID,
+		EventType:   eventType,
+		ImageID:     testImageID1,
+		Payload:     json.RawMessage(payload),
+		Error:       event.ErrSendTimeout.Error(),
+		Attempts:    1,
+		CreatedAt:   &testDeadLetterCreatedAt,
+		LastAttempt: &testDeadLetterCreatedAt,
+		NextAttempt: &testDeadLetterCreatedAt,
+	}
+}
+
+func TestGetDeadLettersHandler(t *testing.T) {
+	Convey("Given a valid config and auth handler", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		authHandlerMock := &mock.AuthHandlerMock{
+			RequireFunc: func(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+				return handler
+			},
+		}
+
+		Convey("And a dead letter in MongoDB", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetDeadLettersFunc: func(ctx context.Context) ([]models.DeadLetter, error) {
+					return []models.DeadLetter{*dbDeadLetter(models.DeadLetterImageUploaded, `{"image_id":"imageImageID1"}`)}, nil
+				},
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("Calling 'get dead letters' results in 200 OK response with the expected dead letters", func() {
+				r := httptest.NewRequest(http.MethodGet, "http://localhost:24700/admin/dead-letters", http.NoBody)
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get(contentTypeKey), ShouldEqual, contentTypeJSON)
+				payload, err := io.ReadAll(w.Body)
+				So(err, ShouldBeNil)
+				retDeadLetters := models.DeadLetters{}
+				err = json.Unmarshal(payload, &retDeadLetters)
+				So(err, ShouldBeNil)
+				So(retDeadLetters.Count, ShouldEqual, 1)
+				So(retDeadLetters.TotalCount, ShouldEqual, 1)
+				So(retDeadLetters.Items, ShouldHaveLength, 1)
+				So(retDeadLetters.Items[0].ID, ShouldEqual, testDeadLetterID)
+				So(retDeadLetters.Items[0].EventType, ShouldEqual, models.DeadLetterImageUploaded)
+				So(retDeadLetters.Items[0].Attempts, ShouldEqual, 1)
+				So(string(retDeadLetters.Items[0].Payload), ShouldEqual, `{"image_id":"imageImageID1"}`)
+			})
+		})
+
+		Convey("And no dead letters in MongoDB", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetDeadLettersFunc: func(ctx context.Context) ([]models.DeadLetter, error) {
+					return nil, nil
+				},
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("Calling 'get dead letters' results in 200 OK response with an empty list", func() {
+				r := httptest.NewRequest(http.MethodGet, "http://localhost:24700/admin/dead-letters", http.NoBody)
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusOK)
+				payload, err := io.ReadAll(w.Body)
+				So(err, ShouldBeNil)
+				So(string(payload), ShouldEqual, `{"count":0,"offset_index":0,"limit":0,"items":[],"total_count":0}`)
+			})
+		})
+
+		Convey("And a MongoDB that fails to return the dead letters", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetDeadLettersFunc: func(ctx context.Context) ([]models.DeadLetter, error) {
+					return nil, errMongoDB
+				},
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("Calling 'get dead letters' results in 500 InternalServerError response", func() {
+				r := httptest.NewRequest(http.MethodGet, "http://localhost:24700/admin/dead-letters", http.NoBody)
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+	})
+}
+
+func TestReplayDeadLetterHandler(t *testing.T) {
+	Convey("Given a valid config and auth handler", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		authHandlerMock := &mock.AuthHandlerMock{
+			RequireFunc: func(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+				return handler
+			},
+		}
+
+		Convey("And an image uploaded dead letter in MongoDB", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetDeadLetterFunc: func(ctx context.Context, id string) (*models.DeadLetter, error) {
+					return dbDeadLetter(models.DeadLetterImageUploaded, `{"image_id":"imageImageID1","path":"path","filename":"image.png"}`), nil
+				},
+				DeleteDeadLetterFunc:        func(ctx context.Context, id string) error { return nil },
+				UpdateDeadLetterAttemptFunc: func(ctx context.Context, deadLetter *models.DeadLetter) error { return nil },
+			}
+
+			Convey("Calling 'replay dead letter' results in 204 NoContent response, with the event sent to kafka and the dead letter deleted", func() {
+				uploadedProducer := newBufferedKafkaProducer()
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, uploadedProducer, kafkaStubProducer, kafkaStubProducer)
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/admin/dead-letters/%s/replay", testDeadLetterID), http.NoBody)
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+				So(mongoDBMock.GetDeadLetterCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.GetDeadLetterCalls()[0].ID, ShouldEqual, testDeadLetterID)
+				So(mongoDBMock.DeleteDeadLetterCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.DeleteDeadLetterCalls()[0].ID, ShouldEqual, testDeadLetterID)
+				So(mongoDBMock.UpdateDeadLetterAttemptCalls(), ShouldHaveLength, 0)
+
+				So(uploadedProducer.Channels().Output, ShouldHaveLength, 1)
+				uploaded := &event.ImageUploaded{}
+				So(schema.ImageUploadedEvent.Unmarshal(<-uploadedProducer.Channels().Output, uploaded), ShouldBeNil)
+				So(uploaded, ShouldResemble, &event.ImageUploaded{ImageID: testImageID1, Path: "path", Filename: "image.png"})
+			})
+
+			Convey("Calling 'replay dead letter' with CloudEvents encoding sends the event with the ID of the original event occurrence", func() {
+				mongoDBMock.GetDeadLetterFunc = func(ctx context.Context, id string) (*models.DeadLetter, error) {
+					deadLetter := dbDeadLetter(models.DeadLetterImageUploaded, `{"image_id":"imageImageID1","path":"path","filename":"image.png"}`)
+					deadLetter.EventID = testEventID
+					return deadLetter, nil
+				}
+				cfg.EventEncoding = string(event.EncodingCloudEventsStructured)
+				uploadedProducer := newBufferedKafkaProducer()
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, uploadedProducer, kafkaStubProducer, kafkaStubProducer)
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/admin/dead-letters/%s/replay", testDeadLetterID), http.NoBody)
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+
+				So(uploadedProducer.Channels().Output, ShouldHaveLength, 1)
+				ce, err := event.UnmarshalCloudEvent(<-uploadedProducer.Channels().Output)
+				So(err, ShouldBeNil)
+				So(ce.ID, ShouldEqual, testEventID)
+			})
+
+			Convey("Calling 'replay dead letter' when the kafka producer is not initialised results in 503 ServiceUnavailable response, and the failed attempt is recorded", func() {
+				uploadedProducer := newBufferedKafkaProducer()
+				uploadedProducer.IsInitialisedFunc = func() bool { return false }
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, uploadedProducer, kafkaStubProducer, kafkaStubProducer)
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/admin/dead-letters/%s/replay", testDeadLetterID), http.NoBody)
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusServiceUnavailable)
+				So(mongoDBMock.DeleteDeadLetterCalls(), ShouldHaveLength, 0)
+				So(mongoDBMock.UpdateDeadLetterAttemptCalls(), ShouldHaveLength, 1)
+				deadLetter := mongoDBMock.UpdateDeadLetterAttemptCalls()[0].DeadLetter
+				So(deadLetter.Attempts, ShouldEqual, 2)
+				So(deadLetter.Error, ShouldEqual, event.ErrProducerNotInitialised.Error())
+				So(*deadLetter.NextAttempt, ShouldEqual, deadLetter.LastAttempt.Add(2*cfg.DeadLetterMinBackoff))
+			})
+		})
+
+		Convey("And a dead letter with an unsupported event type in MongoDB", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetDeadLetterFunc: func(ctx context.Context, id string) (*models.DeadLetter, error) {
+					return dbDeadLetter("wrong", `{}`), nil
+				},
+				UpdateDeadLetterAttemptFunc: func(ctx context.Context, deadLetter *models.DeadLetter) error { return nil },
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("Calling 'replay dead letter' results in 500 InternalServerError response", func() {
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/admin/dead-letters/%s/replay", testDeadLetterID), http.NoBody)
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+				So(mongoDBMock.UpdateDeadLetterAttemptCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UpdateDeadLetterAttemptCalls()[0].DeadLetter.Error, ShouldEqual, apierrors.ErrDeadLetterInvalidEventType.Error())
+			})
+		})
+
+		Convey("And no dead letter in MongoDB", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetDeadLetterFunc: func(ctx context.Context, id string) (*models.DeadLetter, error) {
+					return nil, apierrors.ErrDeadLetterNotFound
+				},
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("Calling 'replay dead letter' results in 404 NotFound response", func() {
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/admin/dead-letters/%s/replay", testDeadLetterID), http.NoBody)
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestPublishImageDeadLetters(t *testing.T) {
+	Convey("Given a valid config, auth handler, and an image in 'imported' state in MongoDB", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		authHandlerMock := &mock.AuthHandlerMock{
+			RequireFunc: func(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+				return handler
+			},
+		}
+		mongoDBMock := &mock.MongoServerMock{
+			GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+				image := dbImage(models.StateImported)
+				image.Downloads = map[string]models.Download{
+					"original": {ID: "original"},
+				}
+				return image, nil
+			},
+			UpdateImageFunc:      func(ctx context.Context, id string, image *models.Image) (bool, error) { return true, nil },
+			AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
+			UnlockImageFunc:      func(ctx context.Context, id string) {},
+			CreateDeadLetterFunc: func(ctx context.Context, deadLetter *models.DeadLetter) error { return nil },
+		}
+
+		Convey("And a published kafka producer that is not initialised", func() {
+			publishedProducer := newBufferedKafkaProducer()
+			publishedProducer.IsInitialisedFunc = func() bool { return false }
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, publishedProducer, kafkaStubProducer)
+
+			Convey("Calling 'publish image' results in 204 NoContent response, with the image published event stored as a dead letter", func() {
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/publish", testImageID1), http.NoBody)
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				r = r.WithContext(context.WithValue(r.Context(), handlers.CollectionID.Context(), testCollectionID1))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+				So(mongoDBMock.UpdateImageCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.CreateDeadLetterCalls(), ShouldHaveLength, 1)
+				deadLetter := mongoDBMock.CreateDeadLetterCalls()[0].DeadLetter
+				So(deadLetter.EventType, ShouldEqual, models.DeadLetterImagePublished)
+				So(deadLetter.ImageID, ShouldEqual, testImageID1)
+				So(deadLetter.EventID, ShouldNotBeEmpty)
+				published := &event.ImagePublished{}
+				So(json.Unmarshal(deadLetter.Payload, published), ShouldBeNil)
+				So(published.ImageVariant, ShouldEqual, "original")
+			})
+
+			Convey("Calling 'publish image' when the dead letter cannot be stored results in 503 ServiceUnavailable response", func() {
+				mongoDBMock.CreateDeadLetterFunc = func(ctx context.Context, deadLetter *models.DeadLetter) error { return errMongoDB }
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/publish", testImageID1), http.NoBody)
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				r = r.WithContext(context.WithValue(r.Context(), handlers.CollectionID.Context(), testCollectionID1))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusServiceUnavailable)
+			})
+		})
+	})
+}
@@ -0,0 +1,372 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	dpauth "github.com/ONSdigital/dp-authorisation/auth"
+	"github.com/ONSdigital/dp-image-api/api/mock"
+	"github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/dp-image-api/config"
+	"github.com/ONSdigital/dp-image-api/event"
+	"github.com/ONSdigital/dp-image-api/models"
+	"github.com/ONSdigital/dp-image-api/schema"
+	dpreq "github.com/ONSdigital/dp-net/v3/request"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// imageStore is an in-memory store of images that applies the conditional download and image state updates as MongoDB does
+type imageStore struct {
+	mutex    sync.Mutex
+	images   map[string]*models.Image
+	versions []*models.Version
+}
+
+// newImageStoreMock returns a MongoDB mock backed by an in-memory store containing the provided images
+func newImageStoreMock(images ...*models.Image) (*mock.MongoServerMock, *imageStore) {
+	store := &imageStore{images: map[string]*models.Image{}}
+	for _, image := range images {
+		store.images[image.ID] = copyImage(image)
+	}
+	return &mock.MongoServerMock{
+		GetImageFunc:           store.getImage,
+		CreateDownloadFunc:     store.createDownload,
+		UpdateDownloadFunc:     store.updateDownload,
+		UpdateImageStateFunc:   store.updateImageState,
+		CreateImageVersionFunc: store.createImageVersion,
+	}, store
+}
+
+// copyImage returns a copy of the provided image, with its own downloads map
+func copyImage(image *models.Image) *models.Image {
+	imageCopy := *image
+	if image.Downloads != nil {
+		imageCopy.Downloads = make(map[string]models.Download, len(image.Downloads))
+		for variant, download := range image.Downloads {
+			imageCopy.Downloads[variant] = download
+		}
+	}
+	return &imageCopy
+}
+
+// get returns a copy of the stored image with the provided ID, or nil if it does not exist
+func (s *imageStore) get(id string) *models.Image {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if image, ok := s.images[id]; ok {
+		return copyImage(image)
+	}
+	return nil
+}
+
+func (s *imageStore) getImage(ctx context.Context, id string) (*models.Image, error) {
+	if image := s.get(id); image != nil {
+		return image, nil
+	}
+	return nil, apierrors.ErrImageNotFound
+}
+
+func (s *imageStore) createDownload(ctx context.Context, id, imageState string, download *models.Download) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	image, ok := s.images[id]
+	if !ok || image.State != imageState {
+		return false, nil
+	}
+	if _, found := image.Downloads[download.ID]; found {
+		return false, nil
+	}
+	if image.Downloads == nil {
+		image.Downloads = map[string]models.Download{}
+	}
+	image.Downloads[download.ID] = *download
+	image.State = models.StateImporting.String()
+	return true, nil
+}
+
+func (s *imageStore) updateDownload(ctx context.Context, id, imageState, variantState string, download *models.Download) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	image, ok := s.images[id]
+	if !ok || image.State != imageState {
+		return false, nil
+	}
+	if existing, found := image.Downloads[download.ID]; !found || existing.State != variantState {
+		return false, nil
+	}
+	image.Downloads[download.ID] = *download
+	return true, nil
+}
+
+func (s *imageStore) updateImageState(ctx context.Context, previousState string, expected *models.Image) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	image, ok := s.images[expected.ID]
+	if !ok || image.State != previousState || len(image.Downloads) != len(expected.Downloads) {
+		return false, nil
+	}
+	for variant, download := range expected.Downloads {
+		if existing, found := image.Downloads[variant]; !found || existing.State != download.State {
+			return false, nil
+		}
+	}
+	image.State = expected.State
+	if expected.Error != "" {
+		image.Error = expected.Error
+	}
+	return true, nil
+}
+
+// updateImage sets the state of the stored image, and the state, href and publish start time of the provided download variants,
+// as the publish image update does in MongoDB
+func (s *imageStore) updateImage(ctx context.Context, id string, update *models.Image) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	image, ok := s.images[id]
+	if !ok {
+		return false, apierrors.ErrImageNotFound
+	}
+	if update.State != "" {
+		image.State = update.State
+	}
+	for variant, download := range update.Downloads {
+		existing := image.Downloads[variant]
+		existing.State = download.State
+		existing.Href = download.Href
+		existing.PublishStarted = download.PublishStarted
+		image.Downloads[variant] = existing
+	}
+	return true, nil
+}
+
+func (s *imageStore) createImageVersion(ctx context.Context, version *models.Version) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.versions = append(s.versions, version)
+	return nil
+}
+
+// putDownload concurrently serves a request updating the provided variant to the provided state, and returns its response recorder
+func putDownload(wg *sync.WaitGroup, imageAPI http.Handler, variant string, state models.DownloadState, payloadFmt string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s/downloads/%s", testImageID2, variant), bytes.NewBufferString(
+		fmt.Sprintf(payloadFmt, variant, testDownloadType, state.String())))
+	r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		imageAPI.ServeHTTP(w, r)
+	}()
+	return w
+}
+
+func TestUpdateDownloadConcurrency(t *testing.T) {
+	Convey("Given an image API in publishing mode with a kafka producer for image state changed events", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		cfg.IsPublishing = true
+		authHandlerMock := &mock.AuthHandlerMock{
+			RequireFunc: func(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+				return handler
+			},
+		}
+
+		Convey("And an image in 'importing' state with two variants in 'importing' state", func() {
+			mongoDBMock, store := newImageStoreMock(dbFullImageWithDownloads(models.StateImporting,
+				dbDownloadWithID(testImageID2, testVariantOriginal, models.StateDownloadImporting),
+				dbDownloadWithID(testImageID2, testVariantAlternative, models.StateDownloadImporting)))
+			stateChangedProducer := newBufferedKafkaProducer()
+			imageAPI := GetAPIWithStateChangedProducer(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer, stateChangedProducer)
+
+			// each variant is read by both requests before either request updates it,
+			// so that neither request sees the other variant as imported when it first reads the image
+			var reads sync.WaitGroup
+			reads.Add(2)
+			mongoDBMock.GetImageFunc = func(ctx context.Context, id string) (*models.Image, error) {
+				image, err := store.getImage(ctx, id)
+				if image != nil && image.Downloads[testVariantOriginal].State == models.StateDownloadImporting.String() &&
+					image.Downloads[testVariantAlternative].State == models.StateDownloadImporting.String() {
+					reads.Done()
+					reads.Wait()
+				}
+				return image, err
+			}
+
+			Convey("When both variants finish importing simultaneously", func() {
+				wg := &sync.WaitGroup{}
+				w1 := putDownload(wg, imageAPI.Router, testVariantOriginal, models.StateDownloadImported, updateImageDownloadImportedPayloadFmt)
+				w2 := putDownload(wg, imageAPI.Router, testVariantAlternative, models.StateDownloadImported, updateImageDownloadImportedPayloadFmt)
+				wg.Wait()
+
+				Convey("Then both requests succeed without locking the image, and the image is moved to 'imported' state", func() {
+					So(w1.Code, ShouldEqual, http.StatusOK)
+					So(w2.Code, ShouldEqual, http.StatusOK)
+					image := store.get(testImageID2)
+					So(image.State, ShouldEqual, models.StateImported.String())
+					So(image.Downloads[testVariantOriginal].State, ShouldEqual, models.StateDownloadImported.String())
+					So(image.Downloads[testVariantAlternative].State, ShouldEqual, models.StateDownloadImported.String())
+					So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 0)
+				})
+
+				Convey("Then the image state change is sent once, along with the state change of each variant", func() {
+					events := []event.ImageStateChanged{}
+					for _, e := range readStateChangedEvents(stateChangedProducer) {
+						events = append(events, *e)
+					}
+					So(events, ShouldHaveLength, 3)
+					So(events, ShouldContain, event.ImageStateChanged{ImageID: testImageID2, CollectionID: testCollectionID1,
+						PreviousState: models.StateImporting.String(), State: models.StateImported.String()})
+					So(events, ShouldContain, event.ImageStateChanged{ImageID: testImageID2, CollectionID: testCollectionID1, ImageVariant: testVariantOriginal,
+						PreviousState: models.StateDownloadImporting.String(), State: models.StateDownloadImported.String()})
+					So(events, ShouldContain, event.ImageStateChanged{ImageID: testImageID2, CollectionID: testCollectionID1, ImageVariant: testVariantAlternative,
+						PreviousState: models.StateDownloadImporting.String(), State: models.StateDownloadImported.String()})
+				})
+			})
+		})
+
+		Convey("And an image in 'published' state with two variants in 'published' state", func() {
+			mongoDBMock, store := newImageStoreMock(dbFullImageWithDownloads(models.StatePublished,
+				dbDownloadWithID(testImageID2, testVariantOriginal, models.StateDownloadPublished),
+				dbDownloadWithID(testImageID2, testVariantAlternative, models.StateDownloadPublished)))
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("When both variants are completed simultaneously, many times over", func() {
+				for i := 0; i < 20; i++ {
+					store.images[testImageID2] = dbFullImageWithDownloads(models.StatePublished,
+						dbDownloadWithID(testImageID2, testVariantOriginal, models.StateDownloadPublished),
+						dbDownloadWithID(testImageID2, testVariantAlternative, models.StateDownloadPublished))
+					wg := &sync.WaitGroup{}
+					w1 := putDownload(wg, imageAPI.Router, testVariantOriginal, models.StateDownloadCompleted, updateImageDownloadCompletedPayloadFmt)
+					w2 := putDownload(wg, imageAPI.Router, testVariantAlternative, models.StateDownloadCompleted, updateImageDownloadCompletedPayloadFmt)
+					wg.Wait()
+
+					So(w1.Code, ShouldEqual, http.StatusOK)
+					So(w2.Code, ShouldEqual, http.StatusOK)
+					So(store.get(testImageID2).State, ShouldEqual, models.StateCompleted.String())
+				}
+
+				Convey("Then the image is always moved to 'completed' state, and a version is recorded", func() {
+					So(len(store.versions), ShouldBeGreaterThanOrEqualTo, 20)
+					So(store.versions[0].State, ShouldEqual, models.StateCompleted.String())
+				})
+			})
+		})
+
+		Convey("And an image whose variant keeps being modified by other requests", func() {
+			mongoDBMock, _ := newImageStoreMock(dbFullImageWithDownloads(models.StateImporting,
+				dbDownloadWithID(testImageID2, testVariantOriginal, models.StateDownloadImporting)))
+			mongoDBMock.UpdateDownloadFunc = func(ctx context.Context, id, imageState, variantState string, download *models.Download) (bool, error) {
+				return false, nil
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("When the variant is updated, then the update is retried and 409 Conflict is returned", func() {
+				wg := &sync.WaitGroup{}
+				w := putDownload(wg, imageAPI.Router, testVariantOriginal, models.StateDownloadImported, updateImageDownloadImportedPayloadFmt)
+				wg.Wait()
+				So(w.Code, ShouldEqual, http.StatusConflict)
+				So(mongoDBMock.UpdateDownloadCalls(), ShouldHaveLength, 3)
+				So(mongoDBMock.GetImageCalls(), ShouldHaveLength, 3)
+				So(mongoDBMock.UpdateImageStateCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("And an image which keeps being modified by other requests once its variant is updated", func() {
+			mongoDBMock, store := newImageStoreMock(dbFullImageWithDownloads(models.StateImporting,
+				dbDownloadWithID(testImageID2, testVariantOriginal, models.StateDownloadImporting)))
+			mongoDBMock.UpdateImageStateFunc = func(ctx context.Context, previousState string, image *models.Image) (bool, error) {
+				return false, nil
+			}
+			stateChangedProducer := newBufferedKafkaProducer()
+			imageAPI := GetAPIWithStateChangedProducer(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer, stateChangedProducer)
+
+			Convey("When the variant is updated", func() {
+				wg := &sync.WaitGroup{}
+				w := putDownload(wg, imageAPI.Router, testVariantOriginal, models.StateDownloadImported, updateImageDownloadImportedPayloadFmt)
+				wg.Wait()
+
+				Convey("Then the image state update is retried, and 409 Conflict is returned although the variant was updated", func() {
+					So(w.Code, ShouldEqual, http.StatusConflict)
+					So(mongoDBMock.UpdateImageStateCalls(), ShouldHaveLength, 3)
+					image := store.get(testImageID2)
+					So(image.Downloads[testVariantOriginal].State, ShouldEqual, models.StateDownloadImported.String())
+					So(image.State, ShouldEqual, models.StateImporting.String())
+					So(readStateChangedEvents(stateChangedProducer), ShouldResemble, []*event.ImageStateChanged{
+						{ImageID: testImageID2, CollectionID: testCollectionID1, ImageVariant: testVariantOriginal,
+							PreviousState: models.StateDownloadImporting.String(), State: models.StateDownloadImported.String()},
+					})
+				})
+			})
+		})
+	})
+}
+
+func TestVersionedFiles(t *testing.T) {
+	Convey("Given an image API in publishing mode, and revision 1 of an image in 'imported' state", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		cfg.IsPublishing = true
+		authHandlerMock := &mock.AuthHandlerMock{
+			RequireFunc: func(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+				return handler
+			},
+		}
+		mongoDBMock, store := newImageStoreMock(dbFullImageWithDownloads(models.StateImported,
+			dbDownloadWithID(testImageID2, testVariantOriginal, models.StateDownloadImported)))
+		mongoDBMock.UpdateImageFunc = store.updateImage
+		mongoDBMock.AcquireImageLockFunc = func(ctx context.Context, id string) (string, error) { return testLockID, nil }
+		mongoDBMock.UnlockImageFunc = func(ctx context.Context, lockID string) {}
+		publishedProducer := newBufferedKafkaProducer()
+		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, publishedProducer, kafkaStubProducer)
+
+		// publishAndComplete publishes the current revision of the image and completes its variant,
+		// returning the destination path of the published file
+		publishAndComplete := func() string {
+			r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/publish", testImageID2), http.NoBody)
+			r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+			w := httptest.NewRecorder()
+			imageAPI.Router.ServeHTTP(w, r)
+			So(w.Code, ShouldEqual, http.StatusNoContent)
+			So(publishedProducer.Channels().Output, ShouldHaveLength, 1)
+			published := &event.ImagePublished{}
+			So(schema.ImagePublishedEvent.Unmarshal(<-publishedProducer.Channels().Output, published), ShouldBeNil)
+
+			wg := &sync.WaitGroup{}
+			w = putDownload(wg, imageAPI.Router, testVariantOriginal, models.StateDownloadCompleted, updateImageDownloadCompletedPayloadFmt)
+			wg.Wait()
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(store.get(testImageID2).State, ShouldEqual, models.StateCompleted.String())
+			return published.DstPath
+		}
+
+		Convey("When revision 1 is published and completed, and then revision 2 is published and completed", func() {
+			dstPath1 := publishAndComplete()
+			So(store.versions, ShouldHaveLength, 1)
+			href1 := store.versions[0].Downloads[testVariantOriginal].Href
+
+			store.images[testImageID2] = dbFullImageWithDownloads(models.StateImported,
+				dbDownloadWithID(testImageID2, testVariantOriginal, models.StateDownloadImported))
+			store.images[testImageID2].Revision = 2
+			dstPath2 := publishAndComplete()
+
+			Convey("Then each revision is published under its own path, which its version href points to", func() {
+				So(dstPath1, ShouldEqual, "images/"+testImageID2+"/"+testVariantOriginal+"/1/"+testFilename)
+				So(dstPath2, ShouldEqual, "images/"+testImageID2+"/"+testVariantOriginal+"/2/"+testFilename)
+				So(href1, ShouldEqual, cfg.DownloadServiceURL+"/"+dstPath1)
+				So(store.versions, ShouldHaveLength, 2)
+				So(store.versions[1].Version, ShouldEqual, 2)
+				So(store.versions[1].Downloads[testVariantOriginal].Href, ShouldEqual, cfg.DownloadServiceURL+"/"+dstPath2)
+			})
+
+			Convey("Then the href of version 1 is unchanged", func() {
+				So(store.versions[0].Version, ShouldEqual, 1)
+				So(store.versions[0].Downloads[testVariantOriginal].Href, ShouldEqual, href1)
+			})
+		})
+	})
+}
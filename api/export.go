@@ -0,0 +1,226 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/dp-image-api/models"
+	dpurl "github.com/ONSdigital/dp-image-api/url"
+	dpreq "github.com/ONSdigital/dp-net/v3/request"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// ndjsonContentType is the content type of newline delimited json streams of image records
+const ndjsonContentType = "application/x-ndjson"
+
+// ImportPath is the path of the image import endpoint, whose request bodies can be larger than those of other endpoints
+const ImportPath = "/admin/import"
+
+// ExportHandler is a handler that streams every image, including its download variants and archived revisions,
+// as newline delimited json. Images are read from mongoDB one at a time, so that memory stays bounded.
+// Records that cannot be exported are reported in place, as export error lines.
+func (api *API) ExportHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	collectionID := req.URL.Query().Get("collection_id")
+	logdata := log.Data{
+		"request-id":    ctx.Value(dpreq.RequestIdKey),
+		"collection-id": collectionID,
+	}
+
+	builder, err := api.getLinksBuilder(req)
+	if err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+
+	// The response status is only written once the first record is available,
+	// so that failing to query mongoDB can still be reported with an error status
+	started := false
+	start := func() {
+		if !started {
+			w.Header().Set("Content-Type", ndjsonContentType)
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	line, failed := 0, 0
+	err = api.mongoDB.IterateImages(ctx, collectionID, func(image *models.Image, decodeErr error) error {
+		start()
+		line++
+		if decodeErr != nil {
+			failed++
+			log.Error(ctx, "failed to export image", decodeErr, logdata, log.Data{"image-id": image.ID, "line": line})
+			return encoder.Encode(&models.ExportErrorLine{
+				ExportError: &models.RecordError{Line: line, ImageID: image.ID, Error: decodeErr.Error()},
+			})
+		}
+		if builder != nil {
+			setImageLinks(builder, image)
+		}
+		return encoder.Encode(models.NewImageRecord(image))
+	})
+	logdata["count"] = line
+	logdata["failed"] = failed
+	if err != nil {
+		if !started {
+			handleError(ctx, w, err, logdata)
+			return
+		}
+		// The response status has already been sent, so the truncated export is reported in its last line
+		log.Error(ctx, "image export interrupted", err, logdata)
+		if encodeErr := encoder.Encode(&models.ExportErrorLine{ExportError: &models.RecordError{Line: line + 1, Error: err.Error()}}); encodeErr != nil {
+			log.Error(ctx, "failed to report interrupted image export", encodeErr, logdata)
+		}
+		return
+	}
+	start()
+	log.Info(ctx, "successfully exported images", logdata)
+}
+
+// ImportHandler is a handler that creates or updates images from newline delimited json image records, as written by the export handler.
+// Each record is validated and upserted independently, and the error of every record that could not be imported is reported.
+func (api *API) ImportHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	logdata := log.Data{
+		"request-id": ctx.Value(dpreq.RequestIdKey),
+	}
+	defer req.Body.Close()
+
+	builder, err := api.getLinksBuilder(req)
+	if err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+
+	result := &models.ImportResult{Errors: []models.RecordError{}}
+	reader := bufio.NewReader(req.Body)
+	for line := 1; ; line++ {
+		b, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			// records read before the error have already been imported
+			logdata["imported"] = result.Imported
+			handleError(ctx, w, bodyReadError(readErr), logdata)
+			return
+		}
+		if len(bytes.TrimSpace(b)) > 0 {
+			result.Count++
+			if id, err := api.importRecord(ctx, b, builder); err != nil {
+				log.Error(ctx, "failed to import image", err, logdata, log.Data{"image-id": id, "line": line})
+				result.Failed++
+				result.Errors = append(result.Errors, models.RecordError{Line: line, ImageID: id, Error: err.Error()})
+			} else {
+				result.Imported++
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+	}
+	logdata["count"] = result.Count
+	logdata["failed"] = result.Failed
+
+	if err := WriteJSONBody(result, w, http.StatusOK); err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+	log.Info(ctx, "successfully imported images", logdata)
+}
+
+// importRecord validates the provided json image record and upserts it under the image lock.
+// The ID of the image is returned whenever the record could be parsed, so that errors can be reported against it.
+func (api *API) importRecord(ctx context.Context, b []byte, builder *dpurl.Builder) (string, error) {
+	exportErr := &models.ExportErrorLine{}
+	if err := json.Unmarshal(b, exportErr); err != nil {
+		return "", apierrors.ErrUnableToParseJSON
+	}
+	if exportErr.ExportError != nil {
+		return exportErr.ExportError.ImageID, fmt.Errorf("%w: %s", apierrors.ErrImportRecordNotExported, exportErr.ExportError.Error)
+	}
+
+	record := &models.ImageRecord{}
+	if err := json.Unmarshal(b, record); err != nil {
+		return "", apierrors.ErrUnableToParseJSON
+	}
+	image := record.ToImage()
+	if image.ID == "" {
+		return "", apierrors.ErrImportRecordNoID
+	}
+	if err := image.Validate(); err != nil {
+		return image.ID, err
+	}
+	for variant, download := range image.Downloads {
+		if err := download.Validate(); err != nil {
+			return image.ID, fmt.Errorf("variant %s: %w", variant, err)
+		}
+	}
+	if builder != nil {
+		setImageLinks(builder, image)
+	}
+	// hrefs of public files are built for the environment that the image is imported to
+	setPublicDownloadHrefs(api.urlBuilder, image.ID, image.CurrentRevision(), image.Filename, image.Downloads)
+	for i := range image.Revisions {
+		setPublicDownloadHrefs(api.urlBuilder, image.ID, image.Revisions[i].Revision, image.Revisions[i].Filename, image.Revisions[i].Downloads)
+	}
+
+	lockID, err := api.lockImage(ctx, image.ID)
+	if err != nil {
+		return image.ID, err
+	}
+	defer api.unlockImage(ctx, lockID)
+
+	return image.ID, api.mongoDB.UpsertImage(ctx, image.ID, image)
+}
+
+// getLinksBuilder returns a url builder for the 'links_url' query parameter, which is the base url of the image API
+// of the environment that the exported or imported images are intended for, or nil if links do not need to be rewritten
+func (api *API) getLinksBuilder(req *http.Request) (*dpurl.Builder, error) {
+	linksURL := req.URL.Query().Get("links_url")
+	if linksURL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(linksURL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, apierrors.ErrInvalidLinksURL
+	}
+	return api.urlBuilder.WithAPIURL(strings.TrimSuffix(linksURL, "/")), nil
+}
+
+// setImageLinks sets the links of the provided image, and of the download variants of the image and its archived revisions,
+// with the provided url builder
+func setImageLinks(builder *dpurl.Builder, image *models.Image) {
+	image.Links = buildImageLinks(builder, image.ID)
+	setDownloadLinks(builder, image.ID, image.Downloads)
+	for i := range image.Revisions {
+		setDownloadLinks(builder, image.ID, image.Revisions[i].Downloads)
+	}
+}
+
+// setDownloadLinks sets the links of the provided download variants of an image with the provided url builder
+func setDownloadLinks(builder *dpurl.Builder, id string, downloads map[string]models.Download) {
+	for variant, download := range downloads {
+		download.Links = buildDownloadLinks(builder, id, variant)
+		downloads[variant] = download
+	}
+}
+
+// setPublicDownloadHrefs rebuilds the hrefs of the provided public download variants of an image with the provided url builder,
+// so that they point to the download service or CDN that the builder is configured with
+func setPublicDownloadHrefs(builder *dpurl.Builder, id string, version int, filename string, downloads map[string]models.Download) {
+	for variant, download := range downloads {
+		if download.Href == "" || !download.IsPublic() {
+			continue
+		}
+		download.Href = builder.BuildPublicDownloadURL(id, variant, version, filename)
+		downloads[variant] = download
+	}
+}
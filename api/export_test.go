@@ -0,0 +1,247 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	dpauth "github.com/ONSdigital/dp-authorisation/auth"
+	"github.com/ONSdigital/dp-image-api/api/mock"
+	"github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/dp-image-api/config"
+	"github.com/ONSdigital/dp-image-api/models"
+	dpreq "github.com/ONSdigital/dp-net/v3/request"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testLinksURL = "https://api.example.org/v1"
+
+var errDecode = errors.New("cannot decode image document")
+
+// newAdminRequest returns a request to the provided admin endpoint, authenticated as a user
+func newAdminRequest(method, target, body string) *http.Request {
+	r := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+	return r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+}
+
+// iterateImagesFunc returns an IterateImages implementation that calls fn with the provided images,
+// with a decoding error for images without state, and then returns the provided error
+func iterateImagesFunc(images []models.Image, iterErr error) func(ctx context.Context, collectionID string, fn func(image *models.Image, decodeErr error) error) error {
+	return func(ctx context.Context, collectionID string, fn func(image *models.Image, decodeErr error) error) error {
+		for i := range images {
+			var decodeErr error
+			if images[i].State == "" {
+				decodeErr = errDecode
+			}
+			if err := fn(&images[i], decodeErr); err != nil {
+				return err
+			}
+		}
+		return iterErr
+	}
+}
+
+func TestExportHandler(t *testing.T) {
+	Convey("Given a valid config and auth handler", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		authHandlerMock := &mock.AuthHandlerMock{
+			RequireFunc: func(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+				return handler
+			},
+		}
+
+		Convey("And a MongoDB with two images, and a document that cannot be decoded in between", func() {
+			image1 := dbFullImageWithDownloads(models.StateCompleted, dbDownloadWithID(testImageID2, testVariantOriginal, models.StateDownloadCompleted))
+			mongoDBMock := &mock.MongoServerMock{
+				IterateImagesFunc: iterateImagesFunc([]models.Image{*image1, {ID: "undecodable"}, *dbFullImage(models.StateUploaded)}, nil),
+			}
+
+			Convey("Calling 'export' with a links url results in 200 OK response, with a record per line including downloads and rewritten links", func() {
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, newAdminRequest(http.MethodGet, "http://localhost:24700/admin/export?collection_id=1234&links_url="+testLinksURL+"/", ""))
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "application/x-ndjson")
+				So(mongoDBMock.IterateImagesCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.IterateImagesCalls()[0].CollectionID, ShouldEqual, testCollectionID1)
+
+				lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+				So(lines, ShouldHaveLength, 3)
+
+				record := &models.ImageRecord{}
+				So(json.Unmarshal([]byte(lines[0]), record), ShouldBeNil)
+				So(record.ID, ShouldEqual, testImageID2)
+				So(record.Links, ShouldResemble, &models.ImageLinks{
+					Self:      testLinksURL + "/images/" + testImageID2,
+					Downloads: testLinksURL + "/images/" + testImageID2 + "/downloads",
+				})
+				So(record.Downloads, ShouldHaveLength, 1)
+				So(record.Downloads[testVariantOriginal].Links, ShouldResemble, &models.DownloadLinks{
+					Self:  testLinksURL + "/images/" + testImageID2 + "/downloads/" + testVariantOriginal,
+					Image: testLinksURL + "/images/" + testImageID2,
+				})
+
+				exportErr := &models.ExportErrorLine{}
+				So(json.Unmarshal([]byte(lines[1]), exportErr), ShouldBeNil)
+				So(exportErr.ExportError, ShouldResemble, &models.RecordError{Line: 2, ImageID: "undecodable", Error: errDecode.Error()})
+
+				record = &models.ImageRecord{}
+				So(json.Unmarshal([]byte(lines[2]), record), ShouldBeNil)
+				So(record.State, ShouldEqual, models.StateUploaded.String())
+			})
+		})
+
+		Convey("Calling 'export' when MongoDB fails to query the images results in 500 InternalServerError response", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				IterateImagesFunc: iterateImagesFunc(nil, errMongoDB),
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+			w := httptest.NewRecorder()
+			imageAPI.Router.ServeHTTP(w, newAdminRequest(http.MethodGet, "http://localhost:24700/admin/export", ""))
+			So(w.Code, ShouldEqual, http.StatusInternalServerError)
+		})
+
+		Convey("Calling 'export' when MongoDB fails after the first image results in 200 OK response, with the error reported in the last line", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				IterateImagesFunc: iterateImagesFunc([]models.Image{*dbFullImage(models.StateUploaded)}, errMongoDB),
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+			w := httptest.NewRecorder()
+			imageAPI.Router.ServeHTTP(w, newAdminRequest(http.MethodGet, "http://localhost:24700/admin/export", ""))
+			So(w.Code, ShouldEqual, http.StatusOK)
+			lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+			So(lines, ShouldHaveLength, 2)
+			exportErr := &models.ExportErrorLine{}
+			So(json.Unmarshal([]byte(lines[1]), exportErr), ShouldBeNil)
+			So(exportErr.ExportError, ShouldResemble, &models.RecordError{Line: 2, Error: errMongoDB.Error()})
+		})
+
+		Convey("Calling 'export' with an invalid links url results in 400 BadRequest response", func() {
+			mongoDBMock := &mock.MongoServerMock{}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+			w := httptest.NewRecorder()
+			imageAPI.Router.ServeHTTP(w, newAdminRequest(http.MethodGet, "http://localhost:24700/admin/export?links_url=api.example.org", ""))
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+			So(mongoDBMock.IterateImagesCalls(), ShouldHaveLength, 0)
+		})
+	})
+}
+
+func TestImportHandler(t *testing.T) {
+	Convey("Given a valid config and auth handler, and a MongoDB where images can be upserted", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		authHandlerMock := &mock.AuthHandlerMock{
+			RequireFunc: func(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+				return handler
+			},
+		}
+		mongoDBMock := &mock.MongoServerMock{
+			AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
+			UnlockImageFunc:      func(ctx context.Context, lockID string) {},
+			UpsertImageFunc: func(ctx context.Context, id string, image *models.Image) error {
+				if id == testImageID1 {
+					return errMongoDB
+				}
+				return nil
+			},
+		}
+
+		Convey("Calling 'import' with a links url results in 200 OK response, with the error of every record that could not be imported", func() {
+			body := strings.Join([]string{
+				`{"id":"imageImageID2","state":"completed","links":{"self":"http://old/images/imageImageID2"},"downloads":{"original":{"state":"completed"}}}`,
+				``,
+				`{"id":"imageImageID1","state":"completed"}`,
+				`{"id":"invalidState","state":"wrong"}`,
+				`{"id":"invalidVariant","state":"completed","downloads":{"original":{"state":"wrong"}}}`,
+				`{"state":"completed"}`,
+				`{"id":`,
+				`{"export_error":{"line":3,"image_id":"undecodable","error":"cannot decode image document"}}`,
+			}, "\n")
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+			w := httptest.NewRecorder()
+			imageAPI.Router.ServeHTTP(w, newAdminRequest(http.MethodPost, "http://localhost:24700/admin/import?links_url="+testLinksURL, body))
+			So(w.Code, ShouldEqual, http.StatusOK)
+
+			result := &models.ImportResult{}
+			So(json.Unmarshal(w.Body.Bytes(), result), ShouldBeNil)
+			So(result, ShouldResemble, &models.ImportResult{
+				Count:    7,
+				Imported: 1,
+				Failed:   6,
+				Errors: []models.RecordError{
+					{Line: 3, ImageID: testImageID1, Error: errMongoDB.Error()},
+					{Line: 4, ImageID: "invalidState", Error: apierrors.ErrImageInvalidState.Error()},
+					{Line: 5, ImageID: "invalidVariant", Error: "variant original: " + apierrors.ErrImageDownloadInvalidState.Error()},
+					{Line: 6, Error: apierrors.ErrImportRecordNoID.Error()},
+					{Line: 7, Error: apierrors.ErrUnableToParseJSON.Error()},
+					{Line: 8, ImageID: "undecodable", Error: apierrors.ErrImportRecordNotExported.Error() + ": cannot decode image document"},
+				},
+			})
+
+			So(mongoDBMock.UpsertImageCalls(), ShouldHaveLength, 2)
+			So(mongoDBMock.UpsertImageCalls()[0].ID, ShouldEqual, testImageID2)
+			So(mongoDBMock.UpsertImageCalls()[0].Image, ShouldResemble, &models.Image{
+				ID:    testImageID2,
+				State: models.StateCompleted.String(),
+				Links: &models.ImageLinks{
+					Self:      testLinksURL + "/images/" + testImageID2,
+					Downloads: testLinksURL + "/images/" + testImageID2 + "/downloads",
+				},
+				Downloads: map[string]models.Download{
+					testVariantOriginal: {
+						State: models.StateDownloadCompleted.String(),
+						Links: &models.DownloadLinks{
+							Self:  testLinksURL + "/images/" + testImageID2 + "/downloads/" + testVariantOriginal,
+							Image: testLinksURL + "/images/" + testImageID2,
+						},
+					},
+				},
+			})
+			So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 2)
+			So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 2)
+		})
+
+		Convey("Calling 'import' without a links url keeps the links of the records", func() {
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+			w := httptest.NewRecorder()
+			imageAPI.Router.ServeHTTP(w, newAdminRequest(http.MethodPost, "http://localhost:24700/admin/import",
+				`{"id":"imageImageID2","state":"completed","links":{"self":"http://old/images/imageImageID2"}}`))
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(mongoDBMock.UpsertImageCalls(), ShouldHaveLength, 1)
+			So(mongoDBMock.UpsertImageCalls()[0].Image.Links, ShouldResemble, &models.ImageLinks{Self: "http://old/images/imageImageID2"})
+		})
+
+		Convey("Calling 'import' rebuilds the hrefs of public download variants for this environment, and keeps the other hrefs", func() {
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+			w := httptest.NewRecorder()
+			imageAPI.Router.ServeHTTP(w, newAdminRequest(http.MethodPost, "http://localhost:24700/admin/import",
+				`{"id":"imageImageID2","state":"completed","revision":2,"filename":"new.png",`+
+					`"downloads":{"original":{"state":"completed","href":"https://cdn.old/images/imageImageID2/original/new.png"},`+
+					`"png_100":{"state":"pending","href":"https://download.old/images/imageImageID2/png_100/new.png"}},`+
+					`"revisions":[{"revision":1,"state":"completed","filename":"old.png",`+
+					`"downloads":{"original":{"state":"completed","href":"https://cdn.old/images/imageImageID2/original/old.png"}}}]}`))
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(mongoDBMock.UpsertImageCalls(), ShouldHaveLength, 1)
+			image := mongoDBMock.UpsertImageCalls()[0].Image
+			So(image.Downloads[testVariantOriginal].Href, ShouldEqual, cfg.DownloadServiceURL+"/images/"+testImageID2+"/original/2/new.png")
+			So(image.Downloads["png_100"].Href, ShouldEqual, "https://download.old/images/imageImageID2/png_100/new.png")
+			So(image.Revisions, ShouldHaveLength, 1)
+			So(image.Revisions[0].Downloads[testVariantOriginal].Href, ShouldEqual, cfg.DownloadServiceURL+"/images/"+testImageID2+"/original/1/old.png")
+		})
+
+		Convey("Calling 'import' with an invalid links url results in 400 BadRequest response", func() {
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+			w := httptest.NewRecorder()
+			imageAPI.Router.ServeHTTP(w, newAdminRequest(http.MethodPost, "http://localhost:24700/admin/import?links_url=ftp://api.example.org", `{}`))
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+			So(mongoDBMock.UpsertImageCalls(), ShouldHaveLength, 0)
+		})
+	})
+}
@@ -1,16 +1,20 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"path"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/ONSdigital/dp-image-api/apierrors"
 	"github.com/ONSdigital/dp-image-api/event"
 	"github.com/ONSdigital/dp-image-api/models"
-	"github.com/ONSdigital/dp-net/v2/links"
+	dpurl "github.com/ONSdigital/dp-image-api/url"
 	"github.com/ONSdigital/dp-net/v3/handlers"
 	dpreq "github.com/ONSdigital/dp-net/v3/request"
 	"github.com/ONSdigital/log.go/v2/log"
@@ -18,6 +22,26 @@ import (
 	"github.com/gorilla/mux"
 )
 
+// ImageWithdrawnEvent returns an ImageWithdrawn event for the provided public path of an image download variant
+var ImageWithdrawnEvent = func(filepath, imageID, variant string) *event.ImageWithdrawn {
+	return &event.ImageWithdrawn{
+		Path:         filepath,
+		ImageID:      imageID,
+		ImageVariant: variant,
+	}
+}
+
+// ImageStateChangedEvent returns an ImageStateChanged event for the provided state transition of an image, or of one of its download variants
+var ImageStateChangedEvent = func(imageID, collectionID, variant, previousState, state string) *event.ImageStateChanged {
+	return &event.ImageStateChanged{
+		ImageID:       imageID,
+		CollectionID:  collectionID,
+		ImageVariant:  variant,
+		PreviousState: previousState,
+		State:         state,
+	}
+}
+
 // NewID returns a new UUID
 var NewID = func() string {
 	return uuid.New().String()
@@ -32,13 +56,16 @@ var ImageUploadedEvent = func(imageID, uploadPath, filename string) *event.Image
 	}
 }
 
-// ImagePublishedEvent returns an ImagePublished event for the provided path
-var ImagePublishedEvent = func(filepath, filename, imageID, variant string) *event.ImagePublished {
+// ImagePublishedEvent returns an ImagePublished event for the provided source and destination paths,
+// including the variant checksum and content type
+var ImagePublishedEvent = func(srcPath, dstPath, imageID, variant, sha256, contentType string) *event.ImagePublished {
 	return &event.ImagePublished{
-		SrcPath:      filepath,
-		DstPath:      path.Join(filepath, filename),
+		SrcPath:      srcPath,
+		DstPath:      dstPath,
 		ImageID:      imageID,
 		ImageVariant: variant,
+		Sha256:       sha256,
+		ContentType:  contentType,
 	}
 }
 
@@ -62,6 +89,20 @@ func (api *API) GetImagesHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// the list changes whenever images are added, so it is revalidated rather than cached for long.
+	// Its date includes the images that are not public, so that it changes when an image stops being public.
+	modified := lastModified(items)
+
+	// in web mode, only serve the live revision of published images, without their private fields
+	if !api.isPublishing {
+		items = publicImages(items)
+	}
+
+	if api.writeCacheHeaders(w, req, cacheControlRevalidate, modified) {
+		log.Info(ctx, "images not modified", logdata)
+		return
+	}
+
 	images := models.Images{
 		Items:      items,
 		Count:      len(items),
@@ -69,15 +110,10 @@ func (api *API) GetImagesHandler(w http.ResponseWriter, req *http.Request) {
 		Limit:      len(items),
 	}
 
-	imageLinkBuilder := links.FromHeadersOrDefault(&req.Header, api.apiUrl)
-
-	if api.enableURLRewriting {
-		for image := range images.Items {
-			err := rewriteImageLinks(ctx, *imageLinkBuilder, &images.Items[image])
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
+	if rewriter := api.newLinksRewriter(req); rewriter != nil {
+		if images.Items, err = rewriter.rewriteImages(images.Items); err != nil {
+			handleError(ctx, w, err, logdata)
+			return
 		}
 	}
 
@@ -142,6 +178,7 @@ func (api *API) CreateImageHandler(w http.ResponseWriter, req *http.Request) {
 		handleError(ctx, w, err, logdata)
 		return
 	}
+	api.sendStateChangedEvents(ctx, generateImageStateChangedEvents(id, nil, &newImage), logdata)
 
 	if err := WriteJSONBody(newImage, w, http.StatusCreated); err != nil {
 		handleError(ctx, w, err, logdata)
@@ -169,12 +206,22 @@ func (api *API) GetImageHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	imageLinkBuilder := links.FromHeadersOrDefault(&req.Header, api.apiUrl)
+	// in web mode, only serve the live revision of published images, without their private fields
+	if !api.isPublishing {
+		if image, err = publicImage(image); err != nil {
+			handleError(ctx, w, err, logdata)
+			return
+		}
+	}
+
+	if api.writeCacheHeaders(w, req, api.cacheControl(image.State), image.LastUpdated) {
+		log.Info(ctx, "image not modified", logdata)
+		return
+	}
 
-	if api.enableURLRewriting {
-		err := rewriteImageLinks(ctx, *imageLinkBuilder, image)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+	if rewriter := api.newLinksRewriter(req); rewriter != nil {
+		if image, err = rewriter.rewriteImage(image); err != nil {
+			handleError(ctx, w, err, logdata)
 			return
 		}
 	}
@@ -234,7 +281,7 @@ func (api *API) doUpdateImage(w http.ResponseWriter, req *http.Request, id strin
 	image.ID = id
 
 	// Acquire lock for image ID, and defer unlocking
-	lockID, err := api.mongoDB.AcquireImageLock(ctx, id)
+	lockID, err := api.lockImage(ctx, id)
 	if err != nil {
 		handleError(ctx, w, err, logdata)
 		return nil
@@ -256,17 +303,20 @@ func (api *API) doUpdateImage(w http.ResponseWriter, req *http.Request, id strin
 		return nil
 	}
 
-	// Copy existing Links to newly updated image
+	// Copy existing Links, scheduled publish and revision to newly updated image
 	image.Links = existingImage.Links
+	image.ScheduledPublish = existingImage.ScheduledPublish
+	image.Revision = existingImage.Revision
 
 	// If the new state is 'uploaded', generate and send the kafka event to trigger import
 	if image.State == models.StateUploaded.String() {
-		uploadS3Path := path.Base(image.Upload.Path)
 		log.Info(ctx, "sending image uploaded message", logdata)
-		uploadedEvent := ImageUploadedEvent(id, uploadS3Path, image.Filename)
-		if uploadErr := api.uploadProducer.ImageUploaded(uploadedEvent); uploadErr != nil {
-			handleError(ctx, w, uploadErr, logdata)
-			return nil
+		uploadedEvent := generateImageUploadedEvent(id, image)
+		if uploadErr := api.uploadProducer.ImageUploaded(ctx, uploadedEvent); uploadErr != nil {
+			if uploadErr = api.deadLetter(ctx, models.DeadLetterImageUploaded, id, uploadedEvent, uploadErr, logdata); uploadErr != nil {
+				handleError(ctx, w, uploadErr, logdata)
+				return nil
+			}
 		}
 	}
 
@@ -276,6 +326,7 @@ func (api *API) doUpdateImage(w http.ResponseWriter, req *http.Request, id strin
 		handleError(ctx, w, err, logdata)
 		return nil
 	}
+	api.sendStateChangedEvents(ctx, generateImageStateChangedEvents(id, existingImage, image), logdata)
 	return image
 }
 
@@ -298,22 +349,34 @@ func (api *API) GetDownloadsHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	imageLinkBuilder := links.FromHeadersOrDefault(&req.Header, api.apiUrl)
-
-	if api.enableURLRewriting {
-		for download := range image.Downloads {
-			err := rewriteDownloadLinks(ctx, *imageLinkBuilder, image.Downloads[download])
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
+	// in web mode, only serve the live revision of published images, without their private fields
+	if !api.isPublishing {
+		if image, err = publicImage(image); err != nil {
+			handleError(ctx, w, err, logdata)
+			return
 		}
 	}
 
+	if api.writeCacheHeaders(w, req, api.cacheControl(image.State), image.LastUpdated) {
+		log.Info(ctx, "downloads not modified", logdata)
+		return
+	}
+
 	downloadsList := make([]models.Download, 0)
 	for i := range image.Downloads {
 		downloadsList = append(downloadsList, image.Downloads[i])
 	}
+
+	if rewriter := api.newLinksRewriter(req); rewriter != nil {
+		for i := range downloadsList {
+			rewritten, err := rewriter.rewriteDownload(&downloadsList[i])
+			if err != nil {
+				handleError(ctx, w, err, logdata)
+				return
+			}
+			downloadsList[i] = *rewritten
+		}
+	}
 	downloads := models.Downloads{
 		Items:      downloadsList,
 		Count:      len(downloadsList),
@@ -364,47 +427,48 @@ func (api *API) CreateDownloadHandler(w http.ResponseWriter, req *http.Request)
 		return
 	}
 
-	// Acquire lock for image ID, and defer unlocking
-	lockID, err := api.mongoDB.AcquireImageLock(ctx, id)
-	if err != nil {
-		handleError(ctx, w, err, logdata)
-		return
-	}
-	defer api.unlockImage(ctx, lockID)
-
-	// get image from mongoDB by id
-	image, err := api.mongoDB.GetImage(req.Context(), id)
-	if err != nil {
-		handleError(ctx, w, err, logdata)
-		return
-	}
-
-	// Validate new download against parent image
-	if validationErr := newDownload.ValidateForImage(image); validationErr != nil {
-		handleError(ctx, w, validationErr, logdata)
-		return
-	}
+	// Add the new download to the image with a conditional update, without locking the image, so that other variants
+	// can be created and updated concurrently. If the image is changed by another request after being read, it is read,
+	// validated and updated again.
+	var image *models.Image
+	for attempt := 1; ; attempt++ {
+		var err error
+		image, err = api.mongoDB.GetImage(ctx, id)
+		if err != nil {
+			handleError(ctx, w, err, logdata)
+			return
+		}
 
-	// check for existing variant
-	_, found := image.Downloads[variant]
-	if found {
-		handleError(ctx, w, apierrors.ErrVariantAlreadyExists, logdata)
-		return
-	}
+		// Validate new download against parent image
+		if validationErr := newDownload.ValidateForImage(image); validationErr != nil {
+			handleError(ctx, w, validationErr, logdata)
+			return
+		}
 
-	// Add new download to existing image and update image state
-	if image.Downloads == nil {
-		image.Downloads = map[string]models.Download{}
-	}
-	image.Downloads[variant] = *newDownload
-	image.State = models.StateImporting.String()
+		// check for existing variant
+		if _, found := image.Downloads[variant]; found {
+			handleError(ctx, w, apierrors.ErrVariantAlreadyExists, logdata)
+			return
+		}
 
-	// Update image in mongo DB
-	err = api.mongoDB.UpsertImage(ctx, id, image)
-	if err != nil {
-		handleError(ctx, w, err, logdata)
-		return
+		created, err := api.mongoDB.CreateDownload(ctx, id, image.State, newDownload)
+		if err != nil {
+			handleError(ctx, w, err, logdata)
+			return
+		}
+		if created {
+			break
+		}
+		if attempt == maxImageUpdateAttempts {
+			handleError(ctx, w, apierrors.ErrImageUpdateConflict, logdata)
+			return
+		}
+		log.Info(ctx, "image was modified by another request, retrying download variant creation", logdata)
 	}
+	api.sendStateChangedEvents(ctx, []*event.ImageStateChanged{
+		ImageStateChangedEvent(id, image.CollectionID, "", image.State, models.StateImporting.String()),
+		ImageStateChangedEvent(id, image.CollectionID, variant, "", newDownload.State),
+	}, logdata)
 
 	if err := WriteJSONBody(newDownload, w, http.StatusCreated); err != nil {
 		handleError(ctx, w, err, logdata)
@@ -414,23 +478,54 @@ func (api *API) CreateDownloadHandler(w http.ResponseWriter, req *http.Request)
 }
 
 func (api *API) createLinksForImage(id string) *models.ImageLinks {
-	self := api.urlBuilder.BuildImageURL(id)
-	downloads := api.urlBuilder.BuildImageDownloadsURL(id)
+	return buildImageLinks(api.urlBuilder, id)
+}
+
+func (api *API) createLinksForDownload(id, variant string) *models.DownloadLinks {
+	return buildDownloadLinks(api.urlBuilder, id, variant)
+}
+
+// buildImageLinks returns the links of the image with the provided ID, built with the provided url builder
+func buildImageLinks(builder *dpurl.Builder, id string) *models.ImageLinks {
+	self := builder.BuildImageURL(id)
+	downloads := builder.BuildImageDownloadsURL(id)
 	return &models.ImageLinks{
 		Self:      self,
 		Downloads: downloads,
 	}
 }
 
-func (api *API) createLinksForDownload(id, variant string) *models.DownloadLinks {
-	self := api.urlBuilder.BuildImageDownloadURL(id, variant)
-	image := api.urlBuilder.BuildImageURL(id)
+// buildDownloadLinks returns the links of the provided download variant of an image, built with the provided url builder
+func buildDownloadLinks(builder *dpurl.Builder, id, variant string) *models.DownloadLinks {
+	self := builder.BuildImageDownloadURL(id, variant)
+	image := builder.BuildImageURL(id)
 	return &models.DownloadLinks{
 		Self:  self,
 		Image: image,
 	}
 }
 
+// createVersion returns a new version of the provided image, with version-specific links and hrefs,
+// so that the files of a version are still served after a new revision of the image is published
+func (api *API) createVersion(image *models.Image) *models.Version {
+	version := image.NewVersion(time.Now().UTC())
+	version.Links = &models.VersionLinks{
+		Self:  api.urlBuilder.BuildImageVersionURL(image.ID, version.Version),
+		Image: api.urlBuilder.BuildImageURL(image.ID),
+	}
+	for variant, download := range version.Downloads {
+		download.Links = &models.DownloadLinks{
+			Self:  api.urlBuilder.BuildImageVersionDownloadURL(image.ID, version.Version, variant),
+			Image: version.Links.Self,
+		}
+		if download.IsPublic() {
+			download.Href = api.urlBuilder.BuildPublicDownloadURL(image.ID, variant, version.Version, image.Filename)
+		}
+		version.Downloads[variant] = download
+	}
+	return version
+}
+
 // GetDownloadHandler is a handler that returns an individual download variant
 func (api *API) GetDownloadHandler(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
@@ -452,12 +547,10 @@ func (api *API) GetDownloadHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	imageLinkBuilder := links.FromHeadersOrDefault(&req.Header, api.apiUrl)
-
-	if api.enableURLRewriting {
-		err := rewriteDownloadLinks(ctx, *imageLinkBuilder, image.Downloads[variant])
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+	// in web mode, only serve the live revision of published images, without their private fields
+	if !api.isPublishing {
+		if image, err = publicImage(image); err != nil {
+			handleError(ctx, w, err, logdata)
 			return
 		}
 	}
@@ -468,6 +561,20 @@ func (api *API) GetDownloadHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if rewriter := api.newLinksRewriter(req); rewriter != nil {
+		rewritten, err := rewriter.rewriteDownload(&download)
+		if err != nil {
+			handleError(ctx, w, err, logdata)
+			return
+		}
+		download = *rewritten
+	}
+
+	if api.writeCacheHeaders(w, req, api.cacheControl(image.State), image.LastUpdated) {
+		log.Info(ctx, "download not modified", logdata)
+		return
+	}
+
 	if err := WriteJSONBody(download, w, http.StatusOK); err != nil {
 		handleError(ctx, w, err, logdata)
 		return
@@ -509,72 +616,460 @@ func (api *API) UpdateDownloadHandler(w http.ResponseWriter, req *http.Request)
 		return
 	}
 
-	// Acquire lock for image ID, and defer unlocking
-	lockID, err := api.mongoDB.AcquireImageLock(ctx, id)
+	// Update the variant with a conditional update, without locking the image, so that the variants of an image
+	// can be updated concurrently. If the image state or the variant are changed by another request after being read,
+	// they are read, validated and updated again.
+	var image *models.Image
+	var existing *models.Download
+	for attempt := 1; ; attempt++ {
+		var err error
+		image, existing, err = api.getDownloadForUpdate(ctx, id, download, logdata)
+		if err != nil {
+			handleError(ctx, w, err, logdata)
+			return
+		}
+
+		// Copy Links from existing
+		download.Links = existing.Links
+
+		updated, err := api.mongoDB.UpdateDownload(ctx, id, image.State, existing.State, download)
+		if err != nil {
+			handleError(ctx, w, err, logdata)
+			return
+		}
+		if updated {
+			break
+		}
+		if attempt == maxImageUpdateAttempts {
+			handleError(ctx, w, apierrors.ErrImageUpdateConflict, logdata)
+			return
+		}
+		log.Info(ctx, "image was modified by another request, retrying download variant update", logdata)
+	}
+
+	// Update image state based on the change to the download, and to any other variant updated concurrently,
+	// recording an immutable version of the image if it is completed
+	previousState, state, err := api.updateImageStateFromDownloads(ctx, id, variant, logdata)
+	variantChanged := ImageStateChangedEvent(id, image.CollectionID, variant, existing.State, download.State)
 	if err != nil {
+		// the variant has been updated even if the image state could not be
+		if err == apierrors.ErrImageUpdateConflict {
+			api.sendStateChangedEvents(ctx, []*event.ImageStateChanged{variantChanged}, logdata)
+		}
 		handleError(ctx, w, err, logdata)
 		return
 	}
-	defer api.unlockImage(ctx, lockID)
+	api.sendStateChangedEvents(ctx, []*event.ImageStateChanged{
+		ImageStateChangedEvent(id, image.CollectionID, "", previousState, state),
+		variantChanged,
+	}, logdata)
 
-	// get image from mongoDB by id
-	image, err := api.mongoDB.GetImage(req.Context(), id)
-	if err != nil {
+	if err := WriteJSONBody(download, w, http.StatusOK); err != nil {
 		handleError(ctx, w, err, logdata)
 		return
 	}
+	log.Info(ctx, "successfully updated download variant", logdata)
+}
+
+// getDownloadForUpdate reads the image and the existing download variant that the provided download updates,
+// and validates the update against the state of both
+func (api *API) getDownloadForUpdate(ctx context.Context, id string, download *models.Download, logdata log.Data) (*models.Image, *models.Download, error) {
+	// get image from mongoDB by id
+	image, err := api.mongoDB.GetImage(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// check for existing variant
-	existing, found := image.Downloads[variant]
+	existing, found := image.Downloads[download.ID]
 	if !found {
-		handleError(ctx, w, apierrors.ErrVariantNotFound, logdata)
-		return
+		return nil, nil, apierrors.ErrVariantNotFound
 	}
 
 	// Validate download variant against parent image state
 	if validationErr := download.ValidateForImage(image); validationErr != nil {
 		logdata["current_image_state"] = image.State
 		logdata["target_download_state"] = download.State
-		handleError(ctx, w, validationErr, logdata)
-		return
+		return nil, nil, validationErr
 	}
 
 	// Validate download variant against existing variant state
 	if transitionErr := download.ValidateTransitionFrom(&existing); transitionErr != nil {
 		logdata["current_image_state"] = existing.State
 		logdata["target_download_state"] = download.State
-		handleError(ctx, w, transitionErr, logdata)
+		return nil, nil, transitionErr
+	}
+
+	return image, &existing, nil
+}
+
+// recordVersionIfCompleted records an immutable version of the provided image if it has just been completed.
+// A version is only stored once, so recording it again has no effect.
+func (api *API) recordVersionIfCompleted(ctx context.Context, previousState string, image *models.Image, logdata log.Data) error {
+	if previousState == models.StateCompleted.String() || image.State != models.StateCompleted.String() {
+		return nil
+	}
+	version := api.createVersion(image)
+	logdata["image-version"] = version.Version
+	return api.mongoDB.CreateImageVersion(ctx, version)
+}
+
+// updateImageStateFromDownloads recomputes the state of the image from the state of all its download variants,
+// after the provided variant has been updated, and returns the previous and the new state of the image.
+// The state is set with a conditional update, which is retried if the image is changed by another request after being read,
+// and ErrImageUpdateConflict is returned if the image kept being changed.
+// If the image is completed, an immutable version is recorded from the updated variants before its state is set, so that
+// versions are only recorded for variant updates that succeeded, and a completed image always has a version.
+func (api *API) updateImageStateFromDownloads(ctx context.Context, id, variant string, logdata log.Data) (previousState, state string, err error) {
+	for attempt := 1; attempt <= maxImageUpdateAttempts; attempt++ {
+		image, err := api.mongoDB.GetImage(ctx, id)
+		if err != nil {
+			return "", "", err
+		}
+
+		previousState, previousError := image.State, image.Error
+		image.State = image.UpdatedState()
+		if image.Downloads[variant].State == models.StateDownloadFailed.String() {
+			image.Error = fmt.Sprintf("error in variant '%s'", variant)
+		}
+		if image.State == previousState && image.Error == previousError {
+			return previousState, image.State, nil
+		}
+
+		// Record an immutable version of the image once its publishing is completed
+		if err := api.recordVersionIfCompleted(ctx, previousState, image, logdata); err != nil {
+			return "", "", err
+		}
+
+		updated, err := api.mongoDB.UpdateImageState(ctx, previousState, image)
+		if err != nil {
+			return "", "", err
+		}
+		if updated {
+			return previousState, image.State, nil
+		}
+		log.Info(ctx, "image was modified by another request, retrying image state update", logdata)
+	}
+
+	// The variant has been updated, but the image state could not be. The requests that kept modifying the image
+	// recompute its state once they have modified it, and the client is told that its update conflicted with them.
+	log.Warn(ctx, "image state not updated after too many concurrent modifications", logdata)
+	return "", "", apierrors.ErrImageUpdateConflict
+}
+
+// PublishImageHandler is a handler that triggers the publishing of an image.
+// If a future publish_at time is provided in the body, the publish is scheduled instead of being triggered immediately.
+func (api *API) PublishImageHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	vars := mux.Vars(req)
+	id := vars["id"]
+	hColID := ctx.Value(handlers.CollectionID.Context())
+	logdata := log.Data{
+		handlers.CollectionID.Header(): hColID,
+		"request-id":                   ctx.Value(dpreq.RequestIdKey),
+		"image-id":                     id,
+	}
+
+	// Unmarshal the optional scheduled publish from body
+	scheduledPublish, err := readScheduledPublish(ctx, req.Body)
+	if err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+
+	if scheduledPublish != nil && scheduledPublish.PublishAt.After(time.Now()) {
+		logdata["publish_at"] = scheduledPublish.PublishAt
+		if err := api.schedulePublish(ctx, id, scheduledPublish, logdata); err != nil {
+			handleError(ctx, w, err, logdata)
+			return
+		}
+		if err := WriteJSONBody(scheduledPublish, w, http.StatusAccepted); err != nil {
+			handleError(ctx, w, err, logdata)
+			return
+		}
+		log.Info(ctx, "successfully scheduled image publish", logdata)
+		return
+	}
+
+	if err := api.PublishImage(ctx, id); err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+
+	// Publish handler does not return any content on success
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CancelScheduledPublishHandler is a handler that cancels the scheduled publish of an image
+func (api *API) CancelScheduledPublishHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	vars := mux.Vars(req)
+	id := vars["id"]
+	hColID := ctx.Value(handlers.CollectionID.Context())
+	logdata := log.Data{
+		handlers.CollectionID.Header(): hColID,
+		"request-id":                   ctx.Value(dpreq.RequestIdKey),
+		"image-id":                     id,
+	}
+
+	// Acquire lock for image ID, and defer unlocking
+	lockID, err := api.lockImage(ctx, id)
+	if err != nil {
+		handleError(ctx, w, err, logdata)
 		return
 	}
+	defer api.unlockImage(ctx, lockID)
 
-	// Copy Links from existing
-	download.Links = existing.Links
+	// get image from mongoDB by id
+	existingImage, err := api.mongoDB.GetImage(ctx, id)
+	if err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
 
-	// Update new download to existing image
-	image.Downloads[variant] = *download
+	if existingImage.ScheduledPublish == nil {
+		handleError(ctx, w, apierrors.ErrImageNoScheduledPublish, logdata)
+		return
+	}
 
-	// Update image state based on change to download
-	image.State = image.UpdatedState()
-	if download.State == models.StateDownloadFailed.String() {
-		image.Error = fmt.Sprintf("error in variant '%s'", variant)
+	if err := api.mongoDB.UnsetScheduledPublish(ctx, id); err != nil {
+		handleError(ctx, w, err, logdata)
+		return
 	}
 
-	// Update image in mongo DB
-	err = api.mongoDB.UpsertImage(ctx, id, image)
+	// Cancel handler does not return any content on success
+	w.WriteHeader(http.StatusNoContent)
+	log.Info(ctx, "successfully cancelled scheduled image publish", logdata)
+}
+
+// CreateRevisionHandler is a handler that starts a new revision of a completed image, keeping its ID and public URLs.
+// The current revision is archived, and keeps being served in web mode until the new revision is published.
+func (api *API) CreateRevisionHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	vars := mux.Vars(req)
+	id := vars["id"]
+	hColID := ctx.Value(handlers.CollectionID.Context())
+	logdata := log.Data{
+		handlers.CollectionID.Header(): hColID,
+		"request-id":                   ctx.Value(dpreq.RequestIdKey),
+		"image-id":                     id,
+	}
+
+	// Acquire lock for image ID, and defer unlocking
+	lockID, err := api.lockImage(ctx, id)
+	if err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+	defer api.unlockImage(ctx, lockID)
+
+	// get image from mongoDB by id
+	existingImage, err := api.mongoDB.GetImage(ctx, id)
 	if err != nil {
 		handleError(ctx, w, err, logdata)
 		return
 	}
 
+	// validate that the image is completed, so that there is a published revision to replace
+	if !existingImage.NewRevisionAllowed() {
+		logdata["current_image_state"] = existingImage.State
+		handleError(ctx, w, apierrors.ErrImageNotCompleted, logdata)
+		return
+	}
+
+	// archive the current revision and reset the image for the new revision
+	archived := existingImage.ArchiveRevision(time.Now().UTC())
+	logdata["archived_revision"] = archived.Revision
+	if err := api.mongoDB.StartImageRevision(ctx, id, archived); err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+
+	newRevision := *existingImage
+	newRevision.State = models.StateCreated.String()
+	newRevision.Revision = archived.Revision + 1
+	newRevision.Error = ""
+	newRevision.Upload = nil
+	newRevision.Downloads = nil
+	newRevision.ScheduledPublish = nil
+	api.sendStateChangedEvents(ctx, generateImageStateChangedEvents(id, existingImage, &newRevision), logdata)
+
+	if err := WriteJSONBody(newRevision, w, http.StatusCreated); err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+	log.Info(ctx, "successfully started new image revision", logdata)
+}
+
+// GetRevisionsHandler is a handler that returns all the previous revisions of an image
+func (api *API) GetRevisionsHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	vars := mux.Vars(req)
+	id := vars["id"]
+	hColID := ctx.Value(handlers.CollectionID.Context())
+	logdata := log.Data{
+		handlers.CollectionID.Header(): hColID,
+		"request-id":                   ctx.Value(dpreq.RequestIdKey),
+		"image-id":                     id,
+	}
+
+	// get image from mongoDB by id
+	image, err := api.mongoDB.GetImage(ctx, id)
+	if err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+
+	// in web mode, only serve the revisions of published images, without their private fields
+	if !api.isPublishing {
+		if image, err = publicImage(image); err != nil {
+			handleError(ctx, w, err, logdata)
+			return
+		}
+	}
+
+	items := image.Revisions
+	if items == nil {
+		items = []models.Revision{}
+	}
+	if rewriter := api.newLinksRewriter(req); rewriter != nil {
+		if items, err = rewriter.rewriteRevisions(items); err != nil {
+			handleError(ctx, w, err, logdata)
+			return
+		}
+	}
+	revisions := models.Revisions{
+		Items:      items,
+		Count:      len(items),
+		TotalCount: len(items),
+		Limit:      len(items),
+	}
+
+	if err := WriteJSONBody(revisions, w, http.StatusOK); err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+	log.Info(ctx, "Successfully retrieved revisions", logdata)
+}
+
+// GetVersionHandler is a handler that returns an immutable published version of an image
+func (api *API) GetVersionHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	vars := mux.Vars(req)
+	id := vars["id"]
+	hColID := ctx.Value(handlers.CollectionID.Context())
+	logdata := log.Data{
+		handlers.CollectionID.Header(): hColID,
+		"request-id":                   ctx.Value(dpreq.RequestIdKey),
+		"image-id":                     id,
+		"image-version":                vars["version"],
+	}
+
+	version, err := api.getVersion(ctx, id, vars["version"])
+	if err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+
+	// versions are immutable, so they can always be cached
+	if api.writeCacheHeaders(w, req, api.cacheControlPublic(), version.CreatedAt) {
+		log.Info(ctx, "image version not modified", logdata)
+		return
+	}
+
+	if rewriter := api.newLinksRewriter(req); rewriter != nil {
+		if version, err = rewriter.rewriteVersion(version); err != nil {
+			handleError(ctx, w, err, logdata)
+			return
+		}
+	}
+
+	if err := WriteJSONBody(version, w, http.StatusOK); err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+	log.Info(ctx, "Successfully retrieved image version", logdata)
+}
+
+// GetVersionDownloadHandler is a handler that returns an individual download variant of an image version
+func (api *API) GetVersionDownloadHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	vars := mux.Vars(req)
+	id := vars["id"]
+	variant := vars["variant"]
+	hColID := ctx.Value(handlers.CollectionID.Context())
+	logdata := log.Data{
+		handlers.CollectionID.Header(): hColID,
+		"request-id":                   ctx.Value(dpreq.RequestIdKey),
+		"image-id":                     id,
+		"image-version":                vars["version"],
+		"download-variant":             variant,
+	}
+
+	version, err := api.getVersion(ctx, id, vars["version"])
+	if err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+
+	download, found := version.Downloads[variant]
+	if !found {
+		handleError(ctx, w, apierrors.ErrVariantNotFound, logdata)
+		return
+	}
+
+	if api.writeCacheHeaders(w, req, api.cacheControlPublic(), version.CreatedAt) {
+		log.Info(ctx, "image version download not modified", logdata)
+		return
+	}
+
+	if rewriter := api.newLinksRewriter(req); rewriter != nil {
+		rewritten, err := rewriter.rewriteDownload(&download)
+		if err != nil {
+			handleError(ctx, w, err, logdata)
+			return
+		}
+		download = *rewritten
+	}
+
 	if err := WriteJSONBody(download, w, http.StatusOK); err != nil {
 		handleError(ctx, w, err, logdata)
 		return
 	}
-	log.Info(ctx, "successfully updated download variant", logdata)
+	log.Info(ctx, "Successfully retrieved image version download", logdata)
 }
 
-// PublishImageHandler is a handler that triggers the publishing of an image
-func (api *API) PublishImageHandler(w http.ResponseWriter, req *http.Request) {
+// getVersion validates the provided version number and gets the corresponding image version from mongoDB.
+// In web mode, versions are only served if their image is served, so that the versions of withdrawn or deleted images are not found.
+func (api *API) getVersion(ctx context.Context, id, versionStr string) (*models.Version, error) {
+	version, err := strconv.Atoi(versionStr)
+	if err != nil || version < 1 {
+		return nil, apierrors.ErrImageVersionInvalid
+	}
+	if !api.isPublishing {
+		image, err := api.mongoDB.GetImage(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := publicImage(image); err != nil {
+			return nil, err
+		}
+	}
+	imageVersion, err := api.mongoDB.GetImageVersion(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+
+	// in web mode, serve versions without their private fields
+	if !api.isPublishing {
+		return imageVersion.PublicView(), nil
+	}
+	return imageVersion, nil
+}
+
+// WithdrawImageHandler is a handler that withdraws a published image, clearing the download hrefs
+// and sending an 'image withdrawn' kafka message for each download variant, so that public copies can be removed.
+func (api *API) WithdrawImageHandler(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 	vars := mux.Vars(req)
 	id := vars["id"]
@@ -585,10 +1080,21 @@ func (api *API) PublishImageHandler(w http.ResponseWriter, req *http.Request) {
 		"image-id":                     id,
 	}
 
-	imageUpdate := &models.Image{State: models.StatePublished.String()}
+	// Unmarshal withdrawal from body and validate it
+	withdrawal := &models.Withdrawal{}
+	if err := ReadJSONBody(ctx, req.Body, withdrawal); err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+	if err := withdrawal.Validate(); err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+	withdrawnAt := time.Now().UTC()
+	withdrawal.WithdrawnAt = &withdrawnAt
 
 	// Acquire lock for image ID, and defer unlocking
-	lockID, err := api.mongoDB.AcquireImageLock(ctx, id)
+	lockID, err := api.lockImage(ctx, id)
 	if err != nil {
 		handleError(ctx, w, err, logdata)
 		return
@@ -596,13 +1102,30 @@ func (api *API) PublishImageHandler(w http.ResponseWriter, req *http.Request) {
 	defer api.unlockImage(ctx, lockID)
 
 	// get image from mongoDB by id
-	existingImage, err := api.mongoDB.GetImage(req.Context(), id)
+	existingImage, err := api.mongoDB.GetImage(ctx, id)
 	if err != nil {
 		handleError(ctx, w, err, logdata)
 		return
 	}
 
-	// validate that the publish transition state is allowed
+	// a withdrawal that is retried after its events could not be sent re-sends them, keeping the original withdrawal
+	if existingImage.State == models.StateWithdrawn.String() {
+		log.Info(ctx, "image already withdrawn, re-sending image withdrawn messages", logdata)
+		if err := api.withdrawFiles(ctx, id, existingImage, logdata); err != nil {
+			handleError(ctx, w, err, logdata)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		log.Info(ctx, "successfully re-sent image withdrawn messages", logdata)
+		return
+	}
+
+	// validate that the withdrawn transition state is allowed
+	imageUpdate := &models.Image{
+		State:      models.StateWithdrawn.String(),
+		Withdrawal: withdrawal,
+		Downloads:  map[string]models.Download{},
+	}
 	if !existingImage.StateTransitionAllowed(imageUpdate.State) {
 		logdata["current_image_state"] = existingImage.State
 		logdata["target_image_state"] = imageUpdate.State
@@ -610,6 +1133,132 @@ func (api *API) PublishImageHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// update image variants, whose hrefs will be cleared
+	for variant := range existingImage.Downloads {
+		imageUpdate.Downloads[variant] = models.Download{
+			State: models.StateDownloadWithdrawn.String(),
+		}
+	}
+
+	// Update image in mongo DB
+	if err := api.mongoDB.WithdrawImage(ctx, id, imageUpdate); err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+	api.sendStateChangedEvents(ctx, generateImageStateChangedEvents(id, existingImage, imageUpdate), logdata)
+
+	// Withdraw the versions of the image, and send 'image withdrawn' kafka messages for the files of all its versions
+	if err := api.withdrawFiles(ctx, id, existingImage, logdata); err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+
+	// Withdraw handler does not return any content on success
+	w.WriteHeader(http.StatusNoContent)
+	log.Info(ctx, "successfully withdrawn image", logdata)
+}
+
+// withdrawFiles withdraws every version of the provided image, clearing their download hrefs, and sends an 'image withdrawn'
+// kafka message for each file of the current revision and of the versions of the image, so that all its public copies can be removed.
+// Versions are withdrawn again when a withdrawal is retried, as it may have failed before they were.
+func (api *API) withdrawFiles(ctx context.Context, id string, image *models.Image, logdata log.Data) error {
+	versions, err := api.mongoDB.WithdrawImageVersions(ctx, id)
+	if err != nil {
+		return err
+	}
+	log.Info(ctx, "sending image withdrawn messages", logdata)
+	return api.sendImageWithdrawnEvents(ctx, id, generateImageWithdrawnEvents(api.urlBuilder, image, versions), logdata)
+}
+
+// readScheduledPublish reads the optional scheduled publish from the provided body, returning nil if the body is empty
+func readScheduledPublish(ctx context.Context, body io.ReadCloser) (*models.ScheduledPublish, error) {
+	payload, err := io.ReadAll(body)
+	if err != nil {
+		return nil, bodyReadError(err)
+	}
+	if len(bytes.TrimSpace(payload)) == 0 {
+		return nil, nil
+	}
+
+	scheduledPublish := &models.ScheduledPublish{}
+	if err := ReadJSONBody(ctx, io.NopCloser(bytes.NewReader(payload)), scheduledPublish); err != nil {
+		return nil, err
+	}
+	if scheduledPublish.PublishAt == nil {
+		return nil, nil
+	}
+	return scheduledPublish, nil
+}
+
+// schedulePublish stores the provided scheduled publish for an image that is ready to be published
+func (api *API) schedulePublish(ctx context.Context, id string, scheduledPublish *models.ScheduledPublish, logdata log.Data) error {
+	// Acquire lock for image ID, and defer unlocking
+	lockID, err := api.lockImage(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer api.unlockImage(ctx, lockID)
+
+	// get image from mongoDB by id
+	existingImage, err := api.mongoDB.GetImage(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// validate that the publish transition state is allowed
+	if !existingImage.StateTransitionAllowed(models.StatePublished.String()) {
+		logdata["current_image_state"] = existingImage.State
+		logdata["target_image_state"] = models.StatePublished.String()
+		return apierrors.ErrImageStateTransitionNotAllowed
+	}
+
+	_, err = api.mongoDB.UpdateImage(ctx, id, &models.Image{ScheduledPublish: scheduledPublish})
+	return err
+}
+
+// PublishImage triggers the publishing of an image, updating its state and download variants in mongoDB
+// and sending an 'image published' kafka message for each download variant.
+// Any scheduled publish for the image is removed once the image has been published.
+func (api *API) PublishImage(ctx context.Context, id string) error {
+	logdata := log.Data{"image-id": id}
+	imageUpdate := &models.Image{State: models.StatePublished.String()}
+
+	// Acquire lock for image ID, and defer unlocking
+	lockID, err := api.lockImage(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer api.unlockImage(ctx, lockID)
+
+	// get image from mongoDB by id
+	existingImage, err := api.mongoDB.GetImage(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// a publish that is retried after its events could not be sent re-sends them for the variants that are still being published
+	if existingImage.State == models.StatePublished.String() {
+		log.Info(ctx, "image already published, re-sending image published messages", logdata)
+		if err := api.unsetScheduledPublish(ctx, id, existingImage); err != nil {
+			return err
+		}
+		events := []*event.ImagePublished{}
+		for _, e := range generateImagePublishEvents(api.urlBuilder, existingImage) {
+			if existingImage.Downloads[e.ImageVariant].State == models.StateDownloadPublished.String() {
+				events = append(events, e)
+			}
+		}
+		return api.sendImagePublishedEvents(ctx, id, events, logdata)
+	}
+
+	// validate that the publish transition state is allowed
+	if !existingImage.StateTransitionAllowed(imageUpdate.State) {
+		logdata["current_image_state"] = existingImage.State
+		logdata["target_image_state"] = imageUpdate.State
+		log.Warn(ctx, "image publish transition not allowed", logdata)
+		return apierrors.ErrImageStateTransitionNotAllowed
+	}
+
 	startTime := time.Now().UTC()
 
 	// update image variants
@@ -618,7 +1267,7 @@ func (api *API) PublishImageHandler(w http.ResponseWriter, req *http.Request) {
 		imageUpdate.Downloads[variant] = models.Download{
 			ID:             variant,
 			State:          models.StateDownloadPublished.String(),
-			Href:           fmt.Sprintf("%s/images/%s/%s/%s", api.downloadServiceURL, id, variant, existingImage.Filename),
+			Href:           api.urlBuilder.BuildPublicDownloadURL(id, variant, existingImage.CurrentRevision(), existingImage.Filename),
 			PublishStarted: &startTime,
 		}
 	}
@@ -626,80 +1275,146 @@ func (api *API) PublishImageHandler(w http.ResponseWriter, req *http.Request) {
 	// Update image in mongo DB
 	_, err = api.mongoDB.UpdateImage(ctx, id, imageUpdate)
 	if err != nil {
-		handleError(ctx, w, err, logdata)
-		return
+		return err
 	}
+	api.sendStateChangedEvents(ctx, generateImageStateChangedEvents(id, existingImage, imageUpdate), logdata)
 
-	// Generate 'image published' events for all download variants
-	events := generateImagePublishEvents(existingImage)
+	// Remove the scheduled publish, if any, now that the image is published
+	if err := api.unsetScheduledPublish(ctx, id, existingImage); err != nil {
+		return err
+	}
 
 	// Send 'image published' kafka messages corresponding to all the download variants
 	log.Info(ctx, "sending image published messages", logdata)
+	return api.sendImagePublishedEvents(ctx, id, generateImagePublishEvents(api.urlBuilder, existingImage), logdata)
+}
+
+// unsetScheduledPublish removes the scheduled publish of the provided image, if it has any
+func (api *API) unsetScheduledPublish(ctx context.Context, id string, image *models.Image) error {
+	if image.ScheduledPublish == nil {
+		return nil
+	}
+	return api.mongoDB.UnsetScheduledPublish(ctx, id)
+}
+
+// sendImagePublishedEvents sends the provided 'image published' kafka messages, storing the ones that cannot be delivered as dead letters.
+// The image state is updated before the events are sent, so a failure can be recovered by publishing the image again.
+func (api *API) sendImagePublishedEvents(ctx context.Context, id string, events []*event.ImagePublished, logdata log.Data) error {
 	for _, e := range events {
-		if err := api.publishedProducer.ImagePublished(e); err != nil {
-			handleError(ctx, w, err, logdata)
-			return
+		if err := api.publishedProducer.ImagePublished(ctx, e); err != nil {
+			if err = api.deadLetter(ctx, models.DeadLetterImagePublished, id, e, err, logdata); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
+}
 
-	// Publish handler does not return any content on success
-	w.WriteHeader(http.StatusNoContent)
+// sendImageWithdrawnEvents sends the provided 'image withdrawn' kafka messages, storing the ones that cannot be delivered as dead letters.
+// The image state is updated before the events are sent, so a failure can be recovered by withdrawing the image again.
+func (api *API) sendImageWithdrawnEvents(ctx context.Context, id string, events []*event.ImageWithdrawn, logdata log.Data) error {
+	for _, e := range events {
+		if err := api.withdrawnProducer.ImageWithdrawn(ctx, e); err != nil {
+			if err = api.deadLetter(ctx, models.DeadLetterImageWithdrawn, id, e, err, logdata); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// generateImageUploadedEvent creates the image uploaded event of the provided image, with the base name of its upload path,
+// which is empty if the image has no upload
+func generateImageUploadedEvent(id string, image *models.Image) *event.ImageUploaded {
+	uploadPath := ""
+	if image.Upload != nil && image.Upload.Path != "" {
+		uploadPath = path.Base(image.Upload.Path)
+	}
+	return ImageUploadedEvent(id, uploadPath, image.Filename)
 }
 
-// generateImagePublishEvents creates a kafka 'image-published' event for each download variant for the provided image.
-func generateImagePublishEvents(image *models.Image) (events []*event.ImagePublished) {
+// generateImagePublishEvents creates a kafka 'image-published' event for each download variant for the provided image,
+// publishing the variant files under the path built by the provided url builder for the current revision of the image.
+func generateImagePublishEvents(builder *dpurl.Builder, image *models.Image) (events []*event.ImagePublished) {
 	for i := range image.Downloads {
 		variant := image.Downloads[i]
 		srcPath := path.Join("images", image.ID, variant.ID)
-		events = append(events, ImagePublishedEvent(srcPath, image.Filename, image.ID, variant.ID))
+		dstPath := builder.BuildFilePath(image.ID, variant.ID, image.CurrentRevision(), image.Filename)
+		events = append(events, ImagePublishedEvent(srcPath, dstPath, image.ID, variant.ID, variant.Sha256, variant.ContentType))
 	}
 	return events
 }
 
-// unlockImage unlocks the provided image lockID
-func (api *API) unlockImage(ctx context.Context, lockID string) {
-	api.mongoDB.UnlockImage(ctx, lockID)
+// generateImageWithdrawnEvents creates a kafka 'image-withdrawn' event for each download variant of the current revision
+// of the provided image, and of the provided versions of the image, whose files were published under the paths built
+// by the provided url builder. A single event is created for each path.
+func generateImageWithdrawnEvents(builder *dpurl.Builder, image *models.Image, versions []models.Version) (events []*event.ImageWithdrawn) {
+	seen := map[string]bool{}
+	add := func(version int, filename string, downloads map[string]models.Download) {
+		for variant := range downloads {
+			publicPath := builder.BuildFilePath(image.ID, variant, version, filename)
+			if !seen[publicPath] {
+				seen[publicPath] = true
+				events = append(events, ImageWithdrawnEvent(publicPath, image.ID, variant))
+			}
+		}
+	}
+	add(image.CurrentRevision(), image.Filename, image.Downloads)
+	for i := range versions {
+		add(versions[i].Version, versions[i].Filename, versions[i].Downloads)
+	}
+	return events
 }
 
-// rewriteImageLinks rewrites the self and download links of a given image
-func rewriteImageLinks(ctx context.Context, builder links.Builder, image *models.Image) error {
-	if image.Links == nil || image.Links.Self == "" || image.Links.Downloads == "" {
-		log.Warn(ctx, "image link missing, skipping", log.Data{"image_id": image.ID})
-		return nil
+// generateImageStateChangedEvents creates a kafka 'image-state-changed' event for each state transition between the previous
+// and updated image, including the state transitions of the download variants present in the updated image.
+// A nil previous image means that the image has just been created.
+func generateImageStateChangedEvents(id string, previous, updated *models.Image) (events []*event.ImageStateChanged) {
+	previousState := ""
+	previousDownloads := map[string]models.Download{}
+	collectionID := updated.CollectionID
+	if previous != nil {
+		previousState = previous.State
+		previousDownloads = previous.Downloads
+		if collectionID == "" {
+			collectionID = previous.CollectionID
+		}
 	}
 
-	var err error
+	events = append(events, ImageStateChangedEvent(id, collectionID, "", previousState, updated.State))
 
-	image.Links.Self, err = builder.BuildLink(image.Links.Self)
-	if err != nil {
-		log.Error(ctx, "could not build self link", err, log.Data{"link": image.Links.Self})
-		return err
+	variants := make([]string, 0, len(updated.Downloads))
+	for variant := range updated.Downloads {
+		variants = append(variants, variant)
 	}
-
-	image.Links.Downloads, err = builder.BuildLink(image.Links.Downloads)
-	if err != nil {
-		log.Error(ctx, "could not build download link", err, log.Data{"link": image.Links.Downloads})
-		return err
+	sort.Strings(variants)
+	for _, variant := range variants {
+		events = append(events, ImageStateChangedEvent(id, collectionID, variant, previousDownloads[variant].State, updated.Downloads[variant].State))
 	}
-
-	return nil
+	return events
 }
 
-// rewriteDownloadLinks rewrites the self and image links of a given image download
-func rewriteDownloadLinks(ctx context.Context, builder links.Builder, download models.Download) error {
-	var err error
-
-	download.Links.Self, err = builder.BuildLink(download.Links.Self)
-	if err != nil {
-		log.Error(ctx, "could not build self link", err, log.Data{"link": download.Links.Self})
-		return err
+// sendStateChangedEvents sends the provided 'image-state-changed' events, skipping the ones that do not change the state.
+// The state changes have already been stored when this is called, so any failure is logged and not returned.
+func (api *API) sendStateChangedEvents(ctx context.Context, events []*event.ImageStateChanged, logdata log.Data) {
+	for _, e := range events {
+		if e.PreviousState == e.State {
+			continue
+		}
+		if err := api.stateProducer.ImageStateChanged(ctx, e); err != nil {
+			log.Error(ctx, "failed to send image state changed event", err, logdata, log.Data{"event": e})
+		}
 	}
+}
 
-	download.Links.Image, err = builder.BuildLink(download.Links.Image)
-	if err != nil {
-		log.Error(ctx, "could not build image link", err, log.Data{"link": download.Links.Image})
-		return err
-	}
+// lockImage locks the provided image, waiting for at most the image lock timeout if the image is locked by another request
+func (api *API) lockImage(ctx context.Context, id string) (lockID string, err error) {
+	lockCtx, cancel := context.WithTimeout(ctx, api.imageLockTimeout)
+	defer cancel()
+	return api.mongoDB.AcquireImageLock(lockCtx, id)
+}
 
-	return nil
+// unlockImage unlocks the provided image lockID
+func (api *API) unlockImage(ctx context.Context, lockID string) {
+	api.mongoDB.UnlockImage(ctx, lockID)
 }
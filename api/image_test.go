@@ -52,6 +52,9 @@ const (
 	contentTypeKey         = "Content-Type"
 	contentTypeJSON        = "application/json; charset=utf-8"
 	downloadServiceURL     = "http://download-web.ons.example"
+	testSha256             = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	testContentType        = "image/png"
+	testDownloadHref       = downloadServiceURL + "/images/" + testImageID1 + "/original/1/some-image-name"
 )
 
 var (
@@ -63,6 +66,7 @@ var (
 	testImportCompleted  = time.Date(2020, time.April, 26, 8, 7, 32, 0, time.UTC)
 	testPublishStarted   = time.Date(2020, time.April, 26, 9, 51, 3, 0, time.UTC)
 	testPublishCompleted = time.Date(2020, time.April, 26, 10, 1, 28, 0, time.UTC)
+	testFuturePublishAt  = time.Date(2099, time.April, 26, 9, 30, 0, 0, time.UTC)
 	expectedProto        = "https"
 	expectedHost         = "api.somehost"
 	expectedPathPrefix   = "v1"
@@ -129,6 +133,17 @@ var updateImageDownloadImportedPayloadFmt = `{
     "import_completed" : "2020-04-26T08:05:52Z"
 }`
 
+// Update Image Download Payload with checksum and content type.
+var updateImageDownloadImportedWithChecksumPayloadFmt = `{
+	"id": "%s",
+	"type": "%s",
+	"state": "%s",
+	"sha256": "%s",
+	"content_type": "%s",
+    "import_started" : "2020-04-26T08:05:52Z",
+    "import_completed" : "2020-04-26T08:05:52Z"
+}`
+
 // Update Image Download Payload without any extra field.
 var updateImageDownloadCompletedPayloadFmt = `{
 	"id": "%s",
@@ -140,6 +155,16 @@ var updateImageDownloadCompletedPayloadFmt = `{
     "publish_completed" : "2020-04-26T10:01:28Z"
 }`
 
+// Withdrawal payload
+var withdrawalPayload = `{
+	"reason": "published in error"
+}`
+
+// Scheduled publish payload
+var scheduledPublishPayloadFmt = `{
+	"publish_at": "%s"
+}`
+
 // Image Download Payload with all possible fields.
 var (
 	imageDownloadPayloadFmt = `{
@@ -221,6 +246,22 @@ func dbImageWithID(state models.State, id string) *models.Image {
 	}
 }
 
+func dbImageWithRevision(state models.State) *models.Image {
+	image := dbImage(state)
+	image.Revision = 2
+	image.Revisions = []models.Revision{
+		{
+			Revision: 1,
+			State:    models.StateCompleted.String(),
+			Filename: image.Filename,
+			Downloads: map[string]models.Download{
+				testVariantOriginal: {ID: testVariantOriginal, State: models.StateDownloadCompleted.String(), Href: testDownloadHref},
+			},
+		},
+	}
+	return image
+}
+
 func dbDownload(variantState models.DownloadState) models.Download {
 	return dbDownloadWithID("", testVariantOriginal, variantState)
 }
@@ -408,6 +449,7 @@ var kafkaStubProducer = &kafkatest.IProducerMock{
 	ChannelsFunc: func() *kafka.ProducerChannels {
 		return &kafka.ProducerChannels{}
 	},
+	IsInitialisedFunc: func() bool { return true },
 }
 
 func TestGetImagesHandler(t *testing.T) {
@@ -448,7 +490,7 @@ func doTestGetImagesHandler() {
 				return handler
 			},
 		}
-		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 		Convey("When existing images are requested with a valid Collection-Id context and query parameter value", func() {
 			r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:24700/images?collection_id=%s", testCollectionID1), http.NoBody)
@@ -527,7 +569,7 @@ func doTestGetImagesHandler() {
 			Convey("And URL rewriting is enabled", func() {
 				cfg.EnableURLRewriting = true
 
-				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 				imageAPI.Router.ServeHTTP(w, r)
 
@@ -544,7 +586,7 @@ func doTestGetImagesHandler() {
 			Convey("And URL rewriting is disabled", func() {
 				cfg.EnableURLRewriting = false
 
-				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 				imageAPI.Router.ServeHTTP(w, r)
 
@@ -585,7 +627,7 @@ func doTestGetImagesHandler() {
 				},
 			}
 
-			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 			imageAPI.Router.ServeHTTP(w, r)
 
@@ -615,7 +657,7 @@ func doTestGetImagesHandler() {
 				return handler
 			},
 		}
-		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 		Convey("Then when images are requested, a 500 error is returned", func() {
 			r := httptest.NewRequest(http.MethodGet, "http://localhost:24700/images", http.NoBody)
@@ -645,7 +687,7 @@ func TestCreateImageHandler(t *testing.T) {
 			},
 		}
 
-		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 		Convey("When a valid new image is posted", func() {
 			r := httptest.NewRequest(http.MethodPost, "http://localhost:24700/images", bytes.NewBufferString(
@@ -727,6 +769,17 @@ func TestCreateImageHandler(t *testing.T) {
 			imageAPI.Router.ServeHTTP(w, r)
 			So(w.Code, ShouldEqual, http.StatusBadRequest)
 		})
+
+		Convey("A request body larger than the maximum request body size results in a RequestEntityTooLarge response", func() {
+			r := httptest.NewRequest(http.MethodPost, "http://localhost:24700/images", bytes.NewBufferString(
+				fmt.Sprintf(newImagePayloadFmt, testCollectionID1, "some-image-name")))
+			r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+			w := httptest.NewRecorder()
+			r.Body = http.MaxBytesReader(w, r.Body, 10)
+			imageAPI.Router.ServeHTTP(w, r)
+			So(w.Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+			So(mongoDBMock.UpsertImageCalls(), ShouldHaveLength, 0)
+		})
 	})
 
 	Convey("Given an image API with mongoDB that fails to insert images", t, func() {
@@ -743,7 +796,7 @@ func TestCreateImageHandler(t *testing.T) {
 				return handler
 			},
 		}
-		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 		Convey("When a new image is posted a 500 InternalServerError status code is returned", func() {
 			r := httptest.NewRequest(http.MethodPost, "http://localhost:24700/images", bytes.NewBufferString(
@@ -791,13 +844,19 @@ func doTestGetImageHandler(cfg *config.Config) {
 				return handler
 			},
 		}
-		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 		Convey("When an existing 'created' image is requested with the valid Collection-Id context value", func() {
 			r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:24700/images/%s", testImageID1), http.NoBody)
 			r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
 			w := httptest.NewRecorder()
 			imageAPI.Router.ServeHTTP(w, r)
+			if !cfg.IsPublishing {
+				Convey("Then the unpublished image is not found in web mode", func() {
+					So(w.Code, ShouldEqual, http.StatusNotFound)
+				})
+				return
+			}
 			Convey("Then the expected image is returned with status code 200", func() {
 				So(w.Code, ShouldEqual, http.StatusOK)
 				So(w.Header().Get(contentTypeKey), ShouldEqual, contentTypeJSON)
@@ -823,7 +882,12 @@ func doTestGetImageHandler(cfg *config.Config) {
 				retImage := models.Image{}
 				err = json.Unmarshal(payload, &retImage)
 				So(err, ShouldBeNil)
-				So(retImage, ShouldResemble, *apiFullImage(models.StatePublished))
+				expected := apiFullImage(models.StatePublished)
+				if !cfg.IsPublishing {
+					// the upload path is private
+					expected.Upload = nil
+				}
+				So(retImage, ShouldResemble, *expected)
 			})
 		})
 
@@ -847,7 +911,7 @@ func doTestGetImageHandler(cfg *config.Config) {
 			Convey("And URL rewriting is enabled", func() {
 				cfg.EnableURLRewriting = true
 
-				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 				imageAPI.Router.ServeHTTP(w, r)
 
@@ -864,7 +928,7 @@ func doTestGetImageHandler(cfg *config.Config) {
 			Convey("And URL rewriting is disabled", func() {
 				cfg.EnableURLRewriting = false
 
-				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 				imageAPI.Router.ServeHTTP(w, r)
 
@@ -893,7 +957,7 @@ func TestUpdateImageHandler(t *testing.T) {
 
 		Convey("And an empty MongoDB mock", func() {
 			mongoMock := &mock.MongoServerMock{}
-			imageAPI := GetAPIWithMocks(cfg, mongoMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+			imageAPI := GetAPIWithMocks(cfg, mongoMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 			Convey("Calling update image with an invalid body results in 400 response", func() {
 				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s", testImageID1), bytes.NewBufferString("wrong"))
@@ -932,7 +996,7 @@ func TestUpdateImageHandler(t *testing.T) {
 				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
 				UnlockImageFunc:      func(ctx context.Context, id string) {},
 			}
-			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 			Convey("Calling update image with same state results in 403 Forbidden response and it is not updated", func() {
 				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s", testImageID2), bytes.NewBufferString(
@@ -956,7 +1020,7 @@ func TestUpdateImageHandler(t *testing.T) {
 				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
 				UnlockImageFunc:      func(ctx context.Context, id string) {},
 			}
-			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 			Convey("Calling update image with an nonexistent image id results in 404 response", func() {
 				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s", "nonexistent"), bytes.NewBufferString(
@@ -980,7 +1044,7 @@ func TestUpdateImageHandler(t *testing.T) {
 				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
 				UnlockImageFunc:      func(ctx context.Context, id string) {},
 			}
-			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 			Convey("Calling update image results in 500 response", func() {
 				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s", testImageID1), bytes.NewBufferString(
@@ -1007,7 +1071,7 @@ func TestUpdateImageHandler(t *testing.T) {
 				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
 				UnlockImageFunc:      func(ctx context.Context, id string) {},
 			}
-			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 			Convey("Calling update image results in 500 result", func() {
 				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s", testImageID1), bytes.NewBufferString(
@@ -1033,7 +1097,7 @@ func TestUpdateImageHandler(t *testing.T) {
 				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
 				UnlockImageFunc:      func(ctx context.Context, id string) {},
 			}
-			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 			Convey("Calling update image results in 403 Forbidden response and it is not updated", func() {
 				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s", testImageID2), bytes.NewBufferString(
@@ -1047,6 +1111,17 @@ func TestUpdateImageHandler(t *testing.T) {
 				So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 1)
 				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
 			})
+
+			Convey("Calling update image to withdraw it results in 403 Forbidden response and it is not withdrawn", func() {
+				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s", testImageID2), bytes.NewBufferString(
+					fmt.Sprintf(newImageWithStatePayloadFmt, testCollectionID1, models.StateWithdrawn.String())))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusForbidden)
+				So(w.Body.String(), ShouldContainSubstring, apierrors.ErrImageWithdrawalNotAllowed.Error())
+				So(mongoDBMock.UpdateImageCalls(), ShouldHaveLength, 0)
+			})
 		})
 
 		Convey("And an image in created state in MongoDB plus a kafka uploadedProducer", func() {
@@ -1057,6 +1132,7 @@ func TestUpdateImageHandler(t *testing.T) {
 				ChannelsFunc: func() *kafka.ProducerChannels {
 					return channels
 				},
+				IsInitialisedFunc: func() bool { return true },
 			}
 
 			mongoDBMock := &mock.MongoServerMock{
@@ -1067,7 +1143,7 @@ func TestUpdateImageHandler(t *testing.T) {
 				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
 				UnlockImageFunc:      func(ctx context.Context, id string) {},
 			}
-			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, uploadedProducer, kafkaStubProducer)
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, uploadedProducer, kafkaStubProducer, kafkaStubProducer)
 
 			Convey("Calling image upload with a valid image upload results in 200 OK response with the expected image provided to mongoDB and the message sent to kafka producer", func() {
 				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s", testImageID2), bytes.NewBufferString(
@@ -1099,11 +1175,52 @@ func TestUpdateImageHandler(t *testing.T) {
 				})
 			})
 
+			Convey("Calling image upload results in a 503 ServiceUnavailable response when the image uploaded event is not accepted by the kafka producer in time and cannot be stored as a dead letter, and the image is not updated in mongoDB", func() {
+				mongoDBMock.CreateDeadLetterFunc = func(ctx context.Context, deadLetter *models.DeadLetter) error { return errMongoDB }
+				timeoutCfg := *cfg
+				timeoutCfg.KafkaSendTimeout = 10 * time.Millisecond
+				imageAPI := GetAPIWithMocks(&timeoutCfg, mongoDBMock, authHandlerMock, uploadedProducer, kafkaStubProducer, kafkaStubProducer)
+				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s", testImageID2), bytes.NewBufferString(
+					fmt.Sprintf(imageUploadPayloadFmt, testCollectionID1, testUploadPath)))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusServiceUnavailable)
+				So(mongoDBMock.CreateDeadLetterCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UpsertImageCalls(), ShouldHaveLength, 0)
+				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
+			})
+
+			Convey("Calling image upload when the kafka producer is not initialised results in the image uploaded event being stored as a dead letter, and a 200 OK response with the image updated in mongoDB", func() {
+				mongoDBMock.CreateDeadLetterFunc = func(ctx context.Context, deadLetter *models.DeadLetter) error { return nil }
+				uploadedProducer.IsInitialisedFunc = func() bool { return false }
+				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s", testImageID2), bytes.NewBufferString(
+					fmt.Sprintf(imageUploadPayloadFmt, testCollectionID1, testUploadPath)))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(uploadedProducer.IsInitialisedCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UpsertImageCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.CreateDeadLetterCalls(), ShouldHaveLength, 1)
+				deadLetter := mongoDBMock.CreateDeadLetterCalls()[0].DeadLetter
+				So(deadLetter.EventType, ShouldEqual, models.DeadLetterImageUploaded)
+				So(deadLetter.ImageID, ShouldEqual, testImageID2)
+				So(deadLetter.Error, ShouldEqual, event.ErrProducerNotInitialised.Error())
+				So(deadLetter.Attempts, ShouldEqual, 1)
+				So(deadLetter.NextAttempt, ShouldNotBeNil)
+				uploaded := &event.ImageUploaded{}
+				So(json.Unmarshal(deadLetter.Payload, uploaded), ShouldBeNil)
+				So(uploaded, ShouldResemble, &event.ImageUploaded{ImageID: testImageID2, Path: testUploadFilename, Filename: testFilename})
+			})
+
 			Convey("Calling image upload results in a 500 InternalError response when an invalid image uploaded event is generated, and the image is not updated in mongoDB", func() {
+				imageUploadedEvent := api.ImageUploadedEvent
+				defer func() { api.ImageUploadedEvent = imageUploadedEvent }()
 				api.ImageUploadedEvent = func(imageID, uploadPath, filename string) *event.ImageUploaded {
 					return nil
 				}
-				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s", testImageID2), bytes.NewBufferString(
 					fmt.Sprintf(imageUploadPayloadFmt, testCollectionID1, testUploadPath)))
 				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
@@ -1124,7 +1241,7 @@ func TestUpdateImageHandler(t *testing.T) {
 					AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
 					UnlockImageFunc:      func(ctx context.Context, id string) {},
 				}
-				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 				Convey("Calling 'upload image' results in 403 Forbidden response", func() {
 					r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s", testImageID2), bytes.NewBufferString(
@@ -1190,7 +1307,7 @@ func doTestGetDownloadsHandler() {
 			},
 		}
 
-		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 		Convey("When downloads are requested from an image with no downloads", func() {
 			r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:24700/images/%s/downloads", testImageUploadedID), http.NoBody)
@@ -1286,7 +1403,7 @@ func doTestGetDownloadsHandler() {
 			Convey("And URL rewriting is enabled", func() {
 				cfg.EnableURLRewriting = true
 
-				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 				imageAPI.Router.ServeHTTP(w, r)
 
@@ -1303,7 +1420,7 @@ func doTestGetDownloadsHandler() {
 			Convey("And URL rewriting is disabled", func() {
 				cfg.EnableURLRewriting = false
 
-				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 				imageAPI.Router.ServeHTTP(w, r)
 
@@ -1330,7 +1447,7 @@ func doTestGetDownloadsHandler() {
 				return handler
 			},
 		}
-		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 		Convey("Then when images are requested, a 500 error is returned", func() {
 			r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:24700/images/%s/downloads", testImageUploadedID), http.NoBody)
@@ -1363,9 +1480,9 @@ func TestCreateDownloadHandler(t *testing.T) {
 					return nil, apierrors.ErrImageNotFound
 				}
 			},
-			UpsertImageFunc:      func(ctx context.Context, id string, image *models.Image) error { return nil },
-			AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
-			UnlockImageFunc:      func(ctx context.Context, id string) {},
+			CreateDownloadFunc: func(ctx context.Context, id, imageState string, download *models.Download) (bool, error) {
+				return true, nil
+			},
 		}
 
 		authHandlerMock := &mock.AuthHandlerMock{
@@ -1374,7 +1491,7 @@ func TestCreateDownloadHandler(t *testing.T) {
 			},
 		}
 
-		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 		Convey("When a valid new download is posted to an image in an 'uploaded' state", func() {
 			r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/downloads", testImageUploadedID), bytes.NewBufferString(
@@ -1393,6 +1510,14 @@ func TestCreateDownloadHandler(t *testing.T) {
 				So(err, ShouldBeNil)
 				So(retDownload, ShouldResemble, dbDownloadWithID(testImageUploadedID, testVariantOriginal, models.StateDownloadImporting))
 			})
+
+			Convey("Then the download is added to the image only if it is still 'uploaded', without locking the image", func() {
+				So(mongoDBMock.CreateDownloadCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.CreateDownloadCalls()[0].ID, ShouldEqual, testImageUploadedID)
+				So(mongoDBMock.CreateDownloadCalls()[0].ImageState, ShouldEqual, models.StateUploaded.String())
+				So(mongoDBMock.CreateDownloadCalls()[0].Download.ID, ShouldEqual, testVariantOriginal)
+				So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 0)
+			})
 		})
 
 		Convey("When a new download with an imported state is posted to an image in an 'uploaded' state", func() {
@@ -1481,10 +1606,10 @@ func TestCreateDownloadHandler(t *testing.T) {
 		cfg.IsPublishing = true
 
 		mongoDBMock := &mock.MongoServerMock{
-			GetImageFunc:         func(ctx context.Context, id string) (*models.Image, error) { return dbImage(models.StateUploaded), nil },
-			UpsertImageFunc:      func(ctx context.Context, id string, image *models.Image) error { return errMongoDB },
-			AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
-			UnlockImageFunc:      func(ctx context.Context, id string) {},
+			GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) { return dbImage(models.StateUploaded), nil },
+			CreateDownloadFunc: func(ctx context.Context, id, imageState string, download *models.Download) (bool, error) {
+				return false, errMongoDB
+			},
 		}
 
 		authHandlerMock := &mock.AuthHandlerMock{
@@ -1493,7 +1618,7 @@ func TestCreateDownloadHandler(t *testing.T) {
 			},
 		}
 
-		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 		Convey("When a valid new download is posted to an image in a 'uploaded' state", func() {
 			r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/downloads", testImageUploadedID), bytes.NewBufferString(
@@ -1508,16 +1633,16 @@ func TestCreateDownloadHandler(t *testing.T) {
 		})
 	})
 
-	Convey("Given an image API in publishing mode with a mongoDB that fails to lock", t, func() {
+	Convey("Given an image API in publishing mode with an image that keeps being modified by other requests", t, func() {
 		cfg, err := config.Get()
 		So(err, ShouldBeNil)
 		cfg.IsPublishing = true
 
 		mongoDBMock := &mock.MongoServerMock{
-			GetImageFunc:         func(ctx context.Context, id string) (*models.Image, error) { return dbImage(models.StateUploaded), nil },
-			UpsertImageFunc:      func(ctx context.Context, id string, image *models.Image) error { return nil },
-			AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return "", errors.New("fail to lock") },
-			UnlockImageFunc:      func(ctx context.Context, id string) {},
+			GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) { return dbImage(models.StateUploaded), nil },
+			CreateDownloadFunc: func(ctx context.Context, id, imageState string, download *models.Download) (bool, error) {
+				return false, nil
+			},
 		}
 
 		authHandlerMock := &mock.AuthHandlerMock{
@@ -1526,7 +1651,7 @@ func TestCreateDownloadHandler(t *testing.T) {
 			},
 		}
 
-		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 		Convey("When a valid new download is posted to an image in a 'uploaded' state", func() {
 			r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/downloads", testImageUploadedID), bytes.NewBufferString(
@@ -1535,8 +1660,10 @@ func TestCreateDownloadHandler(t *testing.T) {
 			w := httptest.NewRecorder()
 			imageAPI.Router.ServeHTTP(w, r)
 
-			Convey("Then a status code 500 (InternalServerError) is returned", func() {
-				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+			Convey("Then the image is read and updated again, and a status code 409 (Conflict) is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusConflict)
+				So(mongoDBMock.GetImageCalls(), ShouldHaveLength, 3)
+				So(mongoDBMock.CreateDownloadCalls(), ShouldHaveLength, 3)
 			})
 		})
 	})
@@ -1587,7 +1714,7 @@ func doTestGetDownloadHandler() {
 			},
 		}
 
-		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 		Convey("When an existing download is requested from an image with one download", func() {
 			r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:24700/images/%s/downloads/%s", testImageImportingID, testVariantOriginal), http.NoBody)
@@ -1661,7 +1788,7 @@ func doTestGetDownloadHandler() {
 			Convey("And URL rewriting is enabled", func() {
 				cfg.EnableURLRewriting = true
 
-				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 				imageAPI.Router.ServeHTTP(w, r)
 
@@ -1678,7 +1805,7 @@ func doTestGetDownloadHandler() {
 			Convey("And URL rewriting is disabled", func() {
 				cfg.EnableURLRewriting = false
 
-				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 				imageAPI.Router.ServeHTTP(w, r)
 
@@ -1705,7 +1832,7 @@ func doTestGetDownloadHandler() {
 				return handler
 			},
 		}
-		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 		Convey("Then when a specific download requested, a 500 error is returned", func() {
 			r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:24700/images/%s/downloads/%s", testImageUploadedID, testVariantOriginal), http.NoBody)
@@ -1728,15 +1855,8 @@ func TestUpdateDownloadHandler(t *testing.T) {
 		}
 
 		Convey("And an image in a state in 'created' state", func() {
-			mongoDBMock := &mock.MongoServerMock{
-				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
-					return dbFullImageWithDownloads(models.StateCreated, dbDownloadWithID(id, testVariantOriginal, models.StateDownloadImporting)), nil
-				},
-				UpsertImageFunc:      func(ctx context.Context, id string, image *models.Image) error { return nil },
-				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
-				UnlockImageFunc:      func(ctx context.Context, id string) {},
-			}
-			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+			mongoDBMock, _ := newImageStoreMock(dbFullImageWithDownloads(models.StateCreated, dbDownloadWithID(testImageID2, testVariantOriginal, models.StateDownloadImporting)))
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 			Convey("Calling 'update variant' for the image results in 403 Forbidden response and nothing is updated", func() {
 				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s/downloads/%s", testImageID2, testVariantOriginal), bytes.NewBufferString(
@@ -1748,21 +1868,14 @@ func TestUpdateDownloadHandler(t *testing.T) {
 				So(w.Code, ShouldEqual, http.StatusForbidden)
 				So(mongoDBMock.GetImageCalls(), ShouldHaveLength, 1)
 				So(mongoDBMock.GetImageCalls()[0].ID, ShouldEqual, testImageID2)
-				So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 1)
-				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UpdateDownloadCalls(), ShouldHaveLength, 0)
+				So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 0)
 			})
 		})
 
 		Convey("And an image in 'importing' state in MongoDB, with a download variant in 'importing' state", func() {
-			mongoDBMock := &mock.MongoServerMock{
-				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
-					return dbFullImageWithDownloads(models.StateImporting, dbDownloadWithID(id, testVariantOriginal, models.StateDownloadImporting)), nil
-				},
-				UpsertImageFunc:      func(ctx context.Context, id string, image *models.Image) error { return nil },
-				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
-				UnlockImageFunc:      func(ctx context.Context, id string) {},
-			}
-			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+			mongoDBMock, store := newImageStoreMock(dbFullImageWithDownloads(models.StateImporting, dbDownloadWithID(testImageID2, testVariantOriginal, models.StateDownloadImporting)))
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 			Convey("Calling 'update variant' for the image results in 200 OK response and the image is updated as expected", func() {
 				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s/downloads/%s", testImageID2, testVariantOriginal), bytes.NewBufferString(
@@ -1773,16 +1886,44 @@ func TestUpdateDownloadHandler(t *testing.T) {
 				imageAPI.Router.ServeHTTP(w, r)
 				So(w.Code, ShouldEqual, http.StatusOK)
 				So(w.Header().Get(contentTypeKey), ShouldEqual, contentTypeJSON)
-				So(mongoDBMock.GetImageCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.GetImageCalls(), ShouldHaveLength, 2)
 				So(mongoDBMock.GetImageCalls()[0].ID, ShouldEqual, testImageID2)
-				So(mongoDBMock.UpsertImageCalls(), ShouldHaveLength, 1)
-				So(mongoDBMock.UpsertImageCalls()[0].ID, ShouldResemble, testImageID2)
-				update := mongoDBMock.UpsertImageCalls()[0].Image
-				So(update.State, ShouldResemble, models.StateImported.String())
-				So(update.Downloads[testVariantOriginal].State, ShouldResemble, models.StateDownloadImported.String())
-				So(*update.Downloads[testVariantOriginal].ImportStarted, ShouldResemble, testImportStarted)
-				So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 1)
-				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UpdateDownloadCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UpdateDownloadCalls()[0].ID, ShouldEqual, testImageID2)
+				So(mongoDBMock.UpdateDownloadCalls()[0].ImageState, ShouldEqual, models.StateImporting.String())
+				So(mongoDBMock.UpdateDownloadCalls()[0].VariantState, ShouldEqual, models.StateDownloadImporting.String())
+				So(mongoDBMock.UpdateImageStateCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UpdateImageStateCalls()[0].PreviousState, ShouldEqual, models.StateImporting.String())
+				image := store.get(testImageID2)
+				So(image.State, ShouldResemble, models.StateImported.String())
+				So(image.Downloads[testVariantOriginal].State, ShouldResemble, models.StateDownloadImported.String())
+				So(*image.Downloads[testVariantOriginal].ImportStarted, ShouldResemble, testImportStarted)
+				So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 0)
+			})
+
+			Convey("Calling 'update variant' with a checksum and content type results in 200 OK response and both values are stored", func() {
+				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s/downloads/%s", testImageID2, testVariantOriginal), bytes.NewBufferString(
+					fmt.Sprintf(updateImageDownloadImportedWithChecksumPayloadFmt, testVariantOriginal, testDownloadType, models.StateDownloadImported.String(), testSha256, testContentType)))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				r = r.WithContext(context.WithValue(r.Context(), handlers.CollectionID.Context(), testCollectionID1))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(mongoDBMock.UpdateDownloadCalls(), ShouldHaveLength, 1)
+				download := store.get(testImageID2).Downloads[testVariantOriginal]
+				So(download.Sha256, ShouldEqual, testSha256)
+				So(download.ContentType, ShouldEqual, testContentType)
+			})
+
+			Convey("Calling 'update variant' with an invalid checksum results in 400 response and nothing is updated", func() {
+				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s/downloads/%s", testImageID2, testVariantOriginal), bytes.NewBufferString(
+					fmt.Sprintf(updateImageDownloadImportedWithChecksumPayloadFmt, testVariantOriginal, testDownloadType, models.StateDownloadImported.String(), "not-a-checksum", testContentType)))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				r = r.WithContext(context.WithValue(r.Context(), handlers.CollectionID.Context(), testCollectionID1))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(mongoDBMock.UpdateDownloadCalls(), ShouldHaveLength, 0)
 			})
 
 			Convey("Calling update download with a download that has a different id results in 400 response", func() {
@@ -1794,17 +1935,51 @@ func TestUpdateDownloadHandler(t *testing.T) {
 				imageAPI.Router.ServeHTTP(w, r)
 				So(w.Code, ShouldEqual, http.StatusBadRequest)
 			})
+
+			Convey("Calling 'update variant' with a failed state results in 200 OK response, and the image import fails with the variant error", func() {
+				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s/downloads/%s", testImageID2, testVariantOriginal), bytes.NewBufferString(
+					fmt.Sprintf(updateImageDownloadImportedPayloadFmt, testVariantOriginal, testDownloadType, models.StateDownloadFailed.String())))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				r = r.WithContext(context.WithValue(r.Context(), handlers.CollectionID.Context(), testCollectionID1))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusOK)
+				image := store.get(testImageID2)
+				So(image.State, ShouldEqual, models.StateFailedImport.String())
+				So(image.Error, ShouldEqual, fmt.Sprintf("error in variant '%s'", testVariantOriginal))
+			})
 		})
 
-		Convey("And an image in 'uploaded' state in MongoDB, without any download variants", func() {
-			mongoDBMock := &mock.MongoServerMock{
-				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
-					return dbImage(models.StateUploaded), nil
-				},
-				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
-				UnlockImageFunc:      func(ctx context.Context, id string) {},
+		Convey("And an image in 'importing' state in MongoDB, whose variant is imported by another request after being read", func() {
+			mongoDBMock, store := newImageStoreMock(dbFullImageWithDownloads(models.StateImporting, dbDownloadWithID(testImageID2, testVariantOriginal, models.StateDownloadImporting)))
+			mongoDBMock.UpdateDownloadFunc = func(ctx context.Context, id, imageState, variantState string, download *models.Download) (bool, error) {
+				if len(mongoDBMock.UpdateDownloadCalls()) == 1 {
+					concurrent := dbDownloadWithID(testImageID2, testVariantOriginal, models.StateDownloadImported)
+					updated, err := store.updateDownload(ctx, id, imageState, variantState, &concurrent)
+					So(err, ShouldBeNil)
+					So(updated, ShouldBeTrue)
+				}
+				return store.updateDownload(ctx, id, imageState, variantState, download)
 			}
-			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("Calling 'update variant' reads the image again and results in 403 Forbidden response, as the variant is no longer importing", func() {
+				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s/downloads/%s", testImageID2, testVariantOriginal), bytes.NewBufferString(
+					fmt.Sprintf(updateImageDownloadImportedPayloadFmt, testVariantOriginal, testDownloadType, models.StateDownloadImported.String())))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				r = r.WithContext(context.WithValue(r.Context(), handlers.CollectionID.Context(), testCollectionID1))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusForbidden)
+				So(mongoDBMock.GetImageCalls(), ShouldHaveLength, 2)
+				So(mongoDBMock.UpdateDownloadCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UpdateImageStateCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("And an image in 'uploaded' state in MongoDB, without any download variants", func() {
+			mongoDBMock, _ := newImageStoreMock(dbImage(models.StateUploaded))
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 			Convey("Calling 'update variant' for the existing image without variants results in 404 Not found response", func() {
 				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s/downloads/%s", testImageID1, testVariantOriginal), bytes.NewBufferString(
@@ -1816,21 +1991,13 @@ func TestUpdateDownloadHandler(t *testing.T) {
 				So(w.Code, ShouldEqual, http.StatusNotFound)
 				So(mongoDBMock.GetImageCalls(), ShouldHaveLength, 1)
 				So(mongoDBMock.GetImageCalls()[0].ID, ShouldEqual, testImageID1)
-				So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 1)
-				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UpdateDownloadCalls(), ShouldHaveLength, 0)
 			})
 		})
 
 		Convey("And an image in 'published' state in MongoDB, with a download variant in 'published' state", func() {
-			mongoDBMock := &mock.MongoServerMock{
-				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
-					return dbFullImageWithDownloads(models.StatePublished, dbDownloadWithID(id, testVariantOriginal, models.StateDownloadPublished)), nil
-				},
-				UpsertImageFunc:      func(ctx context.Context, id string, image *models.Image) error { return nil },
-				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
-				UnlockImageFunc:      func(ctx context.Context, id string) {},
-			}
-			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+			mongoDBMock, store := newImageStoreMock(dbFullImageWithDownloads(models.StatePublished, dbDownloadWithID(testImageID2, testVariantOriginal, models.StateDownloadPublished)))
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 			Convey("Calling 'complete variant' for the image results in 200 OK response and the image is completed", func() {
 				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s/downloads/%s", testImageID2, testVariantOriginal), bytes.NewBufferString(
@@ -1841,69 +2008,119 @@ func TestUpdateDownloadHandler(t *testing.T) {
 				imageAPI.Router.ServeHTTP(w, r)
 				So(w.Code, ShouldEqual, http.StatusOK)
 				So(w.Header().Get(contentTypeKey), ShouldEqual, contentTypeJSON)
-				So(mongoDBMock.GetImageCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.GetImageCalls(), ShouldHaveLength, 2)
 				So(mongoDBMock.GetImageCalls()[0].ID, ShouldEqual, testImageID2)
-				So(mongoDBMock.UpsertImageCalls(), ShouldHaveLength, 1)
-				So(mongoDBMock.UpsertImageCalls()[0].ID, ShouldResemble, testImageID2)
-				update := mongoDBMock.UpsertImageCalls()[0].Image
-				So(update.State, ShouldResemble, models.StateCompleted.String())
-				So(update.Downloads[testVariantOriginal].State, ShouldResemble, models.StateDownloadCompleted.String())
-				So(*update.Downloads[testVariantOriginal].PublishCompleted, ShouldResemble, testPublishCompleted)
-				So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 1)
-				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UpdateDownloadCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UpdateImageStateCalls(), ShouldHaveLength, 1)
+				image := store.get(testImageID2)
+				So(image.State, ShouldResemble, models.StateCompleted.String())
+				So(image.Downloads[testVariantOriginal].State, ShouldResemble, models.StateDownloadCompleted.String())
+				So(*image.Downloads[testVariantOriginal].PublishCompleted, ShouldResemble, testPublishCompleted)
+				So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 0)
+			})
+
+			Convey("Calling 'complete variant' for the image records an immutable version of the completed image, after completing the variant and before completing the image", func() {
+				mongoDBMock.UpdateDownloadFunc = func(ctx context.Context, id, imageState, variantState string, download *models.Download) (bool, error) {
+					So(mongoDBMock.CreateImageVersionCalls(), ShouldHaveLength, 0)
+					return store.updateDownload(ctx, id, imageState, variantState, download)
+				}
+				updateImageState := mongoDBMock.UpdateImageStateFunc
+				mongoDBMock.UpdateImageStateFunc = func(ctx context.Context, previousState string, image *models.Image) (bool, error) {
+					So(mongoDBMock.CreateImageVersionCalls(), ShouldHaveLength, 1)
+					return updateImageState(ctx, previousState, image)
+				}
+				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s/downloads/%s", testImageID2, testVariantOriginal), bytes.NewBufferString(
+					fmt.Sprintf(updateImageDownloadCompletedPayloadFmt, testVariantOriginal, testDownloadType, models.StateDownloadCompleted.String())))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				r = r.WithContext(context.WithValue(r.Context(), handlers.CollectionID.Context(), testCollectionID1))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(mongoDBMock.CreateImageVersionCalls(), ShouldHaveLength, 1)
+				version := mongoDBMock.CreateImageVersionCalls()[0].Version
+				So(version.ImageID, ShouldEqual, testImageID2)
+				So(version.Version, ShouldEqual, 1)
+				So(version.State, ShouldEqual, models.StateCompleted.String())
+				So(version.Downloads[testVariantOriginal].State, ShouldEqual, models.StateDownloadCompleted.String())
+				So(version.Links, ShouldResemble, &models.VersionLinks{
+					Self:  fmt.Sprintf("http://example.com/images/%s/versions/1", testImageID2),
+					Image: fmt.Sprintf("http://example.com/images/%s", testImageID2),
+				})
+				So(version.Downloads[testVariantOriginal].Links, ShouldResemble, &models.DownloadLinks{
+					Self:  fmt.Sprintf("http://example.com/images/%s/versions/1/downloads/%s", testImageID2, testVariantOriginal),
+					Image: fmt.Sprintf("http://example.com/images/%s/versions/1", testImageID2),
+				})
+				So(store.get(testImageID2).Downloads[testVariantOriginal].Links, ShouldNotResemble, version.Downloads[testVariantOriginal].Links)
 			})
 		})
 
-		Convey("And an image in 'published' state in MongoDB, with 2 download variants in 'published' state", func() {
-			mongoDBMock := &mock.MongoServerMock{
-				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
-					return dbFullImageWithDownloads(
-						models.StatePublished,
-						dbDownloadWithID(id, testVariantOriginal, models.StateDownloadPublished),
-						dbDownloadWithID(id, testVariantAlternative, models.StateDownloadPublished)), nil
-				},
-				UpsertImageFunc:      func(ctx context.Context, id string, image *models.Image) error { return nil },
-				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
-				UnlockImageFunc:      func(ctx context.Context, id string) {},
-			}
-			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+		Convey("And an image in 'published' state in MongoDB, with a download variant in 'published' state, and a MongoDB mock that fails to store versions", func() {
+			mongoDBMock, store := newImageStoreMock(dbFullImageWithDownloads(models.StatePublished, dbDownloadWithID(testImageID2, testVariantOriginal, models.StateDownloadPublished)))
+			mongoDBMock.CreateImageVersionFunc = func(ctx context.Context, version *models.Version) error { return errMongoDB }
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
-			Convey("Calling 'update variant' for the image results in 200 OK response, the variant is completed, but the image is not", func() {
+			Convey("Calling 'complete variant' for the image results in 500 response and the image is not completed", func() {
 				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s/downloads/%s", testImageID2, testVariantOriginal), bytes.NewBufferString(
 					fmt.Sprintf(updateImageDownloadCompletedPayloadFmt, testVariantOriginal, testDownloadType, models.StateDownloadCompleted.String())))
 				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
 				r = r.WithContext(context.WithValue(r.Context(), handlers.CollectionID.Context(), testCollectionID1))
 				w := httptest.NewRecorder()
 				imageAPI.Router.ServeHTTP(w, r)
-				So(w.Code, ShouldEqual, http.StatusOK)
-				So(w.Header().Get(contentTypeKey), ShouldEqual, contentTypeJSON)
-				So(mongoDBMock.GetImageCalls(), ShouldHaveLength, 1)
-				So(mongoDBMock.GetImageCalls()[0].ID, ShouldEqual, testImageID2)
-				So(mongoDBMock.UpsertImageCalls(), ShouldHaveLength, 1)
-				So(mongoDBMock.UpsertImageCalls()[0].ID, ShouldResemble, testImageID2)
-				update := mongoDBMock.UpsertImageCalls()[0].Image
-				So(update.State, ShouldResemble, models.StatePublished.String())
-				So(update.Downloads[testVariantOriginal].State, ShouldResemble, models.StateDownloadCompleted.String())
-				So(*update.Downloads[testVariantOriginal].PublishCompleted, ShouldResemble, testPublishCompleted)
-				So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 1)
-				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
+				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+				So(mongoDBMock.CreateImageVersionCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UpdateDownloadCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UpdateImageStateCalls(), ShouldHaveLength, 0)
+				So(store.get(testImageID2).State, ShouldEqual, models.StatePublished.String())
 			})
 		})
 
-		Convey("And a MongoDB mock that fails to lock", func() {
-			mongoDBMock := &mock.MongoServerMock{
-				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return "", errMongoDB },
+		Convey("And an image in 'published' state in MongoDB, with a download variant in 'published' state that keeps being modified by other requests", func() {
+			mongoDBMock, store := newImageStoreMock(dbFullImageWithDownloads(models.StatePublished, dbDownloadWithID(testImageID2, testVariantOriginal, models.StateDownloadPublished)))
+			mongoDBMock.UpdateDownloadFunc = func(ctx context.Context, id, imageState, variantState string, download *models.Download) (bool, error) {
+				return false, nil
 			}
-			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("Calling 'complete variant' for the image results in 409 Conflict response, without recording any version", func() {
+				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s/downloads/%s", testImageID2, testVariantOriginal), bytes.NewBufferString(
+					fmt.Sprintf(updateImageDownloadCompletedPayloadFmt, testVariantOriginal, testDownloadType, models.StateDownloadCompleted.String())))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				r = r.WithContext(context.WithValue(r.Context(), handlers.CollectionID.Context(), testCollectionID1))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusConflict)
+				So(mongoDBMock.CreateImageVersionCalls(), ShouldHaveLength, 0)
+				So(mongoDBMock.UpdateImageStateCalls(), ShouldHaveLength, 0)
+				So(store.get(testImageID2).State, ShouldEqual, models.StatePublished.String())
+			})
+		})
+
+		Convey("And an image in 'published' state in MongoDB, with 2 download variants in 'published' state", func() {
+			mongoDBMock, store := newImageStoreMock(dbFullImageWithDownloads(
+				models.StatePublished,
+				dbDownloadWithID(testImageID2, testVariantOriginal, models.StateDownloadPublished),
+				dbDownloadWithID(testImageID2, testVariantAlternative, models.StateDownloadPublished)))
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
-			Convey("Calling 'update variant' for the variant results in 500 StatusInternalServerError response", func() {
-				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s/downloads/%s", testImageID1, testVariantOriginal),
-					bytes.NewBufferString(imageDownloadPayload))
+			Convey("Calling 'update variant' for the image results in 200 OK response, the variant is completed, but the image is not", func() {
+				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s/downloads/%s", testImageID2, testVariantOriginal), bytes.NewBufferString(
+					fmt.Sprintf(updateImageDownloadCompletedPayloadFmt, testVariantOriginal, testDownloadType, models.StateDownloadCompleted.String())))
 				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				r = r.WithContext(context.WithValue(r.Context(), handlers.CollectionID.Context(), testCollectionID1))
 				w := httptest.NewRecorder()
 				imageAPI.Router.ServeHTTP(w, r)
-				So(w.Code, ShouldEqual, http.StatusInternalServerError)
-				So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 1)
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get(contentTypeKey), ShouldEqual, contentTypeJSON)
+				So(mongoDBMock.GetImageCalls(), ShouldHaveLength, 2)
+				So(mongoDBMock.GetImageCalls()[0].ID, ShouldEqual, testImageID2)
+				So(mongoDBMock.UpdateDownloadCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UpdateImageStateCalls(), ShouldHaveLength, 0)
+				image := store.get(testImageID2)
+				So(image.State, ShouldResemble, models.StatePublished.String())
+				So(image.Downloads[testVariantOriginal].State, ShouldResemble, models.StateDownloadCompleted.String())
+				So(image.Downloads[testVariantAlternative].State, ShouldResemble, models.StateDownloadPublished.String())
+				So(mongoDBMock.CreateImageVersionCalls(), ShouldHaveLength, 0)
+				So(*image.Downloads[testVariantOriginal].PublishCompleted, ShouldResemble, testPublishCompleted)
 			})
 		})
 
@@ -1912,10 +2129,8 @@ func TestUpdateDownloadHandler(t *testing.T) {
 				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
 					return nil, errMongoDB
 				},
-				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
-				UnlockImageFunc:      func(ctx context.Context, id string) {},
 			}
-			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 			Convey("Calling 'update variant' for an nonexistent image results in 500 InternalServerError response", func() {
 				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s/downloads/%s", testImageID1, testVariantOriginal), bytes.NewBufferString(
@@ -1927,21 +2142,19 @@ func TestUpdateDownloadHandler(t *testing.T) {
 				So(w.Code, ShouldEqual, http.StatusInternalServerError)
 				So(mongoDBMock.GetImageCalls(), ShouldHaveLength, 1)
 				So(mongoDBMock.GetImageCalls()[0].ID, ShouldEqual, testImageID1)
-				So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 1)
-				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
 			})
 		})
 
-		Convey("And a MongoDB returning error on UploadImage", func() {
+		Convey("And a MongoDB returning error on UpdateDownload", func() {
 			mongoDBMock := &mock.MongoServerMock{
 				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
 					return dbFullImageWithDownloads(models.StateImporting, dbDownloadWithID(id, testVariantOriginal, models.StateDownloadImporting)), nil
 				},
-				UpsertImageFunc:      func(ctx context.Context, id string, image *models.Image) error { return errMongoDB },
-				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
-				UnlockImageFunc:      func(ctx context.Context, id string) {},
+				UpdateDownloadFunc: func(ctx context.Context, id, imageState, variantState string, download *models.Download) (bool, error) {
+					return false, errMongoDB
+				},
 			}
-			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 			Convey("Calling 'import variant' results in 500 InternalServerError response", func() {
 				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s/downloads/%s", testImageID1, testVariantOriginal), bytes.NewBufferString(
@@ -1953,10 +2166,27 @@ func TestUpdateDownloadHandler(t *testing.T) {
 				So(w.Code, ShouldEqual, http.StatusInternalServerError)
 				So(mongoDBMock.GetImageCalls(), ShouldHaveLength, 1)
 				So(mongoDBMock.GetImageCalls()[0].ID, ShouldEqual, testImageID1)
-				So(mongoDBMock.UpsertImageCalls(), ShouldHaveLength, 1)
-				So(mongoDBMock.UpsertImageCalls()[0].ID, ShouldEqual, testImageID1)
-				So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 1)
-				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UpdateDownloadCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UpdateDownloadCalls()[0].ID, ShouldEqual, testImageID1)
+			})
+		})
+
+		Convey("And a MongoDB returning error on UpdateImageState", func() {
+			mongoDBMock, _ := newImageStoreMock(dbFullImageWithDownloads(models.StateImporting, dbDownloadWithID(testImageID2, testVariantOriginal, models.StateDownloadImporting)))
+			mongoDBMock.UpdateImageStateFunc = func(ctx context.Context, previousState string, image *models.Image) (bool, error) {
+				return false, errMongoDB
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("Calling 'import variant' results in 500 InternalServerError response", func() {
+				r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s/downloads/%s", testImageID2, testVariantOriginal), bytes.NewBufferString(
+					fmt.Sprintf(updateImageDownloadImportedPayloadFmt, testVariantOriginal, testDownloadType, models.StateDownloadImported.String())))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				r = r.WithContext(context.WithValue(r.Context(), handlers.CollectionID.Context(), testCollectionID1))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+				So(mongoDBMock.UpdateImageStateCalls(), ShouldHaveLength, 1)
 			})
 		})
 	})
@@ -1976,13 +2206,16 @@ func TestPublishImageHandler(t *testing.T) {
 		Convey("And an image in 'imported' state in MongoDB", func() {
 			expectedSrcPathOriginal := fmt.Sprintf("images/%s/original", testImageID1)
 			expectedSrcPathPngW500 := fmt.Sprintf("images/%s/png_w500", testImageID1)
-			expectedDstPathOriginal := fmt.Sprintf("%s/some-image-name", expectedSrcPathOriginal)
-			expectedDstPathPngW500 := fmt.Sprintf("%s/some-image-name", expectedSrcPathPngW500)
+			expectedDstPathOriginal := fmt.Sprintf("%s/1/some-image-name", expectedSrcPathOriginal)
+			expectedDstPathPngW500 := fmt.Sprintf("%s/1/some-image-name", expectedSrcPathPngW500)
 			mongoDBMock := &mock.MongoServerMock{
 				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
 					image := dbImage(models.StateImported)
 					image.Filename = "some-image-name"
-					image.Downloads = map[string]models.Download{"original": {ID: "original", Href: expectedSrcPathOriginal}, "png_w500": {ID: "png_w500", Href: expectedSrcPathPngW500}}
+					image.Downloads = map[string]models.Download{
+						"original": {ID: "original", Href: expectedSrcPathOriginal, Sha256: testSha256, ContentType: testContentType},
+						"png_w500": {ID: "png_w500", Href: expectedSrcPathPngW500},
+					}
 					return image, nil
 				},
 				UpdateImageFunc: func(ctx context.Context, id string, image *models.Image) (bool, error) {
@@ -2000,8 +2233,9 @@ func TestPublishImageHandler(t *testing.T) {
 					ChannelsFunc: func() *kafka.ProducerChannels {
 						return channels
 					},
+					IsInitialisedFunc: func() bool { return true },
 				}
-				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, publishedProducer)
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, publishedProducer, kafkaStubProducer)
 				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/publish", testImageID1), http.NoBody)
 				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
 				r = r.WithContext(context.WithValue(r.Context(), handlers.CollectionID.Context(), testCollectionID1))
@@ -2015,19 +2249,21 @@ func TestPublishImageHandler(t *testing.T) {
 				So(mongoDBMock.UpdateImageCalls()[0].Image.State, ShouldEqual, models.StatePublished.String())
 				So(mongoDBMock.UpdateImageCalls()[0].Image.Downloads, ShouldHaveLength, 2)
 				So(mongoDBMock.UpdateImageCalls()[0].Image.Downloads["original"].State, ShouldEqual, models.StateDownloadPublished.String())
-				So(mongoDBMock.UpdateImageCalls()[0].Image.Downloads["original"].Href, ShouldEqual, downloadServiceURL+"/images/"+testImageID1+"/original/some-image-name")
+				So(mongoDBMock.UpdateImageCalls()[0].Image.Downloads["original"].Href, ShouldEqual, downloadServiceURL+"/images/"+testImageID1+"/original/1/some-image-name")
 				So(mongoDBMock.UpdateImageCalls()[0].Image.Downloads["png_w500"].State, ShouldEqual, models.StateDownloadPublished.String())
-				So(mongoDBMock.UpdateImageCalls()[0].Image.Downloads["png_w500"].Href, ShouldEqual, downloadServiceURL+"/images/"+testImageID1+"/png_w500/some-image-name")
+				So(mongoDBMock.UpdateImageCalls()[0].Image.Downloads["png_w500"].Href, ShouldEqual, downloadServiceURL+"/images/"+testImageID1+"/png_w500/1/some-image-name")
 				So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 1)
 				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
 
-				Convey("And the expected avro event is sent to the corresponding kafka output channel, with the expected source and dest paths", func() {
+				Convey("And the expected avro event is sent to the corresponding kafka output channel, with the expected source and dest paths, checksum and content type", func() {
 					// Note: the paths correspond to the path part of DownloadHrefFmt format "http://<host>/images/<imageID>/<variantName>/<fileName>"
 					expectedBytesOriginal, err := schema.ImagePublishedEvent.Marshal(&event.ImagePublished{
 						SrcPath:      expectedSrcPathOriginal,
 						DstPath:      expectedDstPathOriginal,
 						ImageID:      testImageID1,
 						ImageVariant: "original",
+						Sha256:       testSha256,
+						ContentType:  testContentType,
 					})
 					So(err, ShouldBeNil)
 					expectedBytesPngW500, err := schema.ImagePublishedEvent.Marshal(&event.ImagePublished{
@@ -2041,8 +2277,72 @@ func TestPublishImageHandler(t *testing.T) {
 				})
 			})
 
+			Convey("Calling 'publish image' with CloudEvents structured encoding configured results in CloudEvents messages sent to kafka producer", func() {
+				cfg.EventEncoding = string(event.EncodingCloudEventsStructured)
+				channels := &kafka.ProducerChannels{
+					Output: make(chan []byte),
+				}
+				publishedProducer := &kafkatest.IProducerMock{
+					ChannelsFunc: func() *kafka.ProducerChannels {
+						return channels
+					},
+					IsInitialisedFunc: func() bool { return true },
+				}
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, publishedProducer, kafkaStubProducer)
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/publish", testImageID1), http.NoBody)
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				r = r.WithContext(context.WithValue(r.Context(), handlers.CollectionID.Context(), testCollectionID1))
+				w := httptest.NewRecorder()
+				sentBytes := serveHTTPAndReadKafka(w, r, imageAPI, publishedProducer, 2)
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+				for _, b := range sentBytes {
+					ce, err := event.UnmarshalCloudEvent(b)
+					So(err, ShouldBeNil)
+					So(ce.Type, ShouldEqual, event.TypeImagePublished)
+					So(ce.Source, ShouldEqual, cfg.EventSource)
+					published := &event.ImagePublished{}
+					So(ce.DataAs(published), ShouldBeNil)
+					So(published.ImageID, ShouldEqual, testImageID1)
+				}
+			})
+
+			Convey("Calling 'publish image' with CloudEvents binary encoding configured results in the event data, with a distinct occurrence ID for each message, sent to kafka producer", func() {
+				cfg.EventEncoding = string(event.EncodingCloudEventsBinary)
+				channels := &kafka.ProducerChannels{
+					Output: make(chan []byte),
+				}
+				publishedProducer := &kafkatest.IProducerMock{
+					ChannelsFunc: func() *kafka.ProducerChannels {
+						return channels
+					},
+					IsInitialisedFunc: func() bool { return true },
+				}
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, publishedProducer, kafkaStubProducer)
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/publish", testImageID1), http.NoBody)
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				r = r.WithContext(context.WithValue(r.Context(), handlers.CollectionID.Context(), testCollectionID1))
+				w := httptest.NewRecorder()
+				sentBytes := serveHTTPAndReadKafka(w, r, imageAPI, publishedProducer, 2)
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+				ids := map[string]bool{}
+				for _, b := range sentBytes {
+					ce, err := event.DecodeBinary(&event.BinaryMessage{
+						Headers: event.BinaryHeaders(cfg.EventSource, event.TypeImagePublished),
+						Value:   b,
+					})
+					So(err, ShouldBeNil)
+					ids[ce.ID] = true
+					published := &event.ImagePublished{}
+					So(ce.DataAs(published), ShouldBeNil)
+					So(published.ImageID, ShouldEqual, testImageID1)
+				}
+				So(ids, ShouldHaveLength, 2)
+			})
+
 			Convey("Calling 'publish image' with a 500 InternalError response when an invalid image published event is generated", func() {
-				api.ImagePublishedEvent = func(path, filename, imageId, variant string) *event.ImagePublished {
+				imagePublishedEvent := api.ImagePublishedEvent
+				defer func() { api.ImagePublishedEvent = imagePublishedEvent }()
+				api.ImagePublishedEvent = func(path, filename, imageId, variant, sha256, contentType string) *event.ImagePublished {
 					return nil
 				}
 				channels := &kafka.ProducerChannels{
@@ -2052,8 +2352,9 @@ func TestPublishImageHandler(t *testing.T) {
 					ChannelsFunc: func() *kafka.ProducerChannels {
 						return channels
 					},
+					IsInitialisedFunc: func() bool { return true },
 				}
-				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, publishedProducer)
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, publishedProducer, kafkaStubProducer)
 				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/publish", testImageID1), http.NoBody)
 				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
 				r = r.WithContext(context.WithValue(r.Context(), handlers.CollectionID.Context(), testCollectionID1))
@@ -2080,9 +2381,14 @@ func TestPublishImageHandler(t *testing.T) {
 				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
 				UnlockImageFunc:      func(ctx context.Context, id string) {},
 			}
-			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 			Convey("Calling 'publish image' results in 500 response", func() {
+				imagePublishedEvent := api.ImagePublishedEvent
+				defer func() { api.ImagePublishedEvent = imagePublishedEvent }()
+				api.ImagePublishedEvent = func(path, filename, imageId, variant, sha256, contentType string) *event.ImagePublished {
+					return nil
+				}
 				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/publish", testImageID1), http.NoBody)
 				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
 				r = r.WithContext(context.WithValue(r.Context(), handlers.CollectionID.Context(), testCollectionID1))
@@ -2104,7 +2410,7 @@ func TestPublishImageHandler(t *testing.T) {
 				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
 				UnlockImageFunc:      func(ctx context.Context, id string) {},
 			}
-			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 			Convey("Calling 'publish image' results in 403 Forbidden response", func() {
 				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/publish", testImageID1), http.NoBody)
@@ -2126,7 +2432,7 @@ func TestPublishImageHandler(t *testing.T) {
 					return "", errors.New("mongoDB lock error")
 				},
 			}
-			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 			Convey("Calling 'publish image' results in 500 response", func() {
 				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/publish", testImageID1), http.NoBody)
@@ -2146,7 +2452,7 @@ func TestPublishImageHandler(t *testing.T) {
 				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
 				UnlockImageFunc:      func(ctx context.Context, id string) {},
 			}
-			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 			Convey("Calling 'publish image' results in 500 response", func() {
 				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/publish", testImageID1), http.NoBody)
@@ -2172,7 +2478,7 @@ func TestPublishImageHandler(t *testing.T) {
 				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
 				UnlockImageFunc:      func(ctx context.Context, id string) {},
 			}
-			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer)
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
 
 			Convey("Calling 'publish image' results in 500 response", func() {
 				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/publish", testImageID1), http.NoBody)
@@ -2190,9 +2496,879 @@ func TestPublishImageHandler(t *testing.T) {
 				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
 			})
 		})
+
+		Convey("And an image already in 'published' state in MongoDB, whose publish events could not all be sent", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+					image := dbImage(models.StatePublished)
+					image.Filename = "some-image-name"
+					image.Downloads = map[string]models.Download{
+						"original": {ID: "original", State: models.StateDownloadPublished.String()},
+						"png_w500": {ID: "png_w500", State: models.StateDownloadCompleted.String()},
+					}
+					return image, nil
+				},
+				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
+				UnlockImageFunc:      func(ctx context.Context, id string) {},
+			}
+
+			Convey("Calling 'publish image' again results in 204 NoContent response, re-sending the events of the variants that are still being published without updating the image", func() {
+				publishedProducer := newBufferedKafkaProducer()
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, publishedProducer, kafkaStubProducer)
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/publish", testImageID1), http.NoBody)
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				r = r.WithContext(context.WithValue(r.Context(), handlers.CollectionID.Context(), testCollectionID1))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+				So(mongoDBMock.UpdateImageCalls(), ShouldHaveLength, 0)
+				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
+
+				So(publishedProducer.Channels().Output, ShouldHaveLength, 1)
+				published := &event.ImagePublished{}
+				So(schema.ImagePublishedEvent.Unmarshal(<-publishedProducer.Channels().Output, published), ShouldBeNil)
+				So(published, ShouldResemble, &event.ImagePublished{
+					SrcPath:      fmt.Sprintf("images/%s/original", testImageID1),
+					DstPath:      fmt.Sprintf("images/%s/original/1/some-image-name", testImageID1),
+					ImageID:      testImageID1,
+					ImageVariant: "original",
+				})
+			})
+		})
+	})
+}
+
+func TestPublishImageHandlerWithCDN(t *testing.T) {
+	Convey("Given an image API publishing download variants behind a CDN, with slugified filenames", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		cfg.CDNHrefTemplate = "https://cdn.ons.example/{image_id}/{variant}/{version}/{filename}"
+		cfg.SlugifyFilenames = true
+		authHandlerMock := &mock.AuthHandlerMock{
+			RequireFunc: func(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+				return handler
+			},
+		}
+		mongoDBMock := &mock.MongoServerMock{
+			GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+				image := dbImage(models.StateImported)
+				image.Filename = "Some Image (Final).png"
+				image.Downloads = map[string]models.Download{
+					"original": {ID: "original", Sha256: testSha256, ContentType: testContentType},
+				}
+				return image, nil
+			},
+			UpdateImageFunc: func(ctx context.Context, id string, image *models.Image) (bool, error) {
+				return true, nil
+			},
+			AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
+			UnlockImageFunc:      func(ctx context.Context, id string) {},
+		}
+		channels := &kafka.ProducerChannels{
+			Output: make(chan []byte),
+		}
+		publishedProducer := &kafkatest.IProducerMock{
+			ChannelsFunc: func() *kafka.ProducerChannels {
+				return channels
+			},
+			IsInitialisedFunc: func() bool { return true },
+		}
+		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, publishedProducer, kafkaStubProducer)
+
+		Convey("Calling 'publish image' sets the CDN href of the variants, and publishes their files with the slugified filename", func() {
+			r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/publish", testImageID1), http.NoBody)
+			r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+			r = r.WithContext(context.WithValue(r.Context(), handlers.CollectionID.Context(), testCollectionID1))
+			w := httptest.NewRecorder()
+			sentBytes := serveHTTPAndReadKafka(w, r, imageAPI, publishedProducer, 1)
+			So(w.Code, ShouldEqual, http.StatusNoContent)
+			So(mongoDBMock.UpdateImageCalls(), ShouldHaveLength, 1)
+			So(mongoDBMock.UpdateImageCalls()[0].Image.Downloads["original"].Href, ShouldEqual, "https://cdn.ons.example/"+testImageID1+"/original/1/some-image-final.png")
+
+			expectedBytes, err := schema.ImagePublishedEvent.Marshal(&event.ImagePublished{
+				SrcPath:      fmt.Sprintf("images/%s/original", testImageID1),
+				DstPath:      fmt.Sprintf("images/%s/original/1/some-image-final.png", testImageID1),
+				ImageID:      testImageID1,
+				ImageVariant: "original",
+				Sha256:       testSha256,
+				ContentType:  testContentType,
+			})
+			So(err, ShouldBeNil)
+			validateExpectedBytes(sentBytes, [][]byte{expectedBytes})
+		})
+	})
+}
+
+func TestPublishImageHandlerScheduled(t *testing.T) {
+	Convey("Given a valid config, auth handler, kafka producer", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		authHandlerMock := &mock.AuthHandlerMock{
+			RequireFunc: func(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+				return handler
+			},
+		}
+
+		Convey("And an image in 'imported' state in MongoDB", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+					return dbImage(models.StateImported), nil
+				},
+				UpdateImageFunc: func(ctx context.Context, id string, image *models.Image) (bool, error) {
+					return true, nil
+				},
+				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
+				UnlockImageFunc:      func(ctx context.Context, id string) {},
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("Calling 'publish image' with a future publish_at results in 202 Accepted response and the scheduled publish is stored", func() {
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/publish", testImageID1), bytes.NewBufferString(
+					fmt.Sprintf(scheduledPublishPayloadFmt, testFuturePublishAt.Format(time.RFC3339))))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusAccepted)
+				So(w.Header().Get(contentTypeKey), ShouldEqual, contentTypeJSON)
+				So(mongoDBMock.UpdateImageCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UpdateImageCalls()[0].ID, ShouldEqual, testImageID1)
+				So(mongoDBMock.UpdateImageCalls()[0].Image.State, ShouldEqual, "")
+				So(*mongoDBMock.UpdateImageCalls()[0].Image.ScheduledPublish.PublishAt, ShouldEqual, testFuturePublishAt)
+				So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
+			})
+
+			Convey("Calling 'publish image' with a past publish_at results in 204 NoContent response and the image is published immediately", func() {
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/publish", testImageID1), bytes.NewBufferString(
+					fmt.Sprintf(scheduledPublishPayloadFmt, testPublishStarted.Format(time.RFC3339))))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+				So(mongoDBMock.UpdateImageCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UpdateImageCalls()[0].Image.State, ShouldEqual, models.StatePublished.String())
+			})
+
+			Convey("Calling 'publish image' with a malformed body results in 400 BadRequest response", func() {
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/publish", testImageID1), bytes.NewBufferString("{"))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(mongoDBMock.UpdateImageCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("And an image in 'created' state in MongoDB (non-publishable)", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+					return dbImage(models.StateCreated), nil
+				},
+				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
+				UnlockImageFunc:      func(ctx context.Context, id string) {},
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("Calling 'publish image' with a future publish_at results in 403 Forbidden response", func() {
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/publish", testImageID1), bytes.NewBufferString(
+					fmt.Sprintf(scheduledPublishPayloadFmt, testFuturePublishAt.Format(time.RFC3339))))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusForbidden)
+				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
+			})
+		})
+	})
+}
+
+func TestCancelScheduledPublishHandler(t *testing.T) {
+	Convey("Given a valid config, auth handler", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		authHandlerMock := &mock.AuthHandlerMock{
+			RequireFunc: func(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+				return handler
+			},
+		}
+
+		Convey("And an image with a scheduled publish in MongoDB", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+					image := dbImage(models.StateImported)
+					image.ScheduledPublish = &models.ScheduledPublish{PublishAt: &testFuturePublishAt}
+					return image, nil
+				},
+				UnsetScheduledPublishFunc: func(ctx context.Context, id string) error { return nil },
+				AcquireImageLockFunc:      func(ctx context.Context, id string) (string, error) { return testLockID, nil },
+				UnlockImageFunc:           func(ctx context.Context, id string) {},
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("Calling 'cancel scheduled publish' results in 204 NoContent response and the scheduled publish is removed", func() {
+				r := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("http://localhost:24700/images/%s/publish", testImageID1), http.NoBody)
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+				So(mongoDBMock.UnsetScheduledPublishCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UnsetScheduledPublishCalls()[0].ID, ShouldEqual, testImageID1)
+				So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("And an image without a scheduled publish in MongoDB", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+					return dbImage(models.StateImported), nil
+				},
+				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
+				UnlockImageFunc:      func(ctx context.Context, id string) {},
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("Calling 'cancel scheduled publish' results in 404 NotFound response", func() {
+				r := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("http://localhost:24700/images/%s/publish", testImageID1), http.NoBody)
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+				So(mongoDBMock.UnsetScheduledPublishCalls(), ShouldHaveLength, 0)
+				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
+			})
+		})
+	})
+}
+
+func TestWithdrawImageHandler(t *testing.T) {
+	Convey("Given a valid config, auth handler, kafka producer", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		authHandlerMock := &mock.AuthHandlerMock{
+			RequireFunc: func(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+				return handler
+			},
+		}
+
+		Convey("And an image in 'completed' state in MongoDB", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+					image := dbImage(models.StateCompleted)
+					image.Downloads = map[string]models.Download{
+						"original": {ID: "original", Href: downloadServiceURL + "/images/" + testImageID1 + "/original/some-image-name"},
+						"png_w500": {ID: "png_w500", Href: downloadServiceURL + "/images/" + testImageID1 + "/png_w500/some-image-name"},
+					}
+					return image, nil
+				},
+				WithdrawImageFunc: func(ctx context.Context, id string, image *models.Image) error { return nil },
+				WithdrawImageVersionsFunc: func(ctx context.Context, imageID string) ([]models.Version, error) {
+					image := dbImage(models.StateCompleted)
+					image.Downloads = map[string]models.Download{"original": {ID: "original"}, "png_w500": {ID: "png_w500"}}
+					return []models.Version{*image.NewVersion(testPublishCompleted)}, nil
+				},
+				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
+				UnlockImageFunc:      func(ctx context.Context, id string) {},
+			}
+
+			Convey("Calling 'withdraw image' results in 204 NoContent response with the expected withdrawal update to mongoDB and the messages sent to kafka producer", func() {
+				channels := &kafka.ProducerChannels{
+					Output: make(chan []byte),
+				}
+				withdrawnProducer := &kafkatest.IProducerMock{
+					ChannelsFunc: func() *kafka.ProducerChannels {
+						return channels
+					},
+					IsInitialisedFunc: func() bool { return true },
+				}
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, withdrawnProducer)
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/withdraw", testImageID1), bytes.NewBufferString(withdrawalPayload))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				sentBytes := serveHTTPAndReadKafka(w, r, imageAPI, withdrawnProducer, 2)
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+				So(mongoDBMock.WithdrawImageCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.WithdrawImageCalls()[0].ID, ShouldEqual, testImageID1)
+				imageUpdate := mongoDBMock.WithdrawImageCalls()[0].Image
+				So(imageUpdate.State, ShouldEqual, models.StateWithdrawn.String())
+				So(imageUpdate.Withdrawal.Reason, ShouldEqual, "published in error")
+				So(imageUpdate.Withdrawal.WithdrawnAt, ShouldNotBeNil)
+				So(imageUpdate.Downloads, ShouldHaveLength, 2)
+				So(imageUpdate.Downloads["original"].State, ShouldEqual, models.StateDownloadWithdrawn.String())
+				So(imageUpdate.Downloads["original"].Href, ShouldEqual, "")
+				So(imageUpdate.Downloads["png_w500"].State, ShouldEqual, models.StateDownloadWithdrawn.String())
+				So(imageUpdate.Downloads["png_w500"].Href, ShouldEqual, "")
+				So(mongoDBMock.WithdrawImageVersionsCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.WithdrawImageVersionsCalls()[0].ImageID, ShouldEqual, testImageID1)
+				So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
+
+				Convey("And the expected avro events are sent to the corresponding kafka output channel, once for the public path of each variant", func() {
+					expectedBytesOriginal, err := schema.ImageWithdrawnEvent.Marshal(&event.ImageWithdrawn{
+						Path:         fmt.Sprintf("images/%s/original/1/some-image-name", testImageID1),
+						ImageID:      testImageID1,
+						ImageVariant: "original",
+					})
+					So(err, ShouldBeNil)
+					expectedBytesPngW500, err := schema.ImageWithdrawnEvent.Marshal(&event.ImageWithdrawn{
+						Path:         fmt.Sprintf("images/%s/png_w500/1/some-image-name", testImageID1),
+						ImageID:      testImageID1,
+						ImageVariant: "png_w500",
+					})
+					So(err, ShouldBeNil)
+					validateExpectedBytes(sentBytes, [][]byte{expectedBytesOriginal, expectedBytesPngW500})
+				})
+			})
+
+			Convey("Calling 'withdraw image' when MongoDB fails to withdraw the versions of the image results in 500 InternalServerError response, without sending any message", func() {
+				mongoDBMock.WithdrawImageVersionsFunc = func(ctx context.Context, imageID string) ([]models.Version, error) { return nil, errMongoDB }
+				withdrawnProducer := newBufferedKafkaProducer()
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, withdrawnProducer)
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/withdraw", testImageID1), bytes.NewBufferString(withdrawalPayload))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+				So(mongoDBMock.WithdrawImageCalls(), ShouldHaveLength, 1)
+				So(withdrawnProducer.Channels().Output, ShouldHaveLength, 0)
+				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
+			})
+
+			Convey("Calling 'withdraw image' without a reason results in 400 BadRequest response", func() {
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/withdraw", testImageID1), bytes.NewBufferString(`{"reason": " "}`))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 0)
+				So(mongoDBMock.WithdrawImageCalls(), ShouldHaveLength, 0)
+			})
+
+			Convey("Calling 'withdraw image' with a malformed body results in 400 BadRequest response", func() {
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/withdraw", testImageID1), bytes.NewBufferString("{"))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(mongoDBMock.WithdrawImageCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("And revision 2 of an image in 'completed' state in MongoDB, with a version for each revision", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+					image := dbImageWithRevision(models.StateCompleted)
+					image.Downloads = map[string]models.Download{"original": {ID: "original", State: models.StateDownloadCompleted.String()}}
+					return image, nil
+				},
+				WithdrawImageFunc: func(ctx context.Context, id string, image *models.Image) error { return nil },
+				WithdrawImageVersionsFunc: func(ctx context.Context, imageID string) ([]models.Version, error) {
+					return []models.Version{
+						{ImageID: imageID, Version: 1, Filename: "old-name", Downloads: map[string]models.Download{"original": {ID: "original"}}},
+						{ImageID: imageID, Version: 2, Filename: "some-image-name", Downloads: map[string]models.Download{"original": {ID: "original"}}},
+					}, nil
+				},
+				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
+				UnlockImageFunc:      func(ctx context.Context, id string) {},
+			}
+
+			Convey("Calling 'withdraw image' sends an 'image withdrawn' message for the files of every version", func() {
+				withdrawnProducer := newBufferedKafkaProducer()
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, withdrawnProducer)
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/withdraw", testImageID1), bytes.NewBufferString(withdrawalPayload))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+				So(mongoDBMock.WithdrawImageVersionsCalls(), ShouldHaveLength, 1)
+
+				paths := []string{}
+				for len(withdrawnProducer.Channels().Output) > 0 {
+					withdrawn := &event.ImageWithdrawn{}
+					So(schema.ImageWithdrawnEvent.Unmarshal(<-withdrawnProducer.Channels().Output, withdrawn), ShouldBeNil)
+					paths = append(paths, withdrawn.Path)
+				}
+				So(paths, ShouldResemble, []string{
+					fmt.Sprintf("images/%s/original/2/some-image-name", testImageID1),
+					fmt.Sprintf("images/%s/original/1/old-name", testImageID1),
+				})
+			})
+		})
+
+		Convey("And an image already in 'withdrawn' state in MongoDB, whose withdrawn events could not all be sent", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+					image := dbImage(models.StateWithdrawn)
+					image.Downloads = map[string]models.Download{
+						"original": {ID: "original", State: models.StateDownloadWithdrawn.String()},
+					}
+					return image, nil
+				},
+				WithdrawImageVersionsFunc: func(ctx context.Context, imageID string) ([]models.Version, error) { return nil, nil },
+				AcquireImageLockFunc:      func(ctx context.Context, id string) (string, error) { return testLockID, nil },
+				UnlockImageFunc:           func(ctx context.Context, id string) {},
+			}
+
+			Convey("Calling 'withdraw image' again results in 204 NoContent response, withdrawing the versions and re-sending the events without updating the withdrawal", func() {
+				withdrawnProducer := newBufferedKafkaProducer()
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, withdrawnProducer)
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/withdraw", testImageID1), bytes.NewBufferString(withdrawalPayload))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+				So(mongoDBMock.WithdrawImageCalls(), ShouldHaveLength, 0)
+				So(mongoDBMock.WithdrawImageVersionsCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
+
+				So(withdrawnProducer.Channels().Output, ShouldHaveLength, 1)
+				withdrawn := &event.ImageWithdrawn{}
+				So(schema.ImageWithdrawnEvent.Unmarshal(<-withdrawnProducer.Channels().Output, withdrawn), ShouldBeNil)
+				So(withdrawn.ImageVariant, ShouldEqual, "original")
+			})
+		})
+
+		Convey("And an image in 'imported' state in MongoDB (not published)", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+					return dbImage(models.StateImported), nil
+				},
+				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
+				UnlockImageFunc:      func(ctx context.Context, id string) {},
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("Calling 'withdraw image' results in 403 Forbidden response", func() {
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/withdraw", testImageID1), bytes.NewBufferString(withdrawalPayload))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusForbidden)
+				So(mongoDBMock.WithdrawImageCalls(), ShouldHaveLength, 0)
+				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("And an image that does not exist in MongoDB", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+					return nil, apierrors.ErrImageNotFound
+				},
+				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
+				UnlockImageFunc:      func(ctx context.Context, id string) {},
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("Calling 'withdraw image' results in 404 NotFound response", func() {
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/withdraw", testImageID1), bytes.NewBufferString(withdrawalPayload))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+				So(mongoDBMock.WithdrawImageCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+}
+
+func TestCreateRevisionHandler(t *testing.T) {
+	Convey("Given a valid config, auth handler", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		authHandlerMock := &mock.AuthHandlerMock{
+			RequireFunc: func(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+				return handler
+			},
+		}
+
+		Convey("And an image in 'completed' state in MongoDB", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+					return dbFullImageWithDownloads(models.StateCompleted, dbDownload(models.StateDownloadCompleted)), nil
+				},
+				StartImageRevisionFunc: func(ctx context.Context, id string, archived *models.Revision) error { return nil },
+				AcquireImageLockFunc:   func(ctx context.Context, id string) (string, error) { return testLockID, nil },
+				UnlockImageFunc:        func(ctx context.Context, id string) {},
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("Calling 'create revision' results in 201 Created response with the new revision in 'created' state, and the current revision is archived", func() {
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/revisions", testImageID2), http.NoBody)
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusCreated)
+				So(w.Header().Get(contentTypeKey), ShouldEqual, contentTypeJSON)
+				So(mongoDBMock.StartImageRevisionCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.StartImageRevisionCalls()[0].ID, ShouldEqual, testImageID2)
+				archived := mongoDBMock.StartImageRevisionCalls()[0].Archived
+				So(archived.Revision, ShouldEqual, 1)
+				So(archived.State, ShouldEqual, models.StateCompleted.String())
+				So(archived.Upload, ShouldResemble, &models.Upload{Path: testUploadPath})
+				So(archived.Downloads, ShouldHaveLength, 1)
+				So(archived.Downloads[testVariantOriginal].State, ShouldEqual, models.StateDownloadCompleted.String())
+				So(archived.ArchivedAt, ShouldNotBeNil)
+				So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
+
+				payload, err := io.ReadAll(w.Body)
+				So(err, ShouldBeNil)
+				retImage := models.Image{}
+				err = json.Unmarshal(payload, &retImage)
+				So(err, ShouldBeNil)
+				So(retImage.ID, ShouldEqual, testImageID2)
+				So(retImage.State, ShouldEqual, models.StateCreated.String())
+				So(retImage.Revision, ShouldEqual, 2)
+				So(retImage.Upload, ShouldBeNil)
+				So(retImage.Links, ShouldResemble, dbFullImage(models.StateCompleted).Links)
+			})
+		})
+
+		Convey("And an image in 'imported' state in MongoDB (not completed)", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+					return dbImage(models.StateImported), nil
+				},
+				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
+				UnlockImageFunc:      func(ctx context.Context, id string) {},
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("Calling 'create revision' results in 403 Forbidden response", func() {
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/revisions", testImageID1), http.NoBody)
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusForbidden)
+				So(mongoDBMock.StartImageRevisionCalls(), ShouldHaveLength, 0)
+				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("And an image that does not exist in MongoDB", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+					return nil, apierrors.ErrImageNotFound
+				},
+				AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
+				UnlockImageFunc:      func(ctx context.Context, id string) {},
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("Calling 'create revision' results in 404 NotFound response", func() {
+				r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/revisions", testImageID1), http.NoBody)
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+				So(mongoDBMock.StartImageRevisionCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+}
+
+func TestGetRevisionsHandler(t *testing.T) {
+	Convey("Given a valid config, auth handler", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		authHandlerMock := &mock.AuthHandlerMock{
+			RequireFunc: func(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+				return handler
+			},
+		}
+
+		Convey("And an image with a previous revision in MongoDB", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+					return dbImageWithRevision(models.StateImporting), nil
+				},
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("Calling 'get revisions' results in 200 OK response with the expected revisions", func() {
+				r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:24700/images/%s/revisions", testImageID1), http.NoBody)
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get(contentTypeKey), ShouldEqual, contentTypeJSON)
+				payload, err := io.ReadAll(w.Body)
+				So(err, ShouldBeNil)
+				retRevisions := models.Revisions{}
+				err = json.Unmarshal(payload, &retRevisions)
+				So(err, ShouldBeNil)
+				So(retRevisions.Count, ShouldEqual, 1)
+				So(retRevisions.TotalCount, ShouldEqual, 1)
+				So(retRevisions.Items, ShouldHaveLength, 1)
+				So(retRevisions.Items[0].Revision, ShouldEqual, 1)
+				So(retRevisions.Items[0].State, ShouldEqual, models.StateCompleted.String())
+				So(retRevisions.Items[0].Downloads[testVariantOriginal].Href, ShouldEqual, testDownloadHref)
+			})
+		})
+
+		Convey("And an image without previous revisions in MongoDB", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+					return dbImage(models.StateCompleted), nil
+				},
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("Calling 'get revisions' results in 200 OK response with an empty list of revisions", func() {
+				r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:24700/images/%s/revisions", testImageID1), http.NoBody)
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusOK)
+				payload, err := io.ReadAll(w.Body)
+				So(err, ShouldBeNil)
+				retRevisions := models.Revisions{}
+				err = json.Unmarshal(payload, &retRevisions)
+				So(err, ShouldBeNil)
+				So(retRevisions.Count, ShouldEqual, 0)
+				So(retRevisions.Items, ShouldResemble, []models.Revision{})
+			})
+		})
 	})
 }
 
+func TestGetImageLiveRevision(t *testing.T) {
+	Convey("Given an image with a new revision being imported, and a previous completed revision in MongoDB", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		mongoDBMock := &mock.MongoServerMock{
+			GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+				return dbImageWithRevision(models.StateImporting), nil
+			},
+		}
+
+		Convey("When the image downloads are requested to an image API in web mode", func() {
+			cfg.IsPublishing = false
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, &mock.AuthHandlerMock{}, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+			r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:24700/images/%s/downloads/%s", testImageID1, testVariantOriginal), http.NoBody)
+			w := httptest.NewRecorder()
+			imageAPI.Router.ServeHTTP(w, r)
+
+			Convey("Then the download of the previous revision is served", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				payload, err := io.ReadAll(w.Body)
+				So(err, ShouldBeNil)
+				retDownload := models.Download{}
+				err = json.Unmarshal(payload, &retDownload)
+				So(err, ShouldBeNil)
+				So(retDownload.State, ShouldEqual, models.StateDownloadCompleted.String())
+				So(retDownload.Href, ShouldEqual, testDownloadHref)
+			})
+		})
+
+		Convey("When the image is requested to an image API in publishing mode", func() {
+			cfg.IsPublishing = true
+			authHandlerMock := &mock.AuthHandlerMock{
+				RequireFunc: func(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+					return handler
+				},
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+			r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:24700/images/%s", testImageID1), http.NoBody)
+			r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+			w := httptest.NewRecorder()
+			imageAPI.Router.ServeHTTP(w, r)
+
+			Convey("Then the new revision being imported is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				payload, err := io.ReadAll(w.Body)
+				So(err, ShouldBeNil)
+				retImage := models.Image{}
+				err = json.Unmarshal(payload, &retImage)
+				So(err, ShouldBeNil)
+				So(retImage.State, ShouldEqual, models.StateImporting.String())
+				So(retImage.Revision, ShouldEqual, 2)
+			})
+		})
+	})
+}
+
+func TestGetVersionHandler(t *testing.T) {
+	Convey("Given an image with a completed version in MongoDB", t, func() {
+		authHandlerMock := &mock.AuthHandlerMock{
+			RequireFunc: func(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+				return handler
+			},
+		}
+		dbVersion := dbFullImageWithDownloads(models.StateCompleted, dbDownload(models.StateDownloadCompleted)).NewVersion(testPublishCompleted)
+		mongoDBMock := &mock.MongoServerMock{
+			GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+				return dbFullImageWithDownloads(models.StateCompleted, dbDownload(models.StateDownloadCompleted)), nil
+			},
+			GetImageVersionFunc: func(ctx context.Context, imageID string, version int) (*models.Version, error) {
+				if imageID == testImageID2 && version == 1 {
+					return dbVersion, nil
+				}
+				return nil, apierrors.ErrImageVersionNotFound
+			},
+		}
+		imageAPI := GetAPIWithMocks(&config.Config{IsPublishing: false}, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+		Convey("Calling 'get version' for an existing version results in 200 OK response with the expected version", func() {
+			r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:24700/images/%s/versions/1", testImageID2), http.NoBody)
+			w := httptest.NewRecorder()
+			imageAPI.Router.ServeHTTP(w, r)
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(mongoDBMock.GetImageVersionCalls(), ShouldHaveLength, 1)
+			So(mongoDBMock.GetImageVersionCalls()[0].ImageID, ShouldEqual, testImageID2)
+			So(mongoDBMock.GetImageVersionCalls()[0].Version, ShouldEqual, 1)
+			payload, err := io.ReadAll(w.Body)
+			So(err, ShouldBeNil)
+			retVersion := &models.Version{}
+			err = json.Unmarshal(payload, retVersion)
+			So(err, ShouldBeNil)
+			So(retVersion.ImageID, ShouldEqual, testImageID2)
+			So(retVersion.Version, ShouldEqual, 1)
+			So(retVersion.Downloads, ShouldHaveLength, 1)
+		})
+
+		Convey("Calling 'get version download' for an existing version variant results in 200 OK response", func() {
+			r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:24700/images/%s/versions/1/downloads/%s", testImageID2, testVariantOriginal), http.NoBody)
+			w := httptest.NewRecorder()
+			imageAPI.Router.ServeHTTP(w, r)
+			So(w.Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("Calling 'get version download' for a variant that does not exist results in 404 Not Found response", func() {
+			r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:24700/images/%s/versions/1/downloads/%s", testImageID2, testVariantAlternative), http.NoBody)
+			w := httptest.NewRecorder()
+			imageAPI.Router.ServeHTTP(w, r)
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("Calling 'get version' for a version that does not exist results in 404 Not Found response", func() {
+			r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:24700/images/%s/versions/2", testImageID2), http.NoBody)
+			w := httptest.NewRecorder()
+			imageAPI.Router.ServeHTTP(w, r)
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("Calling 'get version' with an invalid version number results in 400 Bad Request response, without querying MongoDB", func() {
+			for _, version := range []string{"0", "-1", "latest"} {
+				r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:24700/images/%s/versions/%s", testImageID2, version), http.NoBody)
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			}
+			So(mongoDBMock.GetImageCalls(), ShouldHaveLength, 0)
+			So(mongoDBMock.GetImageVersionCalls(), ShouldHaveLength, 0)
+		})
+	})
+}
+
+func TestImageStateChangedEvents(t *testing.T) {
+	api.NewID = func() string { return testImageID1 }
+
+	Convey("Given an image API in publishing mode with a kafka producer for image state changed events", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		authHandlerMock := &mock.AuthHandlerMock{
+			RequireFunc: func(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+				return handler
+			},
+		}
+		stateChangedProducer := newBufferedKafkaProducer()
+
+		Convey("When a new image is created, then an event from no state to 'created' is sent", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				UpsertImageFunc: func(ctx context.Context, id string, image *models.Image) error { return nil },
+			}
+			imageAPI := GetAPIWithStateChangedProducer(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer, stateChangedProducer)
+			r := httptest.NewRequest(http.MethodPost, "http://localhost:24700/images", bytes.NewBufferString(
+				fmt.Sprintf(newImagePayloadFmt, testCollectionID1, "some-image-name")))
+			w := httptest.NewRecorder()
+			imageAPI.Router.ServeHTTP(w, r)
+			So(w.Code, ShouldEqual, http.StatusCreated)
+			So(readStateChangedEvents(stateChangedProducer), ShouldResemble, []*event.ImageStateChanged{
+				{ImageID: testImageID1, CollectionID: testCollectionID1, State: models.StateCreated.String()},
+			})
+		})
+
+		Convey("When the last download variant of a published image is completed, then the image and variant state changes are sent", func() {
+			mongoDBMock, _ := newImageStoreMock(dbFullImageWithDownloads(models.StatePublished, dbDownloadWithID(testImageID2, testVariantOriginal, models.StateDownloadPublished)))
+			imageAPI := GetAPIWithStateChangedProducer(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer, stateChangedProducer)
+			r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s/downloads/%s", testImageID2, testVariantOriginal), bytes.NewBufferString(
+				fmt.Sprintf(updateImageDownloadCompletedPayloadFmt, testVariantOriginal, testDownloadType, models.StateDownloadCompleted.String())))
+			w := httptest.NewRecorder()
+			imageAPI.Router.ServeHTTP(w, r)
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(readStateChangedEvents(stateChangedProducer), ShouldResemble, []*event.ImageStateChanged{
+				{ImageID: testImageID2, CollectionID: testCollectionID1, PreviousState: models.StatePublished.String(), State: models.StateCompleted.String()},
+				{ImageID: testImageID2, CollectionID: testCollectionID1, ImageVariant: testVariantOriginal, PreviousState: models.StateDownloadPublished.String(), State: models.StateDownloadCompleted.String()},
+			})
+		})
+
+		Convey("When a download variant is updated without changing the image state, then only the variant state change is sent", func() {
+			mongoDBMock, _ := newImageStoreMock(dbFullImageWithDownloads(
+				models.StatePublished,
+				dbDownloadWithID(testImageID2, testVariantOriginal, models.StateDownloadPublished),
+				dbDownloadWithID(testImageID2, testVariantAlternative, models.StateDownloadPublished)))
+			imageAPI := GetAPIWithStateChangedProducer(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer, stateChangedProducer)
+			r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:24700/images/%s/downloads/%s", testImageID2, testVariantOriginal), bytes.NewBufferString(
+				fmt.Sprintf(updateImageDownloadCompletedPayloadFmt, testVariantOriginal, testDownloadType, models.StateDownloadCompleted.String())))
+			w := httptest.NewRecorder()
+			imageAPI.Router.ServeHTTP(w, r)
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(readStateChangedEvents(stateChangedProducer), ShouldResemble, []*event.ImageStateChanged{
+				{ImageID: testImageID2, CollectionID: testCollectionID1, ImageVariant: testVariantOriginal, PreviousState: models.StateDownloadPublished.String(), State: models.StateDownloadCompleted.String()},
+			})
+		})
+
+		Convey("When a completed image is withdrawn, then the image and variant state changes are sent", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+					return dbFullImageWithDownloads(models.StateCompleted, dbDownloadWithID(id, testVariantOriginal, models.StateDownloadCompleted)), nil
+				},
+				WithdrawImageFunc:         func(ctx context.Context, id string, image *models.Image) error { return nil },
+				WithdrawImageVersionsFunc: func(ctx context.Context, imageID string) ([]models.Version, error) { return nil, nil },
+				AcquireImageLockFunc:      func(ctx context.Context, id string) (string, error) { return testLockID, nil },
+				UnlockImageFunc:           func(ctx context.Context, id string) {},
+			}
+			imageAPI := GetAPIWithStateChangedProducer(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, newBufferedKafkaProducer(), stateChangedProducer)
+			r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/withdraw", testImageID2), bytes.NewBufferString(withdrawalPayload))
+			w := httptest.NewRecorder()
+			imageAPI.Router.ServeHTTP(w, r)
+			So(w.Code, ShouldEqual, http.StatusNoContent)
+			So(readStateChangedEvents(stateChangedProducer), ShouldResemble, []*event.ImageStateChanged{
+				{ImageID: testImageID2, CollectionID: testCollectionID1, PreviousState: models.StateCompleted.String(), State: models.StateWithdrawn.String()},
+				{ImageID: testImageID2, CollectionID: testCollectionID1, ImageVariant: testVariantOriginal, PreviousState: models.StateDownloadCompleted.String(), State: models.StateDownloadWithdrawn.String()},
+			})
+		})
+	})
+}
+
+// newBufferedKafkaProducer returns a kafka producer mock with a buffered output channel, so that messages can be read after the request is served
+func newBufferedKafkaProducer() *kafkatest.IProducerMock {
+	channels := &kafka.ProducerChannels{
+		Output: make(chan []byte, 10),
+	}
+	return &kafkatest.IProducerMock{
+		ChannelsFunc: func() *kafka.ProducerChannels {
+			return channels
+		},
+		IsInitialisedFunc: func() bool { return true },
+	}
+}
+
+// readStateChangedEvents reads and unmarshals all the image state changed events that have been sent to the provided buffered kafka producer
+func readStateChangedEvents(kafkaProducerMock kafka.IProducer) (events []*event.ImageStateChanged) {
+	output := kafkaProducerMock.Channels().Output
+	for len(output) > 0 {
+		e := &event.ImageStateChanged{}
+		err := schema.ImageStateChangedEvent.Unmarshal(<-output, e)
+		So(err, ShouldBeNil)
+		events = append(events, e)
+	}
+	return events
+}
+
 // serveHTTPAndReadKafka performs the ServeHTTP with the provided responseRecorder and Request in a parallel go-routine, then reads the bytes
 // from the kafka output channel for the provided number of messages, and waits for the ServeHTTP routine to finish.
 // The bytes sent to kafka output channel are returned in an array corresponding to each call.
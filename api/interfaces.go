@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"net/http"
+	"time"
 
 	dpauth "github.com/ONSdigital/dp-authorisation/auth"
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
@@ -17,11 +18,39 @@ type MongoServer interface {
 	Close(ctx context.Context) error
 	Checker(ctx context.Context, state *healthcheck.CheckState) (err error)
 	GetImages(ctx context.Context, collectionID string) (images []models.Image, err error)
+	IterateImages(ctx context.Context, collectionID string, fn func(image *models.Image, decodeErr error) error) (err error)
 	GetImage(ctx context.Context, id string) (image *models.Image, err error)
 	UpdateImage(ctx context.Context, id string, image *models.Image) (didChange bool, err error)
 	UpsertImage(ctx context.Context, id string, image *models.Image) (err error)
+	DeleteImage(ctx context.Context, id string) (err error)
+	CreateDownload(ctx context.Context, id, imageState string, download *models.Download) (created bool, err error)
+	UpdateDownload(ctx context.Context, id, imageState, variantState string, download *models.Download) (updated bool, err error)
+	UpdateImageState(ctx context.Context, previousState string, image *models.Image) (updated bool, err error)
 	AcquireImageLock(ctx context.Context, id string) (lockID string, err error)
 	UnlockImage(ctx context.Context, lockID string)
+	GetImageLocks(ctx context.Context) (locks []models.ImageLock, err error)
+	GetImagesScheduledForPublish(ctx context.Context, before time.Time) (images []models.Image, err error)
+	GetImagesForReplay(ctx context.Context, req *models.ReplayRequest) (images []models.Image, err error)
+	UnsetScheduledPublish(ctx context.Context, id string) (err error)
+	WithdrawImage(ctx context.Context, id string, image *models.Image) (err error)
+	StartImageRevision(ctx context.Context, id string, archived *models.Revision) (err error)
+	CreateImageVersion(ctx context.Context, version *models.Version) (err error)
+	GetImageVersion(ctx context.Context, imageID string, version int) (imageVersion *models.Version, err error)
+	WithdrawImageVersions(ctx context.Context, imageID string) (versions []models.Version, err error)
+	AcquireSchedulerLock(ctx context.Context) (lockID string, err error)
+	RenewSchedulerLock(ctx context.Context, lockID string) (err error)
+	UnlockScheduler(ctx context.Context, lockID string)
+	AcquireDeadLetterRetrierLock(ctx context.Context) (lockID string, err error)
+	UnlockDeadLetterRetrier(ctx context.Context, lockID string)
+	CreateDeadLetter(ctx context.Context, deadLetter *models.DeadLetter) (err error)
+	GetDeadLetters(ctx context.Context) (deadLetters []models.DeadLetter, err error)
+	GetDeadLetter(ctx context.Context, id string) (deadLetter *models.DeadLetter, err error)
+	GetDeadLettersDueForRetry(ctx context.Context, before time.Time, maxAttempts int) (deadLetters []models.DeadLetter, err error)
+	UpdateDeadLetterAttempt(ctx context.Context, deadLetter *models.DeadLetter) (err error)
+	DeleteDeadLetter(ctx context.Context, id string) (err error)
+	Migrate(ctx context.Context, dryRun bool, lockTimeout time.Duration) (result *models.MigrationResult, err error)
+	EnsureIndexes(ctx context.Context, deletedImageTTL time.Duration) (err error)
+	IndexChecker(ctx context.Context, state *healthcheck.CheckState) (err error)
 }
 
 // AuthHandler interface for adding auth to endpoints
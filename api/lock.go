@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/ONSdigital/dp-image-api/models"
+	dpreq "github.com/ONSdigital/dp-net/v3/request"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// GetImageLocksHandler is a handler that returns the image locks that are currently held, with the request and host holding them
+func (api *API) GetImageLocksHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	logdata := log.Data{
+		"request-id": ctx.Value(dpreq.RequestIdKey),
+	}
+
+	items, err := api.mongoDB.GetImageLocks(ctx)
+	if err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+	if items == nil {
+		items = []models.ImageLock{}
+	}
+	locks := models.ImageLocks{
+		Items: items,
+		Count: len(items),
+	}
+
+	if err := WriteJSONBody(locks, w, http.StatusOK); err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+	logdata["count"] = locks.Count
+	log.Info(ctx, "successfully retrieved image locks", logdata)
+}
@@ -0,0 +1,134 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	dpauth "github.com/ONSdigital/dp-authorisation/auth"
+	"github.com/ONSdigital/dp-image-api/api/mock"
+	"github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/dp-image-api/config"
+	"github.com/ONSdigital/dp-image-api/models"
+	"github.com/ONSdigital/dp-net/v3/handlers"
+	dpreq "github.com/ONSdigital/dp-net/v3/request"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var testLockCreatedAt = time.Date(2020, time.April, 26, 8, 5, 52, 0, time.UTC)
+
+func TestGetImageLocksHandler(t *testing.T) {
+	Convey("Given a valid config and auth handler", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		authHandlerMock := &mock.AuthHandlerMock{
+			RequireFunc: func(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+				return handler
+			},
+		}
+
+		Convey("And an image lock held in MongoDB", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetImageLocksFunc: func(ctx context.Context) ([]models.ImageLock, error) {
+					return []models.ImageLock{
+						{ImageID: testImageID1, LockID: testLockID, Owner: "request-1", Host: "host-1", CreatedAt: testLockCreatedAt, HeldFor: "1.5s"},
+					}, nil
+				},
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("Calling 'get image locks' results in 200 OK response with the held lock, its owner and host", func() {
+				r := httptest.NewRequest(http.MethodGet, "http://localhost:24700/admin/locks", http.NoBody)
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get(contentTypeKey), ShouldEqual, contentTypeJSON)
+				payload, err := io.ReadAll(w.Body)
+				So(err, ShouldBeNil)
+				So(string(payload), ShouldEqual, `{"count":1,"items":[{"image_id":"imageImageID1","lock_id":"image-myID-123456789",`+
+					`"owner":"request-1","host":"host-1","created_at":"2020-04-26T08:05:52Z","held_for":"1.5s"}]}`)
+			})
+		})
+
+		Convey("And no image locks held in MongoDB", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetImageLocksFunc: func(ctx context.Context) ([]models.ImageLock, error) {
+					return nil, nil
+				},
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("Calling 'get image locks' results in 200 OK response with an empty list", func() {
+				r := httptest.NewRequest(http.MethodGet, "http://localhost:24700/admin/locks", http.NoBody)
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusOK)
+				payload, err := io.ReadAll(w.Body)
+				So(err, ShouldBeNil)
+				So(string(payload), ShouldEqual, `{"count":0,"items":[]}`)
+			})
+		})
+
+		Convey("And a MongoDB that fails to return the image locks", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetImageLocksFunc: func(ctx context.Context) ([]models.ImageLock, error) {
+					return nil, errMongoDB
+				},
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+			Convey("Calling 'get image locks' results in 500 InternalServerError response", func() {
+				r := httptest.NewRequest(http.MethodGet, "http://localhost:24700/admin/locks", http.NoBody)
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+	})
+}
+
+func TestImageLockTimeout(t *testing.T) {
+	Convey("Given a valid config, auth handler, and an image that is locked by another request until the lock timeout", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		authHandlerMock := &mock.AuthHandlerMock{
+			RequireFunc: func(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+				return handler
+			},
+		}
+		var lockDeadline time.Time
+		mongoDBMock := &mock.MongoServerMock{
+			GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+				return dbFullImageWithDownloads(models.StateCompleted, dbDownloadWithID(id, testVariantOriginal, models.StateDownloadCompleted)), nil
+			},
+			AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) {
+				lockDeadline, _ = ctx.Deadline()
+				return "", apierrors.ErrImageLockTimeout
+			},
+		}
+		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+		Convey("Calling 'withdraw image' results in 503 ServiceUnavailable response with a Retry-After header, and nothing is updated", func() {
+			r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:24700/images/%s/withdraw", testImageID2), bytes.NewBufferString(withdrawalPayload))
+			r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+			r = r.WithContext(context.WithValue(r.Context(), handlers.CollectionID.Context(), testCollectionID1))
+			w := httptest.NewRecorder()
+			start := time.Now()
+			imageAPI.Router.ServeHTTP(w, r)
+			So(w.Code, ShouldEqual, http.StatusServiceUnavailable)
+			So(w.Header().Get("Retry-After"), ShouldEqual, "1")
+			So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 1)
+			So(lockDeadline, ShouldHappenWithin, cfg.ImageLockTimeout, start.Add(cfg.ImageLockTimeout))
+			So(mongoDBMock.WithdrawImageCalls(), ShouldHaveLength, 0)
+			So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 0)
+		})
+	})
+}
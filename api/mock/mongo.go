@@ -9,17 +9,46 @@ import (
 	"github.com/ONSdigital/dp-image-api/api"
 	"github.com/ONSdigital/dp-image-api/models"
 	"sync"
+	"time"
 )
 
 var (
-	lockMongoServerMockAcquireImageLock sync.RWMutex
-	lockMongoServerMockChecker          sync.RWMutex
-	lockMongoServerMockClose            sync.RWMutex
-	lockMongoServerMockGetImage         sync.RWMutex
-	lockMongoServerMockGetImages        sync.RWMutex
-	lockMongoServerMockUnlockImage      sync.RWMutex
-	lockMongoServerMockUpdateImage      sync.RWMutex
-	lockMongoServerMockUpsertImage      sync.RWMutex
+	lockMongoServerMockAcquireDeadLetterRetrierLock sync.RWMutex
+	lockMongoServerMockAcquireImageLock             sync.RWMutex
+	lockMongoServerMockAcquireSchedulerLock         sync.RWMutex
+	lockMongoServerMockChecker                      sync.RWMutex
+	lockMongoServerMockClose                        sync.RWMutex
+	lockMongoServerMockCreateDeadLetter             sync.RWMutex
+	lockMongoServerMockCreateDownload               sync.RWMutex
+	lockMongoServerMockCreateImageVersion           sync.RWMutex
+	lockMongoServerMockDeleteDeadLetter             sync.RWMutex
+	lockMongoServerMockDeleteImage                  sync.RWMutex
+	lockMongoServerMockEnsureIndexes                sync.RWMutex
+	lockMongoServerMockGetDeadLetter                sync.RWMutex
+	lockMongoServerMockGetDeadLetters               sync.RWMutex
+	lockMongoServerMockGetDeadLettersDueForRetry    sync.RWMutex
+	lockMongoServerMockGetImage                     sync.RWMutex
+	lockMongoServerMockGetImageLocks                sync.RWMutex
+	lockMongoServerMockGetImageVersion              sync.RWMutex
+	lockMongoServerMockGetImages                    sync.RWMutex
+	lockMongoServerMockGetImagesForReplay           sync.RWMutex
+	lockMongoServerMockGetImagesScheduledForPublish sync.RWMutex
+	lockMongoServerMockIndexChecker                 sync.RWMutex
+	lockMongoServerMockIterateImages                sync.RWMutex
+	lockMongoServerMockMigrate                      sync.RWMutex
+	lockMongoServerMockRenewSchedulerLock           sync.RWMutex
+	lockMongoServerMockStartImageRevision           sync.RWMutex
+	lockMongoServerMockUnlockDeadLetterRetrier      sync.RWMutex
+	lockMongoServerMockUnlockImage                  sync.RWMutex
+	lockMongoServerMockUnlockScheduler              sync.RWMutex
+	lockMongoServerMockUnsetScheduledPublish        sync.RWMutex
+	lockMongoServerMockUpdateDeadLetterAttempt      sync.RWMutex
+	lockMongoServerMockUpdateDownload               sync.RWMutex
+	lockMongoServerMockUpdateImage                  sync.RWMutex
+	lockMongoServerMockUpdateImageState             sync.RWMutex
+	lockMongoServerMockUpsertImage                  sync.RWMutex
+	lockMongoServerMockWithdrawImage                sync.RWMutex
+	lockMongoServerMockWithdrawImageVersions        sync.RWMutex
 )
 
 // Ensure, that MongoServerMock does implement api.MongoServer.
@@ -32,30 +61,114 @@ var _ api.MongoServer = &MongoServerMock{}
 //
 //         // make and configure a mocked api.MongoServer
 //         mockedMongoServer := &MongoServerMock{
+//             AcquireDeadLetterRetrierLockFunc: func(ctx context.Context) (string, error) {
+// 	               panic("mock out the AcquireDeadLetterRetrierLock method")
+//             },
 //             AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) {
 // 	               panic("mock out the AcquireImageLock method")
 //             },
+//             AcquireSchedulerLockFunc: func(ctx context.Context) (string, error) {
+// 	               panic("mock out the AcquireSchedulerLock method")
+//             },
 //             CheckerFunc: func(ctx context.Context, state *healthcheck.CheckState) error {
 // 	               panic("mock out the Checker method")
 //             },
 //             CloseFunc: func(ctx context.Context) error {
 // 	               panic("mock out the Close method")
 //             },
+//             CreateDeadLetterFunc: func(ctx context.Context, deadLetter *models.DeadLetter) error {
+// 	               panic("mock out the CreateDeadLetter method")
+//             },
+//             CreateDownloadFunc: func(ctx context.Context, id string, imageState string, download *models.Download) (bool, error) {
+// 	               panic("mock out the CreateDownload method")
+//             },
+//             CreateImageVersionFunc: func(ctx context.Context, version *models.Version) error {
+// 	               panic("mock out the CreateImageVersion method")
+//             },
+//             DeleteDeadLetterFunc: func(ctx context.Context, id string) error {
+// 	               panic("mock out the DeleteDeadLetter method")
+//             },
+//             DeleteImageFunc: func(ctx context.Context, id string) error {
+// 	               panic("mock out the DeleteImage method")
+//             },
+//             EnsureIndexesFunc: func(ctx context.Context, deletedImageTTL time.Duration) error {
+// 	               panic("mock out the EnsureIndexes method")
+//             },
+//             GetDeadLetterFunc: func(ctx context.Context, id string) (*models.DeadLetter, error) {
+// 	               panic("mock out the GetDeadLetter method")
+//             },
+//             GetDeadLettersFunc: func(ctx context.Context) ([]models.DeadLetter, error) {
+// 	               panic("mock out the GetDeadLetters method")
+//             },
+//             GetDeadLettersDueForRetryFunc: func(ctx context.Context, before time.Time, maxAttempts int) ([]models.DeadLetter, error) {
+// 	               panic("mock out the GetDeadLettersDueForRetry method")
+//             },
 //             GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
 // 	               panic("mock out the GetImage method")
 //             },
+//             GetImageLocksFunc: func(ctx context.Context) ([]models.ImageLock, error) {
+// 	               panic("mock out the GetImageLocks method")
+//             },
+//             GetImageVersionFunc: func(ctx context.Context, imageID string, version int) (*models.Version, error) {
+// 	               panic("mock out the GetImageVersion method")
+//             },
 //             GetImagesFunc: func(ctx context.Context, collectionID string) ([]models.Image, error) {
 // 	               panic("mock out the GetImages method")
 //             },
+//             GetImagesForReplayFunc: func(ctx context.Context, req *models.ReplayRequest) ([]models.Image, error) {
+// 	               panic("mock out the GetImagesForReplay method")
+//             },
+//             GetImagesScheduledForPublishFunc: func(ctx context.Context, before time.Time) ([]models.Image, error) {
+// 	               panic("mock out the GetImagesScheduledForPublish method")
+//             },
+//             IndexCheckerFunc: func(ctx context.Context, state *healthcheck.CheckState) error {
+// 	               panic("mock out the IndexChecker method")
+//             },
+//             IterateImagesFunc: func(ctx context.Context, collectionID string, fn func(image *models.Image, decodeErr error) error) error {
+// 	               panic("mock out the IterateImages method")
+//             },
+//             MigrateFunc: func(ctx context.Context, dryRun bool, lockTimeout time.Duration) (*models.MigrationResult, error) {
+// 	               panic("mock out the Migrate method")
+//             },
+//             RenewSchedulerLockFunc: func(ctx context.Context, lockID string) error {
+// 	               panic("mock out the RenewSchedulerLock method")
+//             },
+//             StartImageRevisionFunc: func(ctx context.Context, id string, archived *models.Revision) error {
+// 	               panic("mock out the StartImageRevision method")
+//             },
+//             UnlockDeadLetterRetrierFunc: func(ctx context.Context, lockID string)  {
+// 	               panic("mock out the UnlockDeadLetterRetrier method")
+//             },
 //             UnlockImageFunc: func(ctx context.Context, lockID string)  {
 // 	               panic("mock out the UnlockImage method")
 //             },
+//             UnlockSchedulerFunc: func(ctx context.Context, lockID string)  {
+// 	               panic("mock out the UnlockScheduler method")
+//             },
+//             UnsetScheduledPublishFunc: func(ctx context.Context, id string) error {
+// 	               panic("mock out the UnsetScheduledPublish method")
+//             },
+//             UpdateDeadLetterAttemptFunc: func(ctx context.Context, deadLetter *models.DeadLetter) error {
+// 	               panic("mock out the UpdateDeadLetterAttempt method")
+//             },
+//             UpdateDownloadFunc: func(ctx context.Context, id string, imageState string, variantState string, download *models.Download) (bool, error) {
+// 	               panic("mock out the UpdateDownload method")
+//             },
 //             UpdateImageFunc: func(ctx context.Context, id string, image *models.Image) (bool, error) {
 // 	               panic("mock out the UpdateImage method")
 //             },
+//             UpdateImageStateFunc: func(ctx context.Context, previousState string, image *models.Image) (bool, error) {
+// 	               panic("mock out the UpdateImageState method")
+//             },
 //             UpsertImageFunc: func(ctx context.Context, id string, image *models.Image) error {
 // 	               panic("mock out the UpsertImage method")
 //             },
+//             WithdrawImageFunc: func(ctx context.Context, id string, image *models.Image) error {
+// 	               panic("mock out the WithdrawImage method")
+//             },
+//             WithdrawImageVersionsFunc: func(ctx context.Context, imageID string) ([]models.Version, error) {
+// 	               panic("mock out the WithdrawImageVersions method")
+//             },
 //         }
 //
 //         // use mockedMongoServer in code that requires api.MongoServer
@@ -63,32 +176,121 @@ var _ api.MongoServer = &MongoServerMock{}
 //
 //     }
 type MongoServerMock struct {
+	// AcquireDeadLetterRetrierLockFunc mocks the AcquireDeadLetterRetrierLock method.
+	AcquireDeadLetterRetrierLockFunc func(ctx context.Context) (string, error)
+
 	// AcquireImageLockFunc mocks the AcquireImageLock method.
 	AcquireImageLockFunc func(ctx context.Context, id string) (string, error)
 
+	// AcquireSchedulerLockFunc mocks the AcquireSchedulerLock method.
+	AcquireSchedulerLockFunc func(ctx context.Context) (string, error)
+
 	// CheckerFunc mocks the Checker method.
 	CheckerFunc func(ctx context.Context, state *healthcheck.CheckState) error
 
 	// CloseFunc mocks the Close method.
 	CloseFunc func(ctx context.Context) error
 
+	// CreateDeadLetterFunc mocks the CreateDeadLetter method.
+	CreateDeadLetterFunc func(ctx context.Context, deadLetter *models.DeadLetter) error
+
+	// CreateDownloadFunc mocks the CreateDownload method.
+	CreateDownloadFunc func(ctx context.Context, id string, imageState string, download *models.Download) (bool, error)
+
+	// CreateImageVersionFunc mocks the CreateImageVersion method.
+	CreateImageVersionFunc func(ctx context.Context, version *models.Version) error
+
+	// DeleteDeadLetterFunc mocks the DeleteDeadLetter method.
+	DeleteDeadLetterFunc func(ctx context.Context, id string) error
+
+	// DeleteImageFunc mocks the DeleteImage method.
+	DeleteImageFunc func(ctx context.Context, id string) error
+
+	// EnsureIndexesFunc mocks the EnsureIndexes method.
+	EnsureIndexesFunc func(ctx context.Context, deletedImageTTL time.Duration) error
+
+	// GetDeadLetterFunc mocks the GetDeadLetter method.
+	GetDeadLetterFunc func(ctx context.Context, id string) (*models.DeadLetter, error)
+
+	// GetDeadLettersFunc mocks the GetDeadLetters method.
+	GetDeadLettersFunc func(ctx context.Context) ([]models.DeadLetter, error)
+
+	// GetDeadLettersDueForRetryFunc mocks the GetDeadLettersDueForRetry method.
+	GetDeadLettersDueForRetryFunc func(ctx context.Context, before time.Time, maxAttempts int) ([]models.DeadLetter, error)
+
 	// GetImageFunc mocks the GetImage method.
 	GetImageFunc func(ctx context.Context, id string) (*models.Image, error)
 
+	// GetImageLocksFunc mocks the GetImageLocks method.
+	GetImageLocksFunc func(ctx context.Context) ([]models.ImageLock, error)
+
+	// GetImageVersionFunc mocks the GetImageVersion method.
+	GetImageVersionFunc func(ctx context.Context, imageID string, version int) (*models.Version, error)
+
 	// GetImagesFunc mocks the GetImages method.
 	GetImagesFunc func(ctx context.Context, collectionID string) ([]models.Image, error)
 
+	// GetImagesForReplayFunc mocks the GetImagesForReplay method.
+	GetImagesForReplayFunc func(ctx context.Context, req *models.ReplayRequest) ([]models.Image, error)
+
+	// GetImagesScheduledForPublishFunc mocks the GetImagesScheduledForPublish method.
+	GetImagesScheduledForPublishFunc func(ctx context.Context, before time.Time) ([]models.Image, error)
+
+	// IndexCheckerFunc mocks the IndexChecker method.
+	IndexCheckerFunc func(ctx context.Context, state *healthcheck.CheckState) error
+
+	// IterateImagesFunc mocks the IterateImages method.
+	IterateImagesFunc func(ctx context.Context, collectionID string, fn func(image *models.Image, decodeErr error) error) error
+
+	// MigrateFunc mocks the Migrate method.
+	MigrateFunc func(ctx context.Context, dryRun bool, lockTimeout time.Duration) (*models.MigrationResult, error)
+
+	// RenewSchedulerLockFunc mocks the RenewSchedulerLock method.
+	RenewSchedulerLockFunc func(ctx context.Context, lockID string) error
+
+	// StartImageRevisionFunc mocks the StartImageRevision method.
+	StartImageRevisionFunc func(ctx context.Context, id string, archived *models.Revision) error
+
+	// UnlockDeadLetterRetrierFunc mocks the UnlockDeadLetterRetrier method.
+	UnlockDeadLetterRetrierFunc func(ctx context.Context, lockID string)
+
 	// UnlockImageFunc mocks the UnlockImage method.
 	UnlockImageFunc func(ctx context.Context, lockID string)
 
+	// UnlockSchedulerFunc mocks the UnlockScheduler method.
+	UnlockSchedulerFunc func(ctx context.Context, lockID string)
+
+	// UnsetScheduledPublishFunc mocks the UnsetScheduledPublish method.
+	UnsetScheduledPublishFunc func(ctx context.Context, id string) error
+
+	// UpdateDeadLetterAttemptFunc mocks the UpdateDeadLetterAttempt method.
+	UpdateDeadLetterAttemptFunc func(ctx context.Context, deadLetter *models.DeadLetter) error
+
+	// UpdateDownloadFunc mocks the UpdateDownload method.
+	UpdateDownloadFunc func(ctx context.Context, id string, imageState string, variantState string, download *models.Download) (bool, error)
+
 	// UpdateImageFunc mocks the UpdateImage method.
 	UpdateImageFunc func(ctx context.Context, id string, image *models.Image) (bool, error)
 
+	// UpdateImageStateFunc mocks the UpdateImageState method.
+	UpdateImageStateFunc func(ctx context.Context, previousState string, image *models.Image) (bool, error)
+
 	// UpsertImageFunc mocks the UpsertImage method.
 	UpsertImageFunc func(ctx context.Context, id string, image *models.Image) error
 
+	// WithdrawImageFunc mocks the WithdrawImage method.
+	WithdrawImageFunc func(ctx context.Context, id string, image *models.Image) error
+
+	// WithdrawImageVersionsFunc mocks the WithdrawImageVersions method.
+	WithdrawImageVersionsFunc func(ctx context.Context, imageID string) ([]models.Version, error)
+
 	// calls tracks calls to the methods.
 	calls struct {
+		// AcquireDeadLetterRetrierLock holds details about calls to the AcquireDeadLetterRetrierLock method.
+		AcquireDeadLetterRetrierLock []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
 		// AcquireImageLock holds details about calls to the AcquireImageLock method.
 		AcquireImageLock []struct {
 			// Ctx is the ctx argument value.
@@ -96,6 +298,11 @@ type MongoServerMock struct {
 			// ID is the id argument value.
 			ID string
 		}
+		// AcquireSchedulerLock holds details about calls to the AcquireSchedulerLock method.
+		AcquireSchedulerLock []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
 		// Checker holds details about calls to the Checker method.
 		Checker []struct {
 			// Ctx is the ctx argument value.
@@ -108,6 +315,73 @@ type MongoServerMock struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 		}
+		// CreateDeadLetter holds details about calls to the CreateDeadLetter method.
+		CreateDeadLetter []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// DeadLetter is the deadLetter argument value.
+			DeadLetter *models.DeadLetter
+		}
+		// CreateDownload holds details about calls to the CreateDownload method.
+		CreateDownload []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+			// ImageState is the imageState argument value.
+			ImageState string
+			// Download is the download argument value.
+			Download *models.Download
+		}
+		// CreateImageVersion holds details about calls to the CreateImageVersion method.
+		CreateImageVersion []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Version is the version argument value.
+			Version *models.Version
+		}
+		// DeleteDeadLetter holds details about calls to the DeleteDeadLetter method.
+		DeleteDeadLetter []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+		}
+		// DeleteImage holds details about calls to the DeleteImage method.
+		DeleteImage []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+		}
+		// EnsureIndexes holds details about calls to the EnsureIndexes method.
+		EnsureIndexes []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// DeletedImageTTL is the deletedImageTTL argument value.
+			DeletedImageTTL time.Duration
+		}
+		// GetDeadLetter holds details about calls to the GetDeadLetter method.
+		GetDeadLetter []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+		}
+		// GetDeadLetters holds details about calls to the GetDeadLetters method.
+		GetDeadLetters []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// GetDeadLettersDueForRetry holds details about calls to the GetDeadLettersDueForRetry method.
+		GetDeadLettersDueForRetry []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Before is the before argument value.
+			Before time.Time
+			// MaxAttempts is the maxAttempts argument value.
+			MaxAttempts int
+		}
 		// GetImage holds details about calls to the GetImage method.
 		GetImage []struct {
 			// Ctx is the ctx argument value.
@@ -115,6 +389,20 @@ type MongoServerMock struct {
 			// ID is the id argument value.
 			ID string
 		}
+		// GetImageLocks holds details about calls to the GetImageLocks method.
+		GetImageLocks []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// GetImageVersion holds details about calls to the GetImageVersion method.
+		GetImageVersion []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ImageID is the imageID argument value.
+			ImageID string
+			// Version is the version argument value.
+			Version int
+		}
 		// GetImages holds details about calls to the GetImages method.
 		GetImages []struct {
 			// Ctx is the ctx argument value.
@@ -122,6 +410,68 @@ type MongoServerMock struct {
 			// CollectionID is the collectionID argument value.
 			CollectionID string
 		}
+		// GetImagesForReplay holds details about calls to the GetImagesForReplay method.
+		GetImagesForReplay []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Req is the req argument value.
+			Req *models.ReplayRequest
+		}
+		// GetImagesScheduledForPublish holds details about calls to the GetImagesScheduledForPublish method.
+		GetImagesScheduledForPublish []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Before is the before argument value.
+			Before time.Time
+		}
+		// IndexChecker holds details about calls to the IndexChecker method.
+		IndexChecker []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// State is the state argument value.
+			State *healthcheck.CheckState
+		}
+		// IterateImages holds details about calls to the IterateImages method.
+		IterateImages []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// CollectionID is the collectionID argument value.
+			CollectionID string
+			// Fn is the fn argument value.
+			Fn func(image *models.Image, decodeErr error) error
+		}
+		// Migrate holds details about calls to the Migrate method.
+		Migrate []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// DryRun is the dryRun argument value.
+			DryRun bool
+			// LockTimeout is the lockTimeout argument value.
+			LockTimeout time.Duration
+		}
+		// RenewSchedulerLock holds details about calls to the RenewSchedulerLock method.
+		RenewSchedulerLock []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// LockID is the lockID argument value.
+			LockID string
+		}
+		// StartImageRevision holds details about calls to the StartImageRevision method.
+		StartImageRevision []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+			// Archived is the archived argument value.
+			Archived *models.Revision
+		}
+		// UnlockDeadLetterRetrier holds details about calls to the UnlockDeadLetterRetrier method.
+		UnlockDeadLetterRetrier []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// LockID is the lockID argument value.
+			LockID string
+		}
 		// UnlockImage holds details about calls to the UnlockImage method.
 		UnlockImage []struct {
 			// Ctx is the ctx argument value.
@@ -129,6 +479,40 @@ type MongoServerMock struct {
 			// LockID is the lockID argument value.
 			LockID string
 		}
+		// UnlockScheduler holds details about calls to the UnlockScheduler method.
+		UnlockScheduler []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// LockID is the lockID argument value.
+			LockID string
+		}
+		// UnsetScheduledPublish holds details about calls to the UnsetScheduledPublish method.
+		UnsetScheduledPublish []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+		}
+		// UpdateDeadLetterAttempt holds details about calls to the UpdateDeadLetterAttempt method.
+		UpdateDeadLetterAttempt []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// DeadLetter is the deadLetter argument value.
+			DeadLetter *models.DeadLetter
+		}
+		// UpdateDownload holds details about calls to the UpdateDownload method.
+		UpdateDownload []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+			// ImageState is the imageState argument value.
+			ImageState string
+			// VariantState is the variantState argument value.
+			VariantState string
+			// Download is the download argument value.
+			Download *models.Download
+		}
 		// UpdateImage holds details about calls to the UpdateImage method.
 		UpdateImage []struct {
 			// Ctx is the ctx argument value.
@@ -138,6 +522,15 @@ type MongoServerMock struct {
 			// Image is the image argument value.
 			Image *models.Image
 		}
+		// UpdateImageState holds details about calls to the UpdateImageState method.
+		UpdateImageState []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// PreviousState is the previousState argument value.
+			PreviousState string
+			// Image is the image argument value.
+			Image *models.Image
+		}
 		// UpsertImage holds details about calls to the UpsertImage method.
 		UpsertImage []struct {
 			// Ctx is the ctx argument value.
@@ -147,7 +540,54 @@ type MongoServerMock struct {
 			// Image is the image argument value.
 			Image *models.Image
 		}
+		// WithdrawImage holds details about calls to the WithdrawImage method.
+		WithdrawImage []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+			// Image is the image argument value.
+			Image *models.Image
+		}
+		// WithdrawImageVersions holds details about calls to the WithdrawImageVersions method.
+		WithdrawImageVersions []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ImageID is the imageID argument value.
+			ImageID string
+		}
+	}
+}
+
+// AcquireDeadLetterRetrierLock calls AcquireDeadLetterRetrierLockFunc.
+func (mock *MongoServerMock) AcquireDeadLetterRetrierLock(ctx context.Context) (string, error) {
+	if mock.AcquireDeadLetterRetrierLockFunc == nil {
+		panic("MongoServerMock.AcquireDeadLetterRetrierLockFunc: method is nil but MongoServer.AcquireDeadLetterRetrierLock was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	lockMongoServerMockAcquireDeadLetterRetrierLock.Lock()
+	mock.calls.AcquireDeadLetterRetrierLock = append(mock.calls.AcquireDeadLetterRetrierLock, callInfo)
+	lockMongoServerMockAcquireDeadLetterRetrierLock.Unlock()
+	return mock.AcquireDeadLetterRetrierLockFunc(ctx)
+}
+
+// AcquireDeadLetterRetrierLockCalls gets all the calls that were made to AcquireDeadLetterRetrierLock.
+// Check the length with:
+//     len(mockedMongoServer.AcquireDeadLetterRetrierLockCalls())
+func (mock *MongoServerMock) AcquireDeadLetterRetrierLockCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
 	}
+	lockMongoServerMockAcquireDeadLetterRetrierLock.RLock()
+	calls = mock.calls.AcquireDeadLetterRetrierLock
+	lockMongoServerMockAcquireDeadLetterRetrierLock.RUnlock()
+	return calls
 }
 
 // AcquireImageLock calls AcquireImageLockFunc.
@@ -185,6 +625,37 @@ func (mock *MongoServerMock) AcquireImageLockCalls() []struct {
 	return calls
 }
 
+// AcquireSchedulerLock calls AcquireSchedulerLockFunc.
+func (mock *MongoServerMock) AcquireSchedulerLock(ctx context.Context) (string, error) {
+	if mock.AcquireSchedulerLockFunc == nil {
+		panic("MongoServerMock.AcquireSchedulerLockFunc: method is nil but MongoServer.AcquireSchedulerLock was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	lockMongoServerMockAcquireSchedulerLock.Lock()
+	mock.calls.AcquireSchedulerLock = append(mock.calls.AcquireSchedulerLock, callInfo)
+	lockMongoServerMockAcquireSchedulerLock.Unlock()
+	return mock.AcquireSchedulerLockFunc(ctx)
+}
+
+// AcquireSchedulerLockCalls gets all the calls that were made to AcquireSchedulerLock.
+// Check the length with:
+//     len(mockedMongoServer.AcquireSchedulerLockCalls())
+func (mock *MongoServerMock) AcquireSchedulerLockCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	lockMongoServerMockAcquireSchedulerLock.RLock()
+	calls = mock.calls.AcquireSchedulerLock
+	lockMongoServerMockAcquireSchedulerLock.RUnlock()
+	return calls
+}
+
 // Checker calls CheckerFunc.
 func (mock *MongoServerMock) Checker(ctx context.Context, state *healthcheck.CheckState) error {
 	if mock.CheckerFunc == nil {
@@ -251,87 +722,772 @@ func (mock *MongoServerMock) CloseCalls() []struct {
 	return calls
 }
 
-// GetImage calls GetImageFunc.
-func (mock *MongoServerMock) GetImage(ctx context.Context, id string) (*models.Image, error) {
-	if mock.GetImageFunc == nil {
-		panic("MongoServerMock.GetImageFunc: method is nil but MongoServer.GetImage was just called")
+// CreateDeadLetter calls CreateDeadLetterFunc.
+func (mock *MongoServerMock) CreateDeadLetter(ctx context.Context, deadLetter *models.DeadLetter) error {
+	if mock.CreateDeadLetterFunc == nil {
+		panic("MongoServerMock.CreateDeadLetterFunc: method is nil but MongoServer.CreateDeadLetter was just called")
 	}
 	callInfo := struct {
-		Ctx context.Context
-		ID  string
+		Ctx        context.Context
+		DeadLetter *models.DeadLetter
 	}{
-		Ctx: ctx,
-		ID:  id,
+		Ctx:        ctx,
+		DeadLetter: deadLetter,
 	}
-	lockMongoServerMockGetImage.Lock()
-	mock.calls.GetImage = append(mock.calls.GetImage, callInfo)
-	lockMongoServerMockGetImage.Unlock()
-	return mock.GetImageFunc(ctx, id)
+	lockMongoServerMockCreateDeadLetter.Lock()
+	mock.calls.CreateDeadLetter = append(mock.calls.CreateDeadLetter, callInfo)
+	lockMongoServerMockCreateDeadLetter.Unlock()
+	return mock.CreateDeadLetterFunc(ctx, deadLetter)
 }
 
-// GetImageCalls gets all the calls that were made to GetImage.
+// CreateDeadLetterCalls gets all the calls that were made to CreateDeadLetter.
 // Check the length with:
-//     len(mockedMongoServer.GetImageCalls())
-func (mock *MongoServerMock) GetImageCalls() []struct {
-	Ctx context.Context
-	ID  string
+//     len(mockedMongoServer.CreateDeadLetterCalls())
+func (mock *MongoServerMock) CreateDeadLetterCalls() []struct {
+	Ctx        context.Context
+	DeadLetter *models.DeadLetter
 } {
 	var calls []struct {
-		Ctx context.Context
-		ID  string
+		Ctx        context.Context
+		DeadLetter *models.DeadLetter
 	}
-	lockMongoServerMockGetImage.RLock()
-	calls = mock.calls.GetImage
-	lockMongoServerMockGetImage.RUnlock()
+	lockMongoServerMockCreateDeadLetter.RLock()
+	calls = mock.calls.CreateDeadLetter
+	lockMongoServerMockCreateDeadLetter.RUnlock()
 	return calls
 }
 
-// GetImages calls GetImagesFunc.
-func (mock *MongoServerMock) GetImages(ctx context.Context, collectionID string) ([]models.Image, error) {
-	if mock.GetImagesFunc == nil {
-		panic("MongoServerMock.GetImagesFunc: method is nil but MongoServer.GetImages was just called")
+// CreateDownload calls CreateDownloadFunc.
+func (mock *MongoServerMock) CreateDownload(ctx context.Context, id string, imageState string, download *models.Download) (bool, error) {
+	if mock.CreateDownloadFunc == nil {
+		panic("MongoServerMock.CreateDownloadFunc: method is nil but MongoServer.CreateDownload was just called")
 	}
 	callInfo := struct {
-		Ctx          context.Context
-		CollectionID string
+		Ctx        context.Context
+		ID         string
+		ImageState string
+		Download   *models.Download
 	}{
-		Ctx:          ctx,
-		CollectionID: collectionID,
+		Ctx:        ctx,
+		ID:         id,
+		ImageState: imageState,
+		Download:   download,
 	}
-	lockMongoServerMockGetImages.Lock()
-	mock.calls.GetImages = append(mock.calls.GetImages, callInfo)
-	lockMongoServerMockGetImages.Unlock()
-	return mock.GetImagesFunc(ctx, collectionID)
+	lockMongoServerMockCreateDownload.Lock()
+	mock.calls.CreateDownload = append(mock.calls.CreateDownload, callInfo)
+	lockMongoServerMockCreateDownload.Unlock()
+	return mock.CreateDownloadFunc(ctx, id, imageState, download)
 }
 
-// GetImagesCalls gets all the calls that were made to GetImages.
+// CreateDownloadCalls gets all the calls that were made to CreateDownload.
 // Check the length with:
-//     len(mockedMongoServer.GetImagesCalls())
-func (mock *MongoServerMock) GetImagesCalls() []struct {
-	Ctx          context.Context
-	CollectionID string
+//     len(mockedMongoServer.CreateDownloadCalls())
+func (mock *MongoServerMock) CreateDownloadCalls() []struct {
+	Ctx        context.Context
+	ID         string
+	ImageState string
+	Download   *models.Download
 } {
 	var calls []struct {
-		Ctx          context.Context
-		CollectionID string
+		Ctx        context.Context
+		ID         string
+		ImageState string
+		Download   *models.Download
 	}
-	lockMongoServerMockGetImages.RLock()
-	calls = mock.calls.GetImages
-	lockMongoServerMockGetImages.RUnlock()
+	lockMongoServerMockCreateDownload.RLock()
+	calls = mock.calls.CreateDownload
+	lockMongoServerMockCreateDownload.RUnlock()
 	return calls
 }
 
-// UnlockImage calls UnlockImageFunc.
-func (mock *MongoServerMock) UnlockImage(ctx context.Context, lockID string) {
-	if mock.UnlockImageFunc == nil {
-		panic("MongoServerMock.UnlockImageFunc: method is nil but MongoServer.UnlockImage was just called")
+// CreateImageVersion calls CreateImageVersionFunc.
+func (mock *MongoServerMock) CreateImageVersion(ctx context.Context, version *models.Version) error {
+	if mock.CreateImageVersionFunc == nil {
+		panic("MongoServerMock.CreateImageVersionFunc: method is nil but MongoServer.CreateImageVersion was just called")
 	}
 	callInfo := struct {
-		Ctx    context.Context
-		LockID string
+		Ctx     context.Context
+		Version *models.Version
 	}{
-		Ctx:    ctx,
-		LockID: lockID,
+		Ctx:     ctx,
+		Version: version,
+	}
+	lockMongoServerMockCreateImageVersion.Lock()
+	mock.calls.CreateImageVersion = append(mock.calls.CreateImageVersion, callInfo)
+	lockMongoServerMockCreateImageVersion.Unlock()
+	return mock.CreateImageVersionFunc(ctx, version)
+}
+
+// CreateImageVersionCalls gets all the calls that were made to CreateImageVersion.
+// Check the length with:
+//     len(mockedMongoServer.CreateImageVersionCalls())
+func (mock *MongoServerMock) CreateImageVersionCalls() []struct {
+	Ctx     context.Context
+	Version *models.Version
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Version *models.Version
+	}
+	lockMongoServerMockCreateImageVersion.RLock()
+	calls = mock.calls.CreateImageVersion
+	lockMongoServerMockCreateImageVersion.RUnlock()
+	return calls
+}
+
+// DeleteDeadLetter calls DeleteDeadLetterFunc.
+func (mock *MongoServerMock) DeleteDeadLetter(ctx context.Context, id string) error {
+	if mock.DeleteDeadLetterFunc == nil {
+		panic("MongoServerMock.DeleteDeadLetterFunc: method is nil but MongoServer.DeleteDeadLetter was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  string
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	lockMongoServerMockDeleteDeadLetter.Lock()
+	mock.calls.DeleteDeadLetter = append(mock.calls.DeleteDeadLetter, callInfo)
+	lockMongoServerMockDeleteDeadLetter.Unlock()
+	return mock.DeleteDeadLetterFunc(ctx, id)
+}
+
+// DeleteDeadLetterCalls gets all the calls that were made to DeleteDeadLetter.
+// Check the length with:
+//     len(mockedMongoServer.DeleteDeadLetterCalls())
+func (mock *MongoServerMock) DeleteDeadLetterCalls() []struct {
+	Ctx context.Context
+	ID  string
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  string
+	}
+	lockMongoServerMockDeleteDeadLetter.RLock()
+	calls = mock.calls.DeleteDeadLetter
+	lockMongoServerMockDeleteDeadLetter.RUnlock()
+	return calls
+}
+
+// DeleteImage calls DeleteImageFunc.
+func (mock *MongoServerMock) DeleteImage(ctx context.Context, id string) error {
+	if mock.DeleteImageFunc == nil {
+		panic("MongoServerMock.DeleteImageFunc: method is nil but MongoServer.DeleteImage was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  string
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	lockMongoServerMockDeleteImage.Lock()
+	mock.calls.DeleteImage = append(mock.calls.DeleteImage, callInfo)
+	lockMongoServerMockDeleteImage.Unlock()
+	return mock.DeleteImageFunc(ctx, id)
+}
+
+// DeleteImageCalls gets all the calls that were made to DeleteImage.
+// Check the length with:
+//     len(mockedMongoServer.DeleteImageCalls())
+func (mock *MongoServerMock) DeleteImageCalls() []struct {
+	Ctx context.Context
+	ID  string
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  string
+	}
+	lockMongoServerMockDeleteImage.RLock()
+	calls = mock.calls.DeleteImage
+	lockMongoServerMockDeleteImage.RUnlock()
+	return calls
+}
+
+// EnsureIndexes calls EnsureIndexesFunc.
+func (mock *MongoServerMock) EnsureIndexes(ctx context.Context, deletedImageTTL time.Duration) error {
+	if mock.EnsureIndexesFunc == nil {
+		panic("MongoServerMock.EnsureIndexesFunc: method is nil but MongoServer.EnsureIndexes was just called")
+	}
+	callInfo := struct {
+		Ctx             context.Context
+		DeletedImageTTL time.Duration
+	}{
+		Ctx:             ctx,
+		DeletedImageTTL: deletedImageTTL,
+	}
+	lockMongoServerMockEnsureIndexes.Lock()
+	mock.calls.EnsureIndexes = append(mock.calls.EnsureIndexes, callInfo)
+	lockMongoServerMockEnsureIndexes.Unlock()
+	return mock.EnsureIndexesFunc(ctx, deletedImageTTL)
+}
+
+// EnsureIndexesCalls gets all the calls that were made to EnsureIndexes.
+// Check the length with:
+//     len(mockedMongoServer.EnsureIndexesCalls())
+func (mock *MongoServerMock) EnsureIndexesCalls() []struct {
+	Ctx             context.Context
+	DeletedImageTTL time.Duration
+} {
+	var calls []struct {
+		Ctx             context.Context
+		DeletedImageTTL time.Duration
+	}
+	lockMongoServerMockEnsureIndexes.RLock()
+	calls = mock.calls.EnsureIndexes
+	lockMongoServerMockEnsureIndexes.RUnlock()
+	return calls
+}
+
+// GetDeadLetter calls GetDeadLetterFunc.
+func (mock *MongoServerMock) GetDeadLetter(ctx context.Context, id string) (*models.DeadLetter, error) {
+	if mock.GetDeadLetterFunc == nil {
+		panic("MongoServerMock.GetDeadLetterFunc: method is nil but MongoServer.GetDeadLetter was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  string
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	lockMongoServerMockGetDeadLetter.Lock()
+	mock.calls.GetDeadLetter = append(mock.calls.GetDeadLetter, callInfo)
+	lockMongoServerMockGetDeadLetter.Unlock()
+	return mock.GetDeadLetterFunc(ctx, id)
+}
+
+// GetDeadLetterCalls gets all the calls that were made to GetDeadLetter.
+// Check the length with:
+//     len(mockedMongoServer.GetDeadLetterCalls())
+func (mock *MongoServerMock) GetDeadLetterCalls() []struct {
+	Ctx context.Context
+	ID  string
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  string
+	}
+	lockMongoServerMockGetDeadLetter.RLock()
+	calls = mock.calls.GetDeadLetter
+	lockMongoServerMockGetDeadLetter.RUnlock()
+	return calls
+}
+
+// GetDeadLetters calls GetDeadLettersFunc.
+func (mock *MongoServerMock) GetDeadLetters(ctx context.Context) ([]models.DeadLetter, error) {
+	if mock.GetDeadLettersFunc == nil {
+		panic("MongoServerMock.GetDeadLettersFunc: method is nil but MongoServer.GetDeadLetters was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	lockMongoServerMockGetDeadLetters.Lock()
+	mock.calls.GetDeadLetters = append(mock.calls.GetDeadLetters, callInfo)
+	lockMongoServerMockGetDeadLetters.Unlock()
+	return mock.GetDeadLettersFunc(ctx)
+}
+
+// GetDeadLettersCalls gets all the calls that were made to GetDeadLetters.
+// Check the length with:
+//     len(mockedMongoServer.GetDeadLettersCalls())
+func (mock *MongoServerMock) GetDeadLettersCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	lockMongoServerMockGetDeadLetters.RLock()
+	calls = mock.calls.GetDeadLetters
+	lockMongoServerMockGetDeadLetters.RUnlock()
+	return calls
+}
+
+// GetDeadLettersDueForRetry calls GetDeadLettersDueForRetryFunc.
+func (mock *MongoServerMock) GetDeadLettersDueForRetry(ctx context.Context, before time.Time, maxAttempts int) ([]models.DeadLetter, error) {
+	if mock.GetDeadLettersDueForRetryFunc == nil {
+		panic("MongoServerMock.GetDeadLettersDueForRetryFunc: method is nil but MongoServer.GetDeadLettersDueForRetry was just called")
+	}
+	callInfo := struct {
+		Ctx         context.Context
+		Before      time.Time
+		MaxAttempts int
+	}{
+		Ctx:         ctx,
+		Before:      before,
+		MaxAttempts: maxAttempts,
+	}
+	lockMongoServerMockGetDeadLettersDueForRetry.Lock()
+	mock.calls.GetDeadLettersDueForRetry = append(mock.calls.GetDeadLettersDueForRetry, callInfo)
+	lockMongoServerMockGetDeadLettersDueForRetry.Unlock()
+	return mock.GetDeadLettersDueForRetryFunc(ctx, before, maxAttempts)
+}
+
+// GetDeadLettersDueForRetryCalls gets all the calls that were made to GetDeadLettersDueForRetry.
+// Check the length with:
+//     len(mockedMongoServer.GetDeadLettersDueForRetryCalls())
+func (mock *MongoServerMock) GetDeadLettersDueForRetryCalls() []struct {
+	Ctx         context.Context
+	Before      time.Time
+	MaxAttempts int
+} {
+	var calls []struct {
+		Ctx         context.Context
+		Before      time.Time
+		MaxAttempts int
+	}
+	lockMongoServerMockGetDeadLettersDueForRetry.RLock()
+	calls = mock.calls.GetDeadLettersDueForRetry
+	lockMongoServerMockGetDeadLettersDueForRetry.RUnlock()
+	return calls
+}
+
+// GetImage calls GetImageFunc.
+func (mock *MongoServerMock) GetImage(ctx context.Context, id string) (*models.Image, error) {
+	if mock.GetImageFunc == nil {
+		panic("MongoServerMock.GetImageFunc: method is nil but MongoServer.GetImage was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  string
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	lockMongoServerMockGetImage.Lock()
+	mock.calls.GetImage = append(mock.calls.GetImage, callInfo)
+	lockMongoServerMockGetImage.Unlock()
+	return mock.GetImageFunc(ctx, id)
+}
+
+// GetImageCalls gets all the calls that were made to GetImage.
+// Check the length with:
+//     len(mockedMongoServer.GetImageCalls())
+func (mock *MongoServerMock) GetImageCalls() []struct {
+	Ctx context.Context
+	ID  string
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  string
+	}
+	lockMongoServerMockGetImage.RLock()
+	calls = mock.calls.GetImage
+	lockMongoServerMockGetImage.RUnlock()
+	return calls
+}
+
+// GetImageLocks calls GetImageLocksFunc.
+func (mock *MongoServerMock) GetImageLocks(ctx context.Context) ([]models.ImageLock, error) {
+	if mock.GetImageLocksFunc == nil {
+		panic("MongoServerMock.GetImageLocksFunc: method is nil but MongoServer.GetImageLocks was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	lockMongoServerMockGetImageLocks.Lock()
+	mock.calls.GetImageLocks = append(mock.calls.GetImageLocks, callInfo)
+	lockMongoServerMockGetImageLocks.Unlock()
+	return mock.GetImageLocksFunc(ctx)
+}
+
+// GetImageLocksCalls gets all the calls that were made to GetImageLocks.
+// Check the length with:
+//     len(mockedMongoServer.GetImageLocksCalls())
+func (mock *MongoServerMock) GetImageLocksCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	lockMongoServerMockGetImageLocks.RLock()
+	calls = mock.calls.GetImageLocks
+	lockMongoServerMockGetImageLocks.RUnlock()
+	return calls
+}
+
+// GetImageVersion calls GetImageVersionFunc.
+func (mock *MongoServerMock) GetImageVersion(ctx context.Context, imageID string, version int) (*models.Version, error) {
+	if mock.GetImageVersionFunc == nil {
+		panic("MongoServerMock.GetImageVersionFunc: method is nil but MongoServer.GetImageVersion was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		ImageID string
+		Version int
+	}{
+		Ctx:     ctx,
+		ImageID: imageID,
+		Version: version,
+	}
+	lockMongoServerMockGetImageVersion.Lock()
+	mock.calls.GetImageVersion = append(mock.calls.GetImageVersion, callInfo)
+	lockMongoServerMockGetImageVersion.Unlock()
+	return mock.GetImageVersionFunc(ctx, imageID, version)
+}
+
+// GetImageVersionCalls gets all the calls that were made to GetImageVersion.
+// Check the length with:
+//     len(mockedMongoServer.GetImageVersionCalls())
+func (mock *MongoServerMock) GetImageVersionCalls() []struct {
+	Ctx     context.Context
+	ImageID string
+	Version int
+} {
+	var calls []struct {
+		Ctx     context.Context
+		ImageID string
+		Version int
+	}
+	lockMongoServerMockGetImageVersion.RLock()
+	calls = mock.calls.GetImageVersion
+	lockMongoServerMockGetImageVersion.RUnlock()
+	return calls
+}
+
+// GetImages calls GetImagesFunc.
+func (mock *MongoServerMock) GetImages(ctx context.Context, collectionID string) ([]models.Image, error) {
+	if mock.GetImagesFunc == nil {
+		panic("MongoServerMock.GetImagesFunc: method is nil but MongoServer.GetImages was just called")
+	}
+	callInfo := struct {
+		Ctx          context.Context
+		CollectionID string
+	}{
+		Ctx:          ctx,
+		CollectionID: collectionID,
+	}
+	lockMongoServerMockGetImages.Lock()
+	mock.calls.GetImages = append(mock.calls.GetImages, callInfo)
+	lockMongoServerMockGetImages.Unlock()
+	return mock.GetImagesFunc(ctx, collectionID)
+}
+
+// GetImagesCalls gets all the calls that were made to GetImages.
+// Check the length with:
+//     len(mockedMongoServer.GetImagesCalls())
+func (mock *MongoServerMock) GetImagesCalls() []struct {
+	Ctx          context.Context
+	CollectionID string
+} {
+	var calls []struct {
+		Ctx          context.Context
+		CollectionID string
+	}
+	lockMongoServerMockGetImages.RLock()
+	calls = mock.calls.GetImages
+	lockMongoServerMockGetImages.RUnlock()
+	return calls
+}
+
+// GetImagesForReplay calls GetImagesForReplayFunc.
+func (mock *MongoServerMock) GetImagesForReplay(ctx context.Context, req *models.ReplayRequest) ([]models.Image, error) {
+	if mock.GetImagesForReplayFunc == nil {
+		panic("MongoServerMock.GetImagesForReplayFunc: method is nil but MongoServer.GetImagesForReplay was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Req *models.ReplayRequest
+	}{
+		Ctx: ctx,
+		Req: req,
+	}
+	lockMongoServerMockGetImagesForReplay.Lock()
+	mock.calls.GetImagesForReplay = append(mock.calls.GetImagesForReplay, callInfo)
+	lockMongoServerMockGetImagesForReplay.Unlock()
+	return mock.GetImagesForReplayFunc(ctx, req)
+}
+
+// GetImagesForReplayCalls gets all the calls that were made to GetImagesForReplay.
+// Check the length with:
+//     len(mockedMongoServer.GetImagesForReplayCalls())
+func (mock *MongoServerMock) GetImagesForReplayCalls() []struct {
+	Ctx context.Context
+	Req *models.ReplayRequest
+} {
+	var calls []struct {
+		Ctx context.Context
+		Req *models.ReplayRequest
+	}
+	lockMongoServerMockGetImagesForReplay.RLock()
+	calls = mock.calls.GetImagesForReplay
+	lockMongoServerMockGetImagesForReplay.RUnlock()
+	return calls
+}
+
+// GetImagesScheduledForPublish calls GetImagesScheduledForPublishFunc.
+func (mock *MongoServerMock) GetImagesScheduledForPublish(ctx context.Context, before time.Time) ([]models.Image, error) {
+	if mock.GetImagesScheduledForPublishFunc == nil {
+		panic("MongoServerMock.GetImagesScheduledForPublishFunc: method is nil but MongoServer.GetImagesScheduledForPublish was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Before time.Time
+	}{
+		Ctx:    ctx,
+		Before: before,
+	}
+	lockMongoServerMockGetImagesScheduledForPublish.Lock()
+	mock.calls.GetImagesScheduledForPublish = append(mock.calls.GetImagesScheduledForPublish, callInfo)
+	lockMongoServerMockGetImagesScheduledForPublish.Unlock()
+	return mock.GetImagesScheduledForPublishFunc(ctx, before)
+}
+
+// GetImagesScheduledForPublishCalls gets all the calls that were made to GetImagesScheduledForPublish.
+// Check the length with:
+//     len(mockedMongoServer.GetImagesScheduledForPublishCalls())
+func (mock *MongoServerMock) GetImagesScheduledForPublishCalls() []struct {
+	Ctx    context.Context
+	Before time.Time
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Before time.Time
+	}
+	lockMongoServerMockGetImagesScheduledForPublish.RLock()
+	calls = mock.calls.GetImagesScheduledForPublish
+	lockMongoServerMockGetImagesScheduledForPublish.RUnlock()
+	return calls
+}
+
+// IndexChecker calls IndexCheckerFunc.
+func (mock *MongoServerMock) IndexChecker(ctx context.Context, state *healthcheck.CheckState) error {
+	if mock.IndexCheckerFunc == nil {
+		panic("MongoServerMock.IndexCheckerFunc: method is nil but MongoServer.IndexChecker was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		State *healthcheck.CheckState
+	}{
+		Ctx:   ctx,
+		State: state,
+	}
+	lockMongoServerMockIndexChecker.Lock()
+	mock.calls.IndexChecker = append(mock.calls.IndexChecker, callInfo)
+	lockMongoServerMockIndexChecker.Unlock()
+	return mock.IndexCheckerFunc(ctx, state)
+}
+
+// IndexCheckerCalls gets all the calls that were made to IndexChecker.
+// Check the length with:
+//     len(mockedMongoServer.IndexCheckerCalls())
+func (mock *MongoServerMock) IndexCheckerCalls() []struct {
+	Ctx   context.Context
+	State *healthcheck.CheckState
+} {
+	var calls []struct {
+		Ctx   context.Context
+		State *healthcheck.CheckState
+	}
+	lockMongoServerMockIndexChecker.RLock()
+	calls = mock.calls.IndexChecker
+	lockMongoServerMockIndexChecker.RUnlock()
+	return calls
+}
+
+// IterateImages calls IterateImagesFunc.
+func (mock *MongoServerMock) IterateImages(ctx context.Context, collectionID string, fn func(image *models.Image, decodeErr error) error) error {
+	if mock.IterateImagesFunc == nil {
+		panic("MongoServerMock.IterateImagesFunc: method is nil but MongoServer.IterateImages was just called")
+	}
+	callInfo := struct {
+		Ctx          context.Context
+		CollectionID string
+		Fn           func(image *models.Image, decodeErr error) error
+	}{
+		Ctx:          ctx,
+		CollectionID: collectionID,
+		Fn:           fn,
+	}
+	lockMongoServerMockIterateImages.Lock()
+	mock.calls.IterateImages = append(mock.calls.IterateImages, callInfo)
+	lockMongoServerMockIterateImages.Unlock()
+	return mock.IterateImagesFunc(ctx, collectionID, fn)
+}
+
+// IterateImagesCalls gets all the calls that were made to IterateImages.
+// Check the length with:
+//     len(mockedMongoServer.IterateImagesCalls())
+func (mock *MongoServerMock) IterateImagesCalls() []struct {
+	Ctx          context.Context
+	CollectionID string
+	Fn           func(image *models.Image, decodeErr error) error
+} {
+	var calls []struct {
+		Ctx          context.Context
+		CollectionID string
+		Fn           func(image *models.Image, decodeErr error) error
+	}
+	lockMongoServerMockIterateImages.RLock()
+	calls = mock.calls.IterateImages
+	lockMongoServerMockIterateImages.RUnlock()
+	return calls
+}
+
+// Migrate calls MigrateFunc.
+func (mock *MongoServerMock) Migrate(ctx context.Context, dryRun bool, lockTimeout time.Duration) (*models.MigrationResult, error) {
+	if mock.MigrateFunc == nil {
+		panic("MongoServerMock.MigrateFunc: method is nil but MongoServer.Migrate was just called")
+	}
+	callInfo := struct {
+		Ctx         context.Context
+		DryRun      bool
+		LockTimeout time.Duration
+	}{
+		Ctx:         ctx,
+		DryRun:      dryRun,
+		LockTimeout: lockTimeout,
+	}
+	lockMongoServerMockMigrate.Lock()
+	mock.calls.Migrate = append(mock.calls.Migrate, callInfo)
+	lockMongoServerMockMigrate.Unlock()
+	return mock.MigrateFunc(ctx, dryRun, lockTimeout)
+}
+
+// MigrateCalls gets all the calls that were made to Migrate.
+// Check the length with:
+//     len(mockedMongoServer.MigrateCalls())
+func (mock *MongoServerMock) MigrateCalls() []struct {
+	Ctx         context.Context
+	DryRun      bool
+	LockTimeout time.Duration
+} {
+	var calls []struct {
+		Ctx         context.Context
+		DryRun      bool
+		LockTimeout time.Duration
+	}
+	lockMongoServerMockMigrate.RLock()
+	calls = mock.calls.Migrate
+	lockMongoServerMockMigrate.RUnlock()
+	return calls
+}
+
+// RenewSchedulerLock calls RenewSchedulerLockFunc.
+func (mock *MongoServerMock) RenewSchedulerLock(ctx context.Context, lockID string) error {
+	if mock.RenewSchedulerLockFunc == nil {
+		panic("MongoServerMock.RenewSchedulerLockFunc: method is nil but MongoServer.RenewSchedulerLock was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		LockID string
+	}{
+		Ctx:    ctx,
+		LockID: lockID,
+	}
+	lockMongoServerMockRenewSchedulerLock.Lock()
+	mock.calls.RenewSchedulerLock = append(mock.calls.RenewSchedulerLock, callInfo)
+	lockMongoServerMockRenewSchedulerLock.Unlock()
+	return mock.RenewSchedulerLockFunc(ctx, lockID)
+}
+
+// RenewSchedulerLockCalls gets all the calls that were made to RenewSchedulerLock.
+// Check the length with:
+//     len(mockedMongoServer.RenewSchedulerLockCalls())
+func (mock *MongoServerMock) RenewSchedulerLockCalls() []struct {
+	Ctx    context.Context
+	LockID string
+} {
+	var calls []struct {
+		Ctx    context.Context
+		LockID string
+	}
+	lockMongoServerMockRenewSchedulerLock.RLock()
+	calls = mock.calls.RenewSchedulerLock
+	lockMongoServerMockRenewSchedulerLock.RUnlock()
+	return calls
+}
+
+// StartImageRevision calls StartImageRevisionFunc.
+func (mock *MongoServerMock) StartImageRevision(ctx context.Context, id string, archived *models.Revision) error {
+	if mock.StartImageRevisionFunc == nil {
+		panic("MongoServerMock.StartImageRevisionFunc: method is nil but MongoServer.StartImageRevision was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		ID       string
+		Archived *models.Revision
+	}{
+		Ctx:      ctx,
+		ID:       id,
+		Archived: archived,
+	}
+	lockMongoServerMockStartImageRevision.Lock()
+	mock.calls.StartImageRevision = append(mock.calls.StartImageRevision, callInfo)
+	lockMongoServerMockStartImageRevision.Unlock()
+	return mock.StartImageRevisionFunc(ctx, id, archived)
+}
+
+// StartImageRevisionCalls gets all the calls that were made to StartImageRevision.
+// Check the length with:
+//     len(mockedMongoServer.StartImageRevisionCalls())
+func (mock *MongoServerMock) StartImageRevisionCalls() []struct {
+	Ctx      context.Context
+	ID       string
+	Archived *models.Revision
+} {
+	var calls []struct {
+		Ctx      context.Context
+		ID       string
+		Archived *models.Revision
+	}
+	lockMongoServerMockStartImageRevision.RLock()
+	calls = mock.calls.StartImageRevision
+	lockMongoServerMockStartImageRevision.RUnlock()
+	return calls
+}
+
+// UnlockDeadLetterRetrier calls UnlockDeadLetterRetrierFunc.
+func (mock *MongoServerMock) UnlockDeadLetterRetrier(ctx context.Context, lockID string) {
+	if mock.UnlockDeadLetterRetrierFunc == nil {
+		panic("MongoServerMock.UnlockDeadLetterRetrierFunc: method is nil but MongoServer.UnlockDeadLetterRetrier was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		LockID string
+	}{
+		Ctx:    ctx,
+		LockID: lockID,
+	}
+	lockMongoServerMockUnlockDeadLetterRetrier.Lock()
+	mock.calls.UnlockDeadLetterRetrier = append(mock.calls.UnlockDeadLetterRetrier, callInfo)
+	lockMongoServerMockUnlockDeadLetterRetrier.Unlock()
+	mock.UnlockDeadLetterRetrierFunc(ctx, lockID)
+}
+
+// UnlockDeadLetterRetrierCalls gets all the calls that were made to UnlockDeadLetterRetrier.
+// Check the length with:
+//     len(mockedMongoServer.UnlockDeadLetterRetrierCalls())
+func (mock *MongoServerMock) UnlockDeadLetterRetrierCalls() []struct {
+	Ctx    context.Context
+	LockID string
+} {
+	var calls []struct {
+		Ctx    context.Context
+		LockID string
+	}
+	lockMongoServerMockUnlockDeadLetterRetrier.RLock()
+	calls = mock.calls.UnlockDeadLetterRetrier
+	lockMongoServerMockUnlockDeadLetterRetrier.RUnlock()
+	return calls
+}
+
+// UnlockImage calls UnlockImageFunc.
+func (mock *MongoServerMock) UnlockImage(ctx context.Context, lockID string) {
+	if mock.UnlockImageFunc == nil {
+		panic("MongoServerMock.UnlockImageFunc: method is nil but MongoServer.UnlockImage was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		LockID string
+	}{
+		Ctx:    ctx,
+		LockID: lockID,
 	}
 	lockMongoServerMockUnlockImage.Lock()
 	mock.calls.UnlockImage = append(mock.calls.UnlockImage, callInfo)
@@ -356,6 +1512,158 @@ func (mock *MongoServerMock) UnlockImageCalls() []struct {
 	return calls
 }
 
+// UnlockScheduler calls UnlockSchedulerFunc.
+func (mock *MongoServerMock) UnlockScheduler(ctx context.Context, lockID string) {
+	if mock.UnlockSchedulerFunc == nil {
+		panic("MongoServerMock.UnlockSchedulerFunc: method is nil but MongoServer.UnlockScheduler was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		LockID string
+	}{
+		Ctx:    ctx,
+		LockID: lockID,
+	}
+	lockMongoServerMockUnlockScheduler.Lock()
+	mock.calls.UnlockScheduler = append(mock.calls.UnlockScheduler, callInfo)
+	lockMongoServerMockUnlockScheduler.Unlock()
+	mock.UnlockSchedulerFunc(ctx, lockID)
+}
+
+// UnlockSchedulerCalls gets all the calls that were made to UnlockScheduler.
+// Check the length with:
+//     len(mockedMongoServer.UnlockSchedulerCalls())
+func (mock *MongoServerMock) UnlockSchedulerCalls() []struct {
+	Ctx    context.Context
+	LockID string
+} {
+	var calls []struct {
+		Ctx    context.Context
+		LockID string
+	}
+	lockMongoServerMockUnlockScheduler.RLock()
+	calls = mock.calls.UnlockScheduler
+	lockMongoServerMockUnlockScheduler.RUnlock()
+	return calls
+}
+
+// UnsetScheduledPublish calls UnsetScheduledPublishFunc.
+func (mock *MongoServerMock) UnsetScheduledPublish(ctx context.Context, id string) error {
+	if mock.UnsetScheduledPublishFunc == nil {
+		panic("MongoServerMock.UnsetScheduledPublishFunc: method is nil but MongoServer.UnsetScheduledPublish was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  string
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	lockMongoServerMockUnsetScheduledPublish.Lock()
+	mock.calls.UnsetScheduledPublish = append(mock.calls.UnsetScheduledPublish, callInfo)
+	lockMongoServerMockUnsetScheduledPublish.Unlock()
+	return mock.UnsetScheduledPublishFunc(ctx, id)
+}
+
+// UnsetScheduledPublishCalls gets all the calls that were made to UnsetScheduledPublish.
+// Check the length with:
+//     len(mockedMongoServer.UnsetScheduledPublishCalls())
+func (mock *MongoServerMock) UnsetScheduledPublishCalls() []struct {
+	Ctx context.Context
+	ID  string
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  string
+	}
+	lockMongoServerMockUnsetScheduledPublish.RLock()
+	calls = mock.calls.UnsetScheduledPublish
+	lockMongoServerMockUnsetScheduledPublish.RUnlock()
+	return calls
+}
+
+// UpdateDeadLetterAttempt calls UpdateDeadLetterAttemptFunc.
+func (mock *MongoServerMock) UpdateDeadLetterAttempt(ctx context.Context, deadLetter *models.DeadLetter) error {
+	if mock.UpdateDeadLetterAttemptFunc == nil {
+		panic("MongoServerMock.UpdateDeadLetterAttemptFunc: method is nil but MongoServer.UpdateDeadLetterAttempt was just called")
+	}
+	callInfo := struct {
+		Ctx        context.Context
+		DeadLetter *models.DeadLetter
+	}{
+		Ctx:        ctx,
+		DeadLetter: deadLetter,
+	}
+	lockMongoServerMockUpdateDeadLetterAttempt.Lock()
+	mock.calls.UpdateDeadLetterAttempt = append(mock.calls.UpdateDeadLetterAttempt, callInfo)
+	lockMongoServerMockUpdateDeadLetterAttempt.Unlock()
+	return mock.UpdateDeadLetterAttemptFunc(ctx, deadLetter)
+}
+
+// UpdateDeadLetterAttemptCalls gets all the calls that were made to UpdateDeadLetterAttempt.
+// Check the length with:
+//     len(mockedMongoServer.UpdateDeadLetterAttemptCalls())
+func (mock *MongoServerMock) UpdateDeadLetterAttemptCalls() []struct {
+	Ctx        context.Context
+	DeadLetter *models.DeadLetter
+} {
+	var calls []struct {
+		Ctx        context.Context
+		DeadLetter *models.DeadLetter
+	}
+	lockMongoServerMockUpdateDeadLetterAttempt.RLock()
+	calls = mock.calls.UpdateDeadLetterAttempt
+	lockMongoServerMockUpdateDeadLetterAttempt.RUnlock()
+	return calls
+}
+
+// UpdateDownload calls UpdateDownloadFunc.
+func (mock *MongoServerMock) UpdateDownload(ctx context.Context, id string, imageState string, variantState string, download *models.Download) (bool, error) {
+	if mock.UpdateDownloadFunc == nil {
+		panic("MongoServerMock.UpdateDownloadFunc: method is nil but MongoServer.UpdateDownload was just called")
+	}
+	callInfo := struct {
+		Ctx          context.Context
+		ID           string
+		ImageState   string
+		VariantState string
+		Download     *models.Download
+	}{
+		Ctx:          ctx,
+		ID:           id,
+		ImageState:   imageState,
+		VariantState: variantState,
+		Download:     download,
+	}
+	lockMongoServerMockUpdateDownload.Lock()
+	mock.calls.UpdateDownload = append(mock.calls.UpdateDownload, callInfo)
+	lockMongoServerMockUpdateDownload.Unlock()
+	return mock.UpdateDownloadFunc(ctx, id, imageState, variantState, download)
+}
+
+// UpdateDownloadCalls gets all the calls that were made to UpdateDownload.
+// Check the length with:
+//     len(mockedMongoServer.UpdateDownloadCalls())
+func (mock *MongoServerMock) UpdateDownloadCalls() []struct {
+	Ctx          context.Context
+	ID           string
+	ImageState   string
+	VariantState string
+	Download     *models.Download
+} {
+	var calls []struct {
+		Ctx          context.Context
+		ID           string
+		ImageState   string
+		VariantState string
+		Download     *models.Download
+	}
+	lockMongoServerMockUpdateDownload.RLock()
+	calls = mock.calls.UpdateDownload
+	lockMongoServerMockUpdateDownload.RUnlock()
+	return calls
+}
+
 // UpdateImage calls UpdateImageFunc.
 func (mock *MongoServerMock) UpdateImage(ctx context.Context, id string, image *models.Image) (bool, error) {
 	if mock.UpdateImageFunc == nil {
@@ -395,6 +1703,45 @@ func (mock *MongoServerMock) UpdateImageCalls() []struct {
 	return calls
 }
 
+// UpdateImageState calls UpdateImageStateFunc.
+func (mock *MongoServerMock) UpdateImageState(ctx context.Context, previousState string, image *models.Image) (bool, error) {
+	if mock.UpdateImageStateFunc == nil {
+		panic("MongoServerMock.UpdateImageStateFunc: method is nil but MongoServer.UpdateImageState was just called")
+	}
+	callInfo := struct {
+		Ctx           context.Context
+		PreviousState string
+		Image         *models.Image
+	}{
+		Ctx:           ctx,
+		PreviousState: previousState,
+		Image:         image,
+	}
+	lockMongoServerMockUpdateImageState.Lock()
+	mock.calls.UpdateImageState = append(mock.calls.UpdateImageState, callInfo)
+	lockMongoServerMockUpdateImageState.Unlock()
+	return mock.UpdateImageStateFunc(ctx, previousState, image)
+}
+
+// UpdateImageStateCalls gets all the calls that were made to UpdateImageState.
+// Check the length with:
+//     len(mockedMongoServer.UpdateImageStateCalls())
+func (mock *MongoServerMock) UpdateImageStateCalls() []struct {
+	Ctx           context.Context
+	PreviousState string
+	Image         *models.Image
+} {
+	var calls []struct {
+		Ctx           context.Context
+		PreviousState string
+		Image         *models.Image
+	}
+	lockMongoServerMockUpdateImageState.RLock()
+	calls = mock.calls.UpdateImageState
+	lockMongoServerMockUpdateImageState.RUnlock()
+	return calls
+}
+
 // UpsertImage calls UpsertImageFunc.
 func (mock *MongoServerMock) UpsertImage(ctx context.Context, id string, image *models.Image) error {
 	if mock.UpsertImageFunc == nil {
@@ -433,3 +1780,77 @@ func (mock *MongoServerMock) UpsertImageCalls() []struct {
 	lockMongoServerMockUpsertImage.RUnlock()
 	return calls
 }
+
+// WithdrawImage calls WithdrawImageFunc.
+func (mock *MongoServerMock) WithdrawImage(ctx context.Context, id string, image *models.Image) error {
+	if mock.WithdrawImageFunc == nil {
+		panic("MongoServerMock.WithdrawImageFunc: method is nil but MongoServer.WithdrawImage was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		ID    string
+		Image *models.Image
+	}{
+		Ctx:   ctx,
+		ID:    id,
+		Image: image,
+	}
+	lockMongoServerMockWithdrawImage.Lock()
+	mock.calls.WithdrawImage = append(mock.calls.WithdrawImage, callInfo)
+	lockMongoServerMockWithdrawImage.Unlock()
+	return mock.WithdrawImageFunc(ctx, id, image)
+}
+
+// WithdrawImageCalls gets all the calls that were made to WithdrawImage.
+// Check the length with:
+//     len(mockedMongoServer.WithdrawImageCalls())
+func (mock *MongoServerMock) WithdrawImageCalls() []struct {
+	Ctx   context.Context
+	ID    string
+	Image *models.Image
+} {
+	var calls []struct {
+		Ctx   context.Context
+		ID    string
+		Image *models.Image
+	}
+	lockMongoServerMockWithdrawImage.RLock()
+	calls = mock.calls.WithdrawImage
+	lockMongoServerMockWithdrawImage.RUnlock()
+	return calls
+}
+
+// WithdrawImageVersions calls WithdrawImageVersionsFunc.
+func (mock *MongoServerMock) WithdrawImageVersions(ctx context.Context, imageID string) ([]models.Version, error) {
+	if mock.WithdrawImageVersionsFunc == nil {
+		panic("MongoServerMock.WithdrawImageVersionsFunc: method is nil but MongoServer.WithdrawImageVersions was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		ImageID string
+	}{
+		Ctx:     ctx,
+		ImageID: imageID,
+	}
+	lockMongoServerMockWithdrawImageVersions.Lock()
+	mock.calls.WithdrawImageVersions = append(mock.calls.WithdrawImageVersions, callInfo)
+	lockMongoServerMockWithdrawImageVersions.Unlock()
+	return mock.WithdrawImageVersionsFunc(ctx, imageID)
+}
+
+// WithdrawImageVersionsCalls gets all the calls that were made to WithdrawImageVersions.
+// Check the length with:
+//     len(mockedMongoServer.WithdrawImageVersionsCalls())
+func (mock *MongoServerMock) WithdrawImageVersionsCalls() []struct {
+	Ctx     context.Context
+	ImageID string
+} {
+	var calls []struct {
+		Ctx     context.Context
+		ImageID string
+	}
+	lockMongoServerMockWithdrawImageVersions.RLock()
+	calls = mock.calls.WithdrawImageVersions
+	lockMongoServerMockWithdrawImageVersions.RUnlock()
+	return calls
+}
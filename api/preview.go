@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/dp-image-api/models"
+	"github.com/ONSdigital/dp-net/v3/handlers"
+	dpreq "github.com/ONSdigital/dp-net/v3/request"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/gorilla/mux"
+)
+
+// GetDownloadPreviewURLHandler is a handler that returns a signed download service URL of an image download variant,
+// so that it can be previewed before it is published. The URL expires after the configured preview URL expiry.
+func (api *API) GetDownloadPreviewURLHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	vars := mux.Vars(req)
+	id := vars["id"]
+	variant := vars["variant"]
+	hColID := ctx.Value(handlers.CollectionID.Context())
+	logdata := log.Data{
+		handlers.CollectionID.Header(): hColID,
+		"request-id":                   ctx.Value(dpreq.RequestIdKey),
+		"image-id":                     id,
+		"download-variant":             variant,
+	}
+
+	if api.previewSigner == nil {
+		handleError(ctx, w, apierrors.ErrPreviewURLsDisabled, logdata)
+		return
+	}
+
+	image, err := api.mongoDB.GetImage(ctx, id)
+	if err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+
+	download, found := image.Downloads[variant]
+	if !found {
+		handleError(ctx, w, apierrors.ErrVariantNotFound, logdata)
+		return
+	}
+	if !download.IsPreviewable() {
+		logdata["download-state"] = download.State
+		handleError(ctx, w, apierrors.ErrVariantNotPreviewable, logdata)
+		return
+	}
+
+	expiresAt := time.Now().UTC().Add(api.previewURLExpiry).Truncate(time.Second)
+	href, err := api.previewSigner.Sign(api.urlBuilder.BuildDownloadURL(id, variant, image.CurrentRevision(), image.Filename), expiresAt)
+	if err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+
+	// signed urls must not be stored by any cache, as they grant access to unpublished files
+	w.Header().Set("Cache-Control", cacheControlNoStore)
+	if err := WriteJSONBody(models.PreviewURL{Href: href, ExpiresAt: &expiresAt}, w, http.StatusOK); err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+	log.Info(ctx, "Successfully created download preview url", logdata)
+}
@@ -0,0 +1,114 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	dpauth "github.com/ONSdigital/dp-authorisation/auth"
+	"github.com/ONSdigital/dp-image-api/api/mock"
+	"github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/dp-image-api/config"
+	"github.com/ONSdigital/dp-image-api/models"
+	"github.com/ONSdigital/dp-image-api/signedurl"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testSigningKey = "0123456789abcdef0123456789abcdef"
+
+// servePreviewURL serves an authenticated GET request for the preview url of the provided image download variant
+func servePreviewURL(h http.Handler, imageID, variant string) *httptest.ResponseRecorder {
+	r := newAdminRequest(http.MethodGet, fmt.Sprintf("http://localhost:24700/images/%s/downloads/%s/preview-url", imageID, variant), "")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestGetDownloadPreviewURLHandler(t *testing.T) {
+	newMongoDBMock := func() *mock.MongoServerMock {
+		return &mock.MongoServerMock{
+			GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+				switch id {
+				case testImageID1:
+					return dbFullImageWithDownloads(models.StateImporting,
+						dbDownloadWithID(testImageID1, testVariantOriginal, models.StateDownloadImported),
+						dbDownloadWithID(testImageID1, testVariantAlternative, models.StateDownloadImporting)), nil
+				case testImageID2:
+					return dbFullImageWithDownloads(models.StatePublished, dbDownload(models.StateDownloadPublished)), nil
+				default:
+					return nil, apierrors.ErrImageNotFound
+				}
+			},
+		}
+	}
+	authHandlerMock := &mock.AuthHandlerMock{
+		RequireFunc: func(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+			return handler
+		},
+	}
+
+	Convey("Given an image API in publishing mode, signing preview urls that expire in 15 minutes", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		cfg.IsPublishing = true
+		cfg.PreviewURLSigningKey = testSigningKey
+		cfg.PreviewURLExpiry = 15 * time.Minute
+		cfg.DownloadServiceURL = downloadServiceURL
+		mongoDBMock := newMongoDBMock()
+		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+		Convey("When the preview url of an imported download variant is requested", func() {
+			before := time.Now().Truncate(time.Second)
+			w := servePreviewURL(imageAPI.Router, testImageID1, testVariantOriginal)
+
+			Convey("Then a download service url signed with the key, and its expiry time, are returned without being cached", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Cache-Control"), ShouldEqual, "no-store")
+
+				var previewURL models.PreviewURL
+				So(json.Unmarshal(w.Body.Bytes(), &previewURL), ShouldBeNil)
+				So(previewURL.ExpiresAt, ShouldNotBeNil)
+				So(*previewURL.ExpiresAt, ShouldHappenOnOrBetween, before.Add(15*time.Minute), time.Now().Add(15*time.Minute))
+
+				u, err := url.Parse(previewURL.Href)
+				So(err, ShouldBeNil)
+				So(u.Scheme+"://"+u.Host+u.Path, ShouldEqual, testDownloadHref)
+				So(u.Query().Get(signedurl.ExpiresParam), ShouldEqual, fmt.Sprint(previewURL.ExpiresAt.Unix()))
+				So(signedurl.NewVerifier([]byte(testSigningKey)).Verify(u), ShouldBeNil)
+				So(signedurl.NewVerifier([]byte("another key")).Verify(u), ShouldEqual, signedurl.ErrInvalidSignature)
+			})
+		})
+
+		Convey("Then the preview url of a published download variant is returned", func() {
+			So(servePreviewURL(imageAPI.Router, testImageID2, testVariantOriginal).Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("Then the preview url of a download variant that is not imported yet is forbidden", func() {
+			So(servePreviewURL(imageAPI.Router, testImageID1, testVariantAlternative).Code, ShouldEqual, http.StatusForbidden)
+		})
+
+		Convey("Then the preview url of a nonexistent image or download variant is not found", func() {
+			So(servePreviewURL(imageAPI.Router, "inexistent", testVariantOriginal).Code, ShouldEqual, http.StatusNotFound)
+			So(servePreviewURL(imageAPI.Router, testImageID1, "inexistent").Code, ShouldEqual, http.StatusNotFound)
+		})
+	})
+
+	Convey("Given an image API in publishing mode without a preview url signing key", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		cfg.IsPublishing = true
+		cfg.PreviewURLSigningKey = ""
+		mongoDBMock := newMongoDBMock()
+		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+		Convey("Then preview urls are not implemented", func() {
+			So(servePreviewURL(imageAPI.Router, testImageID1, testVariantOriginal).Code, ShouldEqual, http.StatusNotImplemented)
+			So(mongoDBMock.GetImageCalls(), ShouldHaveLength, 0)
+		})
+	})
+}
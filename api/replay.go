@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/dp-image-api/config"
+	"github.com/ONSdigital/dp-image-api/event"
+	"github.com/ONSdigital/dp-image-api/models"
+	"github.com/ONSdigital/dp-image-api/schema"
+	dpurl "github.com/ONSdigital/dp-image-api/url"
+	kafka "github.com/ONSdigital/dp-kafka/v3"
+	dpreq "github.com/ONSdigital/dp-net/v3/request"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// EventReplayer re-emits the kafka events of images that are stuck in 'uploaded' or 'published' state,
+// rebuilding them from the current state of the images in mongoDB
+type EventReplayer struct {
+	mongoDB           MongoServer
+	uploadProducer    *event.AvroProducer
+	publishedProducer *event.AvroProducer
+	urlBuilder        *dpurl.Builder
+	rate              int
+}
+
+// replayEvent is an event that needs to be sent by a replay
+type replayEvent struct {
+	models.ReplayedEvent
+	send func(ctx context.Context) error
+}
+
+// NewEventReplayer creates an event replayer that sends at most cfg.EventReplayRate events per second with the provided kafka producers.
+// The kafka producers may be nil if the replayer is only used for dry runs.
+func NewEventReplayer(cfg *config.Config, mongoDB MongoServer, uploadedKafkaProducer, publishedKafkaProducer kafka.IProducer) *EventReplayer {
+	r := &EventReplayer{
+		mongoDB:    mongoDB,
+		urlBuilder: dpurl.NewBuilderFromConfig(cfg),
+		rate:       cfg.EventReplayRate,
+	}
+	if uploadedKafkaProducer != nil {
+		r.uploadProducer = newEventProducer(cfg, uploadedKafkaProducer, schema.ImageUploadedEvent)
+	}
+	if publishedKafkaProducer != nil {
+		r.publishedProducer = newEventProducer(cfg, publishedKafkaProducer, schema.ImagePublishedEvent)
+	}
+	return r
+}
+
+// ReplayEventsHandler is a handler that re-emits the kafka events of the images matching the filter provided in the request body
+func (api *API) ReplayEventsHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	logdata := log.Data{
+		"request-id": ctx.Value(dpreq.RequestIdKey),
+	}
+
+	replayReq := &models.ReplayRequest{}
+	if err := ReadJSONBody(ctx, req.Body, replayReq); err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+	logdata["replay_request"] = replayReq
+
+	result, err := api.replayer.Replay(ctx, replayReq)
+	if err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+	logdata["count"] = result.Count
+	logdata["failed"] = result.Failed
+
+	if err := WriteJSONBody(result, w, http.StatusOK); err != nil {
+		handleError(ctx, w, err, logdata)
+		return
+	}
+	log.Info(ctx, "successfully replayed events", logdata)
+}
+
+// Replay re-emits the 'image-uploaded' event of every image in 'uploaded' state, and the 'image-published' event of every
+// variant in 'published' state of images in 'published' state, for the images matching the provided request.
+// Events are sent at the configured rate, and a failure to send an event is reported in the result without stopping the replay.
+// For dry runs, the events that would be sent are returned without sending them.
+func (r *EventReplayer) Replay(ctx context.Context, req *models.ReplayRequest) (*models.ReplayResult, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	images, err := r.mongoDB.GetImagesForReplay(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []replayEvent{}
+	for i := range images {
+		events = append(events, r.generateReplayEvents(&images[i])...)
+	}
+
+	return r.send(ctx, events, req.DryRun)
+}
+
+// Republish re-emits the 'image-published' event of every download variant of the image with the provided ID, so that its files
+// are published again. The image needs to be in 'published' or 'completed' state. For dry runs, the events are returned without sending them.
+func (r *EventReplayer) Republish(ctx context.Context, id string, dryRun bool) (*models.ReplayResult, error) {
+	image, err := r.mongoDB.GetImage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if image.State != models.StatePublished.String() && image.State != models.StateCompleted.String() {
+		return nil, apierrors.ErrImageNotRepublishable
+	}
+
+	return r.send(ctx, r.generatePublishedReplayEvents(image, false), dryRun)
+}
+
+// send sends the provided events at the configured rate, reporting the error of any event that could not be sent
+func (r *EventReplayer) send(ctx context.Context, events []replayEvent, dryRun bool) (*models.ReplayResult, error) {
+	result := &models.ReplayResult{
+		DryRun: dryRun,
+		Count:  len(events),
+		Items:  make([]models.ReplayedEvent, 0, len(events)),
+	}
+	if dryRun {
+		for _, e := range events {
+			result.Items = append(result.Items, e.ReplayedEvent)
+		}
+		return result, nil
+	}
+
+	// A non-positive rate disables the rate limiting
+	var tick <-chan time.Time
+	if r.rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(r.rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for i, e := range events {
+		if i > 0 && tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		if err := e.send(ctx); err != nil {
+			log.Error(ctx, "failed to replay event", err, log.Data{"event_type": e.EventType, "image-id": e.ImageID, "variant": e.Variant})
+			e.Error = err.Error()
+			result.Failed++
+		}
+		result.Items = append(result.Items, e.ReplayedEvent)
+	}
+
+	return result, nil
+}
+
+// generateReplayEvents creates the events that need to be replayed for the provided image, according to its state.
+// Image published events are only created for the variants that are still in 'published' state, sorted by variant.
+func (r *EventReplayer) generateReplayEvents(image *models.Image) (events []replayEvent) {
+	switch image.State {
+	case models.StateUploaded.String():
+		e := generateImageUploadedEvent(image.ID, image)
+		events = append(events, replayEvent{
+			ReplayedEvent: models.ReplayedEvent{EventType: models.ReplayImageUploaded, ImageID: image.ID},
+			send: func(ctx context.Context) error {
+				if e.Path == "" {
+					return apierrors.ErrImageUploadPathEmpty
+				}
+				return r.uploadProducer.ImageUploaded(ctx, e)
+			},
+		})
+	case models.StatePublished.String():
+		events = r.generatePublishedReplayEvents(image, true)
+	}
+	return events
+}
+
+// generatePublishedReplayEvents creates the image published events of the provided image, sorted by variant.
+// If onlyPublished is true, the events are only created for the variants that are still in 'published' state.
+func (r *EventReplayer) generatePublishedReplayEvents(image *models.Image, onlyPublished bool) (events []replayEvent) {
+	for _, e := range generateImagePublishEvents(r.urlBuilder, image) {
+		if onlyPublished && image.Downloads[e.ImageVariant].State != models.StateDownloadPublished.String() {
+			continue
+		}
+		events = append(events, replayEvent{
+			ReplayedEvent: models.ReplayedEvent{EventType: models.ReplayImagePublished, ImageID: image.ID, Variant: e.ImageVariant},
+			send: func(ctx context.Context) error {
+				return r.publishedProducer.ImagePublished(ctx, e)
+			},
+		})
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Variant < events[j].Variant })
+	return events
+}
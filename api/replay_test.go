@@ -0,0 +1,219 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	dpauth "github.com/ONSdigital/dp-authorisation/auth"
+	"github.com/ONSdigital/dp-image-api/api"
+	"github.com/ONSdigital/dp-image-api/api/mock"
+	"github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/dp-image-api/config"
+	"github.com/ONSdigital/dp-image-api/event"
+	"github.com/ONSdigital/dp-image-api/models"
+	"github.com/ONSdigital/dp-image-api/schema"
+	dpreq "github.com/ONSdigital/dp-net/v3/request"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var (
+	testReplayFrom = time.Date(2020, time.April, 26, 8, 0, 0, 0, time.UTC)
+	testReplayTo   = time.Date(2020, time.April, 27, 8, 0, 0, 0, time.UTC)
+)
+
+// dbImagesForReplay returns an image in uploaded state and an image in published state
+// with a published variant and a variant that has already been completed
+func dbImagesForReplay() []models.Image {
+	uploaded := dbFullImage(models.StateUploaded)
+	published := dbFullImageWithDownloads(models.StatePublished,
+		dbDownloadWithID(testImageID1, testVariantOriginal, models.StateDownloadPublished),
+		dbDownloadWithID(testImageID1, testVariantAlternative, models.StateDownloadCompleted),
+	)
+	published.ID = testImageID1
+	return []models.Image{*uploaded, *published}
+}
+
+func TestReplayEventsHandler(t *testing.T) {
+	Convey("Given a valid config and auth handler", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		authHandlerMock := &mock.AuthHandlerMock{
+			RequireFunc: func(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+				return handler
+			},
+		}
+
+		Convey("And a MongoDB with an uploaded image and a published image", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetImagesForReplayFunc: func(ctx context.Context, req *models.ReplayRequest) ([]models.Image, error) {
+					return dbImagesForReplay(), nil
+				},
+			}
+			uploadedProducer := newBufferedKafkaProducer()
+			publishedProducer := newBufferedKafkaProducer()
+
+			Convey("Calling 'replay events' results in 200 OK response, with the events of the stuck image and variant sent to kafka", func() {
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, uploadedProducer, publishedProducer, kafkaStubProducer)
+				r := httptest.NewRequest(http.MethodPost, "http://localhost:24700/admin/replay-events", bytes.NewBufferString(
+					`{"collection_id":"1234","from":"2020-04-26T08:00:00Z","to":"2020-04-27T08:00:00Z","image_ids":["imageImageID1","imageImageID2"]}`))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(mongoDBMock.GetImagesForReplayCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.GetImagesForReplayCalls()[0].Req, ShouldResemble, &models.ReplayRequest{
+					CollectionID: testCollectionID1,
+					From:         &testReplayFrom,
+					To:           &testReplayTo,
+					ImageIDs:     []string{testImageID1, testImageID2},
+				})
+
+				payload, err := io.ReadAll(w.Body)
+				So(err, ShouldBeNil)
+				result := &models.ReplayResult{}
+				So(json.Unmarshal(payload, result), ShouldBeNil)
+				So(result, ShouldResemble, &models.ReplayResult{
+					Count: 2,
+					Items: []models.ReplayedEvent{
+						{EventType: models.ReplayImageUploaded, ImageID: testImageID2},
+						{EventType: models.ReplayImagePublished, ImageID: testImageID1, Variant: testVariantOriginal},
+					},
+				})
+
+				So(uploadedProducer.Channels().Output, ShouldHaveLength, 1)
+				uploaded := &event.ImageUploaded{}
+				So(schema.ImageUploadedEvent.Unmarshal(<-uploadedProducer.Channels().Output, uploaded), ShouldBeNil)
+				So(uploaded, ShouldResemble, &event.ImageUploaded{ImageID: testImageID2, Path: testUploadFilename, Filename: "some-image-name"})
+
+				So(publishedProducer.Channels().Output, ShouldHaveLength, 1)
+				published := &event.ImagePublished{}
+				So(schema.ImagePublishedEvent.Unmarshal(<-publishedProducer.Channels().Output, published), ShouldBeNil)
+				So(published.ImageID, ShouldEqual, testImageID1)
+				So(published.ImageVariant, ShouldEqual, testVariantOriginal)
+			})
+
+			Convey("Calling 'replay events' as a dry run results in 200 OK response, with the events that would be sent and nothing sent to kafka", func() {
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, uploadedProducer, publishedProducer, kafkaStubProducer)
+				r := httptest.NewRequest(http.MethodPost, "http://localhost:24700/admin/replay-events", bytes.NewBufferString(`{"dry_run":true}`))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusOK)
+
+				payload, err := io.ReadAll(w.Body)
+				So(err, ShouldBeNil)
+				result := &models.ReplayResult{}
+				So(json.Unmarshal(payload, result), ShouldBeNil)
+				So(result, ShouldResemble, &models.ReplayResult{
+					DryRun: true,
+					Count:  2,
+					Items: []models.ReplayedEvent{
+						{EventType: models.ReplayImageUploaded, ImageID: testImageID2},
+						{EventType: models.ReplayImagePublished, ImageID: testImageID1, Variant: testVariantOriginal},
+					},
+				})
+				So(uploadedProducer.Channels().Output, ShouldHaveLength, 0)
+				So(publishedProducer.Channels().Output, ShouldHaveLength, 0)
+			})
+
+			Convey("Calling 'replay events' when the published kafka producer is not initialised results in 200 OK response, with the failed event reported", func() {
+				publishedProducer.IsInitialisedFunc = func() bool { return false }
+				imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, uploadedProducer, publishedProducer, kafkaStubProducer)
+				r := httptest.NewRequest(http.MethodPost, "http://localhost:24700/admin/replay-events", bytes.NewBufferString(`{}`))
+				r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+				w := httptest.NewRecorder()
+				imageAPI.Router.ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusOK)
+
+				payload, err := io.ReadAll(w.Body)
+				So(err, ShouldBeNil)
+				result := &models.ReplayResult{}
+				So(json.Unmarshal(payload, result), ShouldBeNil)
+				So(result.Count, ShouldEqual, 2)
+				So(result.Failed, ShouldEqual, 1)
+				So(result.Items[0].Error, ShouldBeEmpty)
+				So(result.Items[1].Error, ShouldEqual, event.ErrProducerNotInitialised.Error())
+				So(uploadedProducer.Channels().Output, ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("Calling 'replay events' with a state that cannot be replayed results in 400 BadRequest response", func() {
+			mongoDBMock := &mock.MongoServerMock{}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+			r := httptest.NewRequest(http.MethodPost, "http://localhost:24700/admin/replay-events", bytes.NewBufferString(`{"state":"importing"}`))
+			r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+			w := httptest.NewRecorder()
+			imageAPI.Router.ServeHTTP(w, r)
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+			So(mongoDBMock.GetImagesForReplayCalls(), ShouldHaveLength, 0)
+		})
+
+		Convey("Calling 'replay events' when MongoDB fails to return the images results in 500 InternalServerError response", func() {
+			mongoDBMock := &mock.MongoServerMock{
+				GetImagesForReplayFunc: func(ctx context.Context, req *models.ReplayRequest) ([]models.Image, error) {
+					return nil, errMongoDB
+				},
+			}
+			imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+			r := httptest.NewRequest(http.MethodPost, "http://localhost:24700/admin/replay-events", bytes.NewBufferString(`{}`))
+			r = r.WithContext(context.WithValue(r.Context(), dpreq.FlorenceIdentityKey, testUserAuthToken))
+			w := httptest.NewRecorder()
+			imageAPI.Router.ServeHTTP(w, r)
+			So(w.Code, ShouldEqual, http.StatusInternalServerError)
+		})
+	})
+}
+
+func TestRepublish(t *testing.T) {
+	Convey("Given a valid config and a MongoDB with a completed image", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		completed := dbFullImageWithDownloads(models.StateCompleted,
+			dbDownloadWithID(testImageID2, testVariantOriginal, models.StateDownloadCompleted),
+			dbDownloadWithID(testImageID2, testVariantAlternative, models.StateDownloadCompleted),
+		)
+		mongoDBMock := &mock.MongoServerMock{
+			GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+				return completed, nil
+			},
+		}
+		publishedProducer := newBufferedKafkaProducer()
+		replayer := api.NewEventReplayer(cfg, mongoDBMock, nil, publishedProducer)
+
+		Convey("When Republish is called, then the published event of every variant is sent, sorted by variant", func() {
+			result, err := replayer.Republish(context.Background(), testImageID2, false)
+			So(err, ShouldBeNil)
+			So(mongoDBMock.GetImageCalls(), ShouldHaveLength, 1)
+			So(mongoDBMock.GetImageCalls()[0].ID, ShouldEqual, testImageID2)
+			So(result, ShouldResemble, &models.ReplayResult{
+				Count: 2,
+				Items: []models.ReplayedEvent{
+					{EventType: models.ReplayImagePublished, ImageID: testImageID2, Variant: testVariantAlternative},
+					{EventType: models.ReplayImagePublished, ImageID: testImageID2, Variant: testVariantOriginal},
+				},
+			})
+			So(publishedProducer.Channels().Output, ShouldHaveLength, 2)
+		})
+
+		Convey("When Republish is called as a dry run, then the events are returned without being sent", func() {
+			result, err := replayer.Republish(context.Background(), testImageID2, true)
+			So(err, ShouldBeNil)
+			So(result.DryRun, ShouldBeTrue)
+			So(result.Count, ShouldEqual, 2)
+			So(publishedProducer.Channels().Output, ShouldHaveLength, 0)
+		})
+
+		Convey("When Republish is called for an image that is not published or completed, then the expected error is returned", func() {
+			completed.State = models.StateImported.String()
+			_, err := replayer.Republish(context.Background(), testImageID2, false)
+			So(err, ShouldEqual, apierrors.ErrImageNotRepublishable)
+			So(publishedProducer.Channels().Output, ShouldHaveLength, 0)
+		})
+	})
+}
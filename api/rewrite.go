@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ONSdigital/dp-image-api/models"
+	"github.com/ONSdigital/dp-net/v2/links"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// linksRewriter rewrites the links and public download hrefs of a response for the host and path prefix
+// that the request was forwarded from, according to its X-Forwarded-Host and X-Forwarded-Path-Prefix headers
+type linksRewriter struct {
+	ctx                context.Context
+	apiLinks           *links.Builder
+	downloadHost       *url.URL
+	downloadServiceURL string
+}
+
+// newLinksRewriter returns a rewriter for the provided request, or nil if URL rewriting is disabled.
+// Public download hrefs are only rewritten if the request was forwarded from an api host, which routes
+// downloads under its /downloads path. The path prefix of the API is never applied to download hrefs.
+func (api *API) newLinksRewriter(req *http.Request) *linksRewriter {
+	if !api.enableURLRewriting {
+		return nil
+	}
+	rewriter := &linksRewriter{
+		ctx:                req.Context(),
+		apiLinks:           links.FromHeadersOrDefault(&req.Header, api.apiUrl),
+		downloadServiceURL: api.downloadServiceURL.String(),
+	}
+	if host := req.Header.Get("X-Forwarded-Host"); strings.HasPrefix(host, "api.") {
+		rewriter.downloadHost = &url.URL{Scheme: "https", Host: host}
+	}
+	return rewriter
+}
+
+// rewriteImages returns a copy of the provided images, with their links rewritten
+func (r *linksRewriter) rewriteImages(images []models.Image) ([]models.Image, error) {
+	rewritten := make([]models.Image, 0, len(images))
+	for i := range images {
+		image, err := r.rewriteImage(&images[i])
+		if err != nil {
+			return nil, err
+		}
+		rewritten = append(rewritten, *image)
+	}
+	return rewritten, nil
+}
+
+// rewriteImage returns a copy of the provided image, with its self and downloads links rewritten.
+// The provided image is not modified, as it may be shared with other requests.
+func (r *linksRewriter) rewriteImage(image *models.Image) (*models.Image, error) {
+	rewritten := *image
+	if image.Links != nil {
+		imageLinks := *image.Links
+		if err := r.rewriteLink(&imageLinks.Self, r.apiLinks); err != nil {
+			return nil, err
+		}
+		if err := r.rewriteLink(&imageLinks.Downloads, r.apiLinks); err != nil {
+			return nil, err
+		}
+		rewritten.Links = &imageLinks
+	}
+	return &rewritten, nil
+}
+
+// rewriteDownload returns a copy of the provided download variant, with its self and image links, and its href if it is public, rewritten.
+// Only the hrefs pointing to the download service are rewritten, as hrefs pointing to a CDN are already public.
+func (r *linksRewriter) rewriteDownload(download *models.Download) (*models.Download, error) {
+	rewritten := *download
+	if download.Links != nil {
+		downloadLinks := *download.Links
+		if err := r.rewriteLink(&downloadLinks.Self, r.apiLinks); err != nil {
+			return nil, err
+		}
+		if err := r.rewriteLink(&downloadLinks.Image, r.apiLinks); err != nil {
+			return nil, err
+		}
+		rewritten.Links = &downloadLinks
+	}
+	if r.downloadHost != nil && download.IsPublic() && strings.HasPrefix(download.Href, r.downloadServiceURL) {
+		href, err := links.BuildDownloadLink(strings.TrimPrefix(download.Href, r.downloadServiceURL), r.downloadHost)
+		if err != nil {
+			log.Error(r.ctx, "could not rewrite download href", err, log.Data{"href": download.Href})
+			return nil, err
+		}
+		rewritten.Href = href
+	}
+	return &rewritten, nil
+}
+
+// rewriteDownloads returns a copy of the provided download variants, with their links and public hrefs rewritten
+func (r *linksRewriter) rewriteDownloads(downloads map[string]models.Download) (map[string]models.Download, error) {
+	if downloads == nil {
+		return nil, nil
+	}
+	rewritten := make(map[string]models.Download, len(downloads))
+	for variant := range downloads {
+		download := downloads[variant]
+		rewrittenDownload, err := r.rewriteDownload(&download)
+		if err != nil {
+			return nil, err
+		}
+		rewritten[variant] = *rewrittenDownload
+	}
+	return rewritten, nil
+}
+
+// rewriteRevisions returns a copy of the provided revisions, with the links and public hrefs of their download variants rewritten
+func (r *linksRewriter) rewriteRevisions(revisions []models.Revision) ([]models.Revision, error) {
+	rewritten := make([]models.Revision, 0, len(revisions))
+	for i := range revisions {
+		revision := revisions[i]
+		downloads, err := r.rewriteDownloads(revision.Downloads)
+		if err != nil {
+			return nil, err
+		}
+		revision.Downloads = downloads
+		rewritten = append(rewritten, revision)
+	}
+	return rewritten, nil
+}
+
+// rewriteVersion returns a copy of the provided image version, with its self and image links,
+// and the links and public hrefs of its download variants, rewritten
+func (r *linksRewriter) rewriteVersion(version *models.Version) (*models.Version, error) {
+	rewritten := *version
+	if version.Links != nil {
+		versionLinks := *version.Links
+		if err := r.rewriteLink(&versionLinks.Self, r.apiLinks); err != nil {
+			return nil, err
+		}
+		if err := r.rewriteLink(&versionLinks.Image, r.apiLinks); err != nil {
+			return nil, err
+		}
+		rewritten.Links = &versionLinks
+	}
+	downloads, err := r.rewriteDownloads(version.Downloads)
+	if err != nil {
+		return nil, err
+	}
+	rewritten.Downloads = downloads
+	return &rewritten, nil
+}
+
+// rewriteLink rewrites the provided link with the provided builder, leaving it empty if it is missing
+func (r *linksRewriter) rewriteLink(link *string, builder *links.Builder) error {
+	if *link == "" {
+		return nil
+	}
+	rewritten, err := builder.BuildLink(*link)
+	if err != nil {
+		log.Error(r.ctx, "could not rewrite link", err, log.Data{"link": *link})
+		return err
+	}
+	*link = rewritten
+	return nil
+}
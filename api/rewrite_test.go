@@ -0,0 +1,306 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	dpauth "github.com/ONSdigital/dp-authorisation/auth"
+	"github.com/ONSdigital/dp-image-api/api/mock"
+	"github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/dp-image-api/config"
+	"github.com/ONSdigital/dp-image-api/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	testVariantCDN = "cdn"
+	testCDNHref    = "https://cdn.ons.example/" + testImageID2 + "/cdn/some-image-name"
+)
+
+// rewriteTestDownloadLinks returns the stored links of a download variant of the rewrite test image
+func rewriteTestDownloadLinks(variant string) *models.DownloadLinks {
+	return &models.DownloadLinks{
+		Self:  fmt.Sprintf("http://example.com/images/%s/downloads/%s", testImageID2, variant),
+		Image: fmt.Sprintf("http://example.com/images/%s", testImageID2),
+	}
+}
+
+// rewriteTestPublishedDownload returns a published download variant of the rewrite test image, served by the download service
+func rewriteTestPublishedDownload() models.Download {
+	return models.Download{
+		ID:    testVariantOriginal,
+		State: models.StateDownloadPublished.String(),
+		Href:  fmt.Sprintf("%s/images/%s/%s/%s", downloadServiceURL, testImageID2, testVariantOriginal, testFilename),
+		Links: rewriteTestDownloadLinks(testVariantOriginal),
+	}
+}
+
+// rewriteTestVersion returns the first published version of the rewrite test image
+func rewriteTestVersion() *models.Version {
+	return &models.Version{
+		ImageID: testImageID2,
+		Version: 1,
+		State:   models.StatePublished.String(),
+		Links: &models.VersionLinks{
+			Self:  fmt.Sprintf("http://example.com/images/%s/versions/1", testImageID2),
+			Image: fmt.Sprintf("http://example.com/images/%s", testImageID2),
+		},
+		Downloads: map[string]models.Download{testVariantOriginal: rewriteTestPublishedDownload()},
+	}
+}
+
+// rewriteTestImage returns a published image with a published variant served by the download service,
+// a variant that is still importing, a completed variant served by a CDN without links, and a previous revision
+func rewriteTestImage() *models.Image {
+	return &models.Image{
+		ID:       testImageID2,
+		State:    models.StatePublished.String(),
+		Filename: testFilename,
+		Links: &models.ImageLinks{
+			Self:      fmt.Sprintf("http://example.com/images/%s", testImageID2),
+			Downloads: fmt.Sprintf("http://example.com/images/%s/downloads", testImageID2),
+		},
+		Downloads: map[string]models.Download{
+			testVariantOriginal: rewriteTestPublishedDownload(),
+			testVariantAlternative: {
+				ID:    testVariantAlternative,
+				State: models.StateDownloadImporting.String(),
+				Href:  fmt.Sprintf("%s/images/%s/%s/%s", downloadServiceURL, testImageID2, testVariantAlternative, testFilename),
+				Links: rewriteTestDownloadLinks(testVariantAlternative),
+			},
+			testVariantCDN: {
+				ID:    testVariantCDN,
+				State: models.StateDownloadCompleted.String(),
+				Href:  testCDNHref,
+			},
+		},
+		Revisions: []models.Revision{
+			{
+				Revision:  1,
+				State:     models.StatePublished.String(),
+				Downloads: map[string]models.Download{testVariantOriginal: rewriteTestPublishedDownload()},
+			},
+		},
+	}
+}
+
+// serveRewrittenGet serves an authenticated GET request for the provided path with the provided headers,
+// and decodes the response body into the provided value
+func serveRewrittenGet(h http.Handler, path string, headers map[string]string, v interface{}) int {
+	r := newAdminRequest(http.MethodGet, "http://localhost:24700"+path, "")
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code == http.StatusOK {
+		So(json.Unmarshal(w.Body.Bytes(), v), ShouldBeNil)
+	}
+	return w.Code
+}
+
+func TestURLRewriting(t *testing.T) {
+	image := rewriteTestImage()
+	version := rewriteTestVersion()
+	mongoDBMock := &mock.MongoServerMock{
+		GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+			if id == testImageID2 {
+				return image, nil
+			}
+			return nil, apierrors.ErrImageNotFound
+		},
+		GetImagesFunc: func(ctx context.Context, collectionID string) ([]models.Image, error) {
+			return []models.Image{*image}, nil
+		},
+		GetImageVersionFunc: func(ctx context.Context, imageID string, v int) (*models.Version, error) {
+			if imageID == testImageID2 && v == 1 {
+				return version, nil
+			}
+			return nil, apierrors.ErrImageVersionNotFound
+		},
+	}
+	authHandlerMock := &mock.AuthHandlerMock{
+		RequireFunc: func(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+			return handler
+		},
+	}
+
+	tests := []struct {
+		name            string
+		headers         map[string]string
+		expectedAPIURL  string
+		expectedHrefURL string
+	}{
+		{
+			name:            "without forwarded headers, links are rewritten with the configured url, and hrefs are unchanged",
+			headers:         map[string]string{},
+			expectedAPIURL:  "http://localhost:24700",
+			expectedHrefURL: downloadServiceURL,
+		},
+		{
+			name:            "forwarded from an api host, links are rewritten with that host over https, and hrefs with its downloads path",
+			headers:         map[string]string{"X-Forwarded-Host": "api.ons.example"},
+			expectedAPIURL:  "https://api.ons.example",
+			expectedHrefURL: "https://api.ons.example/downloads",
+		},
+		{
+			name:            "forwarded from an api host with a proxy prefix, links are rewritten with that host and prefix, and hrefs without the prefix",
+			headers:         map[string]string{"X-Forwarded-Host": "api.ons.example", "X-Forwarded-Path-Prefix": "v1"},
+			expectedAPIURL:  "https://api.ons.example/v1",
+			expectedHrefURL: "https://api.ons.example/downloads",
+		},
+		{
+			name:            "forwarded from another host with a proxy prefix, links are rewritten with the configured url and that prefix, and hrefs are unchanged",
+			headers:         map[string]string{"X-Forwarded-Host": "www.ons.example", "X-Forwarded-Path-Prefix": "image-api"},
+			expectedAPIURL:  "http://localhost:24700/image-api",
+			expectedHrefURL: downloadServiceURL,
+		},
+		{
+			name:            "with a proxy prefix only, links are rewritten with the configured url and that prefix, and hrefs are unchanged",
+			headers:         map[string]string{"X-Forwarded-Path-Prefix": "/nested/prefix"},
+			expectedAPIURL:  "http://localhost:24700/nested/prefix",
+			expectedHrefURL: downloadServiceURL,
+		},
+	}
+
+	Convey("Given an image API with URL rewriting enabled", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		cfg.APIURL = "http://localhost:24700"
+		cfg.DownloadServiceURL = downloadServiceURL
+		cfg.EnableURLRewriting = true
+		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+		for _, tc := range tests {
+			Convey("When a request is "+tc.name, func() {
+				imageSelf := fmt.Sprintf("%s/images/%s", tc.expectedAPIURL, testImageID2)
+				downloadSelf := fmt.Sprintf("%s/images/%s/downloads/%s", tc.expectedAPIURL, testImageID2, testVariantOriginal)
+				publicHref := fmt.Sprintf("%s/images/%s/%s/%s", tc.expectedHrefURL, testImageID2, testVariantOriginal, testFilename)
+
+				Convey("Then the links of the listed images are rewritten", func() {
+					var images models.Images
+					So(serveRewrittenGet(imageAPI.Router, "/images", tc.headers, &images), ShouldEqual, http.StatusOK)
+					So(images.Items, ShouldHaveLength, 1)
+					So(images.Items[0].Links.Self, ShouldEqual, imageSelf)
+					So(images.Items[0].Links.Downloads, ShouldEqual, imageSelf+"/downloads")
+				})
+
+				Convey("Then the links of the image are rewritten", func() {
+					var got models.Image
+					So(serveRewrittenGet(imageAPI.Router, "/images/"+testImageID2, tc.headers, &got), ShouldEqual, http.StatusOK)
+					So(got.Links.Self, ShouldEqual, imageSelf)
+					So(got.Links.Downloads, ShouldEqual, imageSelf+"/downloads")
+				})
+
+				Convey("Then the links and public hrefs of the list of downloads are rewritten", func() {
+					var downloads models.Downloads
+					So(serveRewrittenGet(imageAPI.Router, fmt.Sprintf("/images/%s/downloads", testImageID2), tc.headers, &downloads), ShouldEqual, http.StatusOK)
+					So(downloads.Items, ShouldHaveLength, 3)
+					for _, download := range downloads.Items {
+						switch download.ID {
+						case testVariantOriginal:
+							So(download.Links.Self, ShouldEqual, downloadSelf)
+							So(download.Links.Image, ShouldEqual, imageSelf)
+							So(download.Href, ShouldEqual, publicHref)
+						case testVariantAlternative:
+							So(download.Links.Self, ShouldEqual, fmt.Sprintf("%s/images/%s/downloads/%s", tc.expectedAPIURL, testImageID2, testVariantAlternative))
+							So(download.Href, ShouldEqual, image.Downloads[testVariantAlternative].Href)
+						case testVariantCDN:
+							So(download.Links, ShouldBeNil)
+							So(download.Href, ShouldEqual, testCDNHref)
+						}
+					}
+				})
+
+				Convey("Then the links and public href of the download are rewritten", func() {
+					var download models.Download
+					So(serveRewrittenGet(imageAPI.Router, fmt.Sprintf("/images/%s/downloads/%s", testImageID2, testVariantOriginal), tc.headers, &download), ShouldEqual, http.StatusOK)
+					So(download.Links.Self, ShouldEqual, downloadSelf)
+					So(download.Links.Image, ShouldEqual, imageSelf)
+					So(download.Href, ShouldEqual, publicHref)
+				})
+
+				Convey("Then the hrefs of variants that are not published, or that are served by a CDN, are not rewritten", func() {
+					var download models.Download
+					So(serveRewrittenGet(imageAPI.Router, fmt.Sprintf("/images/%s/downloads/%s", testImageID2, testVariantAlternative), tc.headers, &download), ShouldEqual, http.StatusOK)
+					So(download.Href, ShouldEqual, image.Downloads[testVariantAlternative].Href)
+
+					download = models.Download{}
+					So(serveRewrittenGet(imageAPI.Router, fmt.Sprintf("/images/%s/downloads/%s", testImageID2, testVariantCDN), tc.headers, &download), ShouldEqual, http.StatusOK)
+					So(download.Href, ShouldEqual, testCDNHref)
+					So(download.Links, ShouldBeNil)
+				})
+
+				Convey("Then the links and public hrefs of the revisions are rewritten", func() {
+					var revisions models.Revisions
+					So(serveRewrittenGet(imageAPI.Router, fmt.Sprintf("/images/%s/revisions", testImageID2), tc.headers, &revisions), ShouldEqual, http.StatusOK)
+					So(revisions.Items, ShouldHaveLength, 1)
+					So(revisions.Items[0].Downloads[testVariantOriginal].Links.Self, ShouldEqual, downloadSelf)
+					So(revisions.Items[0].Downloads[testVariantOriginal].Links.Image, ShouldEqual, imageSelf)
+					So(revisions.Items[0].Downloads[testVariantOriginal].Href, ShouldEqual, publicHref)
+				})
+
+				Convey("Then the links and public hrefs of the version are rewritten", func() {
+					var got models.Version
+					So(serveRewrittenGet(imageAPI.Router, fmt.Sprintf("/images/%s/versions/1", testImageID2), tc.headers, &got), ShouldEqual, http.StatusOK)
+					So(got.Links.Self, ShouldEqual, imageSelf+"/versions/1")
+					So(got.Links.Image, ShouldEqual, imageSelf)
+					So(got.Downloads[testVariantOriginal].Links.Self, ShouldEqual, downloadSelf)
+					So(got.Downloads[testVariantOriginal].Href, ShouldEqual, publicHref)
+				})
+
+				Convey("Then the links and public href of the version download are rewritten", func() {
+					var download models.Download
+					So(serveRewrittenGet(imageAPI.Router, fmt.Sprintf("/images/%s/versions/1/downloads/%s", testImageID2, testVariantOriginal), tc.headers, &download), ShouldEqual, http.StatusOK)
+					So(download.Links.Self, ShouldEqual, downloadSelf)
+					So(download.Links.Image, ShouldEqual, imageSelf)
+					So(download.Href, ShouldEqual, publicHref)
+				})
+
+				Convey("Then a nonexistent variant is not found", func() {
+					var download models.Download
+					So(serveRewrittenGet(imageAPI.Router, fmt.Sprintf("/images/%s/downloads/inexistent", testImageID2), tc.headers, &download), ShouldEqual, http.StatusNotFound)
+				})
+
+				Convey("Then the image returned by mongoDB is not modified", func() {
+					var got models.Image
+					So(serveRewrittenGet(imageAPI.Router, "/images/"+testImageID2, tc.headers, &got), ShouldEqual, http.StatusOK)
+					var downloads models.Downloads
+					So(serveRewrittenGet(imageAPI.Router, fmt.Sprintf("/images/%s/downloads", testImageID2), tc.headers, &downloads), ShouldEqual, http.StatusOK)
+					var download models.Download
+					So(serveRewrittenGet(imageAPI.Router, fmt.Sprintf("/images/%s/downloads/%s", testImageID2, testVariantOriginal), tc.headers, &download), ShouldEqual, http.StatusOK)
+					var revisions models.Revisions
+					So(serveRewrittenGet(imageAPI.Router, fmt.Sprintf("/images/%s/revisions", testImageID2), tc.headers, &revisions), ShouldEqual, http.StatusOK)
+					var gotVersion models.Version
+					So(serveRewrittenGet(imageAPI.Router, fmt.Sprintf("/images/%s/versions/1", testImageID2), tc.headers, &gotVersion), ShouldEqual, http.StatusOK)
+					So(image, ShouldResemble, rewriteTestImage())
+					So(version, ShouldResemble, rewriteTestVersion())
+				})
+			})
+		}
+	})
+
+	Convey("Given an image API with URL rewriting disabled", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		cfg.DownloadServiceURL = downloadServiceURL
+		cfg.EnableURLRewriting = false
+		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+		Convey("Then the links and hrefs are returned as they are stored, regardless of the forwarded headers", func() {
+			headers := map[string]string{"X-Forwarded-Host": "api.ons.example", "X-Forwarded-Path-Prefix": "v1"}
+			var got models.Image
+			So(serveRewrittenGet(imageAPI.Router, "/images/"+testImageID2, headers, &got), ShouldEqual, http.StatusOK)
+			So(got.Links, ShouldResemble, image.Links)
+
+			var download models.Download
+			So(serveRewrittenGet(imageAPI.Router, fmt.Sprintf("/images/%s/downloads/%s", testImageID2, testVariantOriginal), headers, &download), ShouldEqual, http.StatusOK)
+			So(download.Links, ShouldResemble, image.Downloads[testVariantOriginal].Links)
+			So(download.Href, ShouldEqual, image.Downloads[testVariantOriginal].Href)
+		})
+	})
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/dp-image-api/models"
+)
+
+// publicImage returns the image as it is served in web mode, which is the public view of its live revision,
+// or ErrImageNotFound if the live revision is not published, so that unpublished images cannot be told apart from nonexistent ones
+func publicImage(image *models.Image) (*models.Image, error) {
+	live := image.LiveRevision()
+	if !live.IsPublic() {
+		return nil, apierrors.ErrImageNotFound
+	}
+	return live.PublicView(), nil
+}
+
+// publicImages returns the images that are served in web mode, as they are served, leaving out the images that are not published
+func publicImages(images []models.Image) []models.Image {
+	public := make([]models.Image, 0, len(images))
+	for i := range images {
+		if image, err := publicImage(&images[i]); err == nil {
+			public = append(public, *image)
+		}
+	}
+	return public
+}
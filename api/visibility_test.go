@@ -0,0 +1,212 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	dpauth "github.com/ONSdigital/dp-authorisation/auth"
+	"github.com/ONSdigital/dp-image-api/api/mock"
+	"github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/dp-image-api/config"
+	"github.com/ONSdigital/dp-image-api/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// visibilityTestImage returns an image in the provided state with the provided ID, an error, an upload path,
+// and a download variant stored in a private bucket
+func visibilityTestImage(id string, state models.State, downloadState models.DownloadState) *models.Image {
+	image := dbFullImageWithDownloads(state, dbDownloadWithID(id, testVariantOriginal, downloadState))
+	image.ID = id
+	image.Error = "some error"
+	return image
+}
+
+// serveVisibilityGet serves an authenticated GET request for the provided path, and returns the response
+func serveVisibilityGet(h http.Handler, path string) *httptest.ResponseRecorder {
+	r := newAdminRequest(http.MethodGet, "http://localhost:24700"+path, "")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestWebModeVisibility(t *testing.T) {
+	hiddenStates := map[string]models.State{
+		"created":        models.StateCreated,
+		"uploaded":       models.StateUploaded,
+		"importing":      models.StateImporting,
+		"imported":       models.StateImported,
+		"failed_import":  models.StateFailedImport,
+		"failed_publish": models.StateFailedPublish,
+		"withdrawn":      models.StateWithdrawn,
+		"deleted":        models.StateDeleted,
+	}
+	images := map[string]*models.Image{
+		"published": visibilityTestImage("published", models.StatePublished, models.StateDownloadPublished),
+		"completed": visibilityTestImage("completed", models.StateCompleted, models.StateDownloadCompleted),
+	}
+	for id, state := range hiddenStates {
+		images[id] = visibilityTestImage(id, state, models.StateDownloadImported)
+	}
+
+	mongoDBMock := &mock.MongoServerMock{
+		GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+			if image, ok := images[id]; ok {
+				return image, nil
+			}
+			return nil, apierrors.ErrImageNotFound
+		},
+		GetImagesFunc: func(ctx context.Context, collectionID string) ([]models.Image, error) {
+			items := []models.Image{}
+			for _, image := range images {
+				items = append(items, *image)
+			}
+			return items, nil
+		},
+		GetImageVersionFunc: func(ctx context.Context, imageID string, version int) (*models.Version, error) {
+			return images["completed"].NewVersion(testPublishCompleted), nil
+		},
+	}
+	authHandlerMock := &mock.AuthHandlerMock{
+		RequireFunc: func(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+			return handler
+		},
+	}
+	imagePaths := func(id string) []string {
+		return []string{
+			fmt.Sprintf("/images/%s", id),
+			fmt.Sprintf("/images/%s/downloads", id),
+			fmt.Sprintf("/images/%s/downloads/%s", id, testVariantOriginal),
+			fmt.Sprintf("/images/%s/revisions", id),
+		}
+	}
+
+	Convey("Given an image API in web mode", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		cfg.IsPublishing = false
+		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+		Convey("Then images that are not published are not found, as if they did not exist", func() {
+			for id := range hiddenStates {
+				for _, path := range imagePaths(id) {
+					So(serveVisibilityGet(imageAPI.Router, path).Code, ShouldEqual, http.StatusNotFound)
+				}
+			}
+		})
+
+		Convey("Then published and completed images are returned without their error, upload and private bucket", func() {
+			for _, id := range []string{"published", "completed"} {
+				for _, path := range imagePaths(id) {
+					So(serveVisibilityGet(imageAPI.Router, path).Code, ShouldEqual, http.StatusOK)
+				}
+
+				var image map[string]interface{}
+				So(json.Unmarshal(serveVisibilityGet(imageAPI.Router, fmt.Sprintf("/images/%s", id)).Body.Bytes(), &image), ShouldBeNil)
+				So(image["state"], ShouldEqual, id)
+				So(image, ShouldNotContainKey, "error")
+				So(image, ShouldNotContainKey, "upload")
+
+				var download models.Download
+				So(json.Unmarshal(serveVisibilityGet(imageAPI.Router, fmt.Sprintf("/images/%s/downloads/%s", id, testVariantOriginal)).Body.Bytes(), &download), ShouldBeNil)
+				So(download.ID, ShouldEqual, testVariantOriginal)
+				So(download.Private, ShouldBeEmpty)
+
+				var downloads models.Downloads
+				So(json.Unmarshal(serveVisibilityGet(imageAPI.Router, fmt.Sprintf("/images/%s/downloads", id)).Body.Bytes(), &downloads), ShouldBeNil)
+				So(downloads.Items, ShouldHaveLength, 1)
+				So(downloads.Items[0].Private, ShouldBeEmpty)
+			}
+		})
+
+		Convey("Then only published and completed images are listed, without their error, upload and private bucket", func() {
+			w := serveVisibilityGet(imageAPI.Router, "/images")
+			So(w.Code, ShouldEqual, http.StatusOK)
+			var list models.Images
+			So(json.Unmarshal(w.Body.Bytes(), &list), ShouldBeNil)
+			So(list.Count, ShouldEqual, 2)
+			So(list.TotalCount, ShouldEqual, 2)
+			for _, image := range list.Items {
+				So(image.State, ShouldBeIn, []string{models.StatePublished.String(), models.StateCompleted.String()})
+				So(image.Error, ShouldBeEmpty)
+				So(image.Upload, ShouldBeNil)
+			}
+		})
+
+		Convey("Then image versions are returned without their private bucket", func() {
+			var version models.Version
+			So(json.Unmarshal(serveVisibilityGet(imageAPI.Router, "/images/completed/versions/1").Body.Bytes(), &version), ShouldBeNil)
+			So(version.Downloads[testVariantOriginal].Private, ShouldBeEmpty)
+
+			var download models.Download
+			So(json.Unmarshal(serveVisibilityGet(imageAPI.Router, fmt.Sprintf("/images/completed/versions/1/downloads/%s", testVariantOriginal)).Body.Bytes(), &download), ShouldBeNil)
+			So(download.Private, ShouldBeEmpty)
+		})
+
+		Convey("Then the versions of withdrawn and deleted images are not found, even though they are still stored", func() {
+			for _, id := range []string{"withdrawn", "deleted", "purged"} {
+				So(serveVisibilityGet(imageAPI.Router, fmt.Sprintf("/images/%s/versions/1", id)).Code, ShouldEqual, http.StatusNotFound)
+				So(serveVisibilityGet(imageAPI.Router, fmt.Sprintf("/images/%s/versions/1/downloads/%s", id, testVariantOriginal)).Code, ShouldEqual, http.StatusNotFound)
+			}
+		})
+
+		Convey("Then the versions of images that are not published are not found", func() {
+			for id := range hiddenStates {
+				So(serveVisibilityGet(imageAPI.Router, fmt.Sprintf("/images/%s/versions/1", id)).Code, ShouldEqual, http.StatusNotFound)
+			}
+		})
+
+		Convey("Then the images stored in mongoDB are not modified", func() {
+			serveVisibilityGet(imageAPI.Router, "/images")
+			serveVisibilityGet(imageAPI.Router, "/images/completed")
+			So(images["completed"].Upload, ShouldNotBeNil)
+			So(images["completed"].Error, ShouldEqual, "some error")
+			So(images["completed"].Downloads[testVariantOriginal].Private, ShouldEqual, "my-private-bucket")
+		})
+	})
+
+	Convey("Given an image API in publishing mode", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		cfg.IsPublishing = true
+		imageAPI := GetAPIWithMocks(cfg, mongoDBMock, authHandlerMock, kafkaStubProducer, kafkaStubProducer, kafkaStubProducer)
+
+		Convey("Then images in every state are returned with their error, upload and private bucket", func() {
+			for id := range images {
+				for _, path := range imagePaths(id) {
+					So(serveVisibilityGet(imageAPI.Router, path).Code, ShouldEqual, http.StatusOK)
+				}
+
+				var image models.Image
+				So(json.Unmarshal(serveVisibilityGet(imageAPI.Router, fmt.Sprintf("/images/%s", id)).Body.Bytes(), &image), ShouldBeNil)
+				So(image.Error, ShouldEqual, "some error")
+				So(image.Upload, ShouldNotBeNil)
+
+				var download models.Download
+				So(json.Unmarshal(serveVisibilityGet(imageAPI.Router, fmt.Sprintf("/images/%s/downloads/%s", id, testVariantOriginal)).Body.Bytes(), &download), ShouldBeNil)
+				So(download.Private, ShouldEqual, "my-private-bucket")
+			}
+		})
+
+		Convey("Then every image is listed", func() {
+			var list models.Images
+			So(json.Unmarshal(serveVisibilityGet(imageAPI.Router, "/images").Body.Bytes(), &list), ShouldBeNil)
+			So(list.Count, ShouldEqual, len(images))
+		})
+
+		Convey("Then image versions are returned with their private bucket", func() {
+			var version models.Version
+			So(json.Unmarshal(serveVisibilityGet(imageAPI.Router, "/images/completed/versions/1").Body.Bytes(), &version), ShouldBeNil)
+			So(version.Downloads[testVariantOriginal].Private, ShouldEqual, "my-private-bucket")
+		})
+
+		Convey("Then the versions of withdrawn and deleted images are returned", func() {
+			for _, id := range []string{"withdrawn", "deleted"} {
+				So(serveVisibilityGet(imageAPI.Router, fmt.Sprintf("/images/%s/versions/1", id)).Code, ShouldEqual, http.StatusOK)
+			}
+		})
+	})
+}
@@ -20,13 +20,37 @@ var (
 	ErrImageInvalidState                = errors.New("image state is not a valid state name")
 	ErrImageBadInitialState             = errors.New("image state is not a valid initial state")
 	ErrImageStateTransitionNotAllowed   = errors.New("image state transition not allowed")
+	ErrImageWithdrawalNotAllowed        = errors.New("image can only be withdrawn with a withdrawal request")
 	ErrImageUploadEmpty                 = errors.New("image upload section is not populated")
 	ErrImageUploadPathEmpty             = errors.New("image upload path is not populated")
 	ErrImageNotImporting                = errors.New("image is not in importing state")
 	ErrImageNotPublished                = errors.New("image is not in published state")
+	ErrImageNotRepublishable            = errors.New("image is not in published or completed state")
 	ErrVariantIDMismatch                = errors.New("variant id provided in body does not match 'variant' path parameter")
 	ErrVariantStateTransitionNotAllowed = errors.New("image download variant state transition not allowed")
 	ErrImageDownloadTypeMismatch        = errors.New("image download variant type does not match existing type")
 	ErrImageDownloadInvalidState        = errors.New("image download state is not a valid state name")
 	ErrImageDownloadBadInitialState     = errors.New("image download state is not a valid initial state")
+	ErrImageDownloadInvalidSha256       = errors.New("image download sha256 is not a valid hex encoded SHA-256 checksum")
+	ErrImageNoScheduledPublish          = errors.New("image does not have a scheduled publish")
+	ErrLockAlreadyHeld                  = errors.New("lock is already held by another process")
+	ErrImageWithdrawalNoReason          = errors.New("image withdrawal does not have a reason")
+	ErrImageNotCompleted                = errors.New("image is not in completed state")
+	ErrImageVersionNotFound             = errors.New("image version not found")
+	ErrImageVersionInvalid              = errors.New("image version must be a positive integer")
+	ErrDeadLetterNotFound               = errors.New("dead letter not found")
+	ErrDeadLetterInvalidEventType       = errors.New("dead letter event type is not supported")
+	ErrReplayInvalidState               = errors.New("replay state must be 'uploaded' or 'published'")
+	ErrReplayInvalidTimeRange           = errors.New("replay 'from' time must not be after 'to' time")
+	ErrInvalidLinksURL                  = errors.New("links url must be an absolute http or https url")
+	ErrImportRecordNoID                 = errors.New("image record does not have an id")
+	ErrImportRecordNotExported          = errors.New("image record could not be exported")
+	ErrImageLockTimeout                 = errors.New("timed out waiting for the image lock held by another request")
+	ErrImageUpdateConflict              = errors.New("image was modified by other requests while being updated")
+	ErrMigrationLockTimeout             = errors.New("timed out waiting for the migrations lock held by another service instance")
+	ErrLockLost                         = errors.New("lock expired before it could be renewed")
+	ErrRequestBodyTooLarge              = errors.New("request body is too large")
+	ErrTooManyRequests                  = errors.New("too many requests")
+	ErrPreviewURLsDisabled              = errors.New("preview urls are not enabled, as no signing key is configured")
+	ErrVariantNotPreviewable            = errors.New("image download variant cannot be previewed until it is imported")
 )
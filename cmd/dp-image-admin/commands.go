@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ONSdigital/dp-image-api/api"
+	"github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/dp-image-api/config"
+	"github.com/ONSdigital/dp-image-api/models"
+	"github.com/ONSdigital/dp-image-api/service"
+	kafka "github.com/ONSdigital/dp-kafka/v3"
+	"github.com/pkg/errors"
+)
+
+// commands maps the name of each subcommand to the admin method that runs it
+var commands = map[string]func(a *admin, ctx context.Context, args []string) error{
+	"list":          (*admin).list,
+	"show":          (*admin).show,
+	"set-state":     (*admin).setState,
+	"republish":     (*admin).republish,
+	"purge-deleted": (*admin).purgeDeleted,
+	"export":        (*admin).export,
+	"migrate":       (*admin).migrate,
+}
+
+// admin holds the dependencies shared by all the subcommands
+type admin struct {
+	cfg                  *config.Config
+	mongoDB              api.MongoServer
+	getPublishedProducer func(ctx context.Context) (kafka.IProducer, error)
+	out                  io.Writer
+	output               string
+}
+
+// StateChange represents the result of a set-state command
+type StateChange struct {
+	ID     string `json:"id"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Forced bool   `json:"forced,omitempty"`
+}
+
+// PurgeResult represents the result of a purge-deleted command
+type PurgeResult struct {
+	DryRun bool     `json:"dry_run,omitempty"`
+	Count  int      `json:"count"`
+	Purged []string `json:"purged"`
+}
+
+// list writes the images matching the optional state and collection filters
+func (a *admin) list(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	state := flags.String("state", "", "only list images in this state")
+	collectionID := flags.String("collection", "", "only list images in this collection")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	images, err := a.getImages(ctx, *collectionID, *state)
+	if err != nil {
+		return err
+	}
+
+	return a.write(&models.Images{Count: len(images), Limit: len(images), Items: images, TotalCount: len(images)}, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tCOLLECTION\tSTATE\tREVISION\tDOWNLOADS\tFILENAME")
+		for i := range images {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\n", images[i].ID, images[i].CollectionID, images[i].State,
+				images[i].CurrentRevision(), len(images[i].Downloads), images[i].Filename)
+		}
+	})
+}
+
+// show writes the image with the provided ID, including all its download variants
+func (a *admin) show(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("show", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: show <image-id>")
+	}
+
+	image, err := a.mongoDB.GetImage(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	return a.write(models.NewImageRecord(image), func(w io.Writer) {
+		fmt.Fprintf(w, "ID:\t%s\n", image.ID)
+		fmt.Fprintf(w, "Collection:\t%s\n", image.CollectionID)
+		fmt.Fprintf(w, "State:\t%s\n", image.State)
+		fmt.Fprintf(w, "Error:\t%s\n", image.Error)
+		fmt.Fprintf(w, "Filename:\t%s\n", image.Filename)
+		fmt.Fprintf(w, "Type:\t%s\n", image.Type)
+		fmt.Fprintf(w, "Revision:\t%d\n", image.CurrentRevision())
+		if image.Upload != nil {
+			fmt.Fprintf(w, "Upload path:\t%s\n", image.Upload.Path)
+		}
+		if image.ScheduledPublish != nil && image.ScheduledPublish.PublishAt != nil {
+			fmt.Fprintf(w, "Scheduled publish:\t%s\n", image.ScheduledPublish.PublishAt)
+		}
+		if len(image.Downloads) == 0 {
+			return
+		}
+		fmt.Fprintln(w, "\nVARIANT\tSTATE\tTYPE\tPRIVATE\tERROR")
+		for _, variant := range sortedVariants(image.Downloads) {
+			d := image.Downloads[variant]
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", variant, d.State, d.Type, d.Private, d.Error)
+		}
+	})
+}
+
+// setState changes the state of the image with the provided ID, under the image lock.
+// The transition needs to be allowed by the image state machine, unless it is forced.
+func (a *admin) setState(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("set-state", flag.ContinueOnError)
+	force := flags.Bool("force", false, "set the state even if the transition is not allowed by the image state machine")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return errors.New("usage: set-state [-force] <image-id> <state>")
+	}
+	id, target := flags.Arg(0), flags.Arg(1)
+	if _, err := models.ParseState(target); err != nil {
+		return err
+	}
+
+	lockCtx, cancel := context.WithTimeout(ctx, a.cfg.ImageLockTimeout)
+	defer cancel()
+	lockID, err := a.mongoDB.AcquireImageLock(lockCtx, id)
+	if err != nil {
+		return err
+	}
+	defer a.mongoDB.UnlockImage(ctx, lockID)
+
+	image, err := a.mongoDB.GetImage(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !*force && !image.StateTransitionAllowed(target) {
+		return errors.Wrapf(apierrors.ErrImageStateTransitionNotAllowed, "cannot change state from '%s' to '%s'", image.State, target)
+	}
+
+	if _, err := a.mongoDB.UpdateImage(ctx, id, &models.Image{State: target}); err != nil {
+		return err
+	}
+
+	change := &StateChange{ID: id, From: image.State, To: target, Forced: *force}
+	return a.write(change, func(w io.Writer) {
+		fmt.Fprintf(w, "image %s state changed from '%s' to '%s'\n", change.ID, change.From, change.To)
+	})
+}
+
+// republish re-emits the image published event of every download variant of the image with the provided ID
+func (a *admin) republish(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("republish", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "print the events that would be sent without sending them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: republish [-dry-run] <image-id>")
+	}
+
+	// The kafka producer is only needed if the events are actually sent
+	var publishedProducer kafka.IProducer
+	if !*dryRun {
+		var err error
+		if publishedProducer, err = a.getPublishedProducer(ctx); err != nil {
+			return errors.Wrap(err, "could not obtain image published kafka producer")
+		}
+		defer service.CloseKafkaProducer(ctx, publishedProducer)
+	}
+
+	result, err := api.NewEventReplayer(a.cfg, a.mongoDB, nil, publishedProducer).Republish(ctx, flags.Arg(0), *dryRun)
+	if err != nil {
+		return err
+	}
+
+	if err := a.write(result, func(w io.Writer) {
+		fmt.Fprintln(w, "EVENT\tIMAGE\tVARIANT\tERROR")
+		for _, item := range result.Items {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", item.EventType, item.ImageID, item.Variant, item.Error)
+		}
+	}); err != nil {
+		return err
+	}
+	if result.Failed > 0 {
+		return errors.Errorf("%d of %d events could not be sent", result.Failed, result.Count)
+	}
+	return nil
+}
+
+// purgeDeleted removes the documents of all the images in 'deleted' state, optionally filtered by collection
+func (a *admin) purgeDeleted(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("purge-deleted", flag.ContinueOnError)
+	collectionID := flags.String("collection", "", "only purge deleted images in this collection")
+	dryRun := flags.Bool("dry-run", false, "print the images that would be purged without removing them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	images, err := a.getImages(ctx, *collectionID, models.StateDeleted.String())
+	if err != nil {
+		return err
+	}
+
+	result := &PurgeResult{DryRun: *dryRun, Purged: make([]string, 0, len(images))}
+	for i := range images {
+		if !*dryRun {
+			if err := a.mongoDB.DeleteImage(ctx, images[i].ID); err != nil {
+				return errors.Wrapf(err, "failed to purge image %s after purging %d images", images[i].ID, result.Count)
+			}
+		}
+		result.Purged = append(result.Purged, images[i].ID)
+		result.Count++
+	}
+
+	return a.write(result, func(w io.Writer) {
+		summary := "%d deleted images purged\n"
+		if result.DryRun {
+			summary = "%d deleted images would be purged\n"
+		}
+		for _, id := range result.Purged {
+			fmt.Fprintln(w, id)
+		}
+		fmt.Fprintf(w, summary, result.Count)
+	})
+}
+
+// export writes the images matching the optional state and collection filters as newline delimited json, one image record
+// per line including its download variants, regardless of the output format. Images are read one at a time, as for the
+// export endpoint, and documents that cannot be decoded are reported in place as export error lines.
+func (a *admin) export(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	state := flags.String("state", "", "only export images in this state")
+	collectionID := flags.String("collection", "", "only export images in this collection")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *state != "" {
+		if _, err := models.ParseState(*state); err != nil {
+			return err
+		}
+	}
+
+	encoder := json.NewEncoder(a.out)
+	line := 0
+	return a.mongoDB.IterateImages(ctx, *collectionID, func(image *models.Image, decodeErr error) error {
+		if decodeErr != nil {
+			line++
+			return encoder.Encode(&models.ExportErrorLine{
+				ExportError: &models.RecordError{Line: line, ImageID: image.ID, Error: decodeErr.Error()},
+			})
+		}
+		if *state != "" && image.State != *state {
+			return nil
+		}
+		line++
+		return encoder.Encode(models.NewImageRecord(image))
+	})
+}
+
+// migrate applies the pending mongoDB migrations, in version order and under the migrations lock
+func (a *admin) migrate(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "print the pending migrations and the number of documents they would change, without applying them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	result, err := a.mongoDB.Migrate(ctx, *dryRun, a.cfg.MigrationLockTimeout)
+	if err != nil {
+		return err
+	}
+
+	return a.write(result, func(w io.Writer) {
+		if len(result.Applied) == 0 {
+			fmt.Fprintln(w, "no pending migrations")
+			return
+		}
+		fmt.Fprintln(w, "VERSION\tDOCUMENTS\tDESCRIPTION")
+		for _, record := range result.Applied {
+			fmt.Fprintf(w, "%d\t%d\t%s\n", record.Version, record.Documents, record.Description)
+		}
+	})
+}
+
+// getImages returns the images of the provided collection, or of all collections if it is empty,
+// that are in the provided state, or in any state if it is empty
+func (a *admin) getImages(ctx context.Context, collectionID, state string) ([]models.Image, error) {
+	if state != "" {
+		if _, err := models.ParseState(state); err != nil {
+			return nil, err
+		}
+	}
+
+	images, err := a.mongoDB.GetImages(ctx, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	if state == "" {
+		return images, nil
+	}
+
+	filtered := make([]models.Image, 0, len(images))
+	for i := range images {
+		if images[i].State == state {
+			filtered = append(filtered, images[i])
+		}
+	}
+	return filtered, nil
+}
+
+// sortedVariants returns the sorted variant names of the provided downloads
+func sortedVariants(downloads map[string]models.Download) []string {
+	variants := make([]string, 0, len(downloads))
+	for variant := range downloads {
+		variants = append(variants, variant)
+	}
+	sort.Strings(variants)
+	return variants
+}
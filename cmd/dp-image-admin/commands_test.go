@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-image-api/api/mock"
+	"github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/dp-image-api/config"
+	"github.com/ONSdigital/dp-image-api/models"
+	kafka "github.com/ONSdigital/dp-kafka/v3"
+	"github.com/ONSdigital/dp-kafka/v3/kafkatest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	testCollectionID = "1234"
+	testLockID       = "lockID"
+)
+
+var ctx = context.Background()
+
+// testImages returns a completed image with two download variants, an uploaded image and a deleted image
+func testImages() []models.Image {
+	return []models.Image{
+		{
+			ID:           "completedImage",
+			CollectionID: testCollectionID,
+			State:        models.StateCompleted.String(),
+			Filename:     "completed.png",
+			Downloads: map[string]models.Download{
+				"original": {ID: "original", State: models.StateDownloadCompleted.String(), Type: "png"},
+				"bw1024":   {ID: "bw1024", State: models.StateDownloadCompleted.String(), Type: "png"},
+			},
+		},
+		{ID: "uploadedImage", CollectionID: testCollectionID, State: models.StateUploaded.String(), Filename: "uploaded.png"},
+		{ID: "deletedImage", CollectionID: testCollectionID, State: models.StateDeleted.String(), Filename: "deleted.png"},
+	}
+}
+
+// newTestAdmin returns an admin with the provided mongoDB and output format, writing to the returned buffer
+func newTestAdmin(mongoDB *mock.MongoServerMock, output string) (*admin, *bytes.Buffer) {
+	cfg, err := config.Get()
+	So(err, ShouldBeNil)
+	out := &bytes.Buffer{}
+	return &admin{
+		cfg:     cfg,
+		mongoDB: mongoDB,
+		out:     out,
+		output:  output,
+	}, out
+}
+
+// imageMongoDB returns a mongoDB mock that returns the test images
+func imageMongoDB() *mock.MongoServerMock {
+	return &mock.MongoServerMock{
+		GetImagesFunc: func(ctx context.Context, collectionID string) ([]models.Image, error) {
+			return testImages(), nil
+		},
+		GetImageFunc: func(ctx context.Context, id string) (*models.Image, error) {
+			for _, image := range testImages() {
+				if image.ID == id {
+					return &image, nil
+				}
+			}
+			return nil, apierrors.ErrImageNotFound
+		},
+		AcquireImageLockFunc: func(ctx context.Context, id string) (string, error) { return testLockID, nil },
+		UnlockImageFunc:      func(ctx context.Context, lockID string) {},
+		UpdateImageFunc:      func(ctx context.Context, id string, image *models.Image) (bool, error) { return true, nil },
+		DeleteImageFunc:      func(ctx context.Context, id string) error { return nil },
+		IterateImagesFunc: func(ctx context.Context, collectionID string, fn func(image *models.Image, decodeErr error) error) error {
+			for _, image := range testImages() {
+				if err := fn(&image, nil); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	Convey("Running the admin tool with an invalid output format fails before connecting to mongoDB", t, func() {
+		err := run(ctx, []string{"-output", "xml", "list"}, &bytes.Buffer{})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "invalid output format 'xml', must be 'table' or 'json'")
+	})
+
+	Convey("Running the admin tool with an unknown command fails before connecting to mongoDB", t, func() {
+		err := run(ctx, []string{"delete-everything"}, &bytes.Buffer{})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "unknown command 'delete-everything', must be one of: export, list, migrate, purge-deleted, republish, set-state, show")
+	})
+}
+
+func TestList(t *testing.T) {
+	Convey("Given a mongoDB with images in different states", t, func() {
+		mongoDBMock := imageMongoDB()
+
+		Convey("When list is called with state and collection filters and table output", func() {
+			a, out := newTestAdmin(mongoDBMock, outputTable)
+			err := a.list(ctx, []string{"-state", "completed", "-collection", testCollectionID})
+
+			Convey("Then only the images in that state are written as a table", func() {
+				So(err, ShouldBeNil)
+				So(mongoDBMock.GetImagesCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.GetImagesCalls()[0].CollectionID, ShouldEqual, testCollectionID)
+				So(out.String(), ShouldEqual, ""+
+					"ID              COLLECTION  STATE      REVISION  DOWNLOADS  FILENAME\n"+
+					"completedImage  1234        completed  1         2          completed.png\n")
+			})
+		})
+
+		Convey("When list is called with json output", func() {
+			a, out := newTestAdmin(mongoDBMock, outputJSON)
+			err := a.list(ctx, nil)
+
+			Convey("Then all the images are written as json", func() {
+				So(err, ShouldBeNil)
+				images := &models.Images{}
+				So(json.Unmarshal(out.Bytes(), images), ShouldBeNil)
+				So(images.Count, ShouldEqual, 3)
+				So(images.TotalCount, ShouldEqual, 3)
+				So(images.Items[1].ID, ShouldEqual, "uploadedImage")
+			})
+		})
+
+		Convey("When list is called with an invalid state, then the expected error is returned", func() {
+			a, _ := newTestAdmin(mongoDBMock, outputTable)
+			err := a.list(ctx, []string{"-state", "wrong"})
+			So(err, ShouldEqual, apierrors.ErrImageInvalidState)
+			So(mongoDBMock.GetImagesCalls(), ShouldHaveLength, 0)
+		})
+	})
+}
+
+func TestShow(t *testing.T) {
+	Convey("Given a mongoDB with images", t, func() {
+		mongoDBMock := imageMongoDB()
+
+		Convey("When show is called with json output, then the image is written including its download variants", func() {
+			a, out := newTestAdmin(mongoDBMock, outputJSON)
+			So(a.show(ctx, []string{"completedImage"}), ShouldBeNil)
+			record := &models.ImageRecord{}
+			So(json.Unmarshal(out.Bytes(), record), ShouldBeNil)
+			So(record.ID, ShouldEqual, "completedImage")
+			So(record.Downloads, ShouldHaveLength, 2)
+		})
+
+		Convey("When show is called with table output, then the download variants are written sorted by variant", func() {
+			a, out := newTestAdmin(mongoDBMock, outputTable)
+			So(a.show(ctx, []string{"completedImage"}), ShouldBeNil)
+			So(out.String(), ShouldContainSubstring, "State:       completed\n")
+			So(strings.Index(out.String(), "bw1024"), ShouldBeLessThan, strings.Index(out.String(), "original"))
+		})
+
+		Convey("When show is called for an image that does not exist, then the expected error is returned", func() {
+			a, _ := newTestAdmin(mongoDBMock, outputTable)
+			So(a.show(ctx, []string{"unknown"}), ShouldEqual, apierrors.ErrImageNotFound)
+		})
+
+		Convey("When show is called without an image ID, then an error is returned", func() {
+			a, _ := newTestAdmin(mongoDBMock, outputTable)
+			So(a.show(ctx, nil), ShouldNotBeNil)
+			So(mongoDBMock.GetImageCalls(), ShouldHaveLength, 0)
+		})
+	})
+}
+
+func TestSetState(t *testing.T) {
+	Convey("Given a mongoDB with images", t, func() {
+		mongoDBMock := imageMongoDB()
+
+		Convey("When set-state is called with a transition allowed by the state machine", func() {
+			a, out := newTestAdmin(mongoDBMock, outputJSON)
+			err := a.setState(ctx, []string{"uploadedImage", "importing"})
+
+			Convey("Then the image state is updated under the image lock", func() {
+				So(err, ShouldBeNil)
+				So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UpdateImageCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UpdateImageCalls()[0].ID, ShouldEqual, "uploadedImage")
+				So(mongoDBMock.UpdateImageCalls()[0].Image, ShouldResemble, &models.Image{State: models.StateImporting.String()})
+				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.UnlockImageCalls()[0].LockID, ShouldEqual, testLockID)
+
+				change := &StateChange{}
+				So(json.Unmarshal(out.Bytes(), change), ShouldBeNil)
+				So(change, ShouldResemble, &StateChange{ID: "uploadedImage", From: "uploaded", To: "importing"})
+			})
+		})
+
+		Convey("When set-state is called with a transition that is not allowed by the state machine", func() {
+			a, _ := newTestAdmin(mongoDBMock, outputTable)
+			err := a.setState(ctx, []string{"uploadedImage", "published"})
+
+			Convey("Then the expected error is returned and the image is not updated", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "cannot change state from 'uploaded' to 'published': "+apierrors.ErrImageStateTransitionNotAllowed.Error())
+				So(mongoDBMock.UpdateImageCalls(), ShouldHaveLength, 0)
+				So(mongoDBMock.UnlockImageCalls(), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("When set-state is forced with a transition that is not allowed by the state machine", func() {
+			a, out := newTestAdmin(mongoDBMock, outputTable)
+			err := a.setState(ctx, []string{"-force", "uploadedImage", "published"})
+
+			Convey("Then the image state is updated", func() {
+				So(err, ShouldBeNil)
+				So(mongoDBMock.UpdateImageCalls(), ShouldHaveLength, 1)
+				So(out.String(), ShouldEqual, "image uploadedImage state changed from 'uploaded' to 'published'\n")
+			})
+		})
+
+		Convey("When set-state is called with an invalid state, then the expected error is returned without locking the image", func() {
+			a, _ := newTestAdmin(mongoDBMock, outputTable)
+			So(a.setState(ctx, []string{"-force", "uploadedImage", "wrong"}), ShouldEqual, apierrors.ErrImageInvalidState)
+			So(mongoDBMock.AcquireImageLockCalls(), ShouldHaveLength, 0)
+		})
+	})
+}
+
+func TestRepublish(t *testing.T) {
+	Convey("Given a mongoDB with a completed image and a kafka producer", t, func() {
+		mongoDBMock := imageMongoDB()
+		producer := &kafkatest.IProducerMock{
+			ChannelsFunc:      func() *kafka.ProducerChannels { return &kafka.ProducerChannels{Output: make(chan []byte, 10)} },
+			IsInitialisedFunc: func() bool { return true },
+			CloseFunc:         func(ctx context.Context) error { return nil },
+		}
+		channels := producer.Channels()
+		producer.ChannelsFunc = func() *kafka.ProducerChannels { return channels }
+
+		Convey("When republish is called, then the published event of every variant is sent and the producer is closed", func() {
+			a, out := newTestAdmin(mongoDBMock, outputTable)
+			a.getPublishedProducer = func(ctx context.Context) (kafka.IProducer, error) { return producer, nil }
+			So(a.republish(ctx, []string{"completedImage"}), ShouldBeNil)
+			So(channels.Output, ShouldHaveLength, 2)
+			So(producer.CloseCalls(), ShouldHaveLength, 1)
+			So(out.String(), ShouldEqual, ""+
+				"EVENT            IMAGE           VARIANT   ERROR\n"+
+				"image-published  completedImage  bw1024    \n"+
+				"image-published  completedImage  original  \n")
+		})
+
+		Convey("When republish is called as a dry run, then no kafka producer is obtained", func() {
+			a, out := newTestAdmin(mongoDBMock, outputJSON)
+			So(a.republish(ctx, []string{"-dry-run", "completedImage"}), ShouldBeNil)
+			result := &models.ReplayResult{}
+			So(json.Unmarshal(out.Bytes(), result), ShouldBeNil)
+			So(result.DryRun, ShouldBeTrue)
+			So(result.Count, ShouldEqual, 2)
+		})
+
+		Convey("When republish is called for an image that is not published or completed, then the expected error is returned", func() {
+			a, _ := newTestAdmin(mongoDBMock, outputTable)
+			a.getPublishedProducer = func(ctx context.Context) (kafka.IProducer, error) { return producer, nil }
+			So(a.republish(ctx, []string{"uploadedImage"}), ShouldEqual, apierrors.ErrImageNotRepublishable)
+			So(channels.Output, ShouldHaveLength, 0)
+		})
+	})
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	Convey("Given a mongoDB with images in different states", t, func() {
+		mongoDBMock := imageMongoDB()
+
+		Convey("When purge-deleted is called, then only the deleted images are removed", func() {
+			a, out := newTestAdmin(mongoDBMock, outputJSON)
+			So(a.purgeDeleted(ctx, []string{"-collection", testCollectionID}), ShouldBeNil)
+			So(mongoDBMock.GetImagesCalls()[0].CollectionID, ShouldEqual, testCollectionID)
+			So(mongoDBMock.DeleteImageCalls(), ShouldHaveLength, 1)
+			So(mongoDBMock.DeleteImageCalls()[0].ID, ShouldEqual, "deletedImage")
+			result := &PurgeResult{}
+			So(json.Unmarshal(out.Bytes(), result), ShouldBeNil)
+			So(result, ShouldResemble, &PurgeResult{Count: 1, Purged: []string{"deletedImage"}})
+		})
+
+		Convey("When purge-deleted is called as a dry run, then no image is removed", func() {
+			a, out := newTestAdmin(mongoDBMock, outputTable)
+			So(a.purgeDeleted(ctx, []string{"-dry-run"}), ShouldBeNil)
+			So(mongoDBMock.DeleteImageCalls(), ShouldHaveLength, 0)
+			So(out.String(), ShouldEqual, "deletedImage\n1 deleted images would be purged\n")
+		})
+	})
+}
+
+func TestExport(t *testing.T) {
+	Convey("Given a mongoDB with images in different states", t, func() {
+		mongoDBMock := imageMongoDB()
+
+		Convey("When export is called, then one image record per line is written including the download variants", func() {
+			a, out := newTestAdmin(mongoDBMock, outputTable)
+			So(a.export(ctx, nil), ShouldBeNil)
+			lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+			So(lines, ShouldHaveLength, 3)
+			record := &models.ImageRecord{}
+			So(json.Unmarshal([]byte(lines[0]), record), ShouldBeNil)
+			So(record.ID, ShouldEqual, "completedImage")
+			So(record.Downloads, ShouldHaveLength, 2)
+			So(mongoDBMock.GetImagesCalls(), ShouldHaveLength, 0)
+		})
+
+		Convey("When export is called with a state filter, then only the images in that state are written", func() {
+			a, out := newTestAdmin(mongoDBMock, outputTable)
+			So(a.export(ctx, []string{"-state", "uploaded"}), ShouldBeNil)
+			So(strings.Count(out.String(), "\n"), ShouldEqual, 1)
+			So(out.String(), ShouldContainSubstring, `"id":"uploadedImage"`)
+		})
+	})
+}
+
+func TestMigrate(t *testing.T) {
+	Convey("Given a mongoDB with a pending migration", t, func() {
+		mongoDBMock := &mock.MongoServerMock{
+			MigrateFunc: func(ctx context.Context, dryRun bool, lockTimeout time.Duration) (*models.MigrationResult, error) {
+				return &models.MigrationResult{
+					DryRun:  dryRun,
+					Applied: []models.MigrationRecord{{Version: 1, Description: "move private field", Documents: 12}},
+				}, nil
+			},
+		}
+
+		Convey("When migrate is called as a dry run, then the pending migrations are written as a table", func() {
+			a, out := newTestAdmin(mongoDBMock, outputTable)
+			So(a.migrate(ctx, []string{"-dry-run"}), ShouldBeNil)
+			So(mongoDBMock.MigrateCalls(), ShouldHaveLength, 1)
+			So(mongoDBMock.MigrateCalls()[0].DryRun, ShouldBeTrue)
+			So(mongoDBMock.MigrateCalls()[0].LockTimeout, ShouldEqual, 5*time.Minute)
+			So(out.String(), ShouldEqual, ""+
+				"VERSION  DOCUMENTS  DESCRIPTION\n"+
+				"1        12         move private field\n")
+		})
+
+		Convey("When migrate is called, then the applied migrations are written as json", func() {
+			a, out := newTestAdmin(mongoDBMock, outputJSON)
+			So(a.migrate(ctx, nil), ShouldBeNil)
+			So(mongoDBMock.MigrateCalls()[0].DryRun, ShouldBeFalse)
+			result := &models.MigrationResult{}
+			So(json.Unmarshal(out.Bytes(), result), ShouldBeNil)
+			So(result.Applied, ShouldHaveLength, 1)
+		})
+	})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ONSdigital/dp-image-api/config"
+	"github.com/ONSdigital/dp-image-api/mongo"
+	"github.com/ONSdigital/dp-image-api/service"
+	kafka "github.com/ONSdigital/dp-kafka/v3"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/pkg/errors"
+)
+
+const appName = "dp-image-admin"
+
+func main() {
+	log.Namespace = appName
+	// Logs go to stderr so that the command output written to stdout can be piped
+	log.SetDestination(os.Stderr, nil)
+	ctx := context.Background()
+
+	if err := run(ctx, os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run parses the global flags and the subcommand provided as command line arguments, connects to mongoDB
+// with the service configuration read from the environment, and runs the subcommand writing its output to out
+func run(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet(appName, flag.ContinueOnError)
+	output := flags.String("output", outputTable, "output format: 'table' or 'json'")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s [-output table|json] <command> [flags] [args]\n\ncommands: %s\n\nflags:\n",
+			appName, strings.Join(commandNames(), ", "))
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *output != outputTable && *output != outputJSON {
+		return errors.Errorf("invalid output format '%s', must be '%s' or '%s'", *output, outputTable, outputJSON)
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("no command provided")
+	}
+	command, ok := commands[flags.Arg(0)]
+	if !ok {
+		return errors.Errorf("unknown command '%s', must be one of: %s", flags.Arg(0), strings.Join(commandNames(), ", "))
+	}
+
+	cfg, err := config.Get()
+	if err != nil {
+		return errors.Wrap(err, "unable to retrieve service configuration")
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	mongoDB, err := mongo.NewMongoStore(ctx, cfg.MongoConfig)
+	if err != nil {
+		return errors.Wrap(err, "could not obtain mongo session")
+	}
+	defer func() {
+		if err := mongoDB.Close(ctx); err != nil {
+			log.Error(ctx, "error closing mongo db", err)
+		}
+	}()
+
+	a := &admin{
+		cfg:     cfg,
+		mongoDB: mongoDB,
+		getPublishedProducer: func(ctx context.Context) (kafka.IProducer, error) {
+			return service.NewServiceList(&service.Init{}).GetInitialisedKafkaProducer(ctx, cfg, service.KafkaProducerPublished)
+		},
+		out:    out,
+		output: *output,
+	}
+	return command(a, ctx, flags.Args()[1:])
+}
+
+// commandNames returns the sorted names of all the available subcommands
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"text/tabwriter"
+)
+
+// Supported output formats
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// write writes the provided value as indented json if the json output format is selected.
+// Otherwise, the provided table function is called to write the value as tab separated columns, which are then aligned.
+func (a *admin) write(v interface{}, table func(w io.Writer)) error {
+	if a.output == outputJSON {
+		encoder := json.NewEncoder(a.out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
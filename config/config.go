@@ -16,6 +16,7 @@ type Config struct {
 	APIURL                     string        `envconfig:"IMAGE_API_URL"`
 	Brokers                    []string      `envconfig:"KAFKA_ADDR"`
 	KafkaMaxBytes              int           `envconfig:"KAFKA_MAX_BYTES"`
+	KafkaSendTimeout           time.Duration `envconfig:"KAFKA_SEND_TIMEOUT"`
 	KafkaVersion               string        `envconfig:"KAFKA_VERSION"`
 	KafkaSecProtocol           string        `envconfig:"KAFKA_SEC_PROTO"`
 	KafkaSecCACerts            string        `envconfig:"KAFKA_SEC_CA_CERTS"`
@@ -26,6 +27,8 @@ type Config struct {
 	ProducerMinBrokersHealthy  int           `envconfig:"KAFKA_PRODUCER_MIN_BROKERS_HEALTHY"`
 	ImageUploadedTopic         string        `envconfig:"IMAGE_UPLOADED_TOPIC"`
 	StaticFilePublishedTopic   string        `envconfig:"STATIC_FILE_PUBLISHED_TOPIC"`
+	ImageWithdrawnTopic        string        `envconfig:"IMAGE_WITHDRAWN_TOPIC"`
+	ImageStateChangedTopic     string        `envconfig:"IMAGE_STATE_CHANGED_TOPIC"`
 	GracefulShutdownTimeout    time.Duration `envconfig:"GRACEFUL_SHUTDOWN_TIMEOUT"`
 	HealthCheckInterval        time.Duration `envconfig:"HEALTHCHECK_INTERVAL"`
 	HealthCheckCriticalTimeout time.Duration `envconfig:"HEALTHCHECK_CRITICAL_TIMEOUT"`
@@ -33,14 +36,50 @@ type Config struct {
 	ZebedeeURL                 string        `envconfig:"ZEBEDEE_URL"`
 	DownloadServiceURL         string        `envconfig:"DOWNLOAD_SERVICE_URL"`
 	EnableURLRewriting         bool          `envconfig:"ENABLE_URL_REWRITING"`
+	PublishSchedulerInterval   time.Duration `envconfig:"PUBLISH_SCHEDULER_INTERVAL"`
+	EventEncoding              string        `envconfig:"EVENT_ENCODING"`
+	EventSource                string        `envconfig:"EVENT_SOURCE"`
+	DeadLetterRetryInterval    time.Duration `envconfig:"DEAD_LETTER_RETRY_INTERVAL"`
+	DeadLetterMinBackoff       time.Duration `envconfig:"DEAD_LETTER_MIN_BACKOFF"`
+	DeadLetterMaxBackoff       time.Duration `envconfig:"DEAD_LETTER_MAX_BACKOFF"`
+	DeadLetterMaxAttempts      int           `envconfig:"DEAD_LETTER_MAX_ATTEMPTS"`
+	EventReplayRate            int           `envconfig:"EVENT_REPLAY_RATE"`
+	MigrateOnStartup           bool          `envconfig:"MIGRATE_ON_STARTUP"`
+	MigrationLockTimeout       time.Duration `envconfig:"MIGRATION_LOCK_TIMEOUT"`
+	DeletedImageTTL            time.Duration `envconfig:"DELETED_IMAGE_TTL"`
+	ImageLockTimeout           time.Duration `envconfig:"IMAGE_LOCK_TIMEOUT"`
+	MaxRequestBodySize         int64         `envconfig:"MAX_REQUEST_BODY_SIZE"`
+	MaxImportBodySize          int64         `envconfig:"MAX_IMPORT_BODY_SIZE"`
+	RateLimit                  float64       `envconfig:"RATE_LIMIT"`
+	RateLimitBurst             int           `envconfig:"RATE_LIMIT_BURST"`
+	RateLimitMaxClients        int           `envconfig:"RATE_LIMIT_MAX_CLIENTS"`
+	RateLimitTrustProxy        bool          `envconfig:"RATE_LIMIT_TRUST_PROXY"`
+	CacheControlMaxAge         time.Duration `envconfig:"CACHE_CONTROL_MAX_AGE"`
+	EnableCompression          bool          `envconfig:"ENABLE_COMPRESSION"`
+	PreviewURLSigningKey       string        `envconfig:"PREVIEW_URL_SIGNING_KEY"          json:"-"`
+	PreviewURLExpiry           time.Duration `envconfig:"PREVIEW_URL_EXPIRY"`
+	ImageLinkTemplate          string        `envconfig:"IMAGE_LINK_TEMPLATE"`
+	DownloadHrefTemplate       string        `envconfig:"DOWNLOAD_HREF_TEMPLATE"`
+	CDNHrefTemplate            string        `envconfig:"CDN_HREF_TEMPLATE"`
+	SlugifyFilenames           bool          `envconfig:"SLUGIFY_FILENAMES"`
 	MongoConfig
 }
 
 var cfg *Config
 
 const (
-	ImagesCollection     = "ImagesCollection"
-	ImagesLockCollection = "ImagesLockCollection"
+	ImagesCollection         = "ImagesCollection"
+	ImagesLockCollection     = "ImagesLockCollection"
+	ImagesVersionsCollection = "ImagesVersionsCollection"
+	DeadLettersCollection    = "DeadLettersCollection"
+	MigrationsCollection     = "MigrationsCollection"
+)
+
+// Possible values of EVENT_ENCODING
+const (
+	EventEncodingAvro                  = "avro"
+	EventEncodingCloudEventsStructured = "cloudevents-structured"
+	EventEncodingCloudEventsBinary     = "cloudevents-binary"
 )
 
 // Get returns the default config with any modifications through environment
@@ -56,10 +95,13 @@ func Get() (*Config, error) {
 		Brokers:                    []string{"localhost:9092", "localhost:9093", "localhost:9094"},
 		KafkaVersion:               "1.0.2",
 		KafkaMaxBytes:              2000000,
+		KafkaSendTimeout:           5 * time.Second,
 		ConsumerMinBrokersHealthy:  1,
 		ProducerMinBrokersHealthy:  1,
 		ImageUploadedTopic:         "image-uploaded",
 		StaticFilePublishedTopic:   "static-file-published",
+		ImageWithdrawnTopic:        "image-withdrawn",
+		ImageStateChangedTopic:     "image-state-changed",
 		GracefulShutdownTimeout:    5 * time.Second,
 		HealthCheckInterval:        30 * time.Second,
 		HealthCheckCriticalTimeout: 90 * time.Second,
@@ -67,12 +109,38 @@ func Get() (*Config, error) {
 		IsPublishing:               true,
 		DownloadServiceURL:         "http://localhost:23600",
 		EnableURLRewriting:         false,
+		PublishSchedulerInterval:   10 * time.Second,
+		EventEncoding:              EventEncodingAvro,
+		EventSource:                "dp-image-api",
+		DeadLetterRetryInterval:    30 * time.Second,
+		DeadLetterMinBackoff:       10 * time.Second,
+		DeadLetterMaxBackoff:       time.Hour,
+		DeadLetterMaxAttempts:      10,
+		EventReplayRate:            50,
+		MigrateOnStartup:           true,
+		MigrationLockTimeout:       5 * time.Minute,
+		DeletedImageTTL:            30 * 24 * time.Hour,
+		ImageLockTimeout:           5 * time.Second,
+		MaxRequestBodySize:         1024 * 1024,
+		MaxImportBodySize:          1024 * 1024 * 1024,
+		RateLimit:                  100,
+		RateLimitBurst:             200,
+		RateLimitMaxClients:        10000,
+		RateLimitTrustProxy:        false,
+		CacheControlMaxAge:         24 * time.Hour,
+		EnableCompression:          true,
+		PreviewURLSigningKey:       "",
+		PreviewURLExpiry:           15 * time.Minute,
+		ImageLinkTemplate:          "{api_url}/images/{image_id}",
+		DownloadHrefTemplate:       "{download_service_url}/images/{image_id}/{variant}/{version}/{filename}",
+		CDNHrefTemplate:            "",
+		SlugifyFilenames:           false,
 		MongoConfig: MongoConfig{
 			ClusterEndpoint:               "localhost:27017",
 			Username:                      "",
 			Password:                      "",
 			Database:                      "images",
-			Collections:                   map[string]string{ImagesCollection: "images", ImagesLockCollection: "images_locks"},
+			Collections:                   map[string]string{ImagesCollection: "images", ImagesLockCollection: "images_locks", ImagesVersionsCollection: "images_versions", DeadLettersCollection: "images_dead_letters", MigrationsCollection: "images_migrations"},
 			ReplicaSet:                    "",
 			IsStrongReadConcernEnabled:    false,
 			IsWriteConcernMajorityEnabled: true,
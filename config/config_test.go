@@ -23,14 +23,16 @@ func TestConfig(t *testing.T) {
 				So(cfg.KafkaVersion, ShouldEqual, "1.0.2")
 				So(cfg.KafkaSecProtocol, ShouldEqual, "")
 				So(cfg.KafkaMaxBytes, ShouldEqual, 2000000)
+				So(cfg.KafkaSendTimeout, ShouldEqual, 5*time.Second)
 				So(cfg.ImageUploadedTopic, ShouldEqual, "image-uploaded")
 				So(cfg.StaticFilePublishedTopic, ShouldEqual, "static-file-published")
+				So(cfg.ImageWithdrawnTopic, ShouldEqual, "image-withdrawn")
 				So(cfg.GracefulShutdownTimeout, ShouldEqual, 5*time.Second)
 				So(cfg.HealthCheckInterval, ShouldEqual, 30*time.Second)
 				So(cfg.HealthCheckCriticalTimeout, ShouldEqual, 90*time.Second)
 				So(cfg.ClusterEndpoint, ShouldEqual, "localhost:27017")
 				So(cfg.Database, ShouldEqual, "images")
-				So(cfg.Collections, ShouldResemble, map[string]string{ImagesCollection: "images", ImagesLockCollection: "images_locks"})
+				So(cfg.Collections, ShouldResemble, map[string]string{ImagesCollection: "images", ImagesLockCollection: "images_locks", ImagesVersionsCollection: "images_versions", DeadLettersCollection: "images_dead_letters", MigrationsCollection: "images_migrations"})
 				So(cfg.Username, ShouldEqual, "")
 				So(cfg.Password, ShouldEqual, "")
 				So(cfg.ReplicaSet, ShouldEqual, "")
@@ -44,6 +46,33 @@ func TestConfig(t *testing.T) {
 				So(cfg.ZebedeeURL, ShouldEqual, "http://localhost:8082")
 				So(cfg.DownloadServiceURL, ShouldEqual, "http://localhost:23600")
 				So(cfg.EnableURLRewriting, ShouldEqual, false)
+				So(cfg.PublishSchedulerInterval, ShouldEqual, 10*time.Second)
+				So(cfg.ImageStateChangedTopic, ShouldEqual, "image-state-changed")
+				So(cfg.EventEncoding, ShouldEqual, "avro")
+				So(cfg.EventSource, ShouldEqual, "dp-image-api")
+				So(cfg.DeadLetterRetryInterval, ShouldEqual, 30*time.Second)
+				So(cfg.DeadLetterMinBackoff, ShouldEqual, 10*time.Second)
+				So(cfg.DeadLetterMaxBackoff, ShouldEqual, time.Hour)
+				So(cfg.DeadLetterMaxAttempts, ShouldEqual, 10)
+				So(cfg.EventReplayRate, ShouldEqual, 50)
+				So(cfg.MigrateOnStartup, ShouldBeTrue)
+				So(cfg.MigrationLockTimeout, ShouldEqual, 5*time.Minute)
+				So(cfg.DeletedImageTTL, ShouldEqual, 30*24*time.Hour)
+				So(cfg.ImageLockTimeout, ShouldEqual, 5*time.Second)
+				So(cfg.MaxRequestBodySize, ShouldEqual, 1024*1024)
+				So(cfg.MaxImportBodySize, ShouldEqual, 1024*1024*1024)
+				So(cfg.RateLimit, ShouldEqual, 100)
+				So(cfg.RateLimitBurst, ShouldEqual, 200)
+				So(cfg.RateLimitMaxClients, ShouldEqual, 10000)
+				So(cfg.RateLimitTrustProxy, ShouldBeFalse)
+				So(cfg.CacheControlMaxAge, ShouldEqual, 24*time.Hour)
+				So(cfg.EnableCompression, ShouldBeTrue)
+				So(cfg.PreviewURLSigningKey, ShouldEqual, "")
+				So(cfg.PreviewURLExpiry, ShouldEqual, 15*time.Minute)
+				So(cfg.ImageLinkTemplate, ShouldEqual, "{api_url}/images/{image_id}")
+				So(cfg.DownloadHrefTemplate, ShouldEqual, "{download_service_url}/images/{image_id}/{variant}/{version}/{filename}")
+				So(cfg.CDNHrefTemplate, ShouldEqual, "")
+				So(cfg.SlugifyFilenames, ShouldBeFalse)
 			})
 			Convey("Then a second call to config should return the same config", func() {
 				newCfg, newErr := Get()
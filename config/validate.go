@@ -0,0 +1,197 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// kafkaTopicRegex matches the names that kafka accepts for topics
+var kafkaTopicRegex = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,249}$`)
+
+// minSigningKeyLength is the minimum length of the key signing preview URLs, which is the size of an HMAC-SHA256 hash
+const minSigningKeyLength = 32
+
+// ValidationError lists every problem found when validating a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+// Error returns all the problems of the configuration in a single message
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid configuration: %s", strings.Join(e.Problems, "; "))
+}
+
+// Validate checks the configuration values, returning a ValidationError with every problem found, or nil if the configuration is valid.
+// Kafka and Zebedee values are only checked in publishing mode, as they are not used in web mode.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.checkNotEmpty("BIND_ADDR", c.BindAddr)
+	v.checkURL("IMAGE_API_URL", c.APIURL)
+	v.checkURL("DOWNLOAD_SERVICE_URL", c.DownloadServiceURL)
+	v.checkTemplate("IMAGE_LINK_TEMPLATE", c.ImageLinkTemplate, "{image_id}")
+	v.checkTemplate("DOWNLOAD_HREF_TEMPLATE", c.DownloadHrefTemplate, "{image_id}", "{variant}", "{version}", "{filename}")
+	if c.CDNHrefTemplate != "" {
+		v.checkTemplate("CDN_HREF_TEMPLATE", c.CDNHrefTemplate, "{image_id}", "{variant}", "{version}", "{filename}")
+	}
+
+	v.checkPositiveDuration("GRACEFUL_SHUTDOWN_TIMEOUT", c.GracefulShutdownTimeout)
+	v.checkPositiveDuration("HEALTHCHECK_INTERVAL", c.HealthCheckInterval)
+	v.checkPositiveDuration("HEALTHCHECK_CRITICAL_TIMEOUT", c.HealthCheckCriticalTimeout)
+
+	v.checkNotEmpty("MONGODB_BIND_ADDR", c.ClusterEndpoint)
+	v.checkNotEmpty("MONGODB_DATABASE", c.Database)
+	v.checkPositiveDuration("MONGODB_CONNECT_TIMEOUT", c.ConnectTimeout)
+	v.checkPositiveDuration("MONGODB_QUERY_TIMEOUT", c.QueryTimeout)
+	for _, collection := range []string{ImagesCollection, ImagesLockCollection, ImagesVersionsCollection, DeadLettersCollection, MigrationsCollection} {
+		if c.Collections[collection] == "" {
+			v.addf("MONGODB_COLLECTIONS must map %s to a collection name", collection)
+		}
+	}
+
+	v.checkPositiveInt64("MAX_REQUEST_BODY_SIZE", c.MaxRequestBodySize)
+	v.checkPositiveInt64("MAX_IMPORT_BODY_SIZE", c.MaxImportBodySize)
+	if c.RateLimit < 0 {
+		v.addf("RATE_LIMIT must not be negative, got %g", c.RateLimit)
+	}
+	if c.RateLimit > 0 {
+		v.checkPositiveInt("RATE_LIMIT_BURST", c.RateLimitBurst)
+		v.checkPositiveInt("RATE_LIMIT_MAX_CLIENTS", c.RateLimitMaxClients)
+	}
+
+	if c.IsPublishing {
+		v.checkURL("ZEBEDEE_URL", c.ZebedeeURL)
+		v.checkBrokers("KAFKA_ADDR", c.Brokers)
+		v.checkNotEmpty("KAFKA_VERSION", c.KafkaVersion)
+		v.checkPositiveInt("KAFKA_MAX_BYTES", c.KafkaMaxBytes)
+		v.checkPositiveDuration("KAFKA_SEND_TIMEOUT", c.KafkaSendTimeout)
+		if c.KafkaSecProtocol != "" && c.KafkaSecProtocol != "TLS" {
+			v.addf("KAFKA_SEC_PROTO must be empty or 'TLS', got '%s'", c.KafkaSecProtocol)
+		}
+		v.checkTopic("IMAGE_UPLOADED_TOPIC", c.ImageUploadedTopic)
+		v.checkTopic("STATIC_FILE_PUBLISHED_TOPIC", c.StaticFilePublishedTopic)
+		v.checkTopic("IMAGE_WITHDRAWN_TOPIC", c.ImageWithdrawnTopic)
+		v.checkTopic("IMAGE_STATE_CHANGED_TOPIC", c.ImageStateChangedTopic)
+		v.checkEventEncoding("EVENT_ENCODING", c.EventEncoding)
+		v.checkNotEmpty("EVENT_SOURCE", c.EventSource)
+		v.checkPositiveDuration("PUBLISH_SCHEDULER_INTERVAL", c.PublishSchedulerInterval)
+		v.checkPositiveDuration("DEAD_LETTER_RETRY_INTERVAL", c.DeadLetterRetryInterval)
+		v.checkPositiveDuration("DEAD_LETTER_MIN_BACKOFF", c.DeadLetterMinBackoff)
+		v.checkPositiveDuration("DEAD_LETTER_MAX_BACKOFF", c.DeadLetterMaxBackoff)
+		if c.DeadLetterMaxBackoff < c.DeadLetterMinBackoff {
+			v.addf("DEAD_LETTER_MAX_BACKOFF (%s) must not be lower than DEAD_LETTER_MIN_BACKOFF (%s)", c.DeadLetterMaxBackoff, c.DeadLetterMinBackoff)
+		}
+		v.checkPositiveInt("DEAD_LETTER_MAX_ATTEMPTS", c.DeadLetterMaxAttempts)
+		v.checkPositiveDuration("DELETED_IMAGE_TTL", c.DeletedImageTTL)
+		v.checkPositiveDuration("IMAGE_LOCK_TIMEOUT", c.ImageLockTimeout)
+		v.checkPositiveDuration("MIGRATION_LOCK_TIMEOUT", c.MigrationLockTimeout)
+		if c.EventReplayRate < 0 {
+			v.addf("EVENT_REPLAY_RATE must not be negative, got %d", c.EventReplayRate)
+		}
+		if c.PreviewURLSigningKey != "" {
+			if len(c.PreviewURLSigningKey) < minSigningKeyLength {
+				v.addf("PREVIEW_URL_SIGNING_KEY must be at least %d bytes long", minSigningKeyLength)
+			}
+			v.checkPositiveDuration("PREVIEW_URL_EXPIRY", c.PreviewURLExpiry)
+		}
+	} else if c.CacheControlMaxAge < 0 {
+		v.addf("CACHE_CONTROL_MAX_AGE must not be negative, got %s", c.CacheControlMaxAge)
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// validator accumulates the problems found in a configuration
+type validator struct {
+	problems []string
+}
+
+func (v *validator) addf(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) checkNotEmpty(name, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.addf("%s must be set", name)
+	}
+}
+
+func (v *validator) checkPositiveInt(name string, value int) {
+	if value <= 0 {
+		v.addf("%s must be a positive number, got %d", name, value)
+	}
+}
+
+func (v *validator) checkPositiveInt64(name string, value int64) {
+	if value <= 0 {
+		v.addf("%s must be a positive number, got %d", name, value)
+	}
+}
+
+func (v *validator) checkPositiveDuration(name string, value time.Duration) {
+	if value <= 0 {
+		v.addf("%s must be a positive duration, got %s", name, value)
+	}
+}
+
+// checkURL checks that the value is an absolute http or https URL
+func (v *validator) checkURL(name, value string) {
+	if value == "" {
+		v.addf("%s must be set", name)
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.addf("%s must be an absolute http or https URL, got '%s'", name, value)
+	}
+}
+
+// checkTemplate checks that the value is a url template containing every required placeholder,
+// so that the urls built with it are different for every image, variant or file
+func (v *validator) checkTemplate(name, value string, placeholders ...string) {
+	if value == "" {
+		v.addf("%s must be set", name)
+		return
+	}
+	for _, placeholder := range placeholders {
+		if !strings.Contains(value, placeholder) {
+			v.addf("%s must contain the %s placeholder, got '%s'", name, placeholder, value)
+		}
+	}
+}
+
+// checkEventEncoding checks that the value is an event encoding that the kafka producers can send
+func (v *validator) checkEventEncoding(name, value string) {
+	switch value {
+	case EventEncodingAvro, EventEncodingCloudEventsStructured, EventEncodingCloudEventsBinary:
+	default:
+		v.addf("%s must be '%s', '%s' or '%s', got '%s'", name, EventEncodingAvro, EventEncodingCloudEventsStructured, EventEncodingCloudEventsBinary, value)
+	}
+}
+
+// checkBrokers checks that at least one broker is provided, and that every broker is a 'host:port' address
+func (v *validator) checkBrokers(name string, brokers []string) {
+	if len(brokers) == 0 {
+		v.addf("%s must list at least one kafka broker", name)
+		return
+	}
+	for _, broker := range brokers {
+		if host, port, err := net.SplitHostPort(broker); err != nil || host == "" || port == "" {
+			v.addf("%s must only contain 'host:port' addresses, got '%s'", name, broker)
+		}
+	}
+}
+
+// checkTopic checks that the value is a valid kafka topic name
+func (v *validator) checkTopic(name, value string) {
+	if !kafkaTopicRegex.MatchString(value) || value == "." || value == ".." {
+		v.addf("%s must be a valid kafka topic name (up to 249 letters, digits, '.', '_' or '-'), got '%s'", name, value)
+	}
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestValidate(t *testing.T) {
+	Convey("Given the default configuration", t, func() {
+		cfg, err := Get()
+		So(err, ShouldBeNil)
+
+		Convey("Then it is valid", func() {
+			So(cfg.Validate(), ShouldBeNil)
+		})
+
+		Convey("When every kind of value is invalid", func() {
+			cfg.APIURL = ""
+			cfg.DownloadServiceURL = "localhost:23600"
+			cfg.CDNHrefTemplate = "https://cdn.example.com/{image_id}/{version}/{filename}"
+			cfg.Brokers = []string{"localhost:9092", "localhost"}
+			cfg.ImageWithdrawnTopic = ""
+			cfg.StaticFilePublishedTopic = "static file published"
+			cfg.KafkaSendTimeout = 0
+			cfg.DeadLetterMaxBackoff = time.Second
+			cfg.DeletedImageTTL = 0
+			cfg.MaxRequestBodySize = 0
+			cfg.RateLimit = -1.5
+			cfg.Collections = map[string]string{ImagesCollection: "images", ImagesLockCollection: "images_locks", ImagesVersionsCollection: "images_versions", MigrationsCollection: "images_migrations"}
+
+			Convey("Then validation fails with a single error reporting every problem", func() {
+				err := cfg.Validate()
+				So(err, ShouldHaveSameTypeAs, &ValidationError{})
+				So(err.(*ValidationError).Problems, ShouldResemble, []string{
+					"IMAGE_API_URL must be set",
+					"DOWNLOAD_SERVICE_URL must be an absolute http or https URL, got 'localhost:23600'",
+					"CDN_HREF_TEMPLATE must contain the {variant} placeholder, got 'https://cdn.example.com/{image_id}/{version}/{filename}'",
+					"MONGODB_COLLECTIONS must map DeadLettersCollection to a collection name",
+					"MAX_REQUEST_BODY_SIZE must be a positive number, got 0",
+					"RATE_LIMIT must not be negative, got -1.5",
+					"KAFKA_ADDR must only contain 'host:port' addresses, got 'localhost'",
+					"KAFKA_SEND_TIMEOUT must be a positive duration, got 0s",
+					"STATIC_FILE_PUBLISHED_TOPIC must be a valid kafka topic name (up to 249 letters, digits, '.', '_' or '-'), got 'static file published'",
+					"IMAGE_WITHDRAWN_TOPIC must be a valid kafka topic name (up to 249 letters, digits, '.', '_' or '-'), got ''",
+					"DEAD_LETTER_MAX_BACKOFF (1s) must not be lower than DEAD_LETTER_MIN_BACKOFF (10s)",
+					"DELETED_IMAGE_TTL must be a positive duration, got 0s",
+				})
+				So(err.Error(), ShouldStartWith, "invalid configuration: IMAGE_API_URL must be set; DOWNLOAD_SERVICE_URL")
+			})
+		})
+
+		Convey("When publishing-only values are invalid in web mode", func() {
+			cfg.IsPublishing = false
+			cfg.Brokers = []string{}
+			cfg.ZebedeeURL = ""
+			cfg.ImageUploadedTopic = ""
+
+			Convey("Then the configuration is valid", func() {
+				So(cfg.Validate(), ShouldBeNil)
+			})
+
+			Convey("Then validation fails if the cache max age is negative", func() {
+				cfg.CacheControlMaxAge = -time.Minute
+				err := cfg.Validate()
+				So(err, ShouldHaveSameTypeAs, &ValidationError{})
+				So(err.(*ValidationError).Problems, ShouldResemble, []string{"CACHE_CONTROL_MAX_AGE must not be negative, got -1m0s"})
+			})
+		})
+
+		Convey("When requests are rate limited without any burst or tracked client", func() {
+			cfg.RateLimitBurst = 0
+			cfg.RateLimitMaxClients = 0
+
+			Convey("Then validation fails reporting the missing burst and tracked clients", func() {
+				err := cfg.Validate()
+				So(err, ShouldHaveSameTypeAs, &ValidationError{})
+				So(err.(*ValidationError).Problems, ShouldResemble, []string{
+					"RATE_LIMIT_BURST must be a positive number, got 0",
+					"RATE_LIMIT_MAX_CLIENTS must be a positive number, got 0",
+				})
+			})
+
+			Convey("Then the configuration is valid once rate limiting is disabled", func() {
+				cfg.RateLimit = 0
+				So(cfg.Validate(), ShouldBeNil)
+			})
+		})
+
+		Convey("When publishing-only values are invalid in publishing mode", func() {
+			cfg.Brokers = []string{}
+			cfg.ZebedeeURL = ""
+			cfg.KafkaSecProtocol = "SSL"
+
+			Convey("Then validation fails reporting the publishing-only problems", func() {
+				err := cfg.Validate()
+				So(err, ShouldHaveSameTypeAs, &ValidationError{})
+				So(err.(*ValidationError).Problems, ShouldResemble, []string{
+					"ZEBEDEE_URL must be set",
+					"KAFKA_ADDR must list at least one kafka broker",
+					"KAFKA_SEC_PROTO must be empty or 'TLS', got 'SSL'",
+				})
+			})
+		})
+
+		Convey("When the event encoding is not supported by the kafka producers", func() {
+			for _, encoding := range []string{"bogus", "cloudevents"} {
+				cfg.EventEncoding = encoding
+
+				Convey("Then validation fails reporting the '"+encoding+"' encoding", func() {
+					err := cfg.Validate()
+					So(err, ShouldHaveSameTypeAs, &ValidationError{})
+					So(err.(*ValidationError).Problems, ShouldResemble, []string{
+						"EVENT_ENCODING must be 'avro', 'cloudevents-structured' or 'cloudevents-binary', got '" + encoding + "'",
+					})
+				})
+			}
+
+			Convey("Then the configuration is valid in web mode, where no events are produced", func() {
+				cfg.EventEncoding = "bogus"
+				cfg.IsPublishing = false
+				So(cfg.Validate(), ShouldBeNil)
+			})
+		})
+
+		Convey("When preview urls are signed with a short key that never expires", func() {
+			cfg.PreviewURLSigningKey = "too short"
+			cfg.PreviewURLExpiry = 0
+
+			Convey("Then validation fails reporting the signing problems", func() {
+				err := cfg.Validate()
+				So(err, ShouldHaveSameTypeAs, &ValidationError{})
+				So(err.(*ValidationError).Problems, ShouldResemble, []string{
+					"PREVIEW_URL_SIGNING_KEY must be at least 32 bytes long",
+					"PREVIEW_URL_EXPIRY must be a positive duration, got 0s",
+				})
+			})
+
+			Convey("Then the configuration is valid once a long enough key is used in web mode, where preview urls are not signed", func() {
+				cfg.PreviewURLSigningKey = "0123456789abcdef0123456789abcdef"
+				cfg.IsPublishing = false
+				So(cfg.Validate(), ShouldBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,274 @@
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/dp-image-api/config"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// Encoding defines how events are encoded into kafka messages
+type Encoding string
+
+// Possible event encodings, which are the values of EVENT_ENCODING
+const (
+	EncodingAvro                  Encoding = config.EventEncodingAvro
+	EncodingCloudEventsStructured Encoding = config.EventEncodingCloudEventsStructured
+	EncodingCloudEventsBinary     Encoding = config.EventEncodingCloudEventsBinary
+)
+
+// CloudEvents constants, as defined by the CloudEvents 1.0 specification and its kafka protocol binding
+const (
+	CloudEventsSpecVersion     = "1.0"
+	CloudEventsContentType     = "application/cloudevents+json"
+	CloudEventsDataContentType = "application/json"
+	CloudEventsHeaderPrefix    = "ce_"
+	ContentTypeHeader          = "content-type"
+)
+
+// CloudEvents types for each event produced by this service
+const (
+	TypeImageUploaded     = "uk.gov.ons.dp.image.uploaded"
+	TypeImagePublished    = "uk.gov.ons.dp.image.published"
+	TypeImageWithdrawn    = "uk.gov.ons.dp.image.withdrawn"
+	TypeImageStateChanged = "uk.gov.ons.dp.image.state-changed"
+)
+
+// ParseEncoding returns the Encoding corresponding to the provided value, or an error if it is not a valid encoding
+func ParseEncoding(value string) (Encoding, error) {
+	switch e := Encoding(value); e {
+	case EncodingAvro, EncodingCloudEventsStructured, EncodingCloudEventsBinary:
+		return e, nil
+	default:
+		return "", fmt.Errorf("invalid event encoding '%s'", value)
+	}
+}
+
+// CloudEvent represents an event in CloudEvents 1.0 structured JSON format
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+}
+
+// BinaryMessage represents an event in CloudEvents binary mode, where the attributes are kafka headers and the value is the event data.
+// dp-kafka v3 producers cannot send per-message headers, so the headers only hold the attributes shared by all the events of a topic,
+// and the id and time of each event occurrence are members of the value, next to the event data.
+type BinaryMessage struct {
+	Headers map[string]string
+	Value   []byte
+}
+
+// BinaryHeaders returns the kafka headers of the CloudEvents binary mode messages of the provided type,
+// which are set once on the producer of the topic of that type
+func BinaryHeaders(source, eventType string) map[string]string {
+	return map[string]string{
+		CloudEventsHeaderPrefix + "specversion": CloudEventsSpecVersion,
+		CloudEventsHeaderPrefix + "source":      source,
+		CloudEventsHeaderPrefix + "type":        eventType,
+		ContentTypeHeader:                       CloudEventsDataContentType,
+	}
+}
+
+// CloudEventsMarshaller marshals events into CloudEvents structured JSON messages
+type CloudEventsMarshaller struct {
+	Source string
+	Now    func() time.Time
+}
+
+// NewCloudEventsMarshaller returns a new CloudEventsMarshaller for the provided event source
+func NewCloudEventsMarshaller(source string) *CloudEventsMarshaller {
+	return &CloudEventsMarshaller{
+		Source: source,
+		Now:    func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// Marshal marshals the provided event into a CloudEvents structured JSON message
+func (m *CloudEventsMarshaller) Marshal(s interface{}) ([]byte, error) {
+	ce, err := m.NewCloudEvent(s)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(ce)
+}
+
+// NewCloudEvent wraps the provided event into a CloudEvent, identified by the occurrence ID of the event
+func (m *CloudEventsMarshaller) NewCloudEvent(s interface{}) (*CloudEvent, error) {
+	eventType, err := TypeOf(s)
+	if err != nil {
+		return nil, err
+	}
+	id, err := EnsureID(s)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return &CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		ID:              id,
+		Source:          m.Source,
+		Type:            eventType,
+		Time:            m.Now(),
+		DataContentType: CloudEventsDataContentType,
+		Data:            data,
+	}, nil
+}
+
+// CloudEventsBinaryMarshaller marshals events into the values of CloudEvents binary mode messages,
+// whose headers are provided by BinaryHeaders
+type CloudEventsBinaryMarshaller struct {
+	CloudEventsMarshaller
+}
+
+// NewCloudEventsBinaryMarshaller returns a new CloudEventsBinaryMarshaller for the provided event source
+func NewCloudEventsBinaryMarshaller(source string) *CloudEventsBinaryMarshaller {
+	return &CloudEventsBinaryMarshaller{CloudEventsMarshaller: *NewCloudEventsMarshaller(source)}
+}
+
+// Marshal marshals the provided event into the value of a CloudEvents binary mode message:
+// the event data, with the id and time of the event occurrence as ce_id and ce_time members
+func (m *CloudEventsBinaryMarshaller) Marshal(s interface{}) ([]byte, error) {
+	ce, err := m.NewCloudEvent(s)
+	if err != nil {
+		return nil, err
+	}
+	value := map[string]json.RawMessage{}
+	if err := json.Unmarshal(ce.Data, &value); err != nil {
+		return nil, err
+	}
+	if value[CloudEventsHeaderPrefix+"id"], err = json.Marshal(ce.ID); err != nil {
+		return nil, err
+	}
+	if value[CloudEventsHeaderPrefix+"time"], err = json.Marshal(ce.Time); err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// DecodeBinary decodes a CloudEvents binary mode message into a CloudEvent
+func DecodeBinary(msg *BinaryMessage) (*CloudEvent, error) {
+	if msg == nil {
+		return nil, errors.New("message required but was nil")
+	}
+	value := map[string]json.RawMessage{}
+	if err := json.Unmarshal(msg.Value, &value); err != nil {
+		return nil, err
+	}
+	ce := &CloudEvent{
+		SpecVersion:     msg.Headers[CloudEventsHeaderPrefix+"specversion"],
+		Source:          msg.Headers[CloudEventsHeaderPrefix+"source"],
+		Type:            msg.Headers[CloudEventsHeaderPrefix+"type"],
+		DataContentType: msg.Headers[ContentTypeHeader],
+	}
+	if id, ok := value[CloudEventsHeaderPrefix+"id"]; ok {
+		if err := json.Unmarshal(id, &ce.ID); err != nil {
+			return nil, err
+		}
+		delete(value, CloudEventsHeaderPrefix+"id")
+	}
+	if eventTime, ok := value[CloudEventsHeaderPrefix+"time"]; ok {
+		if err := json.Unmarshal(eventTime, &ce.Time); err != nil {
+			return nil, err
+		}
+		delete(value, CloudEventsHeaderPrefix+"time")
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	ce.Data = data
+	return ce, ce.validate()
+}
+
+// UnmarshalCloudEvent unmarshals a CloudEvents structured JSON message
+func UnmarshalCloudEvent(b []byte) (*CloudEvent, error) {
+	ce := &CloudEvent{}
+	if err := json.Unmarshal(b, ce); err != nil {
+		return nil, err
+	}
+	return ce, ce.validate()
+}
+
+// DataAs unmarshals the CloudEvent data into the provided event struct
+func (ce *CloudEvent) DataAs(s interface{}) error {
+	return json.Unmarshal(ce.Data, s)
+}
+
+// validate checks that the CloudEvent has all the attributes required by the specification
+func (ce *CloudEvent) validate() error {
+	missing := []string{}
+	if ce.SpecVersion != CloudEventsSpecVersion {
+		missing = append(missing, "specversion")
+	}
+	if ce.ID == "" {
+		missing = append(missing, "id")
+	}
+	if ce.Source == "" {
+		missing = append(missing, "source")
+	}
+	if ce.Type == "" {
+		missing = append(missing, "type")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("invalid cloud event, missing or wrong attributes: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// TypeOf returns the CloudEvents type corresponding to the provided event
+func TypeOf(s interface{}) (string, error) {
+	switch s.(type) {
+	case *ImageUploaded, ImageUploaded:
+		return TypeImageUploaded, nil
+	case *ImagePublished, ImagePublished:
+		return TypeImagePublished, nil
+	case *ImageWithdrawn, ImageWithdrawn:
+		return TypeImageWithdrawn, nil
+	case *ImageStateChanged, ImageStateChanged:
+		return TypeImageStateChanged, nil
+	default:
+		return "", fmt.Errorf("unsupported event type %T", s)
+	}
+}
+
+// EnsureID returns the occurrence ID of the provided event, assigning a new random ID to it if it has none.
+// Events provided by value cannot be assigned an ID, so a new random ID is returned if they have none.
+func EnsureID(s interface{}) (string, error) {
+	var id *string
+	switch e := s.(type) {
+	case *ImageUploaded:
+		id = &e.ID
+	case ImageUploaded:
+		id = &e.ID
+	case *ImagePublished:
+		id = &e.ID
+	case ImagePublished:
+		id = &e.ID
+	case *ImageWithdrawn:
+		id = &e.ID
+	case ImageWithdrawn:
+		id = &e.ID
+	case *ImageStateChanged:
+		id = &e.ID
+	case ImageStateChanged:
+		id = &e.ID
+	default:
+		return "", fmt.Errorf("unsupported event type %T", s)
+	}
+	if *id == "" {
+		*id = uuid.NewString()
+	}
+	return *id, nil
+}
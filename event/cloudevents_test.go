@@ -0,0 +1,223 @@
+package event_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-image-api/event"
+	"github.com/ONSdigital/dp-image-api/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var testEventTime = time.Date(2020, time.April, 26, 10, 1, 28, 0, time.UTC)
+
+// newTestPublishedEvent returns a new occurrence of an image published event, without ID
+func newTestPublishedEvent() *event.ImagePublished {
+	return &event.ImagePublished{
+		SrcPath:      "images/123/original",
+		DstPath:      "images/123/original/image.png",
+		ImageID:      "123",
+		ImageVariant: "original",
+		Sha256:       "abc",
+		ContentType:  "image/png",
+	}
+}
+
+func testCloudEventsMarshaller() *event.CloudEventsMarshaller {
+	m := event.NewCloudEventsMarshaller("dp-image-api")
+	m.Now = func() time.Time { return testEventTime }
+	return m
+}
+
+func TestParseEncoding(t *testing.T) {
+	Convey("Valid encodings are successfully parsed", t, func() {
+		for _, encoding := range []event.Encoding{event.EncodingAvro, event.EncodingCloudEventsStructured, event.EncodingCloudEventsBinary} {
+			e, err := event.ParseEncoding(string(encoding))
+			So(err, ShouldBeNil)
+			So(e, ShouldEqual, encoding)
+		}
+	})
+
+	Convey("An invalid encoding results in an error", t, func() {
+		_, err := event.ParseEncoding("xml")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestAvroEncodingRoundTrip(t *testing.T) {
+	Convey("Given an image published event encoded with its avro schema", t, func() {
+		b, err := schema.ImagePublishedEvent.Marshal(newTestPublishedEvent())
+		So(err, ShouldBeNil)
+
+		Convey("Then decoding it results in the original event", func() {
+			decoded := &event.ImagePublished{}
+			err := schema.ImagePublishedEvent.Unmarshal(b, decoded)
+			So(err, ShouldBeNil)
+			So(decoded, ShouldResemble, newTestPublishedEvent())
+		})
+	})
+}
+
+func TestCloudEventsStructuredRoundTrip(t *testing.T) {
+	Convey("Given an image published event encoded as a CloudEvents structured JSON message", t, func() {
+		m := testCloudEventsMarshaller()
+		e := newTestPublishedEvent()
+		b, err := m.Marshal(e)
+		So(err, ShouldBeNil)
+
+		Convey("Then the event is assigned an occurrence ID, which is not part of the event data", func() {
+			So(e.ID, ShouldNotBeEmpty)
+			var raw map[string]interface{}
+			So(json.Unmarshal(b, &raw), ShouldBeNil)
+			So(raw["id"], ShouldEqual, e.ID)
+			So(raw["data"], ShouldNotContainKey, "ID")
+			So(raw["data"], ShouldHaveLength, 6)
+		})
+
+		Convey("Then the message contains the CloudEvents attributes and the event data", func() {
+			var raw map[string]interface{}
+			err := json.Unmarshal(b, &raw)
+			So(err, ShouldBeNil)
+			So(raw["specversion"], ShouldEqual, "1.0")
+			So(raw["type"], ShouldEqual, event.TypeImagePublished)
+			So(raw["source"], ShouldEqual, "dp-image-api")
+			So(raw["time"], ShouldEqual, "2020-04-26T10:01:28Z")
+			So(raw["datacontenttype"], ShouldEqual, "application/json")
+			So(raw["data"].(map[string]interface{})["image_id"], ShouldEqual, "123")
+		})
+
+		Convey("Then decoding it results in the original event, identified by the CloudEvent id", func() {
+			ce, err := event.UnmarshalCloudEvent(b)
+			So(err, ShouldBeNil)
+			So(ce.Time, ShouldEqual, testEventTime)
+			decoded := &event.ImagePublished{ID: ce.ID}
+			So(ce.DataAs(decoded), ShouldBeNil)
+			So(decoded, ShouldResemble, e)
+		})
+
+		Convey("Then encoding the same event occurrence again, at a later time, results in the same event ID", func() {
+			m.Now = func() time.Time { return testEventTime.Add(time.Minute) }
+			b2, err := m.Marshal(e)
+			So(err, ShouldBeNil)
+			ce1, err := event.UnmarshalCloudEvent(b)
+			So(err, ShouldBeNil)
+			ce2, err := event.UnmarshalCloudEvent(b2)
+			So(err, ShouldBeNil)
+			So(ce2.ID, ShouldEqual, ce1.ID)
+		})
+
+		Convey("Then encoding another occurrence of an event with the same payload results in a different event ID", func() {
+			b2, err := m.Marshal(newTestPublishedEvent())
+			So(err, ShouldBeNil)
+			ce1, err := event.UnmarshalCloudEvent(b)
+			So(err, ShouldBeNil)
+			ce2, err := event.UnmarshalCloudEvent(b2)
+			So(err, ShouldBeNil)
+			So(ce2.ID, ShouldNotEqual, ce1.ID)
+		})
+	})
+
+	Convey("Each event struct is encoded with its own CloudEvents type", t, func() {
+		m := testCloudEventsMarshaller()
+		for e, expectedType := range map[interface{}]string{
+			&event.ImageUploaded{ImageID: "123"}:     event.TypeImageUploaded,
+			&event.ImagePublished{ImageID: "123"}:    event.TypeImagePublished,
+			&event.ImageWithdrawn{ImageID: "123"}:    event.TypeImageWithdrawn,
+			&event.ImageStateChanged{ImageID: "123"}: event.TypeImageStateChanged,
+		} {
+			ce, err := m.NewCloudEvent(e)
+			So(err, ShouldBeNil)
+			So(ce.Type, ShouldEqual, expectedType)
+		}
+	})
+
+	Convey("An event that already has an occurrence ID is encoded with that ID", t, func() {
+		e := newTestPublishedEvent()
+		e.ID = "4e4b1a4c-2b55-4b8a-9f55-8a3e6b7e2f10"
+		ce, err := testCloudEventsMarshaller().NewCloudEvent(e)
+		So(err, ShouldBeNil)
+		So(ce.ID, ShouldEqual, "4e4b1a4c-2b55-4b8a-9f55-8a3e6b7e2f10")
+	})
+
+	Convey("Encoding an unsupported event results in an error", t, func() {
+		_, err := testCloudEventsMarshaller().Marshal("wrong")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Decoding a structured message without the required attributes results in an error", t, func() {
+		_, err := event.UnmarshalCloudEvent([]byte(`{"specversion": "1.0", "data": {}}`))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "invalid cloud event, missing or wrong attributes: id, source, type")
+	})
+}
+
+func TestCloudEventsBinaryRoundTrip(t *testing.T) {
+	Convey("Given an image published event encoded as a CloudEvents binary mode message", t, func() {
+		m := event.NewCloudEventsBinaryMarshaller("dp-image-api")
+		m.Now = func() time.Time { return testEventTime }
+		e := newTestPublishedEvent()
+		value, err := m.Marshal(e)
+		So(err, ShouldBeNil)
+		msg := &event.BinaryMessage{
+			Headers: event.BinaryHeaders("dp-image-api", event.TypeImagePublished),
+			Value:   value,
+		}
+
+		Convey("Then the attributes shared by all the events of the topic are kafka headers", func() {
+			So(msg.Headers, ShouldResemble, map[string]string{
+				"ce_specversion": "1.0",
+				"ce_source":      "dp-image-api",
+				"ce_type":        event.TypeImagePublished,
+				"content-type":   "application/json",
+			})
+		})
+
+		Convey("Then the value is the event data, with the id and time of the event occurrence", func() {
+			decoded := &event.ImagePublished{ID: e.ID}
+			So(json.Unmarshal(value, decoded), ShouldBeNil)
+			So(decoded, ShouldResemble, e)
+			attributes := map[string]interface{}{}
+			So(json.Unmarshal(value, &attributes), ShouldBeNil)
+			So(attributes["ce_id"], ShouldEqual, e.ID)
+			So(attributes["ce_time"], ShouldEqual, "2020-04-26T10:01:28Z")
+		})
+
+		Convey("Then decoding it results in the same CloudEvent as the structured encoding", func() {
+			ce, err := event.DecodeBinary(msg)
+			So(err, ShouldBeNil)
+			expected, err := testCloudEventsMarshaller().NewCloudEvent(e)
+			So(err, ShouldBeNil)
+			So(ce.SpecVersion, ShouldEqual, expected.SpecVersion)
+			So(ce.ID, ShouldEqual, expected.ID)
+			So(ce.Source, ShouldEqual, expected.Source)
+			So(ce.Type, ShouldEqual, expected.Type)
+			So(ce.Time, ShouldEqual, expected.Time)
+			So(ce.DataContentType, ShouldEqual, expected.DataContentType)
+			decoded := &event.ImagePublished{ID: ce.ID}
+			So(ce.DataAs(decoded), ShouldBeNil)
+			So(decoded, ShouldResemble, e)
+		})
+
+		Convey("Then another occurrence of an event with the same payload is encoded with a different id", func() {
+			value2, err := m.Marshal(newTestPublishedEvent())
+			So(err, ShouldBeNil)
+			ce1, err := event.DecodeBinary(msg)
+			So(err, ShouldBeNil)
+			ce2, err := event.DecodeBinary(&event.BinaryMessage{Headers: msg.Headers, Value: value2})
+			So(err, ShouldBeNil)
+			So(ce2.ID, ShouldNotEqual, ce1.ID)
+		})
+	})
+
+	Convey("Decoding a binary message without headers results in an error", t, func() {
+		_, err := event.DecodeBinary(&event.BinaryMessage{Value: []byte(`{"ce_id": "123"}`)})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "invalid cloud event, missing or wrong attributes: specversion, source, type")
+	})
+
+	Convey("Decoding a nil binary message results in an error", t, func() {
+		_, err := event.DecodeBinary(nil)
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -1,16 +1,42 @@
 package event
 
-// ImageUploaded provides an avro structure for an image uploaded event
+// The ID of each event struct identifies one occurrence of the event, and it is not part of the event payload.
+// It is assigned when the event is first sent, and kept when the same occurrence is sent again.
+
+// ImageUploaded provides an avro and json structure for an image uploaded event
 type ImageUploaded struct {
-	Path     string `avro:"path"`
-	ImageID  string `avro:"image_id"`
-	Filename string `avro:"filename"`
+	Path     string `avro:"path"     json:"path"`
+	ImageID  string `avro:"image_id" json:"image_id"`
+	Filename string `avro:"filename" json:"filename"`
+	ID       string `avro:"-"        json:"-"`
 }
 
-// ImagePublished provides an avro structure for an image published event
+// ImagePublished provides an avro and json structure for an image published event
 type ImagePublished struct {
-	SrcPath      string `avro:"src_path"`
-	DstPath      string `avro:"dst_path"`
-	ImageID      string `avro:"image_id"`
-	ImageVariant string `avro:"image_variant"`
+	SrcPath      string `avro:"src_path"      json:"src_path"`
+	DstPath      string `avro:"dst_path"      json:"dst_path"`
+	ImageID      string `avro:"image_id"      json:"image_id"`
+	ImageVariant string `avro:"image_variant" json:"image_variant"`
+	Sha256       string `avro:"sha256"        json:"sha256"`
+	ContentType  string `avro:"content_type"  json:"content_type"`
+	ID           string `avro:"-"             json:"-"`
+}
+
+// ImageWithdrawn provides an avro and json structure for an image withdrawn event
+type ImageWithdrawn struct {
+	Path         string `avro:"path"          json:"path"`
+	ImageID      string `avro:"image_id"      json:"image_id"`
+	ImageVariant string `avro:"image_variant" json:"image_variant"`
+	ID           string `avro:"-"             json:"-"`
+}
+
+// ImageStateChanged provides an avro and json structure for an image state changed event.
+// The variant is empty for changes of the image state, or the download variant ID for changes of a download variant state.
+type ImageStateChanged struct {
+	ImageID       string `avro:"image_id"       json:"image_id"`
+	CollectionID  string `avro:"collection_id"  json:"collection_id"`
+	ImageVariant  string `avro:"image_variant"  json:"image_variant"`
+	PreviousState string `avro:"previous_state" json:"previous_state"`
+	State         string `avro:"state"          json:"state"`
+	ID            string `avro:"-"              json:"-"`
 }
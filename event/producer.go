@@ -1,15 +1,26 @@
 package event
 
 import (
+	"context"
+	"time"
+
 	"github.com/pkg/errors"
 )
 
 //go:generate moq -out mock/marshaller.go -pkg mock . Marshaller
 
-// AvroProducer of output events.
+// Errors returned when an event cannot be handed over to the kafka producer
+var (
+	ErrProducerNotInitialised = errors.New("kafka producer is not initialised")
+	ErrSendTimeout            = errors.New("timed out sending event to kafka producer")
+)
+
+// AvroProducer of output events, encoded by its Marshaller (an avro schema, or a CloudEventsMarshaller).
 type AvroProducer struct {
-	out        chan []byte
-	marshaller Marshaller
+	out           chan []byte
+	marshaller    Marshaller
+	sendTimeout   time.Duration
+	isInitialised func() bool
 }
 
 // Marshaller marshals events into messages.
@@ -18,35 +29,79 @@ type Marshaller interface {
 }
 
 // NewAvroProducer returns a new instance of AvroProducer.
-func NewAvroProducer(outputChannel chan []byte, marshaller Marshaller) *AvroProducer {
+// Sending an event fails if it is not accepted by the output channel within the provided sendTimeout (zero means no timeout),
+// or if the optional isInitialised func reports that the kafka producer is not initialised, as it would discard the message.
+func NewAvroProducer(outputChannel chan []byte, marshaller Marshaller, sendTimeout time.Duration, isInitialised func() bool) *AvroProducer {
 	return &AvroProducer{
-		out:        outputChannel,
-		marshaller: marshaller,
+		out:           outputChannel,
+		marshaller:    marshaller,
+		sendTimeout:   sendTimeout,
+		isInitialised: isInitialised,
 	}
 }
 
 // ImageUploaded produces a new ImageUploaded event.
-func (producer *AvroProducer) ImageUploaded(event *ImageUploaded) error {
+func (producer *AvroProducer) ImageUploaded(ctx context.Context, event *ImageUploaded) error {
 	if event == nil {
 		return errors.New("event required but was nil")
 	}
-	return producer.marshalAndSendEvent(event)
+	return producer.marshalAndSendEvent(ctx, event)
 }
 
 // ImagePublished produces a new ImagePublished event.
-func (producer *AvroProducer) ImagePublished(event *ImagePublished) error {
+func (producer *AvroProducer) ImagePublished(ctx context.Context, event *ImagePublished) error {
+	if event == nil {
+		return errors.New("event required but was nil")
+	}
+	return producer.marshalAndSendEvent(ctx, event)
+}
+
+// ImageWithdrawn produces a new ImageWithdrawn event.
+func (producer *AvroProducer) ImageWithdrawn(ctx context.Context, event *ImageWithdrawn) error {
+	if event == nil {
+		return errors.New("event required but was nil")
+	}
+	return producer.marshalAndSendEvent(ctx, event)
+}
+
+// ImageStateChanged produces a new ImageStateChanged event.
+func (producer *AvroProducer) ImageStateChanged(ctx context.Context, event *ImageStateChanged) error {
 	if event == nil {
 		return errors.New("event required but was nil")
 	}
-	return producer.marshalAndSendEvent(event)
+	return producer.marshalAndSendEvent(ctx, event)
 }
 
-// marshalAndSendEvent is a generic function that marshals avro events and sends them to the output channel of the producer
-func (producer *AvroProducer) marshalAndSendEvent(event interface{}) error {
+// marshalAndSendEvent is a generic function that marshals avro events and sends them to the output channel of the producer.
+// The event is assigned an occurrence ID first, if it has none, so that it keeps the same ID if it needs to be sent again.
+// It returns an error if the kafka producer is not initialised, or if the message is not accepted before the context is done or the send timeout expires.
+func (producer *AvroProducer) marshalAndSendEvent(ctx context.Context, event interface{}) error {
+	if _, err := EnsureID(event); err != nil {
+		return err
+	}
+
+	if producer.isInitialised != nil && !producer.isInitialised() {
+		return ErrProducerNotInitialised
+	}
+
 	bytes, err := producer.marshaller.Marshal(event)
 	if err != nil {
 		return err
 	}
-	producer.out <- bytes
-	return nil
+
+	if producer.sendTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, producer.sendTimeout)
+		defer cancel()
+	}
+
+	select {
+	case producer.out <- bytes:
+		return nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ErrSendTimeout
+		}
+		return ctx.Err()
+	}
 }
@@ -1,7 +1,9 @@
 package event_test
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/ONSdigital/dp-image-api/event"
 	"github.com/ONSdigital/dp-image-api/event/mock"
@@ -9,7 +11,10 @@ import (
 	. "github.com/smartystreets/goconvey/convey"
 )
 
-var errMarshal = errors.New("Marshal error")
+var (
+	errMarshal = errors.New("Marshal error")
+	testCtx    = context.Background()
+)
 
 func TestAvroProducer(t *testing.T) {
 	Convey("Given a successful message producer mock", t, func() {
@@ -27,10 +32,10 @@ func TestAvroProducer(t *testing.T) {
 		}
 
 		// eventProducer under test
-		eventProducer := event.NewAvroProducer(outputChannel, marshallerMock)
+		eventProducer := event.NewAvroProducer(outputChannel, marshallerMock, time.Second, nil)
 
 		Convey("when ImageUploaded is called with a nil event", func() {
-			err := eventProducer.ImageUploaded(nil)
+			err := eventProducer.ImageUploaded(testCtx, nil)
 
 			Convey("then the expected error is returned", func() {
 				So(err.Error(), ShouldEqual, "event required but was nil")
@@ -42,7 +47,31 @@ func TestAvroProducer(t *testing.T) {
 		})
 
 		Convey("when ImagePublished is called with a nil event", func() {
-			err := eventProducer.ImagePublished(nil)
+			err := eventProducer.ImagePublished(testCtx, nil)
+
+			Convey("then the expected error is returned", func() {
+				So(err.Error(), ShouldEqual, "event required but was nil")
+			})
+
+			Convey("and marshaller is never called", func() {
+				So(marshallerMock.MarshalCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("when ImageWithdrawn is called with a nil event", func() {
+			err := eventProducer.ImageWithdrawn(testCtx, nil)
+
+			Convey("then the expected error is returned", func() {
+				So(err.Error(), ShouldEqual, "event required but was nil")
+			})
+
+			Convey("and marshaller is never called", func() {
+				So(marshallerMock.MarshalCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("when ImageStateChanged is called with a nil event", func() {
+			err := eventProducer.ImageStateChanged(testCtx, nil)
 
 			Convey("then the expected error is returned", func() {
 				So(err.Error(), ShouldEqual, "event required but was nil")
@@ -59,7 +88,7 @@ func TestAvroProducer(t *testing.T) {
 				Path:     "myPath",
 				Filename: "filename.png",
 			}
-			err := eventProducer.ImageUploaded(uploadedEvent)
+			err := eventProducer.ImageUploaded(testCtx, uploadedEvent)
 
 			Convey("The expected event is available on the output channel", func() {
 				So(err, ShouldBeNil)
@@ -77,7 +106,43 @@ func TestAvroProducer(t *testing.T) {
 				ImageID:      "123",
 				ImageVariant: "original",
 			}
-			err := eventProducer.ImagePublished(publishedEvent)
+			err := eventProducer.ImagePublished(testCtx, publishedEvent)
+
+			Convey("The expected event is available on the output channel", func() {
+				So(err, ShouldBeNil)
+
+				messageBytes := <-outputChannel
+				close(outputChannel)
+				So(messageBytes, ShouldResemble, avroBytes)
+			})
+		})
+
+		Convey("When ImageWithdrawn is called on the event producer", func() {
+			withdrawnEvent := &event.ImageWithdrawn{
+				Path:         "path/public/img.png",
+				ImageID:      "123",
+				ImageVariant: "original",
+			}
+			err := eventProducer.ImageWithdrawn(testCtx, withdrawnEvent)
+
+			Convey("The expected event is available on the output channel", func() {
+				So(err, ShouldBeNil)
+
+				messageBytes := <-outputChannel
+				close(outputChannel)
+				So(messageBytes, ShouldResemble, avroBytes)
+			})
+		})
+
+		Convey("When ImageStateChanged is called on the event producer", func() {
+			stateChangedEvent := &event.ImageStateChanged{
+				ImageID:       "123",
+				CollectionID:  "collection1",
+				ImageVariant:  "original",
+				PreviousState: "published",
+				State:         "completed",
+			}
+			err := eventProducer.ImageStateChanged(testCtx, stateChangedEvent)
 
 			Convey("The expected event is available on the output channel", func() {
 				So(err, ShouldBeNil)
@@ -98,7 +163,7 @@ func TestAvroProducer(t *testing.T) {
 		}
 
 		// eventProducer under test, without out channel because nothing is expected to be sent
-		eventProducer := event.NewAvroProducer(nil, marshallerMock)
+		eventProducer := event.NewAvroProducer(nil, marshallerMock, time.Second, nil)
 
 		Convey("When ImageUploaded is called on the event producer", func() {
 			uploadedEvent := &event.ImageUploaded{
@@ -106,7 +171,7 @@ func TestAvroProducer(t *testing.T) {
 				Path:     "myPath",
 				Filename: "filename.png",
 			}
-			err := eventProducer.ImageUploaded(uploadedEvent)
+			err := eventProducer.ImageUploaded(testCtx, uploadedEvent)
 
 			Convey("The expected error is returned", func() {
 				So(err, ShouldResemble, errMarshal)
@@ -120,11 +185,77 @@ func TestAvroProducer(t *testing.T) {
 				ImageID:      "123",
 				ImageVariant: "original",
 			}
-			err := eventProducer.ImagePublished(publishedEvent)
+			err := eventProducer.ImagePublished(testCtx, publishedEvent)
 
 			Convey("The expected error is returned", func() {
 				So(err, ShouldResemble, errMarshal)
 			})
 		})
+
+		Convey("When ImageWithdrawn is called on the event producer", func() {
+			withdrawnEvent := &event.ImageWithdrawn{
+				Path:         "path/public/img.png",
+				ImageID:      "123",
+				ImageVariant: "original",
+			}
+			err := eventProducer.ImageWithdrawn(testCtx, withdrawnEvent)
+
+			Convey("The expected error is returned", func() {
+				So(err, ShouldResemble, errMarshal)
+			})
+		})
+
+		Convey("When ImageStateChanged is called on the event producer", func() {
+			stateChangedEvent := &event.ImageStateChanged{
+				ImageID: "123",
+				State:   "created",
+			}
+			err := eventProducer.ImageStateChanged(testCtx, stateChangedEvent)
+
+			Convey("The expected error is returned", func() {
+				So(err, ShouldResemble, errMarshal)
+			})
+		})
+	})
+
+	Convey("Given an event producer whose output channel does not accept messages", t, func() {
+		marshallerMock := &mock.MarshallerMock{
+			MarshalFunc: func(s interface{}) ([]byte, error) {
+				return []byte("hello world"), nil
+			},
+		}
+		eventProducer := event.NewAvroProducer(make(chan []byte), marshallerMock, 10*time.Millisecond, nil)
+
+		Convey("When ImageUploaded is called on the event producer", func() {
+			err := eventProducer.ImageUploaded(testCtx, &event.ImageUploaded{ImageID: "myImage"})
+
+			Convey("Then the send timeout error is returned", func() {
+				So(err, ShouldEqual, event.ErrSendTimeout)
+			})
+		})
+
+		Convey("When ImageUploaded is called with a context that is already cancelled", func() {
+			ctx, cancel := context.WithCancel(testCtx)
+			cancel()
+			err := eventProducer.ImageUploaded(ctx, &event.ImageUploaded{ImageID: "myImage"})
+
+			Convey("Then the context error is returned", func() {
+				So(err, ShouldEqual, context.Canceled)
+			})
+		})
+	})
+
+	Convey("Given an event producer for a kafka producer that is not initialised", t, func() {
+		marshallerMock := &mock.MarshallerMock{}
+		eventProducer := event.NewAvroProducer(make(chan []byte, 1), marshallerMock, time.Second, func() bool { return false })
+
+		Convey("When ImagePublished is called on the event producer", func() {
+			err := eventProducer.ImagePublished(testCtx, &event.ImagePublished{ImageID: "123"})
+
+			Convey("Then the not initialised error is returned without marshalling the event", func() {
+				So(err, ShouldEqual, event.ErrProducerNotInitialised)
+				So(marshallerMock.MarshalCalls(), ShouldHaveLength, 0)
+			})
+		})
 	})
 }
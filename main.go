@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
@@ -27,11 +29,39 @@ func main() {
 	log.Namespace = serviceName
 	ctx := context.Background()
 
+	if len(os.Args) > 1 && os.Args[1] == replayEventsCommand {
+		if err := runReplayEvents(ctx, os.Args[2:]); err != nil {
+			log.Fatal(ctx, "event replay failed", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	checkConfig := flag.Bool("check-config", false, "validate the configuration provided by the environment and exit")
+	flag.Parse()
+	if *checkConfig {
+		if err := runCheckConfig(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println("configuration is valid")
+		return
+	}
+
 	if err := run(ctx); err != nil {
 		log.Fatal(ctx, "fatal runtime error", err)
 	}
 }
 
+// runCheckConfig reads the configuration from the environment and validates it, returning every problem found
+func runCheckConfig() error {
+	cfg, err := config.Get()
+	if err != nil {
+		return errors.Wrap(err, "unable to retrieve service configuration")
+	}
+	return cfg.Validate()
+}
+
 func run(ctx context.Context) error {
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
@@ -40,11 +70,14 @@ func run(ctx context.Context) error {
 	svcErrors := make(chan error, 1)
 	svcList := service.NewServiceList(&service.Init{})
 
-	// Read config
+	// Read and validate config
 	cfg, err := config.Get()
 	if err != nil {
 		return errors.Wrap(err, "unable to retrieve service configuration")
 	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
 
 	svc, err := service.Run(ctx, cfg, svcList, BuildTime, GitCommit, Version, svcErrors)
 	if err != nil {
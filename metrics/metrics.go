@@ -0,0 +1,46 @@
+// Package metrics holds the counters and gauges of this service instance. They are published as expvar variables,
+// which are served as json by the /debug/vars endpoint.
+package metrics
+
+import "expvar"
+
+// Image lock metric keys
+const (
+	// LocksAcquired is the number of image locks acquired
+	LocksAcquired = "acquired"
+	// LocksContended is the number of image locks acquired after waiting for another request to release them
+	LocksContended = "contended"
+	// LocksTimeouts is the number of image lock acquisitions that timed out
+	LocksTimeouts = "timeouts"
+	// LocksWaitMs is the total time, in milliseconds, spent waiting to acquire image locks
+	LocksWaitMs = "wait_ms"
+	// LocksWaiting is the number of requests currently waiting to acquire an image lock
+	LocksWaiting = "waiting"
+	// LocksHeld is the number of image locks currently held
+	LocksHeld = "held"
+)
+
+// ImageLocks holds the image lock metrics
+var ImageLocks = expvar.NewMap("image_locks")
+
+// Request limit metric keys
+const (
+	// LimitsRate is the configured number of requests per second allowed for each client on each route, 0 if requests are not rate limited
+	LimitsRate = "rate"
+	// LimitsBurst is the configured number of requests that each client can send at once on each route
+	LimitsBurst = "burst"
+	// LimitsMaxClients is the configured maximum number of client and route pairs tracked by the rate limiter
+	LimitsMaxClients = "max_clients"
+	// LimitsMaxBodyBytes is the configured maximum size of request bodies. Paths with their own maximum size have a key
+	// made of this key, a colon and the path.
+	LimitsMaxBodyBytes = "max_body_bytes"
+	// LimitsRateLimited is the number of requests rejected because their client exceeded the rate limit
+	LimitsRateLimited = "rate_limited"
+	// LimitsBodyTooLarge is the number of requests rejected because their body was too large
+	LimitsBodyTooLarge = "body_too_large"
+	// LimitsClients is the number of client and route pairs currently tracked by the rate limiter
+	LimitsClients = "clients"
+)
+
+// RequestLimits holds the request body size and rate limits, and the number of requests that exceeded them
+var RequestLimits = expvar.NewMap("request_limits")
@@ -0,0 +1,67 @@
+// Package middleware provides the http middleware applied to the API routes, which limits requests and compresses responses
+package middleware
+
+import (
+	"errors"
+	"expvar"
+	"io"
+	"net/http"
+
+	"github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/dp-image-api/metrics"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// BodyLimit returns a middleware that limits the size of request bodies to maxBytes, or to the limit of the request path in pathLimits.
+// Requests declaring a larger Content-Length are rejected with 413 without being served. Reading a larger body without
+// Content-Length fails with an *http.MaxBytesError once the limit is reached, for the handler to respond with 413.
+func BodyLimit(maxBytes int64, pathLimits map[string]int64) func(http.Handler) http.Handler {
+	metrics.RequestLimits.Set(metrics.LimitsMaxBodyBytes, newInt(maxBytes))
+	for path, limit := range pathLimits {
+		metrics.RequestLimits.Set(metrics.LimitsMaxBodyBytes+":"+path, newInt(limit))
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			limit := maxBytes
+			if pathLimit, ok := pathLimits[req.URL.Path]; ok {
+				limit = pathLimit
+			}
+
+			if req.ContentLength > limit {
+				metrics.RequestLimits.Add(metrics.LimitsBodyTooLarge, 1)
+				log.Warn(req.Context(), "request body is too large", log.Data{"path": req.URL.Path, "content_length": req.ContentLength, "limit": limit})
+				http.Error(w, apierrors.ErrRequestBodyTooLarge.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			if req.Body != nil && req.Body != http.NoBody {
+				req.Body = &limitedBody{ReadCloser: http.MaxBytesReader(w, req.Body, limit)}
+			}
+			h.ServeHTTP(w, req)
+		})
+	}
+}
+
+// limitedBody is a size-limited request body that records the requests whose body exceeded the limit
+type limitedBody struct {
+	io.ReadCloser
+	exceeded bool
+}
+
+// Read reads from the body, returning an *http.MaxBytesError once the limit is reached
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxBytesErr *http.MaxBytesError
+	if !b.exceeded && errors.As(err, &maxBytesErr) {
+		b.exceeded = true
+		metrics.RequestLimits.Add(metrics.LimitsBodyTooLarge, 1)
+	}
+	return n, err
+}
+
+// newInt returns an expvar integer with the provided value
+func newInt(value int64) *expvar.Int {
+	v := new(expvar.Int)
+	v.Set(value)
+	return v
+}
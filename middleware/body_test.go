@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ONSdigital/dp-image-api/metrics"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// readBodyHandler reads the whole request body, responding with 413 if it is too large, or with the body otherwise
+var readBodyHandler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	_, _ = w.Write(body)
+})
+
+// serveBody serves a POST request with the provided body and content length to the provided handler
+func serveBody(h http.Handler, path, body string, contentLength int64) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "http://localhost:24700"+path, strings.NewReader(body))
+	r.ContentLength = contentLength
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestBodyLimit(t *testing.T) {
+	Convey("Given a body limit of 10 bytes, and of 20 bytes for the import path", t, func() {
+		h := BodyLimit(10, map[string]int64{"/admin/import": 20})(readBodyHandler)
+		tooLarge := getLimitMetric(metrics.LimitsBodyTooLarge)
+
+		Convey("Then the limits are exposed in metrics", func() {
+			So(getLimitMetric(metrics.LimitsMaxBodyBytes), ShouldEqual, 10)
+			So(getLimitMetric(metrics.LimitsMaxBodyBytes+":/admin/import"), ShouldEqual, 20)
+		})
+
+		Convey("Then a body within the limit is served", func() {
+			w := serveBody(h, "/images", "0123456789", 10)
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Body.String(), ShouldEqual, "0123456789")
+		})
+
+		Convey("Then a request declaring a larger body is rejected with 413 without being served", func() {
+			w := serveBody(h, "/images", "01234567890", 11)
+			So(w.Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+			So(w.Body.String(), ShouldEqual, "request body is too large\n")
+			So(getLimitMetric(metrics.LimitsBodyTooLarge), ShouldEqual, tooLarge+1)
+		})
+
+		Convey("Then a larger body without content length fails to be read once the limit is reached", func() {
+			w := serveBody(h, "/images", "01234567890", -1)
+			So(w.Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+			So(getLimitMetric(metrics.LimitsBodyTooLarge), ShouldEqual, tooLarge+1)
+		})
+
+		Convey("Then the import path has its own limit", func() {
+			So(serveBody(h, "/admin/import", "01234567890123456789", 20).Code, ShouldEqual, http.StatusOK)
+			So(serveBody(h, "/admin/import", "012345678901234567890", -1).Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+		})
+	})
+}
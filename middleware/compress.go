@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/andybalholm/brotli"
+)
+
+// minCompressSize is the minimum size of the first chunk of a response body for the response to be compressed,
+// as compressing smaller bodies saves little and costs a lot in comparison
+const minCompressSize = 1024
+
+// Supported content encodings, in order of preference
+const (
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+// Compress returns a handler that compresses the body of 200 OK responses with brotli or gzip, according to the Accept-Encoding
+// header of the request. Responses are only compressed if the first chunk of their body is at least minCompressSize bytes long.
+func Compress(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(req.Header.Get("Accept-Encoding"))
+		if encoding == "" || req.Method == http.MethodHead {
+			h.ServeHTTP(w, req)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer func() {
+			if err := cw.Close(); err != nil {
+				log.Error(req.Context(), "failed to write compressed response body", err, log.Data{"path": req.URL.Path, "encoding": encoding})
+			}
+		}()
+		h.ServeHTTP(cw, req)
+	})
+}
+
+// compressWriter is a response writer that delays writing the response header until the first chunk of body is written,
+// to decide whether the body is compressed from the status, headers and size of the response
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	status      int
+	wroteHeader bool
+	encoder     io.WriteCloser
+}
+
+// WriteHeader records the status of the response, which is written with the first chunk of body
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+}
+
+// Write writes a chunk of body, compressed if the response is being compressed
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.start(len(p))
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Close flushes the compressed body, or writes the header of responses without body, such as 304 Not Modified
+func (cw *compressWriter) Close() error {
+	if !cw.wroteHeader {
+		if cw.status == 0 {
+			// nothing was written, so that net/http writes the default 200 OK response
+			return nil
+		}
+		cw.start(0)
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Close()
+	}
+	return nil
+}
+
+// start decides whether the response is compressed and writes its header, given the size of the first chunk of body
+func (cw *compressWriter) start(size int) {
+	cw.wroteHeader = true
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	header := cw.Header()
+	if cw.status == http.StatusOK && size >= minCompressSize && header.Get("Content-Encoding") == "" {
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+		cw.encoder = newEncoder(cw.encoding, cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+}
+
+// newEncoder returns a writer compressing to w with the provided encoding
+func newEncoder(encoding string, w io.Writer) io.WriteCloser {
+	if encoding == encodingBrotli {
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression)
+	}
+	return gzip.NewWriter(w)
+}
+
+// negotiateEncoding returns the supported encoding with the highest quality value in the provided Accept-Encoding header,
+// preferring brotli to gzip if they have the same quality, or an empty string if the response must not be compressed
+func negotiateEncoding(acceptEncoding string) string {
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			quality = q
+		}
+		qualities[strings.ToLower(strings.TrimSpace(coding))] = quality
+	}
+
+	best, bestQuality := "", 0.0
+	for _, encoding := range []string{encodingBrotli, encodingGzip} {
+		quality, ok := qualities[encoding]
+		if !ok {
+			quality = qualities["*"]
+		}
+		if quality > bestQuality {
+			best, bestQuality = encoding, quality
+		}
+	}
+	return best
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// serveCompressed serves a GET request accepting the provided encodings to the provided handler wrapped by the compression middleware
+func serveCompressed(h http.HandlerFunc, acceptEncoding string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "http://localhost:24700/images", http.NoBody)
+	if acceptEncoding != "" {
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	Compress(h).ServeHTTP(w, r)
+	return w
+}
+
+// decompress returns the decoded body of the provided response
+func decompress(w *httptest.ResponseRecorder) string {
+	var reader io.Reader
+	switch w.Header().Get("Content-Encoding") {
+	case encodingBrotli:
+		reader = brotli.NewReader(w.Body)
+	case encodingGzip:
+		gzipReader, err := gzip.NewReader(w.Body)
+		So(err, ShouldBeNil)
+		reader = gzipReader
+	default:
+		reader = w.Body
+	}
+	body, err := io.ReadAll(reader)
+	So(err, ShouldBeNil)
+	return string(body)
+}
+
+func TestCompress(t *testing.T) {
+	largeBody := strings.Repeat(`{"id":"some-image-id","state":"completed"}`, 100)
+	writeBody := func(status int, body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(body))
+		}
+	}
+
+	Convey("Given a handler returning a large body", t, func() {
+		h := writeBody(http.StatusOK, largeBody)
+
+		Convey("Then the body is compressed with brotli when the client accepts it", func() {
+			w := serveCompressed(h, "gzip, deflate, br")
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get("Content-Encoding"), ShouldEqual, "br")
+			So(w.Header().Get("Vary"), ShouldEqual, "Accept-Encoding")
+			So(w.Body.Len(), ShouldBeLessThan, len(largeBody))
+			So(decompress(w), ShouldEqual, largeBody)
+		})
+
+		Convey("Then the body is compressed with gzip when the client prefers it", func() {
+			w := serveCompressed(h, "br;q=0.5, gzip")
+			So(w.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
+			So(decompress(w), ShouldEqual, largeBody)
+		})
+
+		Convey("Then the body is compressed with brotli when the client accepts any encoding", func() {
+			w := serveCompressed(h, "*")
+			So(w.Header().Get("Content-Encoding"), ShouldEqual, "br")
+			So(decompress(w), ShouldEqual, largeBody)
+		})
+
+		Convey("Then the body is not compressed when the client does not accept any supported encoding", func() {
+			for _, acceptEncoding := range []string{"", "identity", "deflate", "br;q=0, gzip;q=0", "*;q=0"} {
+				w := serveCompressed(h, acceptEncoding)
+				So(w.Header().Get("Content-Encoding"), ShouldBeEmpty)
+				So(w.Header().Get("Vary"), ShouldEqual, "Accept-Encoding")
+				So(w.Body.String(), ShouldEqual, largeBody)
+			}
+		})
+	})
+
+	Convey("Given a handler returning a small body", t, func() {
+		w := serveCompressed(writeBody(http.StatusOK, `{"id":"some-image-id"}`), "gzip, br")
+
+		Convey("Then the body is not compressed", func() {
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get("Content-Encoding"), ShouldBeEmpty)
+			So(w.Body.String(), ShouldEqual, `{"id":"some-image-id"}`)
+		})
+	})
+
+	Convey("Given a handler returning a large error body", t, func() {
+		w := serveCompressed(writeBody(http.StatusInternalServerError, largeBody), "gzip, br")
+
+		Convey("Then the body is not compressed", func() {
+			So(w.Code, ShouldEqual, http.StatusInternalServerError)
+			So(w.Header().Get("Content-Encoding"), ShouldBeEmpty)
+			So(w.Body.String(), ShouldEqual, largeBody)
+		})
+	})
+
+	Convey("Given a handler returning 304 Not Modified without body", t, func() {
+		w := serveCompressed(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusNotModified)
+		}, "gzip, br")
+
+		Convey("Then the status is written without body", func() {
+			So(w.Code, ShouldEqual, http.StatusNotModified)
+			So(w.Header().Get("Content-Encoding"), ShouldBeEmpty)
+			So(w.Body.Len(), ShouldEqual, 0)
+		})
+	})
+}
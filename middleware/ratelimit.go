@@ -0,0 +1,236 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"expvar"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dpauth "github.com/ONSdigital/dp-authorisation/auth"
+	"github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/dp-image-api/metrics"
+	dpreq "github.com/ONSdigital/dp-net/v3/request"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/gorilla/mux"
+)
+
+// sweepInterval is the minimum time between removals of the buckets of clients that have stopped sending requests
+const sweepInterval = time.Minute
+
+// bucketKey identifies the token bucket of a client on a route
+type bucketKey struct {
+	client string
+	route  string
+}
+
+// bucket is a token bucket, holding the number of requests that a client can send at the time it was last updated
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// AuthHandler adds authorisation to handlers, only calling them once the token of the request has been verified
+type AuthHandler interface {
+	Require(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc
+}
+
+// RateLimiter limits the rate of the requests of each client on each route, with a token bucket per client and route.
+// Each bucket holds up to burst tokens and is refilled at rate tokens per second. Each request takes a token,
+// and requests are rejected with 429 while their bucket is empty. At most maxClients buckets are kept,
+// the least recently used bucket being removed to make room for a new client.
+type RateLimiter struct {
+	rate       float64
+	burst      float64
+	maxClients int
+	trustProxy bool
+	router     *mux.Router
+	now        func() time.Time
+	mutex      sync.Mutex
+	buckets    map[bucketKey]*bucket
+	lastSweep  time.Time
+}
+
+// NewRateLimiter creates a rate limiter allowing rate requests per second to each client on each route of the provided router,
+// with bursts of up to burst requests, and tracking up to maxClients client and route pairs. If trustProxy is true,
+// clients are identified by the address that the proxy in front of the service added to X-Forwarded-For,
+// rather than by the address of the connection. Requests are not limited if rate is 0.
+func NewRateLimiter(rate float64, burst, maxClients int, trustProxy bool, router *mux.Router) *RateLimiter {
+	metrics.RequestLimits.Set(metrics.LimitsRate, newFloat(rate))
+	metrics.RequestLimits.Set(metrics.LimitsBurst, newInt(int64(burst)))
+	metrics.RequestLimits.Set(metrics.LimitsMaxClients, newInt(int64(maxClients)))
+	return &RateLimiter{
+		rate:       rate,
+		burst:      float64(burst),
+		maxClients: maxClients,
+		trustProxy: trustProxy,
+		router:     router,
+		now:        time.Now,
+		buckets:    map[bucketKey]*bucket{},
+	}
+}
+
+// Middleware returns a handler that rejects the requests of client addresses that exceeded the rate limit of the route,
+// with a Retry-After header giving the number of seconds until their next request is allowed.
+// Requests have not been authorised yet, so their tokens are not trusted to identify clients.
+func (l *RateLimiter) Middleware(h http.Handler) http.Handler {
+	if l.rate <= 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		l.serve(w, req, l.clientAddress(req), h.ServeHTTP)
+	})
+}
+
+// Authorised returns an auth handler that also limits the rate of the requests of each service or user on each route,
+// once their token has been verified by the provided auth handler
+func (l *RateLimiter) Authorised(auth AuthHandler) AuthHandler {
+	if l.rate <= 0 {
+		return auth
+	}
+	return &authorisedRateLimiter{limiter: l, auth: auth}
+}
+
+// authorisedRateLimiter is an auth handler limiting the rate of the requests of verified services and users
+type authorisedRateLimiter struct {
+	limiter *RateLimiter
+	auth    AuthHandler
+}
+
+// Require returns a handler requiring the provided permissions, which rejects the requests of services and users
+// that exceeded the rate limit of the route. The handler provided to the auth handler is only called once the token is verified.
+func (a *authorisedRateLimiter) Require(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+	return a.auth.Require(required, func(w http.ResponseWriter, req *http.Request) {
+		a.limiter.serve(w, req, tokenKey(req), handler)
+	})
+}
+
+// serve calls the provided handler if the client is allowed another request on the route of the request,
+// or rejects the request with 429 and a Retry-After header otherwise
+func (l *RateLimiter) serve(w http.ResponseWriter, req *http.Request, client string, handler http.HandlerFunc) {
+	key := bucketKey{client: client, route: l.route(req)}
+	if allowed, retryAfter := l.allow(key); !allowed {
+		metrics.RequestLimits.Add(metrics.LimitsRateLimited, 1)
+		log.Warn(req.Context(), "request rate limit exceeded", log.Data{"route": key.route, "method": req.Method, "retry_after": retryAfter.String()})
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		http.Error(w, apierrors.ErrTooManyRequests.Error(), http.StatusTooManyRequests)
+		return
+	}
+	handler(w, req)
+}
+
+// allow takes a token from the provided bucket, and returns whether the request is allowed.
+// If it is not, the time until the bucket holds a token again is returned.
+func (l *RateLimiter) allow(key bucketKey) (allowed bool, retryAfter time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= l.maxClients {
+			l.makeRoom(now)
+		}
+		b = &bucket{tokens: l.burst, updated: now}
+		l.buckets[key] = b
+		metrics.RequestLimits.Add(metrics.LimitsClients, 1)
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep removes the buckets that have been refilled since they were last used, as they are the same as new buckets
+func (l *RateLimiter) sweep(now time.Time) {
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*l.rate >= l.burst {
+			l.remove(key)
+		}
+	}
+}
+
+// makeRoom removes the refilled buckets, or the least recently used bucket if none has been refilled,
+// so that a bucket can be added without tracking more than maxClients buckets
+func (l *RateLimiter) makeRoom(now time.Time) {
+	l.sweep(now)
+	if len(l.buckets) < l.maxClients {
+		return
+	}
+	var oldest bucketKey
+	var oldestUpdate time.Time
+	for key, b := range l.buckets {
+		if oldestUpdate.IsZero() || b.updated.Before(oldestUpdate) {
+			oldest, oldestUpdate = key, b.updated
+		}
+	}
+	l.remove(oldest)
+}
+
+// remove removes the provided bucket
+func (l *RateLimiter) remove(key bucketKey) {
+	delete(l.buckets, key)
+	metrics.RequestLimits.Add(metrics.LimitsClients, -1)
+}
+
+// route returns the method and path template of the route matching the request, so that all the images share the limit of a route
+func (l *RateLimiter) route(req *http.Request) string {
+	var match mux.RouteMatch
+	if l.router.Match(req, &match) && match.Route != nil {
+		if template, err := match.Route.GetPathTemplate(); err == nil {
+			return req.Method + " " + template
+		}
+	}
+	return req.Method + " (unmatched)"
+}
+
+// clientAddress returns the IP address of the client sending the request. Behind a trusted proxy, this is the last
+// X-Forwarded-For address, which the proxy added, as the previous ones are provided by the client and cannot be trusted.
+// Otherwise, it is the address of the connection.
+func (l *RateLimiter) clientAddress(req *http.Request) string {
+	if l.trustProxy {
+		if forwarded := req.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			addresses := strings.Split(forwarded[len(forwarded)-1], ",")
+			if address := strings.TrimSpace(addresses[len(addresses)-1]); address != "" {
+				return "ip:" + address
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// tokenKey returns the identity of the verified service or user sending the request: a hash of its token,
+// so that the token itself is not kept
+func tokenKey(req *http.Request) string {
+	token := req.Header.Get(dpreq.AuthHeaderKey)
+	if token == "" {
+		token = req.Header.Get(dpreq.FlorenceHeaderKey)
+	}
+	hash := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(hash[:8])
+}
+
+// newFloat returns an expvar float with the provided value
+func newFloat(value float64) *expvar.Float {
+	v := new(expvar.Float)
+	v.Set(value)
+	return v
+}
@@ -0,0 +1,206 @@
+package middleware
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	dpauth "github.com/ONSdigital/dp-authorisation/auth"
+	"github.com/ONSdigital/dp-image-api/metrics"
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// getLimitMetric returns the current value of the provided request limit metric
+func getLimitMetric(key string) float64 {
+	switch v := metrics.RequestLimits.Get(key).(type) {
+	case *expvar.Int:
+		return float64(v.Value())
+	case *expvar.Float:
+		return v.Value()
+	}
+	return 0
+}
+
+// newTestRouter returns a router with a route for images, and another for the download variants of images
+func newTestRouter() *mux.Router {
+	ok := func(w http.ResponseWriter, req *http.Request) {}
+	r := mux.NewRouter()
+	r.HandleFunc("/images/{id}", ok).Methods(http.MethodGet)
+	r.HandleFunc("/images/{id}/downloads", ok).Methods(http.MethodGet)
+	return r
+}
+
+// serveGet serves a GET request for the provided path, from the provided remote address with the provided headers
+func serveGet(h http.Handler, path, remoteAddr string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "http://localhost:24700"+path, http.NoBody)
+	r.RemoteAddr = remoteAddr
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// testAuthHandler is an auth handler which only verifies the token "valid-token"
+type testAuthHandler struct{}
+
+func (testAuthHandler) Require(required dpauth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer valid-token" && req.Header.Get("X-Florence-Token") != "valid-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler(w, req)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	Convey("Given a rate limiter allowing 2 requests per second, with bursts of 3 requests", t, func() {
+		router := newTestRouter()
+		limiter := NewRateLimiter(2, 3, 100, false, router)
+		now := time.Date(2020, time.April, 26, 8, 5, 52, 0, time.UTC)
+		limiter.now = func() time.Time { return now }
+		h := limiter.Middleware(router)
+		limited := getLimitMetric(metrics.LimitsRateLimited)
+
+		Convey("Then the limits are exposed in metrics", func() {
+			So(getLimitMetric(metrics.LimitsRate), ShouldEqual, 2)
+			So(getLimitMetric(metrics.LimitsBurst), ShouldEqual, 3)
+			So(getLimitMetric(metrics.LimitsMaxClients), ShouldEqual, 100)
+		})
+
+		Convey("Then a client can send a burst of 3 requests, after which it is rejected with 429 and a Retry-After header", func() {
+			for i := 0; i < 3; i++ {
+				So(serveGet(h, "/images/1", "10.0.0.1:1234", nil).Code, ShouldEqual, http.StatusOK)
+			}
+			w := serveGet(h, "/images/1", "10.0.0.1:1234", nil)
+			So(w.Code, ShouldEqual, http.StatusTooManyRequests)
+			So(w.Header().Get("Retry-After"), ShouldEqual, "1")
+			So(w.Body.String(), ShouldEqual, "too many requests\n")
+			So(getLimitMetric(metrics.LimitsRateLimited), ShouldEqual, limited+1)
+
+			Convey("And a new request is allowed once the bucket has been refilled", func() {
+				now = now.Add(500 * time.Millisecond)
+				So(serveGet(h, "/images/1", "10.0.0.1:1234", nil).Code, ShouldEqual, http.StatusOK)
+				So(serveGet(h, "/images/1", "10.0.0.1:1234", nil).Code, ShouldEqual, http.StatusTooManyRequests)
+			})
+
+			Convey("And the limit is shared by every image of the route, but not with other routes", func() {
+				So(serveGet(h, "/images/2", "10.0.0.1:1234", nil).Code, ShouldEqual, http.StatusTooManyRequests)
+				So(serveGet(h, "/images/1/downloads", "10.0.0.1:1234", nil).Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("And other clients are not limited", func() {
+				So(serveGet(h, "/images/1", "10.0.0.2:1234", nil).Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("And the client cannot bypass the limit by rotating its tokens or X-Forwarded-For addresses", func() {
+				for _, headers := range []map[string]string{
+					{"Authorization": "Bearer token-1"},
+					{"Authorization": "Bearer token-2"},
+					{"X-Florence-Token": "token-3"},
+					{"X-Forwarded-For": "192.168.0.1"},
+					{"X-Forwarded-For": "192.168.0.2, 10.0.0.2"},
+				} {
+					So(serveGet(h, "/images/1", "10.0.0.1:1234", headers).Code, ShouldEqual, http.StatusTooManyRequests)
+				}
+			})
+		})
+
+		Convey("Then the buckets of clients that stopped sending requests are removed once they have been refilled", func() {
+			clients := getLimitMetric(metrics.LimitsClients)
+			serveGet(h, "/images/1", "10.0.0.1:1234", nil)
+			serveGet(h, "/images/1", "10.0.0.2:1234", nil)
+			So(limiter.buckets, ShouldHaveLength, 2)
+			So(getLimitMetric(metrics.LimitsClients), ShouldEqual, clients+2)
+
+			now = now.Add(sweepInterval)
+			serveGet(h, "/images/1", "10.0.0.3:1234", nil)
+			So(limiter.buckets, ShouldHaveLength, 1)
+			So(getLimitMetric(metrics.LimitsClients), ShouldEqual, clients+1)
+		})
+	})
+
+	Convey("Given a rate limiter tracking up to 2 clients, allowing 1 request per second", t, func() {
+		router := newTestRouter()
+		limiter := NewRateLimiter(1, 1, 2, false, router)
+		now := time.Date(2020, time.April, 26, 8, 5, 52, 0, time.UTC)
+		limiter.now = func() time.Time { return now }
+		h := limiter.Middleware(router)
+		clients := getLimitMetric(metrics.LimitsClients)
+
+		Convey("When 3 clients send requests", func() {
+			So(serveGet(h, "/images/1", "10.0.0.1:1234", nil).Code, ShouldEqual, http.StatusOK)
+			now = now.Add(100 * time.Millisecond)
+			So(serveGet(h, "/images/1", "10.0.0.2:1234", nil).Code, ShouldEqual, http.StatusOK)
+			now = now.Add(100 * time.Millisecond)
+			So(serveGet(h, "/images/1", "10.0.0.3:1234", nil).Code, ShouldEqual, http.StatusOK)
+
+			Convey("Then the least recently used bucket is removed, so that no more than 2 buckets are kept", func() {
+				So(limiter.buckets, ShouldHaveLength, 2)
+				So(limiter.buckets, ShouldContainKey, bucketKey{client: "ip:10.0.0.2", route: "GET /images/{id}"})
+				So(limiter.buckets, ShouldContainKey, bucketKey{client: "ip:10.0.0.3", route: "GET /images/{id}"})
+				So(getLimitMetric(metrics.LimitsClients), ShouldEqual, clients+2)
+				So(serveGet(h, "/images/1", "10.0.0.2:1234", nil).Code, ShouldEqual, http.StatusTooManyRequests)
+			})
+		})
+	})
+
+	Convey("Given a rate limiter behind a trusted proxy, allowing bursts of 1 request", t, func() {
+		router := newTestRouter()
+		limiter := NewRateLimiter(1, 1, 100, true, router)
+		now := time.Date(2020, time.April, 26, 8, 5, 52, 0, time.UTC)
+		limiter.now = func() time.Time { return now }
+		h := limiter.Middleware(router)
+
+		Convey("Then clients are identified by the last X-Forwarded-For address, which was added by the proxy", func() {
+			So(serveGet(h, "/images/1", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "192.168.0.1, 172.16.0.1"}).Code, ShouldEqual, http.StatusOK)
+			So(serveGet(h, "/images/1", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "192.168.0.2, 172.16.0.1"}).Code, ShouldEqual, http.StatusTooManyRequests)
+			So(serveGet(h, "/images/1", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "172.16.0.2"}).Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("Then clients are identified by the address of the connection without X-Forwarded-For", func() {
+			So(serveGet(h, "/images/1", "10.0.0.1:1234", nil).Code, ShouldEqual, http.StatusOK)
+			So(serveGet(h, "/images/1", "10.0.0.1:1234", nil).Code, ShouldEqual, http.StatusTooManyRequests)
+		})
+	})
+
+	Convey("Given an auth handler rate limited by a limiter allowing bursts of 2 requests", t, func() {
+		router := mux.NewRouter()
+		limiter := NewRateLimiter(1, 2, 100, false, router)
+		now := time.Date(2020, time.April, 26, 8, 5, 52, 0, time.UTC)
+		limiter.now = func() time.Time { return now }
+		auth := limiter.Authorised(testAuthHandler{})
+		router.HandleFunc("/images/{id}", auth.Require(dpauth.Permissions{Read: true}, func(w http.ResponseWriter, req *http.Request) {})).Methods(http.MethodGet)
+
+		Convey("Then a verified service is limited by its token, whatever its address", func() {
+			service := map[string]string{"Authorization": "Bearer valid-token"}
+			So(serveGet(router, "/images/1", "10.0.0.1:1234", service).Code, ShouldEqual, http.StatusOK)
+			So(serveGet(router, "/images/1", "10.0.0.2:1234", service).Code, ShouldEqual, http.StatusOK)
+			So(serveGet(router, "/images/1", "10.0.0.3:1234", service).Code, ShouldEqual, http.StatusTooManyRequests)
+			So(limiter.buckets, ShouldHaveLength, 1)
+		})
+
+		Convey("Then requests with tokens that could not be verified are rejected without tracking their tokens", func() {
+			for i := 0; i < 5; i++ {
+				So(serveGet(router, "/images/1", "10.0.0.1:1234", map[string]string{"Authorization": "Bearer invalid-token"}).Code, ShouldEqual, http.StatusUnauthorized)
+			}
+			So(limiter.buckets, ShouldBeEmpty)
+		})
+	})
+
+	Convey("Given a rate limiter with a rate of 0", t, func() {
+		router := newTestRouter()
+		h := NewRateLimiter(0, 0, 0, false, router).Middleware(router)
+
+		Convey("Then requests are not limited", func() {
+			for i := 0; i < 10; i++ {
+				So(serveGet(h, "/images/1", "10.0.0.1:1234", nil).Code, ShouldEqual, http.StatusOK)
+			}
+		})
+	})
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event types of the kafka events that can be stored as dead letters
+const (
+	DeadLetterImageUploaded  = "image-uploaded"
+	DeadLetterImagePublished = "image-published"
+	DeadLetterImageWithdrawn = "image-withdrawn"
+)
+
+// DeadLetters represents an array of dead letters and json representation for API
+type DeadLetters struct {
+	Count      int          `bson:"count,omitempty"        json:"count"`
+	Offset     int          `bson:"offset_index,omitempty" json:"offset_index"`
+	Limit      int          `bson:"limit,omitempty"        json:"limit"`
+	Items      []DeadLetter `bson:"items,omitempty"        json:"items"`
+	TotalCount int          `bson:"total_count,omitempty"  json:"total_count"`
+}
+
+// DeadLetter represents a kafka event that could not be sent, as it is stored in mongoDB and json representation for API.
+// The payload is the json representation of the event, so that it can be replayed with any event encoding,
+// and the event ID is the occurrence ID of the event, so that it is replayed with the same ID.
+type DeadLetter struct {
+	ID          string          `bson:"_id"                    json:"id"`
+	EventID     string          `bson:"event_id,omitempty"     json:"event_id,omitempty"`
+	EventType   string          `bson:"event_type"             json:"event_type"`
+	ImageID     string          `bson:"image_id"               json:"image_id"`
+	Payload     json.RawMessage `bson:"payload"                json:"payload"`
+	Error       string          `bson:"error"                  json:"error"`
+	Attempts    int             `bson:"attempts"               json:"attempts"`
+	CreatedAt   *time.Time      `bson:"created_at"             json:"created_at"`
+	LastAttempt *time.Time      `bson:"last_attempt,omitempty" json:"last_attempt,omitempty"`
+	NextAttempt *time.Time      `bson:"next_attempt,omitempty" json:"next_attempt,omitempty"`
+}
+
+// NewDeadLetter creates a dead letter for the provided event occurrence, which failed to be sent with the provided error.
+// The failed send counts as the first attempt, and the next attempt is scheduled after minBackoff.
+func NewDeadLetter(id, eventID, eventType, imageID string, e interface{}, sendErr error, now time.Time, minBackoff time.Duration) (*DeadLetter, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	d := &DeadLetter{
+		ID:        id,
+		EventID:   eventID,
+		EventType: eventType,
+		ImageID:   imageID,
+		Payload:   payload,
+		CreatedAt: &now,
+	}
+	d.RecordFailedAttempt(sendErr, now, minBackoff, minBackoff)
+	return d, nil
+}
+
+// RecordFailedAttempt increments the number of attempts of the dead letter, storing the provided error,
+// and schedules the next attempt with an exponential backoff that starts at minBackoff and is capped at maxBackoff.
+func (d *DeadLetter) RecordFailedAttempt(sendErr error, now time.Time, minBackoff, maxBackoff time.Duration) {
+	d.Attempts++
+	d.Error = sendErr.Error()
+	d.LastAttempt = &now
+
+	backoff := minBackoff
+	for i := 1; i < d.Attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	next := now.Add(backoff)
+	d.NextAttempt = &next
+}
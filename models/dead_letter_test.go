@@ -0,0 +1,61 @@
+package models_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-image-api/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var (
+	testDeadLetterTime = time.Date(2020, time.April, 26, 10, 0, 0, 0, time.UTC)
+	errTestSend        = errors.New("send error")
+)
+
+func TestNewDeadLetter(t *testing.T) {
+	Convey("Given an event that failed to be sent", t, func() {
+		e := map[string]string{"image_id": "123"}
+
+		Convey("Then NewDeadLetter creates a dead letter with the event payload, the error, one attempt and the next attempt after the minimum backoff", func() {
+			d, err := models.NewDeadLetter("dl1", "event1", models.DeadLetterImageUploaded, "123", e, errTestSend, testDeadLetterTime, 10*time.Second)
+			So(err, ShouldBeNil)
+			So(d.ID, ShouldEqual, "dl1")
+			So(d.EventID, ShouldEqual, "event1")
+			So(d.EventType, ShouldEqual, models.DeadLetterImageUploaded)
+			So(d.ImageID, ShouldEqual, "123")
+			So(string(d.Payload), ShouldEqual, `{"image_id":"123"}`)
+			So(d.Error, ShouldEqual, "send error")
+			So(d.Attempts, ShouldEqual, 1)
+			So(*d.CreatedAt, ShouldEqual, testDeadLetterTime)
+			So(*d.LastAttempt, ShouldEqual, testDeadLetterTime)
+			So(*d.NextAttempt, ShouldEqual, testDeadLetterTime.Add(10*time.Second))
+		})
+	})
+
+	Convey("Given an event that cannot be represented as json", t, func() {
+		Convey("Then NewDeadLetter fails", func() {
+			_, err := models.NewDeadLetter("dl1", "event1", models.DeadLetterImageUploaded, "123", make(chan int), errTestSend, testDeadLetterTime, time.Second)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestDeadLetterRecordFailedAttempt(t *testing.T) {
+	Convey("Given a dead letter that failed to be sent once", t, func() {
+		d := &models.DeadLetter{Attempts: 1}
+
+		Convey("Then each further failed attempt doubles the backoff until it is capped by the maximum backoff", func() {
+			expected := []time.Duration{20 * time.Second, 40 * time.Second, 60 * time.Second, 60 * time.Second}
+			for i, backoff := range expected {
+				now := testDeadLetterTime.Add(time.Duration(i) * time.Hour)
+				d.RecordFailedAttempt(errTestSend, now, 10*time.Second, time.Minute)
+				So(d.Attempts, ShouldEqual, i+2)
+				So(*d.LastAttempt, ShouldEqual, now)
+				So(*d.NextAttempt, ShouldEqual, now.Add(backoff))
+				So(d.Error, ShouldEqual, "send error")
+			}
+		})
+	})
+}
@@ -13,9 +13,10 @@ const (
 	StateDownloadPublished
 	StateDownloadCompleted
 	StateDownloadFailed
+	StateDownloadWithdrawn
 )
 
-var downloadStateValues = []string{"pending", "importing", "imported", "published", "completed", "failed"}
+var downloadStateValues = []string{"pending", "importing", "imported", "published", "completed", "failed", "withdrawn"}
 
 // String returns the string representation of a downlad state
 func (ds DownloadState) String() string {
@@ -58,15 +59,22 @@ func (ds DownloadState) TransitionAllowed(target DownloadState) bool {
 		}
 	case StateDownloadPublished:
 		switch target {
-		case StateDownloadCompleted:
+		case StateDownloadCompleted, StateDownloadWithdrawn:
 			return true
 		default:
 			return false
 		}
 	case StateDownloadCompleted:
-		return false
+		switch target {
+		case StateDownloadWithdrawn:
+			return true
+		default:
+			return false
+		}
 	case StateDownloadFailed:
 		return false
+	case StateDownloadWithdrawn:
+		return false
 	default:
 		return false
 	}
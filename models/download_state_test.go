@@ -15,6 +15,7 @@ func TestDownloadStateValidation(t *testing.T) {
 		So(models.StateDownloadPending.TransitionAllowed(models.StateDownloadPublished), ShouldBeFalse)
 		So(models.StateDownloadPending.TransitionAllowed(models.StateDownloadCompleted), ShouldBeFalse)
 		So(models.StateDownloadPending.TransitionAllowed(models.StateDownloadFailed), ShouldBeFalse)
+		So(models.StateDownloadPending.TransitionAllowed(models.StateDownloadWithdrawn), ShouldBeFalse)
 	})
 
 	Convey("Given a Importing download state, then only transitions to importing, imported and failed are allowed", t, func() {
@@ -24,6 +25,7 @@ func TestDownloadStateValidation(t *testing.T) {
 		So(models.StateDownloadImporting.TransitionAllowed(models.StateDownloadPublished), ShouldBeFalse)
 		So(models.StateDownloadImporting.TransitionAllowed(models.StateDownloadCompleted), ShouldBeFalse)
 		So(models.StateDownloadImporting.TransitionAllowed(models.StateDownloadFailed), ShouldBeTrue)
+		So(models.StateDownloadImporting.TransitionAllowed(models.StateDownloadWithdrawn), ShouldBeFalse)
 	})
 
 	Convey("Given a Imported download state, then only transitions to imported and published are allowed", t, func() {
@@ -33,24 +35,27 @@ func TestDownloadStateValidation(t *testing.T) {
 		So(models.StateDownloadImported.TransitionAllowed(models.StateDownloadPublished), ShouldBeTrue)
 		So(models.StateDownloadImported.TransitionAllowed(models.StateDownloadCompleted), ShouldBeFalse)
 		So(models.StateDownloadImported.TransitionAllowed(models.StateDownloadFailed), ShouldBeFalse)
+		So(models.StateDownloadImported.TransitionAllowed(models.StateDownloadWithdrawn), ShouldBeFalse)
 	})
 
-	Convey("Given a Published download state, then only transitions to published and completed are allowed", t, func() {
+	Convey("Given a Published download state, then only transitions to completed and withdrawn are allowed", t, func() {
 		So(models.StateDownloadPublished.TransitionAllowed(models.StateDownloadPending), ShouldBeFalse)
 		So(models.StateDownloadPublished.TransitionAllowed(models.StateDownloadImporting), ShouldBeFalse)
 		So(models.StateDownloadPublished.TransitionAllowed(models.StateDownloadImported), ShouldBeFalse)
 		So(models.StateDownloadPublished.TransitionAllowed(models.StateDownloadPublished), ShouldBeFalse)
 		So(models.StateDownloadPublished.TransitionAllowed(models.StateDownloadCompleted), ShouldBeTrue)
 		So(models.StateDownloadPublished.TransitionAllowed(models.StateDownloadFailed), ShouldBeFalse)
+		So(models.StateDownloadPublished.TransitionAllowed(models.StateDownloadWithdrawn), ShouldBeTrue)
 	})
 
-	Convey("Given a Completed download state, then only transitions to completed are allowed", t, func() {
+	Convey("Given a Completed download state, then only transitions to withdrawn are allowed", t, func() {
 		So(models.StateDownloadCompleted.TransitionAllowed(models.StateDownloadPending), ShouldBeFalse)
 		So(models.StateDownloadCompleted.TransitionAllowed(models.StateDownloadImporting), ShouldBeFalse)
 		So(models.StateDownloadCompleted.TransitionAllowed(models.StateDownloadImported), ShouldBeFalse)
 		So(models.StateDownloadCompleted.TransitionAllowed(models.StateDownloadPublished), ShouldBeFalse)
 		So(models.StateDownloadCompleted.TransitionAllowed(models.StateDownloadCompleted), ShouldBeFalse)
 		So(models.StateDownloadCompleted.TransitionAllowed(models.StateDownloadFailed), ShouldBeFalse)
+		So(models.StateDownloadCompleted.TransitionAllowed(models.StateDownloadWithdrawn), ShouldBeTrue)
 	})
 
 	Convey("Given a Failed download state, then only transitions to failed are allowed", t, func() {
@@ -60,5 +65,15 @@ func TestDownloadStateValidation(t *testing.T) {
 		So(models.StateDownloadFailed.TransitionAllowed(models.StateDownloadPublished), ShouldBeFalse)
 		So(models.StateDownloadFailed.TransitionAllowed(models.StateDownloadCompleted), ShouldBeFalse)
 		So(models.StateDownloadFailed.TransitionAllowed(models.StateDownloadFailed), ShouldBeFalse)
+		So(models.StateDownloadFailed.TransitionAllowed(models.StateDownloadWithdrawn), ShouldBeFalse)
+	})
+	Convey("Given a Withdrawn download state, then no transitions are allowed", t, func() {
+		So(models.StateDownloadWithdrawn.TransitionAllowed(models.StateDownloadPending), ShouldBeFalse)
+		So(models.StateDownloadWithdrawn.TransitionAllowed(models.StateDownloadImporting), ShouldBeFalse)
+		So(models.StateDownloadWithdrawn.TransitionAllowed(models.StateDownloadImported), ShouldBeFalse)
+		So(models.StateDownloadWithdrawn.TransitionAllowed(models.StateDownloadPublished), ShouldBeFalse)
+		So(models.StateDownloadWithdrawn.TransitionAllowed(models.StateDownloadCompleted), ShouldBeFalse)
+		So(models.StateDownloadWithdrawn.TransitionAllowed(models.StateDownloadFailed), ShouldBeFalse)
+		So(models.StateDownloadWithdrawn.TransitionAllowed(models.StateDownloadWithdrawn), ShouldBeFalse)
 	})
 }
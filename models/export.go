@@ -0,0 +1,23 @@
+package models
+
+// ExportErrorLine represents a line of an image export reporting an image record that could not be exported,
+// written in place of the record so that exports keep streaming after a failure
+type ExportErrorLine struct {
+	ExportError *RecordError `json:"export_error"`
+}
+
+// ImportResult represents the outcome of an image import, with the error of every record that could not be imported
+type ImportResult struct {
+	Count    int           `json:"count"`
+	Imported int           `json:"imported"`
+	Failed   int           `json:"failed"`
+	Errors   []RecordError `json:"errors"`
+}
+
+// RecordError represents an error processing a single image record of an export or an import.
+// Line is the 1-based line number of the record in the newline delimited json stream.
+type RecordError struct {
+	Line    int    `json:"line"`
+	ImageID string `json:"image_id,omitempty"`
+	Error   string `json:"error"`
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"encoding/hex"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/ONSdigital/dp-image-api/apierrors"
@@ -9,6 +12,9 @@ import (
 // MaxFilenameLen is the maximum number of characters allowed for Image filenames
 const MaxFilenameLen = 500
 
+// Sha256Len is the number of hex characters of a SHA-256 checksum
+const Sha256Len = 64
+
 // Images represents an array of images model as it is stored in mongoDB and json representation for API
 type Images struct {
 	Count      int     `bson:"count,omitempty"        json:"count"`
@@ -20,16 +26,84 @@ type Images struct {
 
 // Image represents an image metadata model as it is stored in mongoDB and json representation for API
 type Image struct {
-	ID           string              `bson:"_id,omitempty"           json:"id,omitempty"`
+	ID               string              `bson:"_id,omitempty"               json:"id,omitempty"`
+	CollectionID     string              `bson:"collection_id,omitempty"     json:"collection_id,omitempty"`
+	State            string              `bson:"state,omitempty"             json:"state,omitempty"`
+	Error            string              `bson:"error,omitempty"             json:"error,omitempty"`
+	Filename         string              `bson:"filename,omitempty"          json:"filename,omitempty"`
+	License          *License            `bson:"license,omitempty"           json:"license,omitempty"`
+	Links            *ImageLinks         `bson:"links,omitempty"             json:"links,omitempty"`
+	Upload           *Upload             `bson:"upload,omitempty"            json:"upload,omitempty"`
+	Type             string              `bson:"type,omitempty"              json:"type,omitempty"`
+	ScheduledPublish *ScheduledPublish   `bson:"scheduled_publish,omitempty" json:"scheduled_publish,omitempty"`
+	Withdrawal       *Withdrawal         `bson:"withdrawal,omitempty"        json:"withdrawal,omitempty"`
+	Revision         int                 `bson:"revision,omitempty"          json:"revision,omitempty"`
+	Downloads        map[string]Download `bson:"downloads,omitempty"         json:"-"`
+	Revisions        []Revision          `bson:"revisions,omitempty"         json:"-"`
+	LastUpdated      *time.Time          `bson:"last_updated,omitempty"      json:"-"`
+}
+
+// ImageRecord represents an image together with all its download variants and archived revisions, as it is shown, exported and imported by the admin tools
+type ImageRecord struct {
+	*Image
+	Downloads map[string]Download `json:"downloads,omitempty"`
+	Revisions []Revision          `json:"revisions,omitempty"`
+}
+
+// NewImageRecord returns the record of the provided image, exposing its download variants and archived revisions
+func NewImageRecord(image *Image) *ImageRecord {
+	return &ImageRecord{Image: image, Downloads: image.Downloads, Revisions: image.Revisions}
+}
+
+// ToImage returns the image of the record, including its download variants and archived revisions
+func (r *ImageRecord) ToImage() *Image {
+	image := &Image{}
+	if r.Image != nil {
+		*image = *r.Image
+	}
+	image.Downloads = r.Downloads
+	image.Revisions = r.Revisions
+	return image
+}
+
+// Revisions represents an array of image revisions model as it is stored in mongoDB and json representation for API
+type Revisions struct {
+	Count      int        `bson:"count,omitempty"        json:"count"`
+	Offset     int        `bson:"offset_index,omitempty" json:"offset_index"`
+	Limit      int        `bson:"limit,omitempty"        json:"limit"`
+	Items      []Revision `bson:"items,omitempty"        json:"items"`
+	TotalCount int        `bson:"total_count,omitempty"  json:"total_count"`
+}
+
+// Revision represents a previous revision of an image, archived when a new revision was started
+type Revision struct {
+	Revision     int                 `bson:"revision"                json:"revision"`
 	CollectionID string              `bson:"collection_id,omitempty" json:"collection_id,omitempty"`
 	State        string              `bson:"state,omitempty"         json:"state,omitempty"`
-	Error        string              `bson:"error,omitempty"         json:"error,omitempty"`
 	Filename     string              `bson:"filename,omitempty"      json:"filename,omitempty"`
-	License      *License            `bson:"license,omitempty"       json:"license,omitempty"`
-	Links        *ImageLinks         `bson:"links,omitempty"         json:"links,omitempty"`
 	Upload       *Upload             `bson:"upload,omitempty"        json:"upload,omitempty"`
+	Downloads    map[string]Download `bson:"downloads,omitempty"     json:"downloads,omitempty"`
+	ArchivedAt   *time.Time          `bson:"archived_at,omitempty"   json:"archived_at,omitempty"`
+}
+
+// Version represents an immutable published version of an image, recorded when the image publishing is completed
+type Version struct {
+	ID           string              `bson:"_id,omitempty"           json:"-"`
+	ImageID      string              `bson:"image_id,omitempty"      json:"image_id,omitempty"`
+	Version      int                 `bson:"version"                 json:"version"`
+	CollectionID string              `bson:"collection_id,omitempty" json:"collection_id,omitempty"`
+	State        string              `bson:"state,omitempty"         json:"state,omitempty"`
+	Filename     string              `bson:"filename,omitempty"      json:"filename,omitempty"`
+	License      *License            `bson:"license,omitempty"       json:"license,omitempty"`
 	Type         string              `bson:"type,omitempty"          json:"type,omitempty"`
-	Downloads    map[string]Download `bson:"downloads,omitempty"     json:"-"`
+	Links        *VersionLinks       `bson:"links,omitempty"         json:"links,omitempty"`
+	Downloads    map[string]Download `bson:"downloads,omitempty"     json:"downloads,omitempty"`
+	CreatedAt    *time.Time          `bson:"created_at,omitempty"    json:"created_at,omitempty"`
+}
+
+type VersionLinks struct {
+	Self  string `bson:"self,omitempty"       json:"self,omitempty"`
+	Image string `bson:"image,omitempty"      json:"image,omitempty"`
 }
 
 // License represents a license model
@@ -43,6 +117,25 @@ type ImageLinks struct {
 	Downloads string `bson:"downloads,omitempty"    json:"downloads,omitempty"`
 }
 
+// ScheduledPublish represents a publish of an image that will be triggered once the publish_at time is reached
+type ScheduledPublish struct {
+	PublishAt *time.Time `bson:"publish_at,omitempty"        json:"publish_at,omitempty"`
+}
+
+// Withdrawal represents the withdrawal of a previously published image
+type Withdrawal struct {
+	Reason      string     `bson:"reason,omitempty"       json:"reason,omitempty"`
+	WithdrawnAt *time.Time `bson:"withdrawn_at,omitempty" json:"withdrawn_at,omitempty"`
+}
+
+// Validate checks that a withdrawal provides a reason
+func (w *Withdrawal) Validate() error {
+	if strings.TrimSpace(w.Reason) == "" {
+		return apierrors.ErrImageWithdrawalNoReason
+	}
+	return nil
+}
+
 // Upload represents an upload model
 type Upload struct {
 	Path string `bson:"path,omitempty"              json:"path,omitempty"`
@@ -63,11 +156,13 @@ type Download struct {
 	Height           *int           `bson:"height,omitempty"             json:"height,omitempty"`
 	Href             string         `json:"href,omitempty"`
 	Palette          string         `bson:"palette,omitempty"            json:"palette,omitempty"`
-	Private          string         `bson:"private,omitempty"            json:"private,omitempty"`
+	Private          string         `bson:"private_bucket,omitempty"     json:"private,omitempty"`
 	Public           bool           `json:"public,omitempty"`
 	Size             *int           `bson:"size,omitempty"               json:"size,omitempty"`
 	Type             string         `bson:"type,omitempty"               json:"type,omitempty"`
 	Width            *int           `bson:"width,omitempty"              json:"width,omitempty"`
+	Sha256           string         `bson:"sha256,omitempty"             json:"sha256,omitempty"`
+	ContentType      string         `bson:"content_type,omitempty"       json:"content_type,omitempty"`
 	Links            *DownloadLinks `bson:"links,omitempty"              json:"links,omitempty"`
 	State            string         `bson:"state,omitempty"              json:"state,omitempty"`
 	Error            string         `bson:"error,omitempty"              json:"error,omitempty"`
@@ -82,6 +177,12 @@ type DownloadLinks struct {
 	Image string `bson:"image,omitempty"      json:"image,omitempty"`
 }
 
+// PreviewURL represents a signed download service URL of an image download variant, which is valid until it expires
+type PreviewURL struct {
+	Href      string     `json:"href"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
 // Validate checks that an image struct complies with the filename and state constraints, if provided.
 func (i *Image) Validate() error {
 	if i.Filename != "" {
@@ -115,8 +216,13 @@ func validateUpload(upload *Upload) error {
 	return nil
 }
 
-// ValidateTransitionFrom checks that this image state can be validly transitioned from the existing state
+// ValidateTransitionFrom checks that this image state can be validly transitioned from the existing state.
+// Images cannot be withdrawn by an update, as a withdrawal requires a reason and sends image withdrawn events.
 func (i *Image) ValidateTransitionFrom(existing *Image) error {
+	if i.State == StateWithdrawn.String() {
+		return apierrors.ErrImageWithdrawalNotAllowed
+	}
+
 	// check that state transition is allowed, only if state is provided
 	if i.State != "" {
 		if !existing.StateTransitionAllowed(i.State) {
@@ -173,6 +279,145 @@ func (i *Image) UpdatedState() string {
 	return i.State
 }
 
+// CurrentRevision returns the revision number of the image, images created before revisions existed being revision 1
+func (i *Image) CurrentRevision() int {
+	if i.Revision == 0 {
+		return 1
+	}
+	return i.Revision
+}
+
+// NewRevisionAllowed returns true if a new revision can be started for the image, which is only the case for completed images
+func (i *Image) NewRevisionAllowed() bool {
+	return i.State == StateCompleted.String()
+}
+
+// ArchiveRevision returns a revision containing the current state, upload and downloads of the image
+func (i *Image) ArchiveRevision(archivedAt time.Time) *Revision {
+	return &Revision{
+		Revision:     i.CurrentRevision(),
+		CollectionID: i.CollectionID,
+		State:        i.State,
+		Filename:     i.Filename,
+		Upload:       i.Upload,
+		Downloads:    i.Downloads,
+		ArchivedAt:   &archivedAt,
+	}
+}
+
+// LiveRevision returns the image as it needs to be served to the public.
+// While a new revision of a previously published image is being uploaded or imported,
+// the latest archived revision keeps being served. Otherwise, the image itself is served.
+func (i *Image) LiveRevision() *Image {
+	if len(i.Revisions) == 0 {
+		return i
+	}
+	switch i.State {
+	case StateCreated.String(), StateUploaded.String(), StateImporting.String(), StateImported.String(), StateFailedImport.String():
+		latest := i.Revisions[len(i.Revisions)-1]
+		live := *i
+		live.Revision = latest.Revision
+		live.CollectionID = latest.CollectionID
+		live.State = latest.State
+		live.Filename = latest.Filename
+		live.Upload = latest.Upload
+		live.Downloads = latest.Downloads
+		live.ScheduledPublish = nil
+		return &live
+	default:
+		return i
+	}
+}
+
+// IsPublic returns true if the image can be served to the public, which is only the case once it is published
+func (i *Image) IsPublic() bool {
+	return i.State == StatePublished.String() || i.State == StateCompleted.String()
+}
+
+// PublicView returns a copy of the image as it is served to the public, without the fields that are only meant for publishing:
+// the upload and error of the image and its archived revisions, and the private bucket and error of their download variants
+func (i *Image) PublicView() *Image {
+	public := *i
+	public.Upload = nil
+	public.Error = ""
+	public.Downloads = publicDownloads(i.Downloads)
+	if i.Revisions != nil {
+		public.Revisions = make([]Revision, len(i.Revisions))
+		for r := range i.Revisions {
+			revision := i.Revisions[r]
+			revision.Upload = nil
+			revision.Downloads = publicDownloads(revision.Downloads)
+			public.Revisions[r] = revision
+		}
+	}
+	return &public
+}
+
+// PublicView returns a copy of the version as it is served to the public, without the private bucket and error of its download variants
+func (v *Version) PublicView() *Version {
+	public := *v
+	public.Downloads = publicDownloads(v.Downloads)
+	return &public
+}
+
+// PublicView returns a copy of the download variant as it is served to the public, without its private bucket and error
+func (d *Download) PublicView() *Download {
+	public := *d
+	public.Private = ""
+	public.Error = ""
+	return &public
+}
+
+// IsPublic returns true if the file of the download variant is published
+func (d *Download) IsPublic() bool {
+	return d.State == StateDownloadPublished.String() || d.State == StateDownloadCompleted.String()
+}
+
+// IsPreviewable returns true if the file of the download variant has been imported, so that the download service can serve it
+func (d *Download) IsPreviewable() bool {
+	switch d.State {
+	case StateDownloadImported.String(), StateDownloadPublished.String(), StateDownloadCompleted.String():
+		return true
+	default:
+		return false
+	}
+}
+
+// publicDownloads returns a copy of the provided download variants as they are served to the public
+func publicDownloads(downloads map[string]Download) map[string]Download {
+	if downloads == nil {
+		return nil
+	}
+	public := make(map[string]Download, len(downloads))
+	for variant := range downloads {
+		download := downloads[variant]
+		public[variant] = *download.PublicView()
+	}
+	return public
+}
+
+// NewVersion returns an immutable version of the image, numbered after its current revision.
+// The download links of the version need to be set by the caller.
+func (i *Image) NewVersion(createdAt time.Time) *Version {
+	version := i.CurrentRevision()
+	downloads := make(map[string]Download, len(i.Downloads))
+	for variant := range i.Downloads {
+		downloads[variant] = i.Downloads[variant]
+	}
+	return &Version{
+		ID:           fmt.Sprintf("%s-%d", i.ID, version),
+		ImageID:      i.ID,
+		Version:      version,
+		CollectionID: i.CollectionID,
+		State:        i.State,
+		Filename:     i.Filename,
+		License:      i.License,
+		Type:         i.Type,
+		Downloads:    downloads,
+		CreatedAt:    &createdAt,
+	}
+}
+
 // AllDownloadsOfState returns trueOfS if all download variants are in specified state,
 func (i *Image) AllDownloadsOfState(s DownloadState) bool {
 	if len(i.Downloads) == 0 {
@@ -196,13 +441,18 @@ func (i *Image) AnyDownloadsOfState(s DownloadState) bool {
 	return false
 }
 
-// Validate checks that an download struct complies with the state name constraint, if provided.
+// Validate checks that an download struct complies with the state name and checksum constraints, if provided.
 func (d *Download) Validate() error {
 	if d.State != "" {
 		if _, err := ParseDownloadState(d.State); err != nil {
 			return apierrors.ErrImageDownloadInvalidState
 		}
 	}
+	if d.Sha256 != "" {
+		if _, err := hex.DecodeString(d.Sha256); err != nil || len(d.Sha256) != Sha256Len {
+			return apierrors.ErrImageDownloadInvalidSha256
+		}
+	}
 	return nil
 }
 
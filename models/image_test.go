@@ -1,6 +1,7 @@
 package models_test
 
 import (
+	"encoding/json"
 	"strings"
 	"testing"
 	"time"
@@ -209,6 +210,20 @@ func TestImageValidateTransitionFrom(t *testing.T) {
 			So(err, ShouldResemble, apierrors.ErrImageAlreadyCompleted)
 		})
 	})
+
+	Convey("Given an existing image in a Published state", t, func() {
+		existing := &models.Image{
+			State: models.StatePublished.String(),
+		}
+
+		Convey("When we try to transition to a Withdrawn state, then it is not allowed, as it requires a withdrawal", func() {
+			image := &models.Image{
+				State: models.StateWithdrawn.String(),
+			}
+			err := image.ValidateTransitionFrom(existing)
+			So(err, ShouldResemble, apierrors.ErrImageWithdrawalNotAllowed)
+		})
+	})
 }
 
 func TestImageStateTransitionAllowed(t *testing.T) {
@@ -344,6 +359,188 @@ func validateTransitionsToCreated(image models.Image) {
 	})
 }
 
+func TestImageRevisions(t *testing.T) {
+	archivedAt := time.Date(2020, time.May, 1, 10, 0, 0, 0, time.UTC)
+
+	Convey("Given an image created before revisions existed, its current revision is 1", t, func() {
+		image := models.Image{}
+		So(image.CurrentRevision(), ShouldEqual, 1)
+	})
+
+	Convey("Given a completed image, a new revision is allowed and the current revision is archived as expected", t, func() {
+		image := models.Image{
+			State:        models.StateCompleted.String(),
+			CollectionID: "collection1",
+			Filename:     "image-name",
+			Revision:     3,
+			Upload:       &models.Upload{Path: "s3://images/image.png"},
+			Downloads: map[string]models.Download{
+				testVariantOriginal: {ID: testVariantOriginal, State: models.StateDownloadCompleted.String()},
+			},
+		}
+		So(image.NewRevisionAllowed(), ShouldBeTrue)
+		So(image.ArchiveRevision(archivedAt), ShouldResemble, &models.Revision{
+			Revision:     3,
+			CollectionID: "collection1",
+			State:        models.StateCompleted.String(),
+			Filename:     "image-name",
+			Upload:       &models.Upload{Path: "s3://images/image.png"},
+			Downloads:    image.Downloads,
+			ArchivedAt:   &archivedAt,
+		})
+	})
+
+	Convey("Given an image that is not completed, a new revision is not allowed", t, func() {
+		image := models.Image{State: models.StatePublished.String()}
+		So(image.NewRevisionAllowed(), ShouldBeFalse)
+	})
+
+	Convey("Given an image without previous revisions, the live revision is the image itself", t, func() {
+		image := &models.Image{State: models.StateImporting.String()}
+		So(image.LiveRevision(), ShouldEqual, image)
+	})
+
+	Convey("Given an image with a previous revision", t, func() {
+		previous := models.Revision{
+			Revision: 1,
+			State:    models.StateCompleted.String(),
+			Filename: "old-name",
+			Downloads: map[string]models.Download{
+				testVariantOriginal: {ID: testVariantOriginal, State: models.StateDownloadCompleted.String()},
+			},
+		}
+
+		Convey("When the new revision is being imported, the live revision is the previous revision", func() {
+			image := &models.Image{ID: "123", State: models.StateImporting.String(), Filename: "new-name", Revision: 2, Revisions: []models.Revision{previous}}
+			live := image.LiveRevision()
+			So(live.ID, ShouldEqual, "123")
+			So(live.Revision, ShouldEqual, 1)
+			So(live.State, ShouldEqual, models.StateCompleted.String())
+			So(live.Filename, ShouldEqual, "old-name")
+			So(live.Downloads, ShouldResemble, previous.Downloads)
+			So(image.State, ShouldEqual, models.StateImporting.String())
+		})
+
+		Convey("When the new revision has been published, the live revision is the image itself", func() {
+			image := &models.Image{ID: "123", State: models.StatePublished.String(), Revision: 2, Revisions: []models.Revision{previous}}
+			So(image.LiveRevision(), ShouldEqual, image)
+		})
+	})
+}
+
+func TestImageNewVersion(t *testing.T) {
+	createdAt := time.Date(2020, time.May, 1, 10, 0, 0, 0, time.UTC)
+
+	Convey("Given a completed image in its second revision, a version is created as expected", t, func() {
+		image := models.Image{
+			ID:           "123",
+			State:        models.StateCompleted.String(),
+			CollectionID: "collection1",
+			Filename:     "image-name",
+			Type:         "chart",
+			Revision:     2,
+			Downloads: map[string]models.Download{
+				testVariantOriginal: {ID: testVariantOriginal, State: models.StateDownloadCompleted.String()},
+			},
+		}
+		version := image.NewVersion(createdAt)
+		So(version, ShouldResemble, &models.Version{
+			ID:           "123-2",
+			ImageID:      "123",
+			Version:      2,
+			CollectionID: "collection1",
+			State:        models.StateCompleted.String(),
+			Filename:     "image-name",
+			Type:         "chart",
+			Downloads:    image.Downloads,
+			CreatedAt:    &createdAt,
+		})
+
+		Convey("And modifying the version downloads does not modify the image downloads", func() {
+			version.Downloads["other"] = models.Download{}
+			So(image.Downloads, ShouldHaveLength, 1)
+		})
+	})
+}
+
+func TestImageIsPublic(t *testing.T) {
+	Convey("Only published and completed images are public", t, func() {
+		for _, state := range []models.State{models.StatePublished, models.StateCompleted} {
+			So((&models.Image{State: state.String()}).IsPublic(), ShouldBeTrue)
+		}
+		for _, state := range []models.State{models.StateCreated, models.StateUploaded, models.StateImporting, models.StateImported,
+			models.StateDeleted, models.StateFailedImport, models.StateFailedPublish, models.StateWithdrawn} {
+			So((&models.Image{State: state.String()}).IsPublic(), ShouldBeFalse)
+		}
+		So((&models.Image{}).IsPublic(), ShouldBeFalse)
+	})
+}
+
+func TestImagePublicView(t *testing.T) {
+	Convey("Given a completed image with private fields, in its second revision", t, func() {
+		image := &models.Image{
+			ID:       "123",
+			State:    models.StateCompleted.String(),
+			Error:    "some error",
+			Filename: "image-name",
+			Upload:   &models.Upload{Path: "images/image-name.png"},
+			Revision: 2,
+			Downloads: map[string]models.Download{
+				testVariantOriginal: {ID: testVariantOriginal, State: models.StateDownloadCompleted.String(), Private: "private-bucket", Error: "some error", Href: "http://download/image.png"},
+			},
+			Revisions: []models.Revision{
+				{
+					Revision: 1,
+					State:    models.StateCompleted.String(),
+					Upload:   &models.Upload{Path: "images/image-name-1.png"},
+					Downloads: map[string]models.Download{
+						testVariantOriginal: {ID: testVariantOriginal, State: models.StateDownloadCompleted.String(), Private: "private-bucket"},
+					},
+				},
+			},
+		}
+
+		Convey("Then its public view has no upload, error or private bucket, in the image or its revisions", func() {
+			public := image.PublicView()
+			So(public, ShouldResemble, &models.Image{
+				ID:       "123",
+				State:    models.StateCompleted.String(),
+				Filename: "image-name",
+				Revision: 2,
+				Downloads: map[string]models.Download{
+					testVariantOriginal: {ID: testVariantOriginal, State: models.StateDownloadCompleted.String(), Href: "http://download/image.png"},
+				},
+				Revisions: []models.Revision{
+					{
+						Revision: 1,
+						State:    models.StateCompleted.String(),
+						Downloads: map[string]models.Download{
+							testVariantOriginal: {ID: testVariantOriginal, State: models.StateDownloadCompleted.String()},
+						},
+					},
+				},
+			})
+
+			Convey("And the image itself is not modified", func() {
+				So(image.Upload, ShouldNotBeNil)
+				So(image.Error, ShouldEqual, "some error")
+				So(image.Downloads[testVariantOriginal].Private, ShouldEqual, "private-bucket")
+				So(image.Revisions[0].Upload, ShouldNotBeNil)
+				So(image.Revisions[0].Downloads[testVariantOriginal].Private, ShouldEqual, "private-bucket")
+			})
+		})
+
+		Convey("Then the public view of its version has no private bucket", func() {
+			version := image.NewVersion(testImportCompleted)
+			public := version.PublicView()
+			So(public.Downloads[testVariantOriginal].Private, ShouldBeEmpty)
+			So(public.Downloads[testVariantOriginal].Error, ShouldBeEmpty)
+			So(public.Downloads[testVariantOriginal].Href, ShouldEqual, "http://download/image.png")
+			So(version.Downloads[testVariantOriginal].Private, ShouldEqual, "private-bucket")
+		})
+	})
+}
+
 func TestDownloadValidation(t *testing.T) {
 	Convey("Given an empty download variant, it is successfully validated", t, func() {
 		download := models.Download{}
@@ -366,6 +563,49 @@ func TestDownloadValidation(t *testing.T) {
 		err := download.Validate()
 		So(err, ShouldBeNil)
 	})
+
+	Convey("Given a download variant with a valid sha256 checksum, it is successfully validated", t, func() {
+		download := models.Download{
+			Sha256:      "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+			ContentType: "image/png",
+		}
+		err := download.Validate()
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Given a download variant with a sha256 checksum that is not hex encoded, it fails to validate with the expected error", t, func() {
+		download := models.Download{
+			Sha256: strings.Repeat("z", models.Sha256Len),
+		}
+		err := download.Validate()
+		So(err, ShouldResemble, apierrors.ErrImageDownloadInvalidSha256)
+	})
+
+	Convey("Given a download variant with a sha256 checksum of the wrong length, it fails to validate with the expected error", t, func() {
+		download := models.Download{
+			Sha256: "9f86d081",
+		}
+		err := download.Validate()
+		So(err, ShouldResemble, apierrors.ErrImageDownloadInvalidSha256)
+	})
+}
+
+func TestWithdrawalValidation(t *testing.T) {
+	Convey("Given a withdrawal with a reason, it is successfully validated", t, func() {
+		withdrawal := models.Withdrawal{
+			Reason: "published in error",
+		}
+		err := withdrawal.Validate()
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Given a withdrawal without a reason, it fails to validate with the expected error", t, func() {
+		withdrawal := models.Withdrawal{
+			Reason: "  ",
+		}
+		err := withdrawal.Validate()
+		So(err, ShouldResemble, apierrors.ErrImageWithdrawalNoReason)
+	})
 }
 
 func TestDownloadValidateTransitionFrom(t *testing.T) {
@@ -509,6 +749,30 @@ func TestDownloadValidateForImage(t *testing.T) {
 	})
 }
 
+func TestDownloadIsPublic(t *testing.T) {
+	Convey("Only published and completed download variants are public", t, func() {
+		for _, state := range []models.DownloadState{models.StateDownloadPublished, models.StateDownloadCompleted} {
+			So((&models.Download{State: state.String()}).IsPublic(), ShouldBeTrue)
+		}
+		for _, state := range []models.DownloadState{models.StateDownloadPending, models.StateDownloadImporting, models.StateDownloadImported, models.StateDownloadFailed, models.StateDownloadWithdrawn} {
+			So((&models.Download{State: state.String()}).IsPublic(), ShouldBeFalse)
+		}
+		So((&models.Download{}).IsPublic(), ShouldBeFalse)
+	})
+}
+
+func TestDownloadIsPreviewable(t *testing.T) {
+	Convey("Only imported, published and completed download variants can be previewed", t, func() {
+		for _, state := range []models.DownloadState{models.StateDownloadImported, models.StateDownloadPublished, models.StateDownloadCompleted} {
+			So((&models.Download{State: state.String()}).IsPreviewable(), ShouldBeTrue)
+		}
+		for _, state := range []models.DownloadState{models.StateDownloadPending, models.StateDownloadImporting, models.StateDownloadFailed, models.StateDownloadWithdrawn} {
+			So((&models.Download{State: state.String()}).IsPreviewable(), ShouldBeFalse)
+		}
+		So((&models.Download{}).IsPreviewable(), ShouldBeFalse)
+	})
+}
+
 func TestDownloadStateTransitionAllowed(t *testing.T) {
 	Convey("Given an image download variant in pending state", t, func() {
 		download := models.Download{
@@ -539,3 +803,31 @@ func validateDownloadTransitionsToPending(download models.Download) {
 		So(download.StateTransitionAllowed("wrong"), ShouldBeFalse)
 	})
 }
+
+func TestImageRecordJSON(t *testing.T) {
+	Convey("Given an image with a download variant", t, func() {
+		image := &models.Image{
+			ID:    "123",
+			State: models.StatePublished.String(),
+			Downloads: map[string]models.Download{
+				testVariantOriginal: {ID: testVariantOriginal, State: models.StateDownloadPublished.String()},
+			},
+		}
+
+		Convey("Then its record is marshalled to json including the download variants", func() {
+			b, err := json.Marshal(models.NewImageRecord(image))
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, `{"id":"123","state":"published","downloads":{"original":{"id":"original","state":"published"}}}`)
+		})
+
+		Convey("Then the image of its record includes the download variants", func() {
+			So(models.NewImageRecord(image).ToImage(), ShouldResemble, image)
+		})
+
+		Convey("Then the image itself is marshalled to json without the download variants", func() {
+			b, err := json.Marshal(image)
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, `{"id":"123","state":"published"}`)
+		})
+	})
+}
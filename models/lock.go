@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// ImageLocks represents an array of the image locks that are currently held, and json representation for API
+type ImageLocks struct {
+	Count int         `json:"count"`
+	Items []ImageLock `json:"items"`
+}
+
+// ImageLock represents an image lock that is currently held. The owner is the ID of the request holding the lock,
+// and the host is the service instance that acquired it. Locks expire at ExpiresAt if they are not released.
+type ImageLock struct {
+	ImageID   string     `json:"image_id"`
+	LockID    string     `json:"lock_id"`
+	Owner     string     `json:"owner,omitempty"`
+	Host      string     `json:"host,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	HeldFor   string     `json:"held_for"`
+}
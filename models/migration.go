@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// MigrationRecord represents a migration applied to the documents stored in mongoDB, as it is stored in the migrations collection
+// and json representation for the admin tools. Documents is the number of documents that were (or would be, for dry runs) changed.
+type MigrationRecord struct {
+	Version     int        `bson:"_id"                  json:"version"`
+	Description string     `bson:"description"          json:"description"`
+	Documents   int        `bson:"documents"            json:"documents"`
+	AppliedAt   *time.Time `bson:"applied_at,omitempty" json:"applied_at,omitempty"`
+}
+
+// MigrationResult represents the outcome of a migration run, with the migrations that were (or would be, for dry runs) applied, in order
+type MigrationResult struct {
+	DryRun  bool              `json:"dry_run"`
+	Applied []MigrationRecord `json:"applied"`
+}
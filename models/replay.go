@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/ONSdigital/dp-image-api/apierrors"
+)
+
+// Event types of the kafka events that can be replayed
+const (
+	ReplayImageUploaded  = "image-uploaded"
+	ReplayImagePublished = "image-published"
+)
+
+// ReplayRequest represents a request to re-emit the kafka events of the images that are stuck in 'uploaded' or 'published' state.
+// All the provided filters need to match for an image to be selected. An empty state selects images in any of the replayable states.
+type ReplayRequest struct {
+	CollectionID string     `json:"collection_id,omitempty"`
+	State        string     `json:"state,omitempty"`
+	From         *time.Time `json:"from,omitempty"`
+	To           *time.Time `json:"to,omitempty"`
+	ImageIDs     []string   `json:"image_ids,omitempty"`
+	DryRun       bool       `json:"dry_run,omitempty"`
+}
+
+// ReplayResult represents the outcome of an event replay, with one item for each event that was (or would be, for dry runs) sent
+type ReplayResult struct {
+	DryRun bool            `json:"dry_run"`
+	Count  int             `json:"count"`
+	Failed int             `json:"failed"`
+	Items  []ReplayedEvent `json:"items"`
+}
+
+// ReplayedEvent represents an event re-emitted by a replay, and the error that prevented it from being sent, if any
+type ReplayedEvent struct {
+	EventType string `json:"event_type"`
+	ImageID   string `json:"image_id"`
+	Variant   string `json:"variant,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Validate checks that the replay request state is one of the replayable states, and that the time range is not inverted
+func (r *ReplayRequest) Validate() error {
+	if r.State != "" && r.State != StateUploaded.String() && r.State != StatePublished.String() {
+		return apierrors.ErrReplayInvalidState
+	}
+	if r.From != nil && r.To != nil && r.From.After(*r.To) {
+		return apierrors.ErrReplayInvalidTimeRange
+	}
+	return nil
+}
+
+// States returns the image states selected by the replay request
+func (r *ReplayRequest) States() []string {
+	if r.State != "" {
+		return []string{r.State}
+	}
+	return []string{StateUploaded.String(), StatePublished.String()}
+}
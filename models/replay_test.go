@@ -0,0 +1,37 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-image-api/apierrors"
+	. "github.com/ONSdigital/dp-image-api/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReplayRequestValidate(t *testing.T) {
+	earlier := time.Date(2020, time.April, 26, 8, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Hour)
+
+	Convey("An empty replay request is valid and selects both replayable states", t, func() {
+		req := &ReplayRequest{}
+		So(req.Validate(), ShouldBeNil)
+		So(req.States(), ShouldResemble, []string{StateUploaded.String(), StatePublished.String()})
+	})
+
+	Convey("A replay request for a replayable state is valid and only selects that state", t, func() {
+		req := &ReplayRequest{State: StatePublished.String(), From: &earlier, To: &later}
+		So(req.Validate(), ShouldBeNil)
+		So(req.States(), ShouldResemble, []string{StatePublished.String()})
+	})
+
+	Convey("A replay request for a state that cannot be replayed fails to validate", t, func() {
+		req := &ReplayRequest{State: StateImporting.String()}
+		So(req.Validate(), ShouldEqual, apierrors.ErrReplayInvalidState)
+	})
+
+	Convey("A replay request with an inverted time range fails to validate", t, func() {
+		req := &ReplayRequest{From: &later, To: &earlier}
+		So(req.Validate(), ShouldEqual, apierrors.ErrReplayInvalidTimeRange)
+	})
+}
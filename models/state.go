@@ -16,9 +16,10 @@ const (
 	StateDeleted
 	StateFailedImport
 	StateFailedPublish
+	StateWithdrawn
 )
 
-var stateValues = []string{"created", "uploaded", "importing", "imported", "published", "completed", "deleted", "failed_import", "failed_publish"}
+var stateValues = []string{"created", "uploaded", "importing", "imported", "published", "completed", "deleted", "failed_import", "failed_publish", "withdrawn"}
 
 // String returns the string representation of a state
 func (s State) String() string {
@@ -68,14 +69,14 @@ func (s State) TransitionAllowed(target State) bool {
 		}
 	case StatePublished:
 		switch target {
-		case StateCompleted, StateFailedPublish, StateDeleted:
+		case StateCompleted, StateFailedPublish, StateWithdrawn, StateDeleted:
 			return true
 		default:
 			return false
 		}
 	case StateCompleted:
 		switch target {
-		case StateDeleted:
+		case StateWithdrawn, StateDeleted:
 			return true
 		default:
 			return false
@@ -94,6 +95,13 @@ func (s State) TransitionAllowed(target State) bool {
 		default:
 			return false
 		}
+	case StateWithdrawn:
+		switch target {
+		case StateDeleted:
+			return true
+		default:
+			return false
+		}
 	default:
 		return false
 	}
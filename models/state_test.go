@@ -18,6 +18,7 @@ func TestStateValidation(t *testing.T) {
 		So(models.StateCreated.TransitionAllowed(models.StateDeleted), ShouldBeTrue)
 		So(models.StateCreated.TransitionAllowed(models.StateFailedImport), ShouldBeFalse)
 		So(models.StateCreated.TransitionAllowed(models.StateFailedPublish), ShouldBeFalse)
+		So(models.StateCreated.TransitionAllowed(models.StateWithdrawn), ShouldBeFalse)
 	})
 
 	Convey("Given a Uploaded State, then only transitions to importing and deleted are allowed", t, func() {
@@ -30,6 +31,7 @@ func TestStateValidation(t *testing.T) {
 		So(models.StateUploaded.TransitionAllowed(models.StateDeleted), ShouldBeTrue)
 		So(models.StateUploaded.TransitionAllowed(models.StateFailedImport), ShouldBeTrue)
 		So(models.StateUploaded.TransitionAllowed(models.StateFailedPublish), ShouldBeFalse)
+		So(models.StateUploaded.TransitionAllowed(models.StateWithdrawn), ShouldBeFalse)
 	})
 
 	Convey("Given an Importing State, then only transitions to imported, failedImport and deleted are allowed", t, func() {
@@ -42,6 +44,7 @@ func TestStateValidation(t *testing.T) {
 		So(models.StateImporting.TransitionAllowed(models.StateDeleted), ShouldBeTrue)
 		So(models.StateImporting.TransitionAllowed(models.StateFailedImport), ShouldBeTrue)
 		So(models.StateImporting.TransitionAllowed(models.StateFailedPublish), ShouldBeFalse)
+		So(models.StateImporting.TransitionAllowed(models.StateWithdrawn), ShouldBeFalse)
 	})
 
 	Convey("Given an Imported State, then only transitions to published and deleted are allowed", t, func() {
@@ -54,9 +57,10 @@ func TestStateValidation(t *testing.T) {
 		So(models.StateImported.TransitionAllowed(models.StateDeleted), ShouldBeTrue)
 		So(models.StateImported.TransitionAllowed(models.StateFailedImport), ShouldBeFalse)
 		So(models.StateImported.TransitionAllowed(models.StateFailedPublish), ShouldBeFalse)
+		So(models.StateImported.TransitionAllowed(models.StateWithdrawn), ShouldBeFalse)
 	})
 
-	Convey("Given a Published State, then only transitions to failedPublish, completed and deleted are allowed", t, func() {
+	Convey("Given a Published State, then only transitions to failedPublish, completed, withdrawn and deleted are allowed", t, func() {
 		So(models.StatePublished.TransitionAllowed(models.StateCreated), ShouldBeFalse)
 		So(models.StatePublished.TransitionAllowed(models.StateUploaded), ShouldBeFalse)
 		So(models.StatePublished.TransitionAllowed(models.StateImporting), ShouldBeFalse)
@@ -66,9 +70,10 @@ func TestStateValidation(t *testing.T) {
 		So(models.StatePublished.TransitionAllowed(models.StateDeleted), ShouldBeTrue)
 		So(models.StatePublished.TransitionAllowed(models.StateFailedImport), ShouldBeFalse)
 		So(models.StatePublished.TransitionAllowed(models.StateFailedPublish), ShouldBeTrue)
+		So(models.StatePublished.TransitionAllowed(models.StateWithdrawn), ShouldBeTrue)
 	})
 
-	Convey("Given a Completed State, then only transitions to deleted are allowed", t, func() {
+	Convey("Given a Completed State, then only transitions to withdrawn and deleted are allowed", t, func() {
 		So(models.StateCompleted.TransitionAllowed(models.StateCreated), ShouldBeFalse)
 		So(models.StateCompleted.TransitionAllowed(models.StateUploaded), ShouldBeFalse)
 		So(models.StateCompleted.TransitionAllowed(models.StateImporting), ShouldBeFalse)
@@ -78,6 +83,7 @@ func TestStateValidation(t *testing.T) {
 		So(models.StateCompleted.TransitionAllowed(models.StateDeleted), ShouldBeTrue)
 		So(models.StateCompleted.TransitionAllowed(models.StateFailedImport), ShouldBeFalse)
 		So(models.StateCompleted.TransitionAllowed(models.StateFailedPublish), ShouldBeFalse)
+		So(models.StateCompleted.TransitionAllowed(models.StateWithdrawn), ShouldBeTrue)
 	})
 
 	Convey("Given a Deleted State, then no transitions are allowed", t, func() {
@@ -90,6 +96,7 @@ func TestStateValidation(t *testing.T) {
 		So(models.StateDeleted.TransitionAllowed(models.StateDeleted), ShouldBeFalse)
 		So(models.StateDeleted.TransitionAllowed(models.StateFailedImport), ShouldBeFalse)
 		So(models.StateDeleted.TransitionAllowed(models.StateFailedPublish), ShouldBeFalse)
+		So(models.StateDeleted.TransitionAllowed(models.StateWithdrawn), ShouldBeFalse)
 	})
 
 	Convey("Given a FailedImport State, then only transitions to deleted are allowed", t, func() {
@@ -102,6 +109,7 @@ func TestStateValidation(t *testing.T) {
 		So(models.StateFailedImport.TransitionAllowed(models.StateDeleted), ShouldBeTrue)
 		So(models.StateFailedImport.TransitionAllowed(models.StateFailedImport), ShouldBeFalse)
 		So(models.StateFailedImport.TransitionAllowed(models.StateFailedPublish), ShouldBeFalse)
+		So(models.StateFailedImport.TransitionAllowed(models.StateWithdrawn), ShouldBeFalse)
 	})
 
 	Convey("Given a FailedPublish State, then only transitions to deleted are allowed", t, func() {
@@ -114,5 +122,18 @@ func TestStateValidation(t *testing.T) {
 		So(models.StateFailedPublish.TransitionAllowed(models.StateDeleted), ShouldBeTrue)
 		So(models.StateFailedPublish.TransitionAllowed(models.StateFailedImport), ShouldBeFalse)
 		So(models.StateFailedPublish.TransitionAllowed(models.StateFailedPublish), ShouldBeFalse)
+		So(models.StateFailedPublish.TransitionAllowed(models.StateWithdrawn), ShouldBeFalse)
+	})
+	Convey("Given a Withdrawn State, then only transitions to deleted are allowed", t, func() {
+		So(models.StateWithdrawn.TransitionAllowed(models.StateCreated), ShouldBeFalse)
+		So(models.StateWithdrawn.TransitionAllowed(models.StateUploaded), ShouldBeFalse)
+		So(models.StateWithdrawn.TransitionAllowed(models.StateImporting), ShouldBeFalse)
+		So(models.StateWithdrawn.TransitionAllowed(models.StateImported), ShouldBeFalse)
+		So(models.StateWithdrawn.TransitionAllowed(models.StatePublished), ShouldBeFalse)
+		So(models.StateWithdrawn.TransitionAllowed(models.StateCompleted), ShouldBeFalse)
+		So(models.StateWithdrawn.TransitionAllowed(models.StateDeleted), ShouldBeTrue)
+		So(models.StateWithdrawn.TransitionAllowed(models.StateFailedImport), ShouldBeFalse)
+		So(models.StateWithdrawn.TransitionAllowed(models.StateFailedPublish), ShouldBeFalse)
+		So(models.StateWithdrawn.TransitionAllowed(models.StateWithdrawn), ShouldBeFalse)
 	})
 }
@@ -0,0 +1,122 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ONSdigital/dp-image-api/config"
+	"github.com/ONSdigital/dp-image-api/models"
+	"github.com/ONSdigital/log.go/v2/log"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// CreateDownload atomically adds the provided download variant to an image and moves the image to 'importing' state.
+// The image is only updated if it is still in the provided state and does not have the variant yet, otherwise false is returned.
+func (m *Mongo) CreateDownload(ctx context.Context, id, imageState string, download *models.Download) (bool, error) {
+	log.Info(ctx, "creating image download variant", log.Data{"id": id, "variant": download.ID})
+
+	filter, update := createDownloadQuery(id, imageState, download)
+	return m.updateImageIf(ctx, filter, update)
+}
+
+// UpdateDownload atomically replaces the provided download variant of an image, without changing any other variant.
+// The image is only updated if it is still in the provided state and the variant is still in the provided variant state,
+// otherwise false is returned.
+func (m *Mongo) UpdateDownload(ctx context.Context, id, imageState, variantState string, download *models.Download) (bool, error) {
+	log.Info(ctx, "updating image download variant", log.Data{"id": id, "variant": download.ID})
+
+	filter, update := updateDownloadQuery(id, imageState, variantState, download)
+	return m.updateImageIf(ctx, filter, update)
+}
+
+// UpdateImageState atomically sets the state and error of the provided image.
+// The image is only updated if it is still in the provided previous state, and its download variants are still the same
+// and in the same states as in the provided image, otherwise false is returned.
+func (m *Mongo) UpdateImageState(ctx context.Context, previousState string, image *models.Image) (bool, error) {
+	log.Info(ctx, "updating image state", log.Data{"id": image.ID, "previous_state": previousState, "state": image.State})
+
+	filter, update := updateImageStateQuery(previousState, image)
+	return m.updateImageIf(ctx, filter, update)
+}
+
+// updateImageIf applies the provided update to the image matching the provided filter, and returns false if no image matched it
+func (m *Mongo) updateImageIf(ctx context.Context, filter, update bson.M) (bool, error) {
+	result, err := m.connection.Collection(m.ActualCollectionName(config.ImagesCollection)).UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// createDownloadQuery returns the filter and update that add the provided download variant to an image in the provided state
+func createDownloadQuery(id, imageState string, download *models.Download) (filter, update bson.M) {
+	filter = bson.M{
+		"_id":                          id,
+		"state":                        stateFilter(imageState),
+		downloadField(download.ID, ""): bson.M{"$exists": false},
+	}
+	update = bson.M{
+		"$set": bson.M{
+			downloadField(download.ID, ""): download,
+			"state":                        models.StateImporting.String(),
+		},
+		"$currentDate": bson.M{"last_updated": true},
+	}
+	return filter, update
+}
+
+// updateDownloadQuery returns the filter and update that replace the provided download variant of an image,
+// if the image and the variant are in the provided states
+func updateDownloadQuery(id, imageState, variantState string, download *models.Download) (filter, update bson.M) {
+	filter = bson.M{
+		"_id":                               id,
+		"state":                             stateFilter(imageState),
+		downloadField(download.ID, "state"): stateFilter(variantState),
+	}
+	update = bson.M{
+		"$set":         bson.M{downloadField(download.ID, ""): download},
+		"$currentDate": bson.M{"last_updated": true},
+	}
+	return filter, update
+}
+
+// updateImageStateQuery returns the filter and update that set the state and error of the provided image, if the image
+// is in the provided previous state and has exactly the same download variants, in the same states, as the provided image
+func updateImageStateQuery(previousState string, image *models.Image) (filter, update bson.M) {
+	filter = bson.M{
+		"_id":   image.ID,
+		"state": stateFilter(previousState),
+		// a variant created concurrently would not be matched by the variant states below
+		"$expr": bson.M{"$eq": bson.A{bson.M{"$size": bson.M{"$objectToArray": bson.M{"$ifNull": bson.A{"$downloads", bson.M{}}}}}, len(image.Downloads)}},
+	}
+	for variant, download := range image.Downloads {
+		filter[downloadField(variant, "state")] = stateFilter(download.State)
+	}
+
+	set := bson.M{"state": image.State}
+	if image.Error != "" {
+		set["error"] = image.Error
+	}
+	update = bson.M{
+		"$set":         set,
+		"$currentDate": bson.M{"last_updated": true},
+	}
+	return filter, update
+}
+
+// downloadField returns the path of the provided field of a download variant, or of the variant itself if field is empty
+func downloadField(variant, field string) string {
+	if field == "" {
+		return fmt.Sprintf("downloads.%s", variant)
+	}
+	return fmt.Sprintf("downloads.%s.%s", variant, field)
+}
+
+// stateFilter returns the filter value matching the provided state. States are omitted when empty, so an empty state matches a missing field.
+func stateFilter(state string) interface{} {
+	if state == "" {
+		return nil
+	}
+	return state
+}
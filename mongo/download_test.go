@@ -0,0 +1,97 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/ONSdigital/dp-image-api/models"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCreateDownloadQuery(t *testing.T) {
+	Convey("Given a new download variant for an image in 'uploaded' state", t, func() {
+		download := &models.Download{ID: "original", State: models.StateDownloadImporting.String()}
+
+		Convey("Then the variant is only added if the image is still 'uploaded' and does not have the variant yet", func() {
+			filter, update := createDownloadQuery("123", models.StateUploaded.String(), download)
+			So(filter, ShouldResemble, bson.M{
+				"_id":                "123",
+				"state":              "uploaded",
+				"downloads.original": bson.M{"$exists": false},
+			})
+			So(update, ShouldResemble, bson.M{
+				"$set":         bson.M{"downloads.original": download, "state": "importing"},
+				"$currentDate": bson.M{"last_updated": true},
+			})
+		})
+	})
+}
+
+func TestUpdateDownloadQuery(t *testing.T) {
+	Convey("Given an imported download variant of an image in 'importing' state", t, func() {
+		download := &models.Download{ID: "original", State: models.StateDownloadImported.String()}
+
+		Convey("Then only the variant is replaced, if the image and the variant are still 'importing'", func() {
+			filter, update := updateDownloadQuery("123", models.StateImporting.String(), models.StateDownloadImporting.String(), download)
+			So(filter, ShouldResemble, bson.M{
+				"_id":                      "123",
+				"state":                    "importing",
+				"downloads.original.state": "importing",
+			})
+			So(update, ShouldResemble, bson.M{
+				"$set":         bson.M{"downloads.original": download},
+				"$currentDate": bson.M{"last_updated": true},
+			})
+		})
+
+		Convey("Then a variant without state is matched by a missing state", func() {
+			filter, _ := updateDownloadQuery("123", models.StateImporting.String(), "", download)
+			So(filter["downloads.original.state"], ShouldBeNil)
+			So(filter, ShouldContainKey, "downloads.original.state")
+		})
+	})
+}
+
+func TestUpdateImageStateQuery(t *testing.T) {
+	Convey("Given an image with two imported variants, moving from 'importing' to 'imported' state", t, func() {
+		image := &models.Image{
+			ID:    "123",
+			State: models.StateImported.String(),
+			Downloads: map[string]models.Download{
+				"original": {State: models.StateDownloadImported.String()},
+				"bw1024":   {State: models.StateDownloadImported.String()},
+			},
+		}
+
+		Convey("Then the state is only set if the image is still 'importing' with exactly the same variants in the same states", func() {
+			filter, update := updateImageStateQuery(models.StateImporting.String(), image)
+			So(filter, ShouldResemble, bson.M{
+				"_id":                      "123",
+				"state":                    "importing",
+				"downloads.original.state": "imported",
+				"downloads.bw1024.state":   "imported",
+				"$expr": bson.M{"$eq": bson.A{
+					bson.M{"$size": bson.M{"$objectToArray": bson.M{"$ifNull": bson.A{"$downloads", bson.M{}}}}}, 2,
+				}},
+			})
+			So(update, ShouldResemble, bson.M{
+				"$set":         bson.M{"state": "imported"},
+				"$currentDate": bson.M{"last_updated": true},
+			})
+		})
+	})
+
+	Convey("Given an image with a failed variant, moving from 'importing' to 'failed_import' state", t, func() {
+		image := &models.Image{
+			ID:        "123",
+			State:     models.StateFailedImport.String(),
+			Error:     "error in variant 'original'",
+			Downloads: map[string]models.Download{"original": {State: models.StateDownloadFailed.String()}},
+		}
+
+		Convey("Then the error is set along with the state", func() {
+			_, update := updateImageStateQuery(models.StateImporting.String(), image)
+			So(update["$set"], ShouldResemble, bson.M{"state": "failed_import", "error": "error in variant 'original'"})
+		})
+	})
+}
@@ -0,0 +1,176 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/ONSdigital/dp-image-api/config"
+	"github.com/ONSdigital/dp-image-api/models"
+	"github.com/ONSdigital/log.go/v2/log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// indexOptionsConflictCode is the mongoDB error code returned when an index already exists with the same name but different options
+const indexOptionsConflictCode = 85
+
+// Index health check messages
+const (
+	msgIndexesHealthy = "all mongoDB indexes are available"
+	msgIndexesPending = "mongoDB indexes are not available"
+	msgIndexesUnknown = "mongoDB indexes could not be checked"
+)
+
+// index is the definition of an index that the service requires on one of its collections.
+// A positive expireAfter makes it a TTL index, which removes the documents once the indexed date is older than expireAfter.
+type index struct {
+	collection    string
+	name          string
+	keys          bson.D
+	expireAfter   time.Duration
+	partialFilter bson.M
+}
+
+// requiredIndexes returns the definitions of every index that the service requires.
+// Deleted images are kept as tombstones, which are removed by the TTL index once they have been deleted for longer than deletedImageTTL.
+func requiredIndexes(deletedImageTTL time.Duration) []index {
+	return []index{
+		{collection: config.ImagesCollection, name: "collection_id_1", keys: bson.D{{Key: "collection_id", Value: 1}}},
+		{collection: config.ImagesCollection, name: "state_1", keys: bson.D{{Key: "state", Value: 1}}},
+		{collection: config.ImagesCollection, name: "last_updated_1", keys: bson.D{{Key: "last_updated", Value: 1}}},
+		{collection: config.ImagesCollection, name: "state_1_last_updated_1", keys: bson.D{{Key: "state", Value: 1}, {Key: "last_updated", Value: 1}}},
+		{
+			collection:    config.ImagesCollection,
+			name:          "deleted_at_ttl",
+			keys:          bson.D{{Key: "deleted_at", Value: 1}},
+			expireAfter:   deletedImageTTL,
+			partialFilter: bson.M{"state": models.StateDeleted.String()},
+		},
+	}
+}
+
+// spec returns the index specification used by the createIndexes command
+func (i index) spec() bson.D {
+	spec := bson.D{{Key: "key", Value: i.keys}, {Key: "name", Value: i.name}}
+	if i.expireAfter > 0 {
+		spec = append(spec, bson.E{Key: "expireAfterSeconds", Value: int64(i.expireAfter.Seconds())})
+	}
+	if len(i.partialFilter) > 0 {
+		spec = append(spec, bson.E{Key: "partialFilterExpression", Value: i.partialFilter})
+	}
+	return spec
+}
+
+// EnsureIndexes creates every required index that does not exist yet. Creating an index that already exists is a no-op,
+// so this is safe to call on every startup. If the expiry of a TTL index has changed, the existing index is updated.
+func (m *Mongo) EnsureIndexes(ctx context.Context, deletedImageTTL time.Duration) error {
+	for _, idx := range requiredIndexes(deletedImageTTL) {
+		collection := m.ActualCollectionName(idx.collection)
+		logdata := log.Data{"collection": collection, "index": idx.name}
+
+		err := m.connection.RunCommand(ctx, bson.D{
+			{Key: "createIndexes", Value: collection},
+			{Key: "indexes", Value: bson.A{idx.spec()}},
+		})
+		var cmdErr mongo.CommandError
+		if err != nil && idx.expireAfter > 0 && errors.As(err, &cmdErr) && cmdErr.Code == indexOptionsConflictCode {
+			logdata["expire_after"] = idx.expireAfter.String()
+			log.Info(ctx, "updating expiry of TTL index", logdata)
+			err = m.connection.RunCommand(ctx, bson.D{
+				{Key: "collMod", Value: collection},
+				{Key: "index", Value: bson.D{{Key: "name", Value: idx.name}, {Key: "expireAfterSeconds", Value: int64(idx.expireAfter.Seconds())}}},
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create index %s on collection %s: %w", idx.name, collection, err)
+		}
+		log.Info(ctx, "mongoDB index ensured", logdata)
+	}
+	return nil
+}
+
+// indexStorageStats is the part of the $collStats storage stats that lists the indexes of a collection.
+// There is one document per shard, and indexBuilds lists the indexes that are still being built.
+type indexStorageStats struct {
+	StorageStats struct {
+		IndexSizes  map[string]interface{} `bson:"indexSizes"`
+		IndexBuilds []string               `bson:"indexBuilds"`
+	} `bson:"storageStats"`
+}
+
+// IndexChecker is called by the healthcheck library to check that every required index is available.
+// The state is WARNING if any index is missing or still being built, as queries still work, only slower.
+func (m *Mongo) IndexChecker(ctx context.Context, state *healthcheck.CheckState) error {
+	missing, building, err := m.checkIndexes(ctx)
+	if err != nil {
+		log.Error(ctx, "failed to check mongoDB indexes", err)
+		return state.Update(healthcheck.StatusWarning, fmt.Sprintf("%s: %s", msgIndexesUnknown, err.Error()), 0)
+	}
+	if len(missing) > 0 || len(building) > 0 {
+		msg := fmt.Sprintf("%s: missing [%s], building [%s]", msgIndexesPending, strings.Join(missing, ", "), strings.Join(building, ", "))
+		return state.Update(healthcheck.StatusWarning, msg, 0)
+	}
+	return state.Update(healthcheck.StatusOK, msgIndexesHealthy, 0)
+}
+
+// checkIndexes returns the names of the required indexes that are missing, and of those that are still being built.
+// Names are prefixed by their collection, and sorted.
+func (m *Mongo) checkIndexes(ctx context.Context) (missing, building []string, err error) {
+	stats := map[string][]indexStorageStats{}
+	for _, idx := range requiredIndexes(0) {
+		collection := m.ActualCollectionName(idx.collection)
+		if _, ok := stats[collection]; !ok {
+			var results []indexStorageStats
+			pipeline := bson.A{bson.M{"$collStats": bson.M{"storageStats": bson.M{}}}}
+			if err := m.connection.Collection(collection).Aggregate(ctx, pipeline, &results); err != nil {
+				return nil, nil, err
+			}
+			stats[collection] = results
+		}
+		switch getIndexStatus(stats[collection], idx.name) {
+		case indexMissing:
+			missing = append(missing, collection+"."+idx.name)
+		case indexBuilding:
+			building = append(building, collection+"."+idx.name)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(building)
+	return missing, building, nil
+}
+
+// indexStatus is the availability of an index
+type indexStatus int
+
+// Possible index statuses
+const (
+	indexAvailable indexStatus = iota
+	indexBuilding
+	indexMissing
+)
+
+// getIndexStatus returns the status of the named index from the storage stats of every shard of its collection.
+// The index is only available once it has been built on every shard.
+func getIndexStatus(stats []indexStorageStats, name string) indexStatus {
+	if len(stats) == 0 {
+		return indexMissing
+	}
+	status := indexAvailable
+	for _, shard := range stats {
+		if slices.Contains(shard.StorageStats.IndexBuilds, name) {
+			status = indexBuilding
+			continue
+		}
+		if _, ok := shard.StorageStats.IndexSizes[name]; !ok {
+			return indexMissing
+		}
+	}
+	return status
+}
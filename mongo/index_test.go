@@ -0,0 +1,80 @@
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRequiredIndexes(t *testing.T) {
+	Convey("Given the required indexes for a deleted image TTL of 1 day", t, func() {
+		indexes := requiredIndexes(24 * time.Hour)
+
+		Convey("Then index names are unique", func() {
+			names := map[string]bool{}
+			for _, idx := range indexes {
+				So(names[idx.collection+"."+idx.name], ShouldBeFalse)
+				names[idx.collection+"."+idx.name] = true
+			}
+		})
+
+		Convey("Then the TTL index only expires deleted images, one day after they are deleted", func() {
+			var ttl *index
+			for i := range indexes {
+				if indexes[i].expireAfter > 0 {
+					So(ttl, ShouldBeNil)
+					ttl = &indexes[i]
+				}
+			}
+			So(ttl, ShouldNotBeNil)
+			So(ttl.spec(), ShouldResemble, bson.D{
+				{Key: "key", Value: bson.D{{Key: "deleted_at", Value: 1}}},
+				{Key: "name", Value: "deleted_at_ttl"},
+				{Key: "expireAfterSeconds", Value: int64(86400)},
+				{Key: "partialFilterExpression", Value: bson.M{"state": "deleted"}},
+			})
+		})
+
+		Convey("Then the other indexes only have keys and a name", func() {
+			for _, idx := range indexes {
+				if idx.expireAfter == 0 {
+					So(idx.spec(), ShouldResemble, bson.D{{Key: "key", Value: idx.keys}, {Key: "name", Value: idx.name}})
+				}
+			}
+		})
+	})
+}
+
+func TestGetIndexStatus(t *testing.T) {
+	// newStats returns the storage stats of a shard with the provided built and building indexes
+	newStats := func(built []string, building []string) indexStorageStats {
+		stats := indexStorageStats{}
+		stats.StorageStats.IndexSizes = map[string]interface{}{}
+		for _, name := range built {
+			stats.StorageStats.IndexSizes[name] = 4096
+		}
+		stats.StorageStats.IndexBuilds = building
+		return stats
+	}
+
+	Convey("An index is missing if the collection does not exist", t, func() {
+		So(getIndexStatus(nil, "state_1"), ShouldEqual, indexMissing)
+	})
+
+	Convey("An index is available if it has been built on every shard", t, func() {
+		stats := []indexStorageStats{newStats([]string{"_id_", "state_1"}, nil), newStats([]string{"state_1"}, nil)}
+		So(getIndexStatus(stats, "state_1"), ShouldEqual, indexAvailable)
+	})
+
+	Convey("An index is building if it is still being built on any shard", t, func() {
+		stats := []indexStorageStats{newStats([]string{"state_1"}, nil), newStats([]string{"state_1"}, []string{"state_1"})}
+		So(getIndexStatus(stats, "state_1"), ShouldEqual, indexBuilding)
+	})
+
+	Convey("An index is missing if it neither exists nor is being built on any shard", t, func() {
+		stats := []indexStorageStats{newStats(nil, []string{"state_1"}), newStats([]string{"_id_"}, nil)}
+		So(getIndexStatus(stats, "state_1"), ShouldEqual, indexMissing)
+	})
+}
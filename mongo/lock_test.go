@@ -0,0 +1,269 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"testing"
+	"time"
+
+	errs "github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/dp-image-api/metrics"
+	mongolock "github.com/ONSdigital/dp-mongodb/v3/dplock"
+	lockmock "github.com/ONSdigital/dp-mongodb/v3/dplock/mock"
+	dpreq "github.com/ONSdigital/dp-net/v3/request"
+	. "github.com/smartystreets/goconvey/convey"
+	lock "github.com/square/mongo-lock"
+)
+
+// lockStatusClientFunc is a lockStatusClient implemented by a function
+type lockStatusClientFunc func(ctx context.Context, f lock.Filter) ([]lock.LockStatus, error)
+
+func (fn lockStatusClientFunc) Status(ctx context.Context, f lock.Filter) ([]lock.LockStatus, error) {
+	return fn(ctx, f)
+}
+
+// lockRenewClientFunc is a lockRenewClient implemented by a function
+type lockRenewClientFunc func(ctx context.Context, lockID string, ttl uint) ([]lock.LockStatus, error)
+
+func (fn lockRenewClientFunc) Renew(ctx context.Context, lockID string, ttl uint) ([]lock.LockStatus, error) {
+	return fn(ctx, lockID, ttl)
+}
+
+// newTestLockMongo returns a Mongo with the provided lock clients
+func newTestLockMongo(client mongolock.Client, statusClient lockStatusClient) *Mongo {
+	return &Mongo{
+		lockClient:       &mongolock.Lock{Client: client, Resource: "images", CloserChannel: make(chan struct{})},
+		lockStatusClient: statusClient,
+		hostname:         "host-1",
+	}
+}
+
+// getLockMetric returns the current value of the provided image lock metric
+func getLockMetric(key string) int64 {
+	if v, ok := metrics.ImageLocks.Get(key).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func TestAcquireImageLock(t *testing.T) {
+	imageLockRetryPeriod = time.Millisecond
+	ctx := context.WithValue(context.Background(), dpreq.RequestIdKey, "request-1")
+
+	Convey("Given an image that is not locked", t, func() {
+		clientMock := &lockmock.ClientMock{
+			XLockFunc: func(ctx context.Context, resourceName string, lockID string, ld lock.LockDetails) error { return nil },
+		}
+		m := newTestLockMongo(clientMock, nil)
+		acquired, held, contended := getLockMetric(metrics.LocksAcquired), getLockMetric(metrics.LocksHeld), getLockMetric(metrics.LocksContended)
+
+		Convey("Then the lock is acquired at the first attempt, recording the request and host holding it", func() {
+			lockID, err := m.AcquireImageLock(ctx, "123")
+			So(err, ShouldBeNil)
+			So(clientMock.XLockCalls(), ShouldHaveLength, 1)
+			So(clientMock.XLockCalls()[0].ResourceName, ShouldEqual, "images-123")
+			So(clientMock.XLockCalls()[0].LockID, ShouldEqual, lockID)
+			So(lockID, ShouldStartWith, "images-123-")
+			So(clientMock.XLockCalls()[0].Ld, ShouldResemble, lock.LockDetails{Owner: "request-1", Host: "host-1", TTL: mongolock.TTL})
+			So(getLockMetric(metrics.LocksAcquired), ShouldEqual, acquired+1)
+			So(getLockMetric(metrics.LocksHeld), ShouldEqual, held+1)
+			So(getLockMetric(metrics.LocksContended), ShouldEqual, contended)
+		})
+	})
+
+	Convey("Given an image that is locked by another request, which releases it after two attempts", t, func() {
+		clientMock := &lockmock.ClientMock{}
+		clientMock.XLockFunc = func(ctx context.Context, resourceName string, lockID string, ld lock.LockDetails) error {
+			if len(clientMock.XLockCalls()) < 3 {
+				return lock.ErrAlreadyLocked
+			}
+			return nil
+		}
+		m := newTestLockMongo(clientMock, nil)
+		acquired, contended := getLockMetric(metrics.LocksAcquired), getLockMetric(metrics.LocksContended)
+
+		Convey("Then the lock is acquired once released, and the contention is recorded", func() {
+			_, err := m.AcquireImageLock(ctx, "123")
+			So(err, ShouldBeNil)
+			So(clientMock.XLockCalls(), ShouldHaveLength, 3)
+			So(getLockMetric(metrics.LocksAcquired), ShouldEqual, acquired+1)
+			So(getLockMetric(metrics.LocksContended), ShouldEqual, contended+1)
+			So(getLockMetric(metrics.LocksWaiting), ShouldEqual, 0)
+		})
+	})
+
+	Convey("Given an image that is locked by another request for longer than the lock timeout", t, func() {
+		clientMock := &lockmock.ClientMock{
+			XLockFunc: func(ctx context.Context, resourceName string, lockID string, ld lock.LockDetails) error {
+				return lock.ErrAlreadyLocked
+			},
+		}
+		m := newTestLockMongo(clientMock, nil)
+		timeouts := getLockMetric(metrics.LocksTimeouts)
+		lockCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+
+		Convey("Then acquiring the lock times out with ErrImageLockTimeout, and the timeout is recorded", func() {
+			_, err := m.AcquireImageLock(lockCtx, "123")
+			So(err, ShouldEqual, errs.ErrImageLockTimeout)
+			So(len(clientMock.XLockCalls()), ShouldBeGreaterThan, 1)
+			So(getLockMetric(metrics.LocksTimeouts), ShouldEqual, timeouts+1)
+			So(getLockMetric(metrics.LocksWaiting), ShouldEqual, 0)
+		})
+	})
+
+	Convey("Given that locking fails with an unexpected error", t, func() {
+		errLock := errors.New("lock failed")
+		clientMock := &lockmock.ClientMock{
+			XLockFunc: func(ctx context.Context, resourceName string, lockID string, ld lock.LockDetails) error {
+				return errLock
+			},
+		}
+		m := newTestLockMongo(clientMock, nil)
+
+		Convey("Then the error is returned without retrying", func() {
+			_, err := m.AcquireImageLock(ctx, "123")
+			So(err, ShouldEqual, errLock)
+			So(clientMock.XLockCalls(), ShouldHaveLength, 1)
+		})
+	})
+}
+
+func TestGetImageLocks(t *testing.T) {
+	Convey("Given an image lock, a service lock and a lock of another resource", t, func() {
+		createdAt := time.Now().Add(-2 * time.Second)
+		m := newTestLockMongo(nil, lockStatusClientFunc(func(ctx context.Context, f lock.Filter) ([]lock.LockStatus, error) {
+			return []lock.LockStatus{
+				{Resource: "images-publish-scheduler", LockId: "images-publish-scheduler-1", CreatedAt: createdAt, TTL: 30},
+				{Resource: "images-123", LockId: "images-123-1", Owner: "request-1", Host: "host-1", CreatedAt: createdAt, TTL: 28},
+				{Resource: "datasets-456", LockId: "datasets-456-1", CreatedAt: createdAt, TTL: 30},
+			}, nil
+		}))
+
+		Convey("Then only the image lock is returned, with its expiry and the time it has been held for", func() {
+			locks, err := m.GetImageLocks(context.Background())
+			So(err, ShouldBeNil)
+			So(locks, ShouldHaveLength, 1)
+			So(locks[0].ImageID, ShouldEqual, "123")
+			So(locks[0].LockID, ShouldEqual, "images-123-1")
+			So(locks[0].Owner, ShouldEqual, "request-1")
+			So(locks[0].Host, ShouldEqual, "host-1")
+			So(locks[0].CreatedAt, ShouldEqual, createdAt.UTC())
+			So(*locks[0].ExpiresAt, ShouldHappenWithin, time.Second, time.Now().Add(28*time.Second))
+			So(locks[0].HeldFor, ShouldStartWith, "2")
+		})
+	})
+
+	Convey("Given that the locks cannot be read", t, func() {
+		errStatus := errors.New("status failed")
+		m := newTestLockMongo(nil, lockStatusClientFunc(func(ctx context.Context, f lock.Filter) ([]lock.LockStatus, error) {
+			return nil, errStatus
+		}))
+
+		Convey("Then the error is returned", func() {
+			_, err := m.GetImageLocks(context.Background())
+			So(err, ShouldEqual, errStatus)
+		})
+	})
+}
+
+func TestAcquireMigrationsLock(t *testing.T) {
+	migrationsLockRetryPeriod = time.Millisecond
+
+	Convey("Given that another service instance is migrating, and releases the migrations lock after two attempts", t, func() {
+		clientMock := &lockmock.ClientMock{}
+		clientMock.XLockFunc = func(ctx context.Context, resourceName string, lockID string, ld lock.LockDetails) error {
+			if len(clientMock.XLockCalls()) < 3 {
+				return lock.ErrAlreadyLocked
+			}
+			return nil
+		}
+		m := newTestLockMongo(clientMock, nil)
+
+		Convey("Then the lock is acquired once released, rather than giving up after a fixed number of attempts", func() {
+			lockID, err := m.acquireMigrationsLock(context.Background(), time.Minute)
+			So(err, ShouldBeNil)
+			So(clientMock.XLockCalls(), ShouldHaveLength, 3)
+			So(clientMock.XLockCalls()[2].ResourceName, ShouldEqual, "images-migrations")
+			So(clientMock.XLockCalls()[2].LockID, ShouldEqual, lockID)
+			So(clientMock.XLockCalls()[2].Ld, ShouldResemble, lock.LockDetails{Host: "host-1", TTL: mongolock.TTL})
+		})
+	})
+
+	Convey("Given that another service instance keeps migrating for longer than the lock timeout", t, func() {
+		clientMock := &lockmock.ClientMock{
+			XLockFunc: func(ctx context.Context, resourceName string, lockID string, ld lock.LockDetails) error {
+				return lock.ErrAlreadyLocked
+			},
+		}
+		m := newTestLockMongo(clientMock, nil)
+
+		Convey("Then acquiring the lock times out with ErrMigrationLockTimeout", func() {
+			_, err := m.acquireMigrationsLock(context.Background(), 20*time.Millisecond)
+			So(err, ShouldEqual, errs.ErrMigrationLockTimeout)
+			So(len(clientMock.XLockCalls()), ShouldBeGreaterThan, 1)
+		})
+	})
+
+	Convey("Given that locking fails with an unexpected error", t, func() {
+		errLock := errors.New("lock failed")
+		clientMock := &lockmock.ClientMock{
+			XLockFunc: func(ctx context.Context, resourceName string, lockID string, ld lock.LockDetails) error {
+				return errLock
+			},
+		}
+		m := newTestLockMongo(clientMock, nil)
+
+		Convey("Then the error is returned without retrying", func() {
+			_, err := m.acquireMigrationsLock(context.Background(), time.Minute)
+			So(err, ShouldEqual, errLock)
+			So(clientMock.XLockCalls(), ShouldHaveLength, 1)
+		})
+	})
+}
+
+func TestMigrationsLockKeepAlive(t *testing.T) {
+	Convey("Given a migrations lock held by this service instance", t, func() {
+		renewCalls := []string{}
+		var renewErr error
+		renewed := []lock.LockStatus{{LockId: "images-migrations-1", TTL: mongolock.TTL}}
+		m := newTestLockMongo(nil, nil)
+		m.lockRenewClient = lockRenewClientFunc(func(ctx context.Context, lockID string, ttl uint) ([]lock.LockStatus, error) {
+			renewCalls = append(renewCalls, lockID)
+			So(ttl, ShouldEqual, mongolock.TTL)
+			return renewed, renewErr
+		})
+		migLock := &migrationsLock{m: m, lockID: "images-migrations-1", renewed: time.Now()}
+
+		Convey("Then the lock is not renewed while it was recently renewed", func() {
+			So(migLock.keepAlive(context.Background()), ShouldBeNil)
+			So(renewCalls, ShouldBeEmpty)
+		})
+
+		Convey("When the renew period has passed since the lock was last renewed", func() {
+			migLock.renewed = time.Now().Add(-migrationsLockRenewPeriod)
+
+			Convey("Then the lock is renewed", func() {
+				So(migLock.keepAlive(context.Background()), ShouldBeNil)
+				So(renewCalls, ShouldResemble, []string{"images-migrations-1"})
+				So(migLock.renewed, ShouldHappenWithin, time.Second, time.Now())
+			})
+
+			Convey("Then ErrLockLost is returned if the lock has expired and been purged", func() {
+				renewed, renewErr = nil, lock.ErrLockNotFound
+				So(migLock.keepAlive(context.Background()), ShouldEqual, errs.ErrLockLost)
+			})
+
+			Convey("Then ErrLockLost is returned if the lock has expired but not been purged yet", func() {
+				renewed = []lock.LockStatus{}
+				So(migLock.keepAlive(context.Background()), ShouldEqual, errs.ErrLockLost)
+			})
+
+			Convey("Then any other error is returned", func() {
+				renewErr = errors.New("renew failed")
+				So(migLock.keepAlive(context.Background()), ShouldEqual, renewErr)
+			})
+		})
+	})
+}
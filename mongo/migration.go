@@ -0,0 +1,284 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	errs "github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/dp-image-api/config"
+	"github.com/ONSdigital/dp-image-api/models"
+	"github.com/ONSdigital/log.go/v2/log"
+
+	mongolock "github.com/ONSdigital/dp-mongodb/v3/dplock"
+	mongodriver "github.com/ONSdigital/dp-mongodb/v3/mongodb"
+	lock "github.com/square/mongo-lock"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// migrationsLockID is the resource ID locked by the service instance that applies the migrations
+const migrationsLockID = "migrations"
+
+// migrationsLockRetryPeriod is the time between attempts to acquire the migrations lock while another instance migrates
+var migrationsLockRetryPeriod = time.Second
+
+// migrationsLockRenewPeriod is the time after which the migrations lock is renewed, well before its TTL expires
+var migrationsLockRenewPeriod = mongolock.TTL * time.Second / 3
+
+// migrationsLock is the migrations lock held while migrating, which is renewed between documents so that it does not expire
+type migrationsLock struct {
+	m       *Mongo
+	lockID  string
+	renewed time.Time
+}
+
+// keepAlive renews the lock if it was last renewed more than migrationsLockRenewPeriod ago.
+// ErrLockLost is returned if the lock has already expired, as another service instance may be migrating.
+func (l *migrationsLock) keepAlive(ctx context.Context) error {
+	if time.Since(l.renewed) < migrationsLockRenewPeriod {
+		return nil
+	}
+	if err := l.m.renewLock(ctx, l.lockID); err != nil {
+		return err
+	}
+	l.renewed = time.Now()
+	return nil
+}
+
+// migration is a versioned change to the documents stored in mongoDB, which is applied once, in version order.
+// apply changes the documents, or only counts the documents that would be changed for dry runs, keeping the migrations lock alive.
+type migration struct {
+	version     int
+	description string
+	apply       func(ctx context.Context, m *Mongo, migLock *migrationsLock, dryRun bool) (documents int, err error)
+}
+
+// migrations lists every migration in version order. New migrations need to be appended with the next version number,
+// and applied migrations must never be changed or removed.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "move the 'private' field of download variants to 'private_bucket'",
+		apply:       migratePrivateBucket,
+	},
+	{
+		version:     2,
+		description: "set 'deleted_at' on deleted images, so that they are removed by the TTL index",
+		apply:       migrateDeletedAt,
+	},
+}
+
+// Migrate applies, in version order, every migration that has not been recorded in the migrations collection yet.
+// The migrations lock is held while migrating, so that only one service instance migrates at a time. If another instance
+// is migrating, the lock is waited for up to lockTimeout, and ErrMigrationLockTimeout is returned if it is not released by then.
+// The lock is renewed while migrating, and the run stops with ErrLockLost if the lock expired before it could be renewed.
+// Each migration is recorded as soon as it is applied, and the run stops at the first failure.
+// For dry runs, the documents that pending migrations would change are only counted, and nothing is recorded.
+func (m *Mongo) Migrate(ctx context.Context, dryRun bool, lockTimeout time.Duration) (*models.MigrationResult, error) {
+	lockID, err := m.acquireMigrationsLock(ctx, lockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer m.lockClient.Unlock(ctx, lockID)
+	migLock := &migrationsLock{m: m, lockID: lockID, renewed: time.Now()}
+
+	collection := m.connection.Collection(m.ActualCollectionName(config.MigrationsCollection))
+	var applied []models.MigrationRecord
+	if _, err := collection.Find(ctx, bson.M{}, &applied); err != nil {
+		return nil, err
+	}
+	appliedVersions := make(map[int]bool, len(applied))
+	for _, record := range applied {
+		appliedVersions[record.Version] = true
+	}
+
+	result := &models.MigrationResult{DryRun: dryRun, Applied: []models.MigrationRecord{}}
+	for _, mig := range migrations {
+		if appliedVersions[mig.version] {
+			continue
+		}
+		logdata := log.Data{"version": mig.version, "description": mig.description, "dry_run": dryRun}
+		if err := migLock.keepAlive(ctx); err != nil {
+			return result, fmt.Errorf("migration %d could not be applied: %w", mig.version, err)
+		}
+		log.Info(ctx, "applying migration", logdata)
+
+		documents, err := mig.apply(ctx, m, migLock, dryRun)
+		if err != nil {
+			return result, fmt.Errorf("migration %d failed: %w", mig.version, err)
+		}
+		record := models.MigrationRecord{Version: mig.version, Description: mig.description, Documents: documents}
+		if !dryRun {
+			appliedAt := time.Now().UTC()
+			record.AppliedAt = &appliedAt
+			if _, err := collection.Insert(ctx, &record); err != nil {
+				return result, fmt.Errorf("migration %d could not be recorded: %w", mig.version, err)
+			}
+		}
+		result.Applied = append(result.Applied, record)
+		logdata["documents"] = documents
+		log.Info(ctx, "migration applied", logdata)
+	}
+
+	return result, nil
+}
+
+// acquireMigrationsLock locks the migrations resource, retrying while another service instance holds it,
+// until the provided timeout expires or the context is done
+func (m *Mongo) acquireMigrationsLock(ctx context.Context, timeout time.Duration) (lockID string, err error) {
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	resource := fmt.Sprintf("%s-%s", m.lockClient.Resource, migrationsLockID)
+	details := lock.LockDetails{Host: m.hostname, TTL: mongolock.TTL}
+	for attempts := 1; ; attempts++ {
+		lockID = fmt.Sprintf("%s-%d", resource, mongolock.GenerateTimeID())
+		err = m.lockClient.Client.XLock(waitCtx, resource, lockID, details)
+		if err == nil {
+			if attempts > 1 {
+				log.Info(ctx, "migrations lock acquired after waiting", log.Data{"wait": time.Since(start).String(), "attempts": attempts})
+			}
+			return lockID, nil
+		}
+		if waitCtx.Err() == nil && !errors.Is(err, lock.ErrAlreadyLocked) {
+			return "", err
+		}
+		if attempts == 1 {
+			log.Info(ctx, "waiting for another service instance to finish migrating", log.Data{"timeout": timeout.String()})
+		}
+
+		delay := time.NewTimer(migrationsLockRetryPeriod)
+		select {
+		case <-delay.C:
+			continue
+		case <-waitCtx.Done():
+			delay.Stop()
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			log.Warn(ctx, "timed out waiting for migrations lock", log.Data{"wait": time.Since(start).String(), "attempts": attempts})
+			return "", errs.ErrMigrationLockTimeout
+		case <-m.lockClient.CloserChannel:
+			delay.Stop()
+			return "", mongolock.ErrMongoDbClosing
+		}
+	}
+}
+
+// updateEachDocument reads every document of the provided collection with a cursor, and applies the update returned by getUpdate
+// for it, if any. The migrations lock is kept alive between documents. The number of documents that were (or would be, for dry runs)
+// updated is returned.
+func (m *Mongo) updateEachDocument(ctx context.Context, migLock *migrationsLock, collectionName string, dryRun bool,
+	getUpdate func(cursor mongodriver.Cursor) (id interface{}, update bson.M, err error)) (updated int, err error) {
+	collection := m.connection.Collection(m.ActualCollectionName(collectionName))
+	cursor, err := collection.FindCursor(ctx, bson.M{})
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if closeErr := cursor.Close(ctx); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	for cursor.Next(ctx) {
+		if err := migLock.keepAlive(ctx); err != nil {
+			return updated, err
+		}
+		id, update, err := getUpdate(cursor)
+		if err != nil {
+			return updated, err
+		}
+		if update == nil {
+			continue
+		}
+		if !dryRun {
+			if _, err := collection.UpdateById(ctx, id, update); err != nil {
+				return updated, err
+			}
+		}
+		updated++
+	}
+
+	return updated, cursor.Err()
+}
+
+// privateBucketDocument is the part of an image or version document holding the download variants with a private bucket
+type privateBucketDocument struct {
+	ID        interface{}         `bson:"_id"`
+	Downloads map[string]bson.Raw `bson:"downloads,omitempty"`
+	Revisions []struct {
+		Downloads map[string]bson.Raw `bson:"downloads,omitempty"`
+	} `bson:"revisions,omitempty"`
+}
+
+// migratePrivateBucket moves the 'private' field of the download variants of images, of their archived revisions and of image versions
+// to 'private_bucket'. Images were created with 'private', while image updates wrote 'private_bucket'.
+func migratePrivateBucket(ctx context.Context, m *Mongo, migLock *migrationsLock, dryRun bool) (int, error) {
+	documents := 0
+	for _, collection := range []string{config.ImagesCollection, config.ImagesVersionsCollection} {
+		updated, err := m.updateEachDocument(ctx, migLock, collection, dryRun, func(cursor mongodriver.Cursor) (interface{}, bson.M, error) {
+			var doc privateBucketDocument
+			if err := cursor.Decode(&doc); err != nil {
+				return nil, nil, err
+			}
+			return doc.ID, privateBucketUpdate(&doc), nil
+		})
+		documents += updated
+		if err != nil {
+			return documents, err
+		}
+	}
+	return documents, nil
+}
+
+// privateBucketUpdate returns the update that moves the 'private' field of every download variant of the provided document to
+// 'private_bucket', or nil if no variant has a 'private' field. If a variant has both fields, 'private_bucket' is kept,
+// as it was written by the latest update of the variant.
+func privateBucketUpdate(doc *privateBucketDocument) bson.M {
+	set, unset := bson.M{}, bson.M{}
+	addDownloads := func(prefix string, downloads map[string]bson.Raw) {
+		for variant, download := range downloads {
+			private, err := download.LookupErr("private")
+			if err != nil {
+				continue
+			}
+			field := fmt.Sprintf("%sdownloads.%s.", prefix, variant)
+			if _, err := download.LookupErr("private_bucket"); err != nil {
+				set[field+"private_bucket"] = private
+			}
+			unset[field+"private"] = ""
+		}
+	}
+
+	addDownloads("", doc.Downloads)
+	for i, revision := range doc.Revisions {
+		addDownloads(fmt.Sprintf("revisions.%d.", i), revision.Downloads)
+	}
+
+	if len(unset) == 0 {
+		return nil
+	}
+	update := bson.M{"$unset": unset}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	return update
+}
+
+// migrateDeletedAt sets the 'deleted_at' date of the images that were deleted before it was recorded to the migration date,
+// so that they are removed by the TTL index once the deleted image TTL has passed from now.
+func migrateDeletedAt(ctx context.Context, m *Mongo, _ *migrationsLock, dryRun bool) (int, error) {
+	collection := m.connection.Collection(m.ActualCollectionName(config.ImagesCollection))
+	filter := bson.M{"state": models.StateDeleted.String(), "deleted_at": bson.M{"$exists": false}}
+	if dryRun {
+		return collection.Count(ctx, filter)
+	}
+	result, err := collection.UpdateMany(ctx, filter, bson.M{"$currentDate": bson.M{"deleted_at": true}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
@@ -0,0 +1,75 @@
+package mongo
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// decodePrivateBucketDocument marshals the provided document and decodes it as it would be read from mongoDB
+func decodePrivateBucketDocument(doc bson.M) *privateBucketDocument {
+	b, err := bson.Marshal(doc)
+	So(err, ShouldBeNil)
+	decoded := &privateBucketDocument{}
+	So(bson.Unmarshal(b, decoded), ShouldBeNil)
+	return decoded
+}
+
+func TestPrivateBucketUpdate(t *testing.T) {
+	Convey("Given an image with variants using 'private', 'private_bucket' or both, and an archived revision using 'private'", t, func() {
+		doc := decodePrivateBucketDocument(bson.M{
+			"_id": "123",
+			"downloads": bson.M{
+				"original": bson.M{"state": "completed", "private": "bucket-a"},
+				"bw1024":   bson.M{"state": "completed", "private_bucket": "bucket-b"},
+				"bw640":    bson.M{"state": "completed", "private": "old-bucket", "private_bucket": "bucket-c"},
+			},
+			"revisions": bson.A{
+				bson.M{"revision": 1, "downloads": bson.M{"original": bson.M{"private": "bucket-d"}}},
+			},
+		})
+
+		Convey("Then the update moves 'private' to 'private_bucket', keeping 'private_bucket' when both are present", func() {
+			update := privateBucketUpdate(doc)
+			So(update["$unset"], ShouldResemble, bson.M{
+				"downloads.original.private":             "",
+				"downloads.bw640.private":                "",
+				"revisions.0.downloads.original.private": "",
+			})
+			set := update["$set"].(bson.M)
+			So(set, ShouldHaveLength, 2)
+			So(set["downloads.original.private_bucket"].(bson.RawValue).StringValue(), ShouldEqual, "bucket-a")
+			So(set["revisions.0.downloads.original.private_bucket"].(bson.RawValue).StringValue(), ShouldEqual, "bucket-d")
+		})
+	})
+
+	Convey("Given an image whose variants only use 'private_bucket'", t, func() {
+		doc := decodePrivateBucketDocument(bson.M{
+			"_id":       "123",
+			"downloads": bson.M{"original": bson.M{"private_bucket": "bucket-a"}},
+		})
+
+		Convey("Then no update is needed", func() {
+			So(privateBucketUpdate(doc), ShouldBeNil)
+		})
+	})
+
+	Convey("Given an image without download variants", t, func() {
+		doc := decodePrivateBucketDocument(bson.M{"_id": "123", "state": "created"})
+
+		Convey("Then no update is needed", func() {
+			So(privateBucketUpdate(doc), ShouldBeNil)
+		})
+	})
+}
+
+func TestMigrationsOrder(t *testing.T) {
+	Convey("Migrations have unique versions in increasing order", t, func() {
+		for i := range migrations {
+			So(migrations[i].version, ShouldEqual, i+1)
+			So(migrations[i].description, ShouldNotBeEmpty)
+			So(migrations[i].apply, ShouldNotBeNil)
+		}
+	})
+}
@@ -4,26 +4,57 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
 	errs "github.com/ONSdigital/dp-image-api/apierrors"
 	"github.com/ONSdigital/dp-image-api/config"
+	"github.com/ONSdigital/dp-image-api/metrics"
 	"github.com/ONSdigital/dp-image-api/models"
+	dpreq "github.com/ONSdigital/dp-net/v3/request"
 	"github.com/ONSdigital/log.go/v2/log"
 
 	mongolock "github.com/ONSdigital/dp-mongodb/v3/dplock"
 	mongohealth "github.com/ONSdigital/dp-mongodb/v3/health"
 	mongodriver "github.com/ONSdigital/dp-mongodb/v3/mongodb"
+	lock "github.com/square/mongo-lock"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
+// schedulerLockID is the resource ID locked by the service instance that triggers scheduled publishes
+const schedulerLockID = "publish-scheduler"
+
+// deadLetterRetrierLockID is the resource ID locked by the service instance that retries dead letters
+const deadLetterRetrierLockID = "dead-letter-retrier"
+
+// serviceLockIDs are the resource IDs locked by the service itself, rather than for an image
+var serviceLockIDs = map[string]bool{schedulerLockID: true, deadLetterRetrierLockID: true, migrationsLockID: true}
+
+// imageLockRetryPeriod is the time between attempts to acquire an image lock that is held by another request
+var imageLockRetryPeriod = 50 * time.Millisecond
+
+// lockStatusClient defines the mongo-lock method used to list the locks that are currently held
+type lockStatusClient interface {
+	Status(ctx context.Context, f lock.Filter) ([]lock.LockStatus, error)
+}
+
+// lockRenewClient defines the mongo-lock method used to extend the expiry of the locks held by long running tasks
+type lockRenewClient interface {
+	Renew(ctx context.Context, lockID string, ttl uint) ([]lock.LockStatus, error)
+}
+
 type Mongo struct {
 	mongodriver.MongoDriverConfig
 
-	connection   *mongodriver.MongoConnection
-	healthClient *mongohealth.CheckMongoClient
-	lockClient   *mongolock.Lock
+	connection       *mongodriver.MongoConnection
+	healthClient     *mongohealth.CheckMongoClient
+	lockClient       *mongolock.Lock
+	lockStatusClient lockStatusClient
+	lockRenewClient  lockRenewClient
+	hostname         string
 }
 
 // NewMongoStore creates a new Mongo object encapsulating a connection to the mongo server/cluster with the given configuration,
@@ -39,24 +70,167 @@ func NewMongoStore(ctx context.Context, cfg config.MongoConfig) (m *Mongo, err e
 		mongohealth.Database(m.Database): {
 			mongohealth.Collection(m.ActualCollectionName(config.ImagesCollection)),
 			mongohealth.Collection(m.ActualCollectionName(config.ImagesLockCollection)),
+			mongohealth.Collection(m.ActualCollectionName(config.ImagesVersionsCollection)),
+			mongohealth.Collection(m.ActualCollectionName(config.DeadLettersCollection)),
 		},
 	}
 	m.healthClient = mongohealth.NewClientWithCollections(m.connection, databaseCollectionBuilder)
 	m.lockClient = mongolock.New(ctx, m.connection, m.ActualCollectionName(config.ImagesCollection))
+	lockClient := m.connection.Collection(fmt.Sprintf("%s_locks", m.lockClient.Resource)).NewLockClient()
+	m.lockStatusClient = lockClient
+	m.lockRenewClient = lockClient
+	if m.hostname, err = os.Hostname(); err != nil {
+		log.Warn(ctx, "could not get hostname for image locks", log.Data{"error": err.Error()})
+	}
 
 	return m, nil
 }
 
-// AcquireImageLock tries to lock the provided imageID.
-// If the image is already locked, this function will block until it's released,
-// at which point we acquire the lock and return.
+// AcquireImageLock tries to lock the provided imageID. If the image is already locked, it retries until the lock is released
+// or the provided context is done. ErrImageLockTimeout is returned if the context deadline is exceeded while waiting.
+// The lock records the ID of the request that holds it, and this host, so that contention can be diagnosed.
 func (m *Mongo) AcquireImageLock(ctx context.Context, imageID string) (lockID string, err error) {
-	return m.lockClient.Acquire(ctx, imageID)
+	start := time.Now()
+	metrics.ImageLocks.Add(metrics.LocksWaiting, 1)
+	defer metrics.ImageLocks.Add(metrics.LocksWaiting, -1)
+
+	resource := fmt.Sprintf("%s-%s", m.lockClient.Resource, imageID)
+	details := lock.LockDetails{Owner: getRequestID(ctx), Host: m.hostname, TTL: mongolock.TTL}
+	for attempts := 1; ; attempts++ {
+		lockID = fmt.Sprintf("%s-%d", resource, mongolock.GenerateTimeID())
+		err = m.lockClient.Client.XLock(ctx, resource, lockID, details)
+		if err == nil {
+			wait := time.Since(start)
+			metrics.ImageLocks.Add(metrics.LocksAcquired, 1)
+			metrics.ImageLocks.Add(metrics.LocksHeld, 1)
+			metrics.ImageLocks.Add(metrics.LocksWaitMs, wait.Milliseconds())
+			if attempts > 1 {
+				metrics.ImageLocks.Add(metrics.LocksContended, 1)
+				log.Info(ctx, "image lock acquired after waiting", log.Data{"image_id": imageID, "wait": wait.String(), "attempts": attempts})
+			}
+			return lockID, nil
+		}
+		if ctx.Err() == nil && !errors.Is(err, lock.ErrAlreadyLocked) {
+			return "", err
+		}
+
+		delay := time.NewTimer(imageLockRetryPeriod)
+		select {
+		case <-delay.C:
+			continue
+		case <-ctx.Done():
+			delay.Stop()
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				metrics.ImageLocks.Add(metrics.LocksTimeouts, 1)
+				log.Warn(ctx, "timed out waiting for image lock", log.Data{"image_id": imageID, "wait": time.Since(start).String(), "attempts": attempts})
+				return "", errs.ErrImageLockTimeout
+			}
+			return "", ctx.Err()
+		case <-m.lockClient.CloserChannel:
+			delay.Stop()
+			return "", mongolock.ErrMongoDbClosing
+		}
+	}
 }
 
 // UnlockImage releases an exclusive mongoDB lock for the provided lockId (if it exists)
 func (m *Mongo) UnlockImage(ctx context.Context, lockID string) {
 	m.lockClient.Unlock(ctx, lockID)
+	metrics.ImageLocks.Add(metrics.LocksHeld, -1)
+}
+
+// GetImageLocks returns the image locks that are currently held, by any service instance, sorted by creation time
+func (m *Mongo) GetImageLocks(ctx context.Context) ([]models.ImageLock, error) {
+	statuses, err := m.lockStatusClient.Status(ctx, lock.Filter{})
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := m.lockClient.Resource + "-"
+	now := time.Now().UTC()
+	locks := []models.ImageLock{}
+	for _, status := range statuses {
+		imageID, isImageLock := strings.CutPrefix(status.Resource, prefix)
+		if !isImageLock || serviceLockIDs[imageID] {
+			continue
+		}
+		imageLock := models.ImageLock{
+			ImageID:   imageID,
+			LockID:    status.LockId,
+			Owner:     status.Owner,
+			Host:      status.Host,
+			CreatedAt: status.CreatedAt.UTC(),
+			HeldFor:   now.Sub(status.CreatedAt).Round(time.Millisecond).String(),
+		}
+		if status.TTL >= 0 {
+			expiresAt := now.Add(time.Duration(status.TTL) * time.Second)
+			imageLock.ExpiresAt = &expiresAt
+		}
+		locks = append(locks, imageLock)
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i].CreatedAt.Before(locks[j].CreatedAt) })
+
+	return locks, nil
+}
+
+// getRequestID returns the ID of the request of the provided context, if any
+func getRequestID(ctx context.Context) string {
+	if id, ok := ctx.Value(dpreq.RequestIdKey).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// renewLock extends the expiry of the provided lock by the lock TTL. ErrLockLost is returned if the lock has expired,
+// as another service instance may hold it now.
+func (m *Mongo) renewLock(ctx context.Context, lockID string) error {
+	renewed, err := m.lockRenewClient.Renew(ctx, lockID, mongolock.TTL)
+	if errors.Is(err, lock.ErrLockNotFound) || (err == nil && len(renewed) == 0) {
+		return errs.ErrLockLost
+	}
+	return err
+}
+
+// AcquireSchedulerLock tries to lock the publish scheduler resource, without blocking.
+// If another service instance already holds the lock, ErrLockAlreadyHeld is returned.
+func (m *Mongo) AcquireSchedulerLock(ctx context.Context) (lockID string, err error) {
+	lockID, err = m.lockClient.Lock(ctx, schedulerLockID)
+	if err != nil {
+		if errors.Is(err, lock.ErrAlreadyLocked) {
+			return "", errs.ErrLockAlreadyHeld
+		}
+		return "", err
+	}
+	return lockID, nil
+}
+
+// RenewSchedulerLock extends the expiry of the publish scheduler lock for the provided lockID.
+// ErrLockLost is returned if the lock has already expired.
+func (m *Mongo) RenewSchedulerLock(ctx context.Context, lockID string) error {
+	return m.renewLock(ctx, lockID)
+}
+
+// UnlockScheduler releases the publish scheduler lock for the provided lockID
+func (m *Mongo) UnlockScheduler(ctx context.Context, lockID string) {
+	m.lockClient.Unlock(ctx, lockID)
+}
+
+// AcquireDeadLetterRetrierLock tries to lock the dead letter retrier resource, without blocking.
+// If another service instance already holds the lock, ErrLockAlreadyHeld is returned.
+func (m *Mongo) AcquireDeadLetterRetrierLock(ctx context.Context) (lockID string, err error) {
+	lockID, err = m.lockClient.Lock(ctx, deadLetterRetrierLockID)
+	if err != nil {
+		if errors.Is(err, lock.ErrAlreadyLocked) {
+			return "", errs.ErrLockAlreadyHeld
+		}
+		return "", err
+	}
+	return lockID, nil
+}
+
+// UnlockDeadLetterRetrier releases the dead letter retrier lock for the provided lockID
+func (m *Mongo) UnlockDeadLetterRetrier(ctx context.Context, lockID string) {
+	m.lockClient.Unlock(ctx, lockID)
 }
 
 // Close closes the mongo session and returns any error
@@ -89,6 +263,49 @@ func (m *Mongo) GetImages(ctx context.Context, collectionID string) ([]models.Im
 	return results, nil
 }
 
+// IterateImages calls fn with each image document corresponding to the provided collectionID, or with every image if it is empty.
+// Documents are read one at a time with a cursor, so that memory stays bounded regardless of the number of images.
+// If a document cannot be decoded, fn is called with the decoding error and an image containing only the document ID.
+// Iteration stops as soon as fn returns an error, which is then returned.
+func (m *Mongo) IterateImages(ctx context.Context, collectionID string, fn func(image *models.Image, decodeErr error) error) (err error) {
+	log.Info(ctx, "iterating images for collectionID", log.Data{"collectionID": collectionID})
+
+	filter := make(bson.M)
+	if collectionID != "" {
+		filter["collection_id"] = collectionID
+	}
+
+	cursor, err := m.connection.Collection(m.ActualCollectionName(config.ImagesCollection)).FindCursor(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := cursor.Close(ctx); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	for cursor.Next(ctx) {
+		var image models.Image
+		if decodeErr := cursor.Decode(&image); decodeErr != nil {
+			var doc struct {
+				ID string `bson:"_id"`
+			}
+			_ = cursor.Decode(&doc)
+			image = models.Image{ID: doc.ID}
+			if err := fn(&image, decodeErr); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(&image, nil); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
 // GetImage retrieves an image document by its ID
 func (m *Mongo) GetImage(ctx context.Context, id string) (*models.Image, error) {
 	log.Info(ctx, "getting image by ID", log.Data{"id": id})
@@ -105,6 +322,183 @@ func (m *Mongo) GetImage(ctx context.Context, id string) (*models.Image, error)
 	return &image, nil
 }
 
+// GetImagesScheduledForPublish retrieves all imported images with a scheduled publish time before the provided time
+func (m *Mongo) GetImagesScheduledForPublish(ctx context.Context, before time.Time) ([]models.Image, error) {
+	log.Info(ctx, "getting images scheduled for publish", log.Data{"before": before})
+
+	filter := bson.M{
+		"state":                        models.StateImported.String(),
+		"scheduled_publish.publish_at": bson.M{"$lte": before},
+	}
+
+	var results []models.Image
+	_, err := m.connection.Collection(m.ActualCollectionName(config.ImagesCollection)).Find(ctx, filter, &results)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// GetImagesForReplay retrieves all images matching the provided replay request, which are in one of the replayable states
+func (m *Mongo) GetImagesForReplay(ctx context.Context, req *models.ReplayRequest) ([]models.Image, error) {
+	log.Info(ctx, "getting images for event replay", log.Data{"request": req})
+
+	filter := bson.M{"state": bson.M{"$in": req.States()}}
+	if req.CollectionID != "" {
+		filter["collection_id"] = req.CollectionID
+	}
+	if len(req.ImageIDs) > 0 {
+		filter["_id"] = bson.M{"$in": req.ImageIDs}
+	}
+	if req.From != nil || req.To != nil {
+		lastUpdated := bson.M{}
+		if req.From != nil {
+			lastUpdated["$gte"] = req.From
+		}
+		if req.To != nil {
+			lastUpdated["$lte"] = req.To
+		}
+		filter["last_updated"] = lastUpdated
+	}
+
+	var results []models.Image
+	_, err := m.connection.Collection(m.ActualCollectionName(config.ImagesCollection)).Find(ctx, filter, &results)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// UnsetScheduledPublish removes the scheduled publish from an existing image document
+func (m *Mongo) UnsetScheduledPublish(ctx context.Context, id string) error {
+	log.Info(ctx, "unsetting scheduled publish", log.Data{"id": id})
+
+	update := bson.M{"$unset": bson.M{"scheduled_publish": ""}, "$currentDate": bson.M{"last_updated": true}}
+	if _, err := m.connection.Collection(m.ActualCollectionName(config.ImagesCollection)).Must().UpdateById(ctx, id, update); err != nil {
+		if errors.Is(err, mongodriver.ErrNoDocumentFound) {
+			return errs.ErrImageNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// WithdrawImage applies the provided withdrawal update to an existing image document,
+// removing the public href of every download variant present in the update.
+func (m *Mongo) WithdrawImage(ctx context.Context, id string, image *models.Image) error {
+	log.Info(ctx, "withdrawing image", log.Data{"id": id})
+
+	unsets := bson.M{}
+	for variant := range image.Downloads {
+		unsets[fmt.Sprintf("downloads.%s.href", variant)] = ""
+	}
+
+	update := bson.M{"$set": createImageUpdateQuery(ctx, id, image), "$currentDate": bson.M{"last_updated": true}}
+	if len(unsets) > 0 {
+		update["$unset"] = unsets
+	}
+	if _, err := m.connection.Collection(m.ActualCollectionName(config.ImagesCollection)).Must().UpdateById(ctx, id, update); err != nil {
+		if errors.Is(err, mongodriver.ErrNoDocumentFound) {
+			return errs.ErrImageNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// StartImageRevision archives the provided revision and resets the image document to the 'created' state
+// with the next revision number, so that a new upload and import cycle can start for the same image ID.
+func (m *Mongo) StartImageRevision(ctx context.Context, id string, archived *models.Revision) error {
+	log.Info(ctx, "starting new image revision", log.Data{"id": id, "archived_revision": archived.Revision})
+
+	update := bson.M{
+		"$push": bson.M{"revisions": archived},
+		"$set": bson.M{
+			"state":    models.StateCreated.String(),
+			"revision": archived.Revision + 1,
+		},
+		"$unset": bson.M{
+			"error":             "",
+			"upload":            "",
+			"downloads":         "",
+			"scheduled_publish": "",
+		},
+		"$currentDate": bson.M{"last_updated": true},
+	}
+	if _, err := m.connection.Collection(m.ActualCollectionName(config.ImagesCollection)).Must().UpdateById(ctx, id, update); err != nil {
+		if errors.Is(err, mongodriver.ErrNoDocumentFound) {
+			return errs.ErrImageNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// CreateImageVersion stores the provided image version, only if it does not exist yet, so that versions are immutable
+func (m *Mongo) CreateImageVersion(ctx context.Context, version *models.Version) error {
+	log.Info(ctx, "creating image version", log.Data{"image_id": version.ImageID, "version": version.Version})
+
+	update := bson.M{"$setOnInsert": version}
+	if _, err := m.connection.Collection(m.ActualCollectionName(config.ImagesVersionsCollection)).UpsertById(ctx, version.ID, update); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetImageVersion retrieves an image version document by its image ID and version number
+func (m *Mongo) GetImageVersion(ctx context.Context, imageID string, version int) (*models.Version, error) {
+	log.Info(ctx, "getting image version", log.Data{"image_id": imageID, "version": version})
+
+	var imageVersion models.Version
+	err := m.connection.Collection(m.ActualCollectionName(config.ImagesVersionsCollection)).FindOne(ctx, bson.M{"image_id": imageID, "version": version}, &imageVersion)
+	if err != nil {
+		if errors.Is(err, mongodriver.ErrNoDocumentFound) {
+			return nil, errs.ErrImageVersionNotFound
+		}
+		return nil, err
+	}
+
+	return &imageVersion, nil
+}
+
+// WithdrawImageVersions marks every version of the provided image as withdrawn and clears the hrefs of their download variants.
+// The versions are returned as they were before being withdrawn, so that the files of every version can be removed.
+func (m *Mongo) WithdrawImageVersions(ctx context.Context, imageID string) ([]models.Version, error) {
+	log.Info(ctx, "withdrawing image versions", log.Data{"image_id": imageID})
+
+	collection := m.connection.Collection(m.ActualCollectionName(config.ImagesVersionsCollection))
+	var versions []models.Version
+	if _, err := collection.Find(ctx, bson.M{"image_id": imageID}, &versions); err != nil {
+		return nil, err
+	}
+	for i := range versions {
+		if _, err := collection.UpdateById(ctx, versions[i].ID, withdrawVersionUpdate(&versions[i])); err != nil {
+			return nil, err
+		}
+	}
+
+	return versions, nil
+}
+
+// withdrawVersionUpdate returns the update that marks the provided image version as withdrawn and clears its download hrefs
+func withdrawVersionUpdate(version *models.Version) bson.M {
+	update := bson.M{"$set": bson.M{"state": models.StateWithdrawn.String()}}
+	unsets := bson.M{}
+	for variant := range version.Downloads {
+		unsets[fmt.Sprintf("downloads.%s.href", variant)] = ""
+	}
+	if len(unsets) > 0 {
+		update["$unset"] = unsets
+	}
+	return update
+}
+
 // UpdateImage updates an existing image document
 func (m *Mongo) UpdateImage(ctx context.Context, id string, image *models.Image) (bool, error) {
 	log.Info(ctx, "updating image", log.Data{"id": id})
@@ -115,7 +509,12 @@ func (m *Mongo) UpdateImage(ctx context.Context, id string, image *models.Image)
 		return false, nil
 	}
 
-	update := bson.M{"$set": updates, "$currentDate": bson.M{"last_updated": true}}
+	currentDate := bson.M{"last_updated": true}
+	if image.State == models.StateDeleted.String() {
+		// deleted_at is the date indexed by the TTL index that removes deleted images
+		currentDate["deleted_at"] = true
+	}
+	update := bson.M{"$set": updates, "$currentDate": currentDate}
 	if _, err := m.connection.Collection(m.ActualCollectionName(config.ImagesCollection)).Must().UpdateById(ctx, id, update); err != nil {
 		if errors.Is(err, mongodriver.ErrNoDocumentFound) {
 			return false, errs.ErrImageNotFound
@@ -166,6 +565,16 @@ func createImageUpdateQuery(ctx context.Context, id string, image *models.Image)
 		}
 	}
 
+	if image.ScheduledPublish != nil {
+		if image.ScheduledPublish.PublishAt != nil {
+			updates["scheduled_publish"] = image.ScheduledPublish
+		}
+	}
+
+	if image.Withdrawal != nil {
+		updates["withdrawal"] = image.Withdrawal
+	}
+
 	if image.Downloads != nil {
 		for i := range image.Downloads {
 			variant := i
@@ -185,6 +594,12 @@ func createImageUpdateQuery(ctx context.Context, id string, image *models.Image)
 			if download.Height != nil {
 				updates[fmt.Sprintf("downloads.%s.height", variant)] = download.Height
 			}
+			if download.Sha256 != "" {
+				updates[fmt.Sprintf("downloads.%s.sha256", variant)] = download.Sha256
+			}
+			if download.ContentType != "" {
+				updates[fmt.Sprintf("downloads.%s.content_type", variant)] = download.ContentType
+			}
 			if download.Links != nil {
 				updates[fmt.Sprintf("downloads.%s.links", variant)] = download.Links
 			}
@@ -221,13 +636,123 @@ func createImageUpdateQuery(ctx context.Context, id string, image *models.Image)
 func (m *Mongo) UpsertImage(ctx context.Context, id string, image *models.Image) (err error) {
 	log.Info(ctx, "upserting image", log.Data{"id": id})
 
+	// last_updated is set to the current date, so the date read with the image is not written back
+	upsert := *image
+	upsert.LastUpdated = nil
 	update := bson.M{
-		"$set": image,
-		"$setOnInsert": bson.M{
-			"last_updated": time.Now(),
-		},
+		"$set":         &upsert,
+		"$currentDate": bson.M{"last_updated": true},
+	}
+	if image.State == models.StateDeleted.String() {
+		// keep the original deletion date of images that were already deleted
+		update["$min"] = bson.M{"deleted_at": time.Now()}
 	}
 
 	_, err = m.connection.Collection(m.ActualCollectionName(config.ImagesCollection)).UpsertById(ctx, id, update)
 	return
 }
+
+// DeleteImage removes an image document by its ID
+func (m *Mongo) DeleteImage(ctx context.Context, id string) error {
+	log.Info(ctx, "deleting image", log.Data{"id": id})
+
+	if _, err := m.connection.Collection(m.ActualCollectionName(config.ImagesCollection)).Must().DeleteById(ctx, id); err != nil {
+		if errors.Is(err, mongodriver.ErrNoDocumentFound) {
+			return errs.ErrImageNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// CreateDeadLetter stores the provided dead letter
+func (m *Mongo) CreateDeadLetter(ctx context.Context, deadLetter *models.DeadLetter) error {
+	log.Info(ctx, "creating dead letter", log.Data{"id": deadLetter.ID, "event_type": deadLetter.EventType, "image_id": deadLetter.ImageID})
+
+	if _, err := m.connection.Collection(m.ActualCollectionName(config.DeadLettersCollection)).Insert(ctx, deadLetter); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetDeadLetters retrieves all the dead letter documents
+func (m *Mongo) GetDeadLetters(ctx context.Context) ([]models.DeadLetter, error) {
+	log.Info(ctx, "getting dead letters")
+
+	var results []models.DeadLetter
+	if _, err := m.connection.Collection(m.ActualCollectionName(config.DeadLettersCollection)).Find(ctx, bson.M{}, &results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// GetDeadLetter retrieves a dead letter document by its ID
+func (m *Mongo) GetDeadLetter(ctx context.Context, id string) (*models.DeadLetter, error) {
+	log.Info(ctx, "getting dead letter by ID", log.Data{"id": id})
+
+	var deadLetter models.DeadLetter
+	err := m.connection.Collection(m.ActualCollectionName(config.DeadLettersCollection)).FindOne(ctx, bson.M{"_id": id}, &deadLetter)
+	if err != nil {
+		if errors.Is(err, mongodriver.ErrNoDocumentFound) {
+			return nil, errs.ErrDeadLetterNotFound
+		}
+		return nil, err
+	}
+
+	return &deadLetter, nil
+}
+
+// GetDeadLettersDueForRetry retrieves the dead letters whose next attempt is before the provided time,
+// and that have been attempted less than maxAttempts times
+func (m *Mongo) GetDeadLettersDueForRetry(ctx context.Context, before time.Time, maxAttempts int) ([]models.DeadLetter, error) {
+	log.Info(ctx, "getting dead letters due for retry", log.Data{"before": before, "max_attempts": maxAttempts})
+
+	filter := bson.M{
+		"next_attempt": bson.M{"$lte": before},
+		"attempts":     bson.M{"$lt": maxAttempts},
+	}
+
+	var results []models.DeadLetter
+	if _, err := m.connection.Collection(m.ActualCollectionName(config.DeadLettersCollection)).Find(ctx, filter, &results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// UpdateDeadLetterAttempt stores the attempts, error and attempt times of the provided dead letter
+func (m *Mongo) UpdateDeadLetterAttempt(ctx context.Context, deadLetter *models.DeadLetter) error {
+	log.Info(ctx, "updating dead letter attempt", log.Data{"id": deadLetter.ID, "attempts": deadLetter.Attempts})
+
+	update := bson.M{"$set": bson.M{
+		"attempts":     deadLetter.Attempts,
+		"error":        deadLetter.Error,
+		"last_attempt": deadLetter.LastAttempt,
+		"next_attempt": deadLetter.NextAttempt,
+	}}
+	if _, err := m.connection.Collection(m.ActualCollectionName(config.DeadLettersCollection)).Must().UpdateById(ctx, deadLetter.ID, update); err != nil {
+		if errors.Is(err, mongodriver.ErrNoDocumentFound) {
+			return errs.ErrDeadLetterNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// DeleteDeadLetter removes a dead letter document by its ID
+func (m *Mongo) DeleteDeadLetter(ctx context.Context, id string) error {
+	log.Info(ctx, "deleting dead letter", log.Data{"id": id})
+
+	if _, err := m.connection.Collection(m.ActualCollectionName(config.DeadLettersCollection)).Must().DeleteById(ctx, id); err != nil {
+		if errors.Is(err, mongodriver.ErrNoDocumentFound) {
+			return errs.ErrDeadLetterNotFound
+		}
+		return err
+	}
+
+	return nil
+}
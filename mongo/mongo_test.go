@@ -0,0 +1,37 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/ONSdigital/dp-image-api/models"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestWithdrawVersionUpdate(t *testing.T) {
+	Convey("Given an image version with two download variants", t, func() {
+		version := &models.Version{
+			ID:      "123-1",
+			Version: 1,
+			Downloads: map[string]models.Download{
+				"original": {ID: "original", Href: "http://download/images/123/original/1/image.png"},
+				"png_w500": {ID: "png_w500", Href: "http://download/images/123/png_w500/1/image.png"},
+			},
+		}
+
+		Convey("Then the version is marked as withdrawn, and the href of each variant is cleared", func() {
+			So(withdrawVersionUpdate(version), ShouldResemble, bson.M{
+				"$set":   bson.M{"state": "withdrawn"},
+				"$unset": bson.M{"downloads.original.href": "", "downloads.png_w500.href": ""},
+			})
+		})
+	})
+
+	Convey("Given an image version without download variants", t, func() {
+		version := &models.Version{ID: "123-1", Version: 1}
+
+		Convey("Then the version is only marked as withdrawn", func() {
+			So(withdrawVersionUpdate(version), ShouldResemble, bson.M{"$set": bson.M{"state": "withdrawn"}})
+		})
+	})
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/dp-image-api/api"
+	"github.com/ONSdigital/dp-image-api/config"
+	"github.com/ONSdigital/dp-image-api/models"
+	"github.com/ONSdigital/dp-image-api/service"
+	kafka "github.com/ONSdigital/dp-kafka/v3"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/pkg/errors"
+)
+
+const replayEventsCommand = "replay-events"
+
+// runReplayEvents re-emits the kafka events of the images stuck in 'uploaded' or 'published' state that match the filters
+// provided as command line arguments, and writes the replay result as json to stdout
+func runReplayEvents(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet(replayEventsCommand, flag.ContinueOnError)
+	collectionID := flags.String("collection", "", "only replay events of images in this collection")
+	state := flags.String("state", "", "only replay events of images in this state: 'uploaded' or 'published' (default both)")
+	from := flags.String("from", "", "only replay events of images last updated at or after this time (RFC3339)")
+	to := flags.String("to", "", "only replay events of images last updated at or before this time (RFC3339)")
+	ids := flags.String("ids", "", "only replay events of these comma separated image IDs")
+	dryRun := flags.Bool("dry-run", false, "print the events that would be replayed without sending them")
+	rate := flags.Int("rate", 0, "maximum number of events sent per second (default EVENT_REPLAY_RATE)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	req := &models.ReplayRequest{
+		CollectionID: *collectionID,
+		State:        *state,
+		DryRun:       *dryRun,
+	}
+	if *ids != "" {
+		req.ImageIDs = strings.Split(*ids, ",")
+	}
+	var err error
+	if req.From, err = parseTimeFlag("from", *from); err != nil {
+		return err
+	}
+	if req.To, err = parseTimeFlag("to", *to); err != nil {
+		return err
+	}
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	cfg, err := config.Get()
+	if err != nil {
+		return errors.Wrap(err, "unable to retrieve service configuration")
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if *rate > 0 {
+		cfg.EventReplayRate = *rate
+	}
+
+	svcList := service.NewServiceList(&service.Init{})
+
+	mongoDB, err := svcList.GetMongoDB(ctx, cfg.MongoConfig)
+	if err != nil {
+		return errors.Wrap(err, "could not obtain mongo session")
+	}
+	defer func() {
+		if err := mongoDB.Close(ctx); err != nil {
+			log.Error(ctx, "error closing mongo db", err)
+		}
+	}()
+
+	// Kafka producers are only needed if the events are actually sent
+	var uploadedProducer, publishedProducer kafka.IProducer
+	if !req.DryRun {
+		if uploadedProducer, err = svcList.GetInitialisedKafkaProducer(ctx, cfg, service.KafkaProducerUploaded); err != nil {
+			return errors.Wrap(err, "could not obtain image uploaded kafka producer")
+		}
+		defer service.CloseKafkaProducer(ctx, uploadedProducer)
+		if publishedProducer, err = svcList.GetInitialisedKafkaProducer(ctx, cfg, service.KafkaProducerPublished); err != nil {
+			return errors.Wrap(err, "could not obtain image published kafka producer")
+		}
+		defer service.CloseKafkaProducer(ctx, publishedProducer)
+	}
+
+	result, err := api.NewEventReplayer(cfg, mongoDB, uploadedProducer, publishedProducer).Replay(ctx, req)
+	if err != nil {
+		return errors.Wrap(err, "failed to replay events")
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		return err
+	}
+	if result.Failed > 0 {
+		return errors.Errorf("%d of %d events could not be replayed", result.Failed, result.Count)
+	}
+	return nil
+}
+
+// parseTimeFlag parses the value of the named flag as an RFC3339 time, returning nil if the value is empty
+func parseTimeFlag(name, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid '%s' time", name)
+	}
+	return &t, nil
+}
@@ -0,0 +1,263 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// primitive Avro types
+var primitives = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true, "float": true, "double": true, "bytes": true, "string": true,
+}
+
+// promotions contains, for each writer type, the reader types it can be promoted to, as defined by the Avro schema resolution rules
+var promotions = map[string][]string{
+	"int":    {"long", "float", "double"},
+	"long":   {"float", "double"},
+	"float":  {"double"},
+	"string": {"bytes"},
+	"bytes":  {"string"},
+}
+
+// CheckCompatibility checks that data written with the writer schema definition can be read with the reader schema definition,
+// following the Avro schema resolution rules. It returns an error describing the first incompatibility found, if any.
+func CheckCompatibility(reader, writer string) error {
+	var r, w interface{}
+	if err := json.Unmarshal([]byte(reader), &r); err != nil {
+		return fmt.Errorf("invalid reader schema: %w", err)
+	}
+	if err := json.Unmarshal([]byte(writer), &w); err != nil {
+		return fmt.Errorf("invalid writer schema: %w", err)
+	}
+	c := &checker{
+		readerNames: map[string]interface{}{},
+		writerNames: map[string]interface{}{},
+		checked:     map[string]bool{},
+	}
+	return c.check(r, w, "")
+}
+
+// CheckVersions checks that every version of each subject in the provided versions can read the data written with the previous version
+func CheckVersions(versions map[string][]*Versioned) error {
+	subjects := make([]string, 0, len(versions))
+	for subject := range versions {
+		subjects = append(subjects, subject)
+	}
+	sort.Strings(subjects)
+
+	for _, subject := range subjects {
+		for i := 1; i < len(versions[subject]); i++ {
+			previous, current := versions[subject][i-1], versions[subject][i]
+			if current.Version != previous.Version+1 {
+				return fmt.Errorf("schema %s follows %s, but versions must be consecutive", current.Name(), previous.Name())
+			}
+			if err := CheckCompatibility(current.Definition, previous.Definition); err != nil {
+				return fmt.Errorf("schema %s cannot read data written with %s: %w", current.Name(), previous.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// checker keeps track of the named types defined in the reader and writer schemas
+type checker struct {
+	readerNames map[string]interface{}
+	writerNames map[string]interface{}
+	checked     map[string]bool
+}
+
+// check checks that the writer type can be read as the reader type
+func (c *checker) check(reader, writer interface{}, path string) error {
+	reader = resolve(reader, c.readerNames)
+	writer = resolve(writer, c.writerNames)
+
+	// every branch of a writer union must be readable
+	if union, ok := writer.([]interface{}); ok {
+		for _, w := range union {
+			if err := c.check(reader, w, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// at least one branch of a reader union must be able to read the writer type
+	if union, ok := reader.([]interface{}); ok {
+		for _, r := range union {
+			if c.check(r, writer, path) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: no type in reader union can read writer type '%s'", location(path), typeOf(writer))
+	}
+
+	readerType, writerType := typeOf(reader), typeOf(writer)
+	if readerType != writerType {
+		for _, promoted := range promotions[writerType] {
+			if promoted == readerType {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: reader type '%s' cannot read writer type '%s'", location(path), readerType, writerType)
+	}
+
+	switch readerType {
+	case "record":
+		return c.checkRecord(reader.(map[string]interface{}), writer.(map[string]interface{}), path)
+	case "enum":
+		return checkEnum(reader.(map[string]interface{}), writer.(map[string]interface{}), path)
+	case "fixed":
+		r, w := reader.(map[string]interface{}), writer.(map[string]interface{})
+		if r["name"] != w["name"] || r["size"] != w["size"] {
+			return fmt.Errorf("%s: fixed types do not match in name or size", location(path))
+		}
+	case "array":
+		return c.check(reader.(map[string]interface{})["items"], writer.(map[string]interface{})["items"], path+"[]")
+	case "map":
+		return c.check(reader.(map[string]interface{})["values"], writer.(map[string]interface{})["values"], path+"{}")
+	}
+	return nil
+}
+
+// checkRecord checks that all the reader fields are present in the writer record with a compatible type, or have a default value
+func (c *checker) checkRecord(reader, writer map[string]interface{}, path string) error {
+	readerName, _ := reader["name"].(string)
+	writerName, _ := writer["name"].(string)
+	if readerName != writerName && !contains(reader["aliases"], writerName) {
+		return fmt.Errorf("%s: reader record '%s' does not match writer record '%s'", location(path), readerName, writerName)
+	}
+
+	// avoid infinite recursion for recursive records
+	key := readerName + "<-" + writerName
+	if c.checked[key] {
+		return nil
+	}
+	c.checked[key] = true
+
+	writerFields := map[string]map[string]interface{}{}
+	for _, f := range fields(writer) {
+		name, _ := f["name"].(string)
+		writerFields[name] = f
+	}
+
+	for _, readerField := range fields(reader) {
+		name, _ := readerField["name"].(string)
+		writerField, found := writerFields[name]
+		if !found {
+			for _, alias := range strings.Fields(aliases(readerField["aliases"])) {
+				if writerField, found = writerFields[alias]; found {
+					break
+				}
+			}
+		}
+		if !found {
+			if _, hasDefault := readerField["default"]; !hasDefault {
+				return fmt.Errorf("%s: reader field '%s' is missing in the writer schema and has no default value", location(path), name)
+			}
+			continue
+		}
+		if err := c.check(readerField["type"], writerField["type"], path+"."+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkEnum checks that all the writer symbols are present in the reader enum, unless the reader enum has a default symbol
+func checkEnum(reader, writer map[string]interface{}, path string) error {
+	if _, hasDefault := reader["default"]; hasDefault {
+		return nil
+	}
+	readerSymbols, _ := reader["symbols"].([]interface{})
+	writerSymbols, _ := writer["symbols"].([]interface{})
+	for _, symbol := range writerSymbols {
+		if !contains(readerSymbols, symbol) {
+			return fmt.Errorf("%s: writer enum symbol '%v' is missing in the reader enum", location(path), symbol)
+		}
+	}
+	return nil
+}
+
+// resolve returns the definition of the provided type, replacing references to named types by their definition
+// and registering any named type that is defined.
+func resolve(t interface{}, names map[string]interface{}) interface{} {
+	switch v := t.(type) {
+	case string:
+		if !primitives[v] {
+			if named, ok := names[v]; ok {
+				return named
+			}
+		}
+	case map[string]interface{}:
+		switch inner := v["type"].(type) {
+		case string:
+			if name, ok := v["name"].(string); ok && (inner == "record" || inner == "enum" || inner == "fixed") {
+				names[name] = v
+			} else if primitives[inner] || names[inner] != nil {
+				return resolve(inner, names)
+			}
+		default:
+			return resolve(inner, names)
+		}
+	}
+	return t
+}
+
+// typeOf returns the Avro type name of the provided resolved type definition
+func typeOf(t interface{}) string {
+	switch v := t.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		if s, ok := v["type"].(string); ok {
+			return s
+		}
+	case []interface{}:
+		return "union"
+	}
+	return fmt.Sprintf("%v", t)
+}
+
+// fields returns the fields of the provided record definition
+func fields(record map[string]interface{}) (result []map[string]interface{}) {
+	list, _ := record["fields"].([]interface{})
+	for _, f := range list {
+		if field, ok := f.(map[string]interface{}); ok {
+			result = append(result, field)
+		}
+	}
+	return result
+}
+
+// aliases returns the provided aliases list as a space separated string
+func aliases(list interface{}) string {
+	values, _ := list.([]interface{})
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return strings.Join(result, " ")
+}
+
+// contains returns true if the provided list contains the provided value
+func contains(list, value interface{}) bool {
+	values, _ := list.([]interface{})
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// location returns a human readable location for the provided field path
+func location(path string) string {
+	if path == "" {
+		return "root"
+	}
+	return strings.TrimPrefix(path, ".")
+}
@@ -0,0 +1,149 @@
+package schema_test
+
+import (
+	"testing"
+
+	"github.com/ONSdigital/dp-image-api/schema"
+	"github.com/ONSdigital/dp-kafka/v3/avro"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testWriter = `{
+  "type": "record",
+  "name": "image-test",
+  "fields": [
+    {"name": "image_id", "type": "string", "default": ""},
+    {"name": "count", "type": "int", "default": 0},
+    {"name": "state", "type": {"type": "enum", "name": "state", "symbols": ["created", "published"]}},
+    {"name": "tags", "type": {"type": "array", "items": "string"}}
+  ]
+}`
+
+func TestRegisteredVersions(t *testing.T) {
+	Convey("Every registered schema version can read the data written with its previous version", t, func() {
+		So(schema.CheckVersions(schema.Versions), ShouldBeNil)
+	})
+
+	Convey("Every registered schema version is a valid avro schema whose record name is its subject", t, func() {
+		for subject, versions := range schema.Versions {
+			So(versions, ShouldNotBeEmpty)
+			for i, v := range versions {
+				So(v.Subject, ShouldEqual, subject)
+				So(v.Version, ShouldEqual, i+1)
+				So(v.Definition, ShouldContainSubstring, `"name": "`+subject+`"`)
+				_, err := v.Marshal(&struct{}{})
+				So(err, ShouldBeNil)
+			}
+		}
+	})
+
+	Convey("The exported schemas are the latest version of each subject", t, func() {
+		So(schema.ImageUploadedEvent, ShouldEqual, schema.Latest("image-uploaded").Schema)
+		So(schema.ImagePublishedEvent, ShouldEqual, schema.Latest("image-published").Schema)
+		So(schema.ImageWithdrawnEvent, ShouldEqual, schema.Latest("image-withdrawn").Schema)
+		So(schema.ImageStateChangedEvent, ShouldEqual, schema.Latest("image-state-changed").Schema)
+		So(schema.Latest("image-published").Name(), ShouldEqual, "image-published.v2")
+		So(schema.Latest("unknown"), ShouldBeNil)
+	})
+}
+
+func TestCheckVersions(t *testing.T) {
+	Convey("Given a subject whose new version adds a field without a default value", t, func() {
+		versions := map[string][]*schema.Versioned{
+			"image-test": {
+				{Subject: "image-test", Version: 1, Schema: &avro.Schema{Definition: `{"type": "record", "name": "image-test", "fields": [{"name": "image_id", "type": "string"}]}`}},
+				{Subject: "image-test", Version: 2, Schema: &avro.Schema{Definition: `{"type": "record", "name": "image-test", "fields": [{"name": "image_id", "type": "string"}, {"name": "path", "type": "string"}]}`}},
+			},
+		}
+
+		Convey("Then checking the versions fails with an error naming both versions", func() {
+			err := schema.CheckVersions(versions)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "schema image-test.v2 cannot read data written with image-test.v1: root: reader field 'path' is missing in the writer schema and has no default value")
+		})
+	})
+
+	Convey("Given a subject with non consecutive versions", t, func() {
+		versions := map[string][]*schema.Versioned{
+			"image-test": {
+				{Subject: "image-test", Version: 1, Schema: &avro.Schema{Definition: testWriter}},
+				{Subject: "image-test", Version: 3, Schema: &avro.Schema{Definition: testWriter}},
+			},
+		}
+
+		Convey("Then checking the versions fails", func() {
+			So(schema.CheckVersions(versions), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	Convey("Compatible schema changes are accepted", t, func() {
+		for _, tc := range []struct{ description, reader string }{
+			{"identical schema", testWriter},
+			{"removed field", `{"type": "record", "name": "image-test", "fields": [
+				{"name": "image_id", "type": "string"}
+			]}`},
+			{"added field with default", `{"type": "record", "name": "image-test", "fields": [
+				{"name": "image_id", "type": "string"},
+				{"name": "path", "type": "string", "default": ""}
+			]}`},
+			{"promoted int to long", `{"type": "record", "name": "image-test", "fields": [
+				{"name": "count", "type": "long"}
+			]}`},
+			{"field made nullable", `{"type": "record", "name": "image-test", "fields": [
+				{"name": "image_id", "type": ["null", "string"]}
+			]}`},
+			{"renamed field with alias", `{"type": "record", "name": "image-test", "fields": [
+				{"name": "id", "aliases": ["image_id"], "type": "string"}
+			]}`},
+			{"renamed record with alias", `{"type": "record", "name": "image", "aliases": ["image-test"], "fields": []}`},
+			{"added enum symbol", `{"type": "record", "name": "image-test", "fields": [
+				{"name": "state", "type": {"type": "enum", "name": "state", "symbols": ["created", "published", "withdrawn"]}}
+			]}`},
+		} {
+			Convey(tc.description, func() {
+				So(schema.CheckCompatibility(tc.reader, testWriter), ShouldBeNil)
+			})
+		}
+	})
+
+	Convey("Incompatible schema changes are rejected", t, func() {
+		for _, tc := range []struct{ description, reader string }{
+			{"added field without default", `{"type": "record", "name": "image-test", "fields": [
+				{"name": "path", "type": "string"}
+			]}`},
+			{"changed field type", `{"type": "record", "name": "image-test", "fields": [
+				{"name": "image_id", "type": "int"}
+			]}`},
+			{"changed int to boolean", `{"type": "record", "name": "image-test", "fields": [
+				{"name": "image_id", "type": "string"},
+				{"name": "count", "type": "boolean"}
+			]}`},
+			{"renamed record", `{"type": "record", "name": "image", "fields": []}`},
+			{"removed enum symbol", `{"type": "record", "name": "image-test", "fields": [
+				{"name": "state", "type": {"type": "enum", "name": "state", "symbols": ["created"]}}
+			]}`},
+			{"changed array items", `{"type": "record", "name": "image-test", "fields": [
+				{"name": "tags", "type": {"type": "array", "items": "int"}}
+			]}`},
+		} {
+			Convey(tc.description, func() {
+				So(schema.CheckCompatibility(tc.reader, testWriter), ShouldNotBeNil)
+			})
+		}
+	})
+
+	Convey("A writer union can only be read if all its branches can be read", t, func() {
+		writer := `{"type": "record", "name": "image-test", "fields": [{"name": "image_id", "type": ["null", "string"]}]}`
+		reader := `{"type": "record", "name": "image-test", "fields": [{"name": "image_id", "type": "string"}]}`
+		err := schema.CheckCompatibility(reader, writer)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "image_id: reader type 'string' cannot read writer type 'null'")
+	})
+
+	Convey("Invalid schema definitions result in an error", t, func() {
+		So(schema.CheckCompatibility("{", testWriter), ShouldNotBeNil)
+		So(schema.CheckCompatibility(testWriter, "{"), ShouldNotBeNil)
+	})
+}
@@ -1,10 +1,28 @@
 package schema
 
 import (
+	"fmt"
+
 	"github.com/ONSdigital/dp-kafka/v3/avro"
 )
 
-var imageUploadedEvent = `{
+// Versioned is a version of an Avro schema. New versions of a schema must be able to read the data written with the previous version.
+type Versioned struct {
+	Subject string
+	Version int
+	*avro.Schema
+}
+
+// Name returns the versioned name of the schema, for example 'image-uploaded.v1'
+func (v *Versioned) Name() string {
+	return fmt.Sprintf("%s.v%d", v.Subject, v.Version)
+}
+
+var imageUploadedV1 = &Versioned{
+	Subject: "image-uploaded",
+	Version: 1,
+	Schema: &avro.Schema{
+		Definition: `{
   "type": "record",
   "name": "image-uploaded",
   "fields": [
@@ -12,9 +30,15 @@ var imageUploadedEvent = `{
     {"name": "path", "type": "string", "default": ""},
     {"name": "filename", "type": "string", "default": ""}
   ]
-}`
+}`,
+	},
+}
 
-var imagePublishedEvent = `{
+var imagePublishedV1 = &Versioned{
+	Subject: "image-published",
+	Version: 1,
+	Schema: &avro.Schema{
+		Definition: `{
   "type": "record",
   "name": "image-published",
   "fields": [
@@ -23,14 +47,90 @@ var imagePublishedEvent = `{
     {"name": "image_id", "type": "string", "default": ""},
     {"name": "image_variant", "type": "string", "default": ""}
   ]
-}`
+}`,
+	},
+}
 
-// ImageUploadedEvent is the Avro schema for Image uploaded messages.
-var ImageUploadedEvent = &avro.Schema{
-	Definition: imageUploadedEvent,
+// imagePublishedV2 adds the checksum and content type of the published variant
+var imagePublishedV2 = &Versioned{
+	Subject: "image-published",
+	Version: 2,
+	Schema: &avro.Schema{
+		Definition: `{
+  "type": "record",
+  "name": "image-published",
+  "fields": [
+    {"name": "src_path", "type": "string", "default": ""},
+    {"name": "dst_path", "type": "string", "default": ""},
+    {"name": "image_id", "type": "string", "default": ""},
+    {"name": "image_variant", "type": "string", "default": ""},
+    {"name": "sha256", "type": "string", "default": ""},
+    {"name": "content_type", "type": "string", "default": ""}
+  ]
+}`,
+	},
 }
 
-// ImagePublishedEvent is the Avro schema for Image published messages.
-var ImagePublishedEvent = &avro.Schema{
-	Definition: imagePublishedEvent,
+var imageWithdrawnV1 = &Versioned{
+	Subject: "image-withdrawn",
+	Version: 1,
+	Schema: &avro.Schema{
+		Definition: `{
+  "type": "record",
+  "name": "image-withdrawn",
+  "fields": [
+    {"name": "path", "type": "string", "default": ""},
+    {"name": "image_id", "type": "string", "default": ""},
+    {"name": "image_variant", "type": "string", "default": ""}
+  ]
+}`,
+	},
+}
+
+var imageStateChangedV1 = &Versioned{
+	Subject: "image-state-changed",
+	Version: 1,
+	Schema: &avro.Schema{
+		Definition: `{
+  "type": "record",
+  "name": "image-state-changed",
+  "fields": [
+    {"name": "image_id", "type": "string", "default": ""},
+    {"name": "collection_id", "type": "string", "default": ""},
+    {"name": "image_variant", "type": "string", "default": ""},
+    {"name": "previous_state", "type": "string", "default": ""},
+    {"name": "state", "type": "string", "default": ""}
+  ]
+}`,
+	},
 }
+
+// Versions contains all the versions of each schema subject, from oldest to newest.
+// New versions must be appended, and the latest version of each subject is the one used to produce events.
+var Versions = map[string][]*Versioned{
+	imageUploadedV1.Subject:     {imageUploadedV1},
+	imagePublishedV1.Subject:    {imagePublishedV1, imagePublishedV2},
+	imageWithdrawnV1.Subject:    {imageWithdrawnV1},
+	imageStateChangedV1.Subject: {imageStateChangedV1},
+}
+
+// Latest returns the latest version of the provided schema subject
+func Latest(subject string) *Versioned {
+	versions := Versions[subject]
+	if len(versions) == 0 {
+		return nil
+	}
+	return versions[len(versions)-1]
+}
+
+// ImageUploadedEvent is the Avro schema for Image uploaded messages.
+var ImageUploadedEvent = Latest("image-uploaded").Schema
+
+// ImagePublishedEvent is the Avro schema for Image published messages.
+var ImagePublishedEvent = Latest("image-published").Schema
+
+// ImageWithdrawnEvent is the Avro schema for Image withdrawn messages.
+var ImageWithdrawnEvent = Latest("image-withdrawn").Schema
+
+// ImageStateChangedEvent is the Avro schema for Image state changed messages.
+var ImageStateChangedEvent = Latest("image-state-changed").Schema
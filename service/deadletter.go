@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/dp-image-api/api"
+	"github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// DeadLetterRetrier periodically replays the events that could not be sent to kafka, once their next attempt is due.
+// The backoff between attempts is computed when each attempt fails, and a MongoDB lock is used to elect a single instance to retry on each tick.
+type DeadLetterRetrier struct {
+	mongoDB     api.MongoServer
+	replayer    DeadLetterReplayer
+	interval    time.Duration
+	maxAttempts int
+	stop        chan struct{}
+	wg          sync.WaitGroup
+}
+
+// NewDeadLetterRetrier creates a new DeadLetterRetrier that checks for due dead letters on the provided interval,
+// giving up on the dead letters that have been attempted maxAttempts times
+func NewDeadLetterRetrier(mongoDB api.MongoServer, replayer DeadLetterReplayer, interval time.Duration, maxAttempts int) *DeadLetterRetrier {
+	return &DeadLetterRetrier{
+		mongoDB:     mongoDB,
+		replayer:    replayer,
+		interval:    interval,
+		maxAttempts: maxAttempts,
+		stop:        make(chan struct{}),
+	}
+}
+
+// Start runs the retrier in a new go-routine until Close is called
+func (r *DeadLetterRetrier) Start(ctx context.Context) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.RetryDueDeadLetters(ctx)
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+	log.Info(ctx, "dead letter retrier started", log.Data{"interval": r.interval.String(), "max_attempts": r.maxAttempts})
+}
+
+// Close stops the retrier and waits for any in-flight retry to finish
+func (r *DeadLetterRetrier) Close(ctx context.Context) error {
+	close(r.stop)
+	r.wg.Wait()
+	log.Info(ctx, "dead letter retrier stopped")
+	return nil
+}
+
+// RetryDueDeadLetters replays all the dead letters whose next attempt is before now.
+// If another instance holds the retrier lock, nothing is done.
+func (r *DeadLetterRetrier) RetryDueDeadLetters(ctx context.Context) {
+	lockID, err := r.mongoDB.AcquireDeadLetterRetrierLock(ctx)
+	if err != nil {
+		if !errors.Is(err, apierrors.ErrLockAlreadyHeld) {
+			log.Error(ctx, "failed to acquire dead letter retrier lock", err)
+		}
+		return
+	}
+	defer r.mongoDB.UnlockDeadLetterRetrier(ctx, lockID)
+
+	deadLetters, err := r.mongoDB.GetDeadLettersDueForRetry(ctx, time.Now().UTC(), r.maxAttempts)
+	if err != nil {
+		log.Error(ctx, "failed to get dead letters due for retry", err)
+		return
+	}
+
+	for i := range deadLetters {
+		logdata := log.Data{"dead-letter-id": deadLetters[i].ID, "event_type": deadLetters[i].EventType, "image_id": deadLetters[i].ImageID}
+		if err := r.replayer.ReplayDeadLetter(ctx, &deadLetters[i]); err != nil {
+			logdata["attempts"] = deadLetters[i].Attempts
+			log.Error(ctx, "failed to replay dead letter", err, logdata)
+			continue
+		}
+		log.Info(ctx, "dead letter replayed", logdata)
+	}
+}
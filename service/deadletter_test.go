@@ -0,0 +1,101 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apiMock "github.com/ONSdigital/dp-image-api/api/mock"
+	"github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/dp-image-api/models"
+	"github.com/ONSdigital/dp-image-api/service"
+	serviceMock "github.com/ONSdigital/dp-image-api/service/mock"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	testRetrierLockID         = "retrierLockID"
+	testDeadLetterMaxAttempts = 5
+)
+
+var errReplay = errors.New("replay error")
+
+func TestRetryDueDeadLetters(t *testing.T) {
+	Convey("Given a mongoDB with two dead letters due for retry", t, func() {
+		mongoDBMock := &apiMock.MongoServerMock{
+			AcquireDeadLetterRetrierLockFunc: func(ctx context.Context) (string, error) { return testRetrierLockID, nil },
+			UnlockDeadLetterRetrierFunc:      func(ctx context.Context, lockID string) {},
+			GetDeadLettersDueForRetryFunc: func(ctx context.Context, before time.Time, maxAttempts int) ([]models.DeadLetter, error) {
+				return []models.DeadLetter{{ID: "deadLetter1"}, {ID: "deadLetter2"}}, nil
+			},
+		}
+
+		Convey("And a replayer that fails to replay the first dead letter", func() {
+			replayerMock := &serviceMock.DeadLetterReplayerMock{
+				ReplayDeadLetterFunc: func(ctx context.Context, deadLetter *models.DeadLetter) error {
+					if deadLetter.ID == "deadLetter1" {
+						return errReplay
+					}
+					return nil
+				},
+			}
+			retrier := service.NewDeadLetterRetrier(mongoDBMock, replayerMock, time.Second, testDeadLetterMaxAttempts)
+
+			Convey("When RetryDueDeadLetters is called", func() {
+				before := time.Now().UTC()
+				retrier.RetryDueDeadLetters(ctx)
+
+				Convey("Then all due dead letters are replayed, and the retrier lock is released", func() {
+					So(mongoDBMock.AcquireDeadLetterRetrierLockCalls(), ShouldHaveLength, 1)
+					So(mongoDBMock.GetDeadLettersDueForRetryCalls(), ShouldHaveLength, 1)
+					So(mongoDBMock.GetDeadLettersDueForRetryCalls()[0].Before, ShouldHappenOnOrAfter, before)
+					So(mongoDBMock.GetDeadLettersDueForRetryCalls()[0].MaxAttempts, ShouldEqual, testDeadLetterMaxAttempts)
+					So(replayerMock.ReplayDeadLetterCalls(), ShouldHaveLength, 2)
+					So(replayerMock.ReplayDeadLetterCalls()[0].DeadLetter.ID, ShouldEqual, "deadLetter1")
+					So(replayerMock.ReplayDeadLetterCalls()[1].DeadLetter.ID, ShouldEqual, "deadLetter2")
+					So(mongoDBMock.UnlockDeadLetterRetrierCalls(), ShouldHaveLength, 1)
+					So(mongoDBMock.UnlockDeadLetterRetrierCalls()[0].LockID, ShouldEqual, testRetrierLockID)
+				})
+			})
+		})
+	})
+
+	Convey("Given a mongoDB where the retrier lock is held by another instance", t, func() {
+		mongoDBMock := &apiMock.MongoServerMock{
+			AcquireDeadLetterRetrierLockFunc: func(ctx context.Context) (string, error) { return "", apierrors.ErrLockAlreadyHeld },
+		}
+		replayerMock := &serviceMock.DeadLetterReplayerMock{}
+		retrier := service.NewDeadLetterRetrier(mongoDBMock, replayerMock, time.Second, testDeadLetterMaxAttempts)
+
+		Convey("When RetryDueDeadLetters is called", func() {
+			retrier.RetryDueDeadLetters(ctx)
+
+			Convey("Then no dead letters are retrieved or replayed", func() {
+				So(mongoDBMock.GetDeadLettersDueForRetryCalls(), ShouldHaveLength, 0)
+				So(replayerMock.ReplayDeadLetterCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+
+	Convey("Given a mongoDB that fails to return the due dead letters", t, func() {
+		mongoDBMock := &apiMock.MongoServerMock{
+			AcquireDeadLetterRetrierLockFunc: func(ctx context.Context) (string, error) { return testRetrierLockID, nil },
+			UnlockDeadLetterRetrierFunc:      func(ctx context.Context, lockID string) {},
+			GetDeadLettersDueForRetryFunc: func(ctx context.Context, before time.Time, maxAttempts int) ([]models.DeadLetter, error) {
+				return nil, errMongoDB
+			},
+		}
+		replayerMock := &serviceMock.DeadLetterReplayerMock{}
+		retrier := service.NewDeadLetterRetrier(mongoDBMock, replayerMock, time.Second, testDeadLetterMaxAttempts)
+
+		Convey("When RetryDueDeadLetters is called", func() {
+			retrier.RetryDueDeadLetters(ctx)
+
+			Convey("Then no dead letters are replayed and the retrier lock is released", func() {
+				So(replayerMock.ReplayDeadLetterCalls(), ShouldHaveLength, 0)
+				So(mongoDBMock.UnlockDeadLetterRetrierCalls(), ShouldHaveLength, 1)
+			})
+		})
+	})
+}
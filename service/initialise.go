@@ -3,14 +3,17 @@ package service
 import (
 	"context"
 	"net/http"
+	"sort"
 
 	"github.com/ONSdigital/dp-api-clients-go/v2/health"
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
 	"github.com/ONSdigital/dp-image-api/api"
 	"github.com/ONSdigital/dp-image-api/config"
+	"github.com/ONSdigital/dp-image-api/event"
 	"github.com/ONSdigital/dp-image-api/mongo"
 	kafka "github.com/ONSdigital/dp-kafka/v3"
 	dphttp "github.com/ONSdigital/dp-net/v3/http"
+	"github.com/ONSdigital/log.go/v2/log"
 )
 
 // KafkaProducerType to differentiate the kafka producers
@@ -20,25 +23,31 @@ type KafkaProducerType int
 const (
 	KafkaProducerUploaded KafkaProducerType = iota
 	KafkaProducerPublished
+	KafkaProducerWithdrawn
+	KafkaProducerStateChanged
 )
 
 // ExternalServiceList holds the initialiser and initialisation state of external services.
 type ExternalServiceList struct {
-	MongoDB                bool
-	HealthCheck            bool
-	KafkaProducerUploaded  bool
-	KafkaProducerPublished bool
-	Init                   Initialiser
+	MongoDB                   bool
+	HealthCheck               bool
+	KafkaProducerUploaded     bool
+	KafkaProducerPublished    bool
+	KafkaProducerWithdrawn    bool
+	KafkaProducerStateChanged bool
+	Init                      Initialiser
 }
 
 // NewServiceList creates a new service list with the provided initialiser
 func NewServiceList(initialiser Initialiser) *ExternalServiceList {
 	return &ExternalServiceList{
-		MongoDB:                false,
-		HealthCheck:            false,
-		KafkaProducerUploaded:  false,
-		KafkaProducerPublished: false,
-		Init:                   initialiser,
+		MongoDB:                   false,
+		HealthCheck:               false,
+		KafkaProducerUploaded:     false,
+		KafkaProducerPublished:    false,
+		KafkaProducerWithdrawn:    false,
+		KafkaProducerStateChanged: false,
+		Init:                      initialiser,
 	}
 }
 
@@ -61,8 +70,10 @@ func (e *ExternalServiceList) GetMongoDB(ctx context.Context, cfg config.MongoCo
 	return mongoDB, nil
 }
 
-// GetKafkaProducer returns a kafka producer
+// GetKafkaProducer returns a kafka producer. In CloudEvents binary mode, the producer sends the CloudEvents headers
+// of the events of its topic with every message.
 func (e *ExternalServiceList) GetKafkaProducer(ctx context.Context, cfg *config.Config, producerType KafkaProducerType) (kafkaProducer kafka.IProducer, err error) {
+	var eventType string
 	switch producerType {
 	case KafkaProducerUploaded:
 		kafkaProducer, err = e.Init.DoGetKafkaProducer(ctx, cfg, cfg.ImageUploadedTopic)
@@ -70,16 +81,64 @@ func (e *ExternalServiceList) GetKafkaProducer(ctx context.Context, cfg *config.
 			return nil, err
 		}
 		e.KafkaProducerUploaded = true
+		eventType = event.TypeImageUploaded
 	case KafkaProducerPublished:
 		kafkaProducer, err = e.Init.DoGetKafkaProducer(ctx, cfg, cfg.StaticFilePublishedTopic)
 		if err != nil {
 			return nil, err
 		}
 		e.KafkaProducerPublished = true
+		eventType = event.TypeImagePublished
+	case KafkaProducerWithdrawn:
+		kafkaProducer, err = e.Init.DoGetKafkaProducer(ctx, cfg, cfg.ImageWithdrawnTopic)
+		if err != nil {
+			return nil, err
+		}
+		e.KafkaProducerWithdrawn = true
+		eventType = event.TypeImageWithdrawn
+	case KafkaProducerStateChanged:
+		kafkaProducer, err = e.Init.DoGetKafkaProducer(ctx, cfg, cfg.ImageStateChangedTopic)
+		if err != nil {
+			return nil, err
+		}
+		e.KafkaProducerStateChanged = true
+		eventType = event.TypeImageStateChanged
+	}
+	if event.Encoding(cfg.EventEncoding) == event.EncodingCloudEventsBinary {
+		headers := event.BinaryHeaders(cfg.EventSource, eventType)
+		names := make([]string, 0, len(headers))
+		for name := range headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			kafkaProducer.AddHeader(name, headers[name])
+		}
 	}
 	return kafkaProducer, nil
 }
 
+// GetInitialisedKafkaProducer returns a kafka producer of the provided type, making sure that it is initialised
+// so that events are not dropped because kafka could not be reached yet
+func (e *ExternalServiceList) GetInitialisedKafkaProducer(ctx context.Context, cfg *config.Config, producerType KafkaProducerType) (kafka.IProducer, error) {
+	producer, err := e.GetKafkaProducer(ctx, cfg, producerType)
+	if err != nil {
+		return nil, err
+	}
+	if err := producer.Initialise(ctx); err != nil {
+		CloseKafkaProducer(ctx, producer)
+		return nil, err
+	}
+	return producer, nil
+}
+
+// CloseKafkaProducer closes the provided kafka producer, flushing any pending message
+func CloseKafkaProducer(ctx context.Context, producer kafka.IProducer) {
+	if err := producer.Close(ctx); err != nil {
+		log.Error(ctx, "error closing kafka producer", err)
+	}
+}
+
 // GetHealthClient returns a healthclient for the provided URL
 func (e *ExternalServiceList) GetHealthClient(name, url string) *health.Client {
 	return e.Init.DoGetHealthClient(name, url)
@@ -8,12 +8,15 @@ import (
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
 	"github.com/ONSdigital/dp-image-api/api"
 	"github.com/ONSdigital/dp-image-api/config"
+	"github.com/ONSdigital/dp-image-api/models"
 	kafka "github.com/ONSdigital/dp-kafka/v3"
 )
 
 //go:generate moq -out mock/initialiser.go -pkg mock . Initialiser
 //go:generate moq -out mock/server.go -pkg mock . HTTPServer
 //go:generate moq -out mock/healthcheck.go -pkg mock . HealthChecker
+//go:generate moq -out mock/publisher.go -pkg mock . ImagePublisher
+//go:generate moq -out mock/replayer.go -pkg mock . DeadLetterReplayer
 
 // Initialiser defines the methods to initialise external services
 type Initialiser interface {
@@ -35,5 +38,16 @@ type HealthChecker interface {
 	Handler(w http.ResponseWriter, req *http.Request)
 	Start(ctx context.Context)
 	Stop()
-	AddCheck(name string, checker healthcheck.Checker) (err error)
+	AddAndGetCheck(name string, checker healthcheck.Checker) (check *healthcheck.Check, err error)
+	Subscribe(s healthcheck.Subscriber, checks ...*healthcheck.Check)
+}
+
+// ImagePublisher defines the required methods to publish an image
+type ImagePublisher interface {
+	PublishImage(ctx context.Context, id string) error
+}
+
+// DeadLetterReplayer defines the required methods to replay a dead letter
+type DeadLetterReplayer interface {
+	ReplayDeadLetter(ctx context.Context, deadLetter *models.DeadLetter) error
 }
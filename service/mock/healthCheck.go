@@ -21,8 +21,8 @@ var _ service.HealthChecker = &HealthCheckerMock{}
 //
 //		// make and configure a mocked service.HealthChecker
 //		mockedHealthChecker := &HealthCheckerMock{
-//			AddCheckFunc: func(name string, checker healthcheck.Checker) error {
-//				panic("mock out the AddCheck method")
+//			AddAndGetCheckFunc: func(name string, checker healthcheck.Checker) (*healthcheck.Check, error) {
+//				panic("mock out the AddAndGetCheck method")
 //			},
 //			HandlerFunc: func(w http.ResponseWriter, req *http.Request)  {
 //				panic("mock out the Handler method")
@@ -33,6 +33,9 @@ var _ service.HealthChecker = &HealthCheckerMock{}
 //			StopFunc: func()  {
 //				panic("mock out the Stop method")
 //			},
+//			SubscribeFunc: func(s healthcheck.Subscriber, checks ...*healthcheck.Check)  {
+//				panic("mock out the Subscribe method")
+//			},
 //		}
 //
 //		// use mockedHealthChecker in code that requires service.HealthChecker
@@ -40,8 +43,8 @@ var _ service.HealthChecker = &HealthCheckerMock{}
 //
 //	}
 type HealthCheckerMock struct {
-	// AddCheckFunc mocks the AddCheck method.
-	AddCheckFunc func(name string, checker healthcheck.Checker) error
+	// AddAndGetCheckFunc mocks the AddAndGetCheck method.
+	AddAndGetCheckFunc func(name string, checker healthcheck.Checker) (*healthcheck.Check, error)
 
 	// HandlerFunc mocks the Handler method.
 	HandlerFunc func(w http.ResponseWriter, req *http.Request)
@@ -52,10 +55,13 @@ type HealthCheckerMock struct {
 	// StopFunc mocks the Stop method.
 	StopFunc func()
 
+	// SubscribeFunc mocks the Subscribe method.
+	SubscribeFunc func(s healthcheck.Subscriber, checks ...*healthcheck.Check)
+
 	// calls tracks calls to the methods.
 	calls struct {
-		// AddCheck holds details about calls to the AddCheck method.
-		AddCheck []struct {
+		// AddAndGetCheck holds details about calls to the AddAndGetCheck method.
+		AddAndGetCheck []struct {
 			// Name is the name argument value.
 			Name string
 			// Checker is the checker argument value.
@@ -76,17 +82,25 @@ type HealthCheckerMock struct {
 		// Stop holds details about calls to the Stop method.
 		Stop []struct {
 		}
+		// Subscribe holds details about calls to the Subscribe method.
+		Subscribe []struct {
+			// S is the s argument value.
+			S healthcheck.Subscriber
+			// Checks is the checks argument value.
+			Checks []*healthcheck.Check
+		}
 	}
-	lockAddCheck sync.RWMutex
-	lockHandler  sync.RWMutex
-	lockStart    sync.RWMutex
-	lockStop     sync.RWMutex
+	lockAddAndGetCheck sync.RWMutex
+	lockHandler        sync.RWMutex
+	lockStart          sync.RWMutex
+	lockStop           sync.RWMutex
+	lockSubscribe      sync.RWMutex
 }
 
-// AddCheck calls AddCheckFunc.
-func (mock *HealthCheckerMock) AddCheck(name string, checker healthcheck.Checker) error {
-	if mock.AddCheckFunc == nil {
-		panic("HealthCheckerMock.AddCheckFunc: method is nil but HealthChecker.AddCheck was just called")
+// AddAndGetCheck calls AddAndGetCheckFunc.
+func (mock *HealthCheckerMock) AddAndGetCheck(name string, checker healthcheck.Checker) (*healthcheck.Check, error) {
+	if mock.AddAndGetCheckFunc == nil {
+		panic("HealthCheckerMock.AddAndGetCheckFunc: method is nil but HealthChecker.AddAndGetCheck was just called")
 	}
 	callInfo := struct {
 		Name    string
@@ -95,17 +109,17 @@ func (mock *HealthCheckerMock) AddCheck(name string, checker healthcheck.Checker
 		Name:    name,
 		Checker: checker,
 	}
-	mock.lockAddCheck.Lock()
-	mock.calls.AddCheck = append(mock.calls.AddCheck, callInfo)
-	mock.lockAddCheck.Unlock()
-	return mock.AddCheckFunc(name, checker)
+	mock.lockAddAndGetCheck.Lock()
+	mock.calls.AddAndGetCheck = append(mock.calls.AddAndGetCheck, callInfo)
+	mock.lockAddAndGetCheck.Unlock()
+	return mock.AddAndGetCheckFunc(name, checker)
 }
 
-// AddCheckCalls gets all the calls that were made to AddCheck.
+// AddAndGetCheckCalls gets all the calls that were made to AddAndGetCheck.
 // Check the length with:
 //
-//	len(mockedHealthChecker.AddCheckCalls())
-func (mock *HealthCheckerMock) AddCheckCalls() []struct {
+//	len(mockedHealthChecker.AddAndGetCheckCalls())
+func (mock *HealthCheckerMock) AddAndGetCheckCalls() []struct {
 	Name    string
 	Checker healthcheck.Checker
 } {
@@ -113,9 +127,9 @@ func (mock *HealthCheckerMock) AddCheckCalls() []struct {
 		Name    string
 		Checker healthcheck.Checker
 	}
-	mock.lockAddCheck.RLock()
-	calls = mock.calls.AddCheck
-	mock.lockAddCheck.RUnlock()
+	mock.lockAddAndGetCheck.RLock()
+	calls = mock.calls.AddAndGetCheck
+	mock.lockAddAndGetCheck.RUnlock()
 	return calls
 }
 
@@ -213,3 +227,39 @@ func (mock *HealthCheckerMock) StopCalls() []struct {
 	mock.lockStop.RUnlock()
 	return calls
 }
+
+// Subscribe calls SubscribeFunc.
+func (mock *HealthCheckerMock) Subscribe(s healthcheck.Subscriber, checks ...*healthcheck.Check) {
+	if mock.SubscribeFunc == nil {
+		panic("HealthCheckerMock.SubscribeFunc: method is nil but HealthChecker.Subscribe was just called")
+	}
+	callInfo := struct {
+		S      healthcheck.Subscriber
+		Checks []*healthcheck.Check
+	}{
+		S:      s,
+		Checks: checks,
+	}
+	mock.lockSubscribe.Lock()
+	mock.calls.Subscribe = append(mock.calls.Subscribe, callInfo)
+	mock.lockSubscribe.Unlock()
+	mock.SubscribeFunc(s, checks...)
+}
+
+// SubscribeCalls gets all the calls that were made to Subscribe.
+// Check the length with:
+//
+//	len(mockedHealthChecker.SubscribeCalls())
+func (mock *HealthCheckerMock) SubscribeCalls() []struct {
+	S      healthcheck.Subscriber
+	Checks []*healthcheck.Check
+} {
+	var calls []struct {
+		S      healthcheck.Subscriber
+		Checks []*healthcheck.Check
+	}
+	mock.lockSubscribe.RLock()
+	calls = mock.calls.Subscribe
+	mock.lockSubscribe.RUnlock()
+	return calls
+}
@@ -0,0 +1,82 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mock
+
+import (
+	"context"
+	"github.com/ONSdigital/dp-image-api/service"
+	"sync"
+)
+
+// Ensure, that ImagePublisherMock does implement service.ImagePublisher.
+// If this is not the case, regenerate this file with moq.
+var _ service.ImagePublisher = &ImagePublisherMock{}
+
+// ImagePublisherMock is a mock implementation of service.ImagePublisher.
+//
+//	func TestSomethingThatUsesImagePublisher(t *testing.T) {
+//
+//		// make and configure a mocked service.ImagePublisher
+//		mockedImagePublisher := &ImagePublisherMock{
+//			PublishImageFunc: func(ctx context.Context, id string) error {
+//				panic("mock out the PublishImage method")
+//			},
+//		}
+//
+//		// use mockedImagePublisher in code that requires service.ImagePublisher
+//		// and then make assertions.
+//
+//	}
+type ImagePublisherMock struct {
+	// PublishImageFunc mocks the PublishImage method.
+	PublishImageFunc func(ctx context.Context, id string) error
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// PublishImage holds details about calls to the PublishImage method.
+		PublishImage []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+		}
+	}
+	lockPublishImage sync.RWMutex
+}
+
+// PublishImage calls PublishImageFunc.
+func (mock *ImagePublisherMock) PublishImage(ctx context.Context, id string) error {
+	if mock.PublishImageFunc == nil {
+		panic("ImagePublisherMock.PublishImageFunc: method is nil but ImagePublisher.PublishImage was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  string
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	mock.lockPublishImage.Lock()
+	mock.calls.PublishImage = append(mock.calls.PublishImage, callInfo)
+	mock.lockPublishImage.Unlock()
+	return mock.PublishImageFunc(ctx, id)
+}
+
+// PublishImageCalls gets all the calls that were made to PublishImage.
+// Check the length with:
+//
+//	len(mockedImagePublisher.PublishImageCalls())
+func (mock *ImagePublisherMock) PublishImageCalls() []struct {
+	Ctx context.Context
+	ID  string
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  string
+	}
+	mock.lockPublishImage.RLock()
+	calls = mock.calls.PublishImage
+	mock.lockPublishImage.RUnlock()
+	return calls
+}
@@ -0,0 +1,83 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mock
+
+import (
+	"context"
+	"github.com/ONSdigital/dp-image-api/models"
+	"github.com/ONSdigital/dp-image-api/service"
+	"sync"
+)
+
+// Ensure, that DeadLetterReplayerMock does implement service.DeadLetterReplayer.
+// If this is not the case, regenerate this file with moq.
+var _ service.DeadLetterReplayer = &DeadLetterReplayerMock{}
+
+// DeadLetterReplayerMock is a mock implementation of service.DeadLetterReplayer.
+//
+//	func TestSomethingThatUsesDeadLetterReplayer(t *testing.T) {
+//
+//		// make and configure a mocked service.DeadLetterReplayer
+//		mockedDeadLetterReplayer := &DeadLetterReplayerMock{
+//			ReplayDeadLetterFunc: func(ctx context.Context, deadLetter *models.DeadLetter) error {
+//				panic("mock out the ReplayDeadLetter method")
+//			},
+//		}
+//
+//		// use mockedDeadLetterReplayer in code that requires service.DeadLetterReplayer
+//		// and then make assertions.
+//
+//	}
+type DeadLetterReplayerMock struct {
+	// ReplayDeadLetterFunc mocks the ReplayDeadLetter method.
+	ReplayDeadLetterFunc func(ctx context.Context, deadLetter *models.DeadLetter) error
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// ReplayDeadLetter holds details about calls to the ReplayDeadLetter method.
+		ReplayDeadLetter []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// DeadLetter is the deadLetter argument value.
+			DeadLetter *models.DeadLetter
+		}
+	}
+	lockReplayDeadLetter sync.RWMutex
+}
+
+// ReplayDeadLetter calls ReplayDeadLetterFunc.
+func (mock *DeadLetterReplayerMock) ReplayDeadLetter(ctx context.Context, deadLetter *models.DeadLetter) error {
+	if mock.ReplayDeadLetterFunc == nil {
+		panic("DeadLetterReplayerMock.ReplayDeadLetterFunc: method is nil but DeadLetterReplayer.ReplayDeadLetter was just called")
+	}
+	callInfo := struct {
+		Ctx        context.Context
+		DeadLetter *models.DeadLetter
+	}{
+		Ctx:        ctx,
+		DeadLetter: deadLetter,
+	}
+	mock.lockReplayDeadLetter.Lock()
+	mock.calls.ReplayDeadLetter = append(mock.calls.ReplayDeadLetter, callInfo)
+	mock.lockReplayDeadLetter.Unlock()
+	return mock.ReplayDeadLetterFunc(ctx, deadLetter)
+}
+
+// ReplayDeadLetterCalls gets all the calls that were made to ReplayDeadLetter.
+// Check the length with:
+//
+//	len(mockedDeadLetterReplayer.ReplayDeadLetterCalls())
+func (mock *DeadLetterReplayerMock) ReplayDeadLetterCalls() []struct {
+	Ctx        context.Context
+	DeadLetter *models.DeadLetter
+} {
+	var calls []struct {
+		Ctx        context.Context
+		DeadLetter *models.DeadLetter
+	}
+	mock.lockReplayDeadLetter.RLock()
+	calls = mock.calls.ReplayDeadLetter
+	mock.lockReplayDeadLetter.RUnlock()
+	return calls
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// statusShuttingDown is the readiness status reported once the service has started its graceful shutdown
+const statusShuttingDown = "SHUTTING_DOWN"
+
+// Readiness tracks whether the service is ready to serve traffic. It is notified of the accumulated health
+// of the dependencies it is subscribed to, and it is only ready when all of them are healthy.
+// It is not ready until the first health update is received, and never again once the service is shutting down.
+type Readiness struct {
+	mutex        sync.RWMutex
+	status       string
+	shuttingDown bool
+}
+
+// NewReadiness creates a Readiness that is not ready until it is notified of a healthy state
+func NewReadiness() *Readiness {
+	return &Readiness{
+		status: healthcheck.StatusCritical,
+	}
+}
+
+// OnHealthUpdate implements the healthcheck Subscriber interface, storing the accumulated health status of the subscribed checks
+func (r *Readiness) OnHealthUpdate(status string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.status = status
+}
+
+// SetShuttingDown flips the readiness to not-ready for the rest of the lifetime of the service
+func (r *Readiness) SetShuttingDown() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.shuttingDown = true
+}
+
+// Status returns the readiness status, and whether the service is ready to serve traffic
+func (r *Readiness) Status() (status string, ready bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if r.shuttingDown {
+		return statusShuttingDown, false
+	}
+	return r.status, r.status == healthcheck.StatusOK
+}
+
+// Handler responds with 200 OK if the service is ready to serve traffic, or 503 ServiceUnavailable otherwise
+func (r *Readiness) Handler(w http.ResponseWriter, req *http.Request) {
+	status, ready := r.Status()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if _, err := w.Write([]byte(status)); err != nil {
+		log.Error(req.Context(), "failed to write readiness response", err)
+	}
+}
+
+// LiveHandler responds with 200 OK as long as the process is able to serve http requests, regardless of its dependencies
+func LiveHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if _, err := w.Write([]byte(healthcheck.StatusOK)); err != nil {
+		log.Error(req.Context(), "failed to write liveness response", err)
+	}
+}
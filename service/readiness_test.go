@@ -0,0 +1,62 @@
+package service_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/ONSdigital/dp-image-api/service"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func getReadiness(readiness *service.Readiness) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	readiness.Handler(w, httptest.NewRequest(http.MethodGet, "/ready", http.NoBody))
+	return w
+}
+
+func TestReadiness(t *testing.T) {
+	Convey("Given a new readiness", t, func() {
+		readiness := service.NewReadiness()
+
+		Convey("Then it is not ready before any health update is received", func() {
+			w := getReadiness(readiness)
+			So(w.Code, ShouldEqual, http.StatusServiceUnavailable)
+			So(w.Body.String(), ShouldEqual, healthcheck.StatusCritical)
+		})
+
+		Convey("When it is notified that the subscribed dependencies are healthy", func() {
+			readiness.OnHealthUpdate(healthcheck.StatusOK)
+
+			Convey("Then it is ready", func() {
+				w := getReadiness(readiness)
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldEqual, healthcheck.StatusOK)
+			})
+
+			Convey("And then that a dependency is in warning state, then it is not ready", func() {
+				readiness.OnHealthUpdate(healthcheck.StatusWarning)
+				w := getReadiness(readiness)
+				So(w.Code, ShouldEqual, http.StatusServiceUnavailable)
+				So(w.Body.String(), ShouldEqual, healthcheck.StatusWarning)
+			})
+
+			Convey("And then the service starts shutting down, then it is not ready regardless of later health updates", func() {
+				readiness.SetShuttingDown()
+				readiness.OnHealthUpdate(healthcheck.StatusOK)
+				w := getReadiness(readiness)
+				So(w.Code, ShouldEqual, http.StatusServiceUnavailable)
+				So(w.Body.String(), ShouldEqual, "SHUTTING_DOWN")
+			})
+		})
+	})
+}
+
+func TestLiveHandler(t *testing.T) {
+	Convey("The liveness handler responds with 200 OK", t, func() {
+		w := httptest.NewRecorder()
+		service.LiveHandler(w, httptest.NewRequest(http.MethodGet, "/live", http.NoBody))
+		So(w.Code, ShouldEqual, http.StatusOK)
+	})
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/dp-image-api/api"
+	"github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// PublishScheduler periodically publishes any images whose scheduled publish time has passed.
+// Scheduled publishes are persisted in MongoDB, so they are picked up again after a restart,
+// and a MongoDB lock is used to elect a single instance to publish on each tick.
+type PublishScheduler struct {
+	mongoDB   api.MongoServer
+	publisher ImagePublisher
+	interval  time.Duration
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewPublishScheduler creates a new PublishScheduler that checks for due images on the provided interval
+func NewPublishScheduler(mongoDB api.MongoServer, publisher ImagePublisher, interval time.Duration) *PublishScheduler {
+	return &PublishScheduler{
+		mongoDB:   mongoDB,
+		publisher: publisher,
+		interval:  interval,
+		stop:      make(chan struct{}),
+	}
+}
+
+// Start runs the scheduler in a new go-routine until Close is called
+func (s *PublishScheduler) Start(ctx context.Context) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.PublishDueImages(ctx)
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	log.Info(ctx, "publish scheduler started", log.Data{"interval": s.interval.String()})
+}
+
+// Close stops the scheduler and waits for any in-flight publishing to finish
+func (s *PublishScheduler) Close(ctx context.Context) error {
+	close(s.stop)
+	s.wg.Wait()
+	log.Info(ctx, "publish scheduler stopped")
+	return nil
+}
+
+// PublishDueImages publishes all the images with a scheduled publish time before now.
+// If another instance holds the scheduler lock, nothing is done. The lock is renewed before each image after the first,
+// and if it has expired the remaining images are left for a later tick, as another instance may be publishing them.
+func (s *PublishScheduler) PublishDueImages(ctx context.Context) {
+	lockID, err := s.mongoDB.AcquireSchedulerLock(ctx)
+	if err != nil {
+		if !errors.Is(err, apierrors.ErrLockAlreadyHeld) {
+			log.Error(ctx, "failed to acquire publish scheduler lock", err)
+		}
+		return
+	}
+	defer s.mongoDB.UnlockScheduler(ctx, lockID)
+
+	images, err := s.mongoDB.GetImagesScheduledForPublish(ctx, time.Now().UTC())
+	if err != nil {
+		log.Error(ctx, "failed to get images scheduled for publish", err)
+		return
+	}
+
+	for i := range images {
+		logdata := log.Data{"image_id": images[i].ID}
+		if i > 0 {
+			if err := s.mongoDB.RenewSchedulerLock(ctx, lockID); err != nil {
+				log.Error(ctx, "failed to renew publish scheduler lock, remaining images will be published on a later tick", err, logdata)
+				return
+			}
+		}
+		if err := s.publisher.PublishImage(ctx, images[i].ID); err != nil {
+			log.Error(ctx, "failed to publish scheduled image", err, logdata)
+			continue
+		}
+		log.Info(ctx, "scheduled image published", logdata)
+	}
+}
@@ -0,0 +1,119 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apiMock "github.com/ONSdigital/dp-image-api/api/mock"
+	"github.com/ONSdigital/dp-image-api/apierrors"
+	"github.com/ONSdigital/dp-image-api/models"
+	"github.com/ONSdigital/dp-image-api/service"
+	serviceMock "github.com/ONSdigital/dp-image-api/service/mock"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testSchedulerLockID = "schedulerLockID"
+
+var errPublish = errors.New("publish error")
+
+func TestPublishDueImages(t *testing.T) {
+	Convey("Given a mongoDB with two images due for publishing", t, func() {
+		mongoDBMock := &apiMock.MongoServerMock{
+			AcquireSchedulerLockFunc: func(ctx context.Context) (string, error) { return testSchedulerLockID, nil },
+			RenewSchedulerLockFunc:   func(ctx context.Context, lockID string) error { return nil },
+			UnlockSchedulerFunc:      func(ctx context.Context, lockID string) {},
+			GetImagesScheduledForPublishFunc: func(ctx context.Context, before time.Time) ([]models.Image, error) {
+				return []models.Image{{ID: "image1"}, {ID: "image2"}}, nil
+			},
+		}
+
+		Convey("And a publisher that fails to publish the first image", func() {
+			publisherMock := &serviceMock.ImagePublisherMock{
+				PublishImageFunc: func(ctx context.Context, id string) error {
+					if id == "image1" {
+						return errPublish
+					}
+					return nil
+				},
+			}
+			scheduler := service.NewPublishScheduler(mongoDBMock, publisherMock, time.Second)
+
+			Convey("When PublishDueImages is called", func() {
+				before := time.Now().UTC()
+				scheduler.PublishDueImages(ctx)
+
+				Convey("Then all due images are published, and the scheduler lock is released", func() {
+					So(mongoDBMock.AcquireSchedulerLockCalls(), ShouldHaveLength, 1)
+					So(mongoDBMock.GetImagesScheduledForPublishCalls(), ShouldHaveLength, 1)
+					So(mongoDBMock.GetImagesScheduledForPublishCalls()[0].Before, ShouldHappenOnOrAfter, before)
+					So(publisherMock.PublishImageCalls(), ShouldHaveLength, 2)
+					So(publisherMock.PublishImageCalls()[0].ID, ShouldEqual, "image1")
+					So(publisherMock.PublishImageCalls()[1].ID, ShouldEqual, "image2")
+					So(mongoDBMock.RenewSchedulerLockCalls(), ShouldHaveLength, 1)
+					So(mongoDBMock.RenewSchedulerLockCalls()[0].LockID, ShouldEqual, testSchedulerLockID)
+					So(mongoDBMock.UnlockSchedulerCalls(), ShouldHaveLength, 1)
+					So(mongoDBMock.UnlockSchedulerCalls()[0].LockID, ShouldEqual, testSchedulerLockID)
+				})
+			})
+		})
+
+		Convey("And a scheduler lock that expires while the first image is being published", func() {
+			mongoDBMock.RenewSchedulerLockFunc = func(ctx context.Context, lockID string) error { return apierrors.ErrLockLost }
+			publisherMock := &serviceMock.ImagePublisherMock{
+				PublishImageFunc: func(ctx context.Context, id string) error { return nil },
+			}
+			scheduler := service.NewPublishScheduler(mongoDBMock, publisherMock, time.Second)
+
+			Convey("When PublishDueImages is called", func() {
+				scheduler.PublishDueImages(ctx)
+
+				Convey("Then only the first image is published, and the remaining images are left for a later tick", func() {
+					So(publisherMock.PublishImageCalls(), ShouldHaveLength, 1)
+					So(publisherMock.PublishImageCalls()[0].ID, ShouldEqual, "image1")
+					So(mongoDBMock.RenewSchedulerLockCalls(), ShouldHaveLength, 1)
+					So(mongoDBMock.UnlockSchedulerCalls(), ShouldHaveLength, 1)
+				})
+			})
+		})
+	})
+
+	Convey("Given a mongoDB where the scheduler lock is held by another instance", t, func() {
+		mongoDBMock := &apiMock.MongoServerMock{
+			AcquireSchedulerLockFunc: func(ctx context.Context) (string, error) { return "", apierrors.ErrLockAlreadyHeld },
+		}
+		publisherMock := &serviceMock.ImagePublisherMock{}
+		scheduler := service.NewPublishScheduler(mongoDBMock, publisherMock, time.Second)
+
+		Convey("When PublishDueImages is called", func() {
+			scheduler.PublishDueImages(ctx)
+
+			Convey("Then no images are retrieved or published", func() {
+				So(mongoDBMock.GetImagesScheduledForPublishCalls(), ShouldHaveLength, 0)
+				So(publisherMock.PublishImageCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+
+	Convey("Given a mongoDB that fails to return scheduled images", t, func() {
+		mongoDBMock := &apiMock.MongoServerMock{
+			AcquireSchedulerLockFunc: func(ctx context.Context) (string, error) { return testSchedulerLockID, nil },
+			UnlockSchedulerFunc:      func(ctx context.Context, lockID string) {},
+			GetImagesScheduledForPublishFunc: func(ctx context.Context, before time.Time) ([]models.Image, error) {
+				return nil, errMongoDB
+			},
+		}
+		publisherMock := &serviceMock.ImagePublisherMock{}
+		scheduler := service.NewPublishScheduler(mongoDBMock, publisherMock, time.Second)
+
+		Convey("When PublishDueImages is called", func() {
+			scheduler.PublishDueImages(ctx)
+
+			Convey("Then no images are published and the scheduler lock is released", func() {
+				So(publisherMock.PublishImageCalls(), ShouldHaveLength, 0)
+				So(mongoDBMock.UnlockSchedulerCalls(), ShouldHaveLength, 1)
+			})
+		})
+	})
+}
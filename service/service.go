@@ -2,13 +2,16 @@ package service
 
 import (
 	"context"
+	"expvar"
 
 	"github.com/ONSdigital/dp-image-api/url"
 
 	"github.com/ONSdigital/dp-api-clients-go/v2/health"
 	dpauth "github.com/ONSdigital/dp-authorisation/auth"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
 	"github.com/ONSdigital/dp-image-api/api"
 	"github.com/ONSdigital/dp-image-api/config"
+	"github.com/ONSdigital/dp-image-api/middleware"
 	kafka "github.com/ONSdigital/dp-kafka/v3"
 	"github.com/ONSdigital/dp-net/v3/handlers"
 	"github.com/ONSdigital/log.go/v2/log"
@@ -19,25 +22,40 @@ import (
 
 // Service contains all the configs, server and clients to run the Image API
 type Service struct {
-	config                 *config.Config
-	server                 HTTPServer
-	router                 *mux.Router
-	api                    *api.API
-	serviceList            *ExternalServiceList
-	healthCheck            HealthChecker
-	mongoDB                api.MongoServer
-	uploadedKafkaProducer  kafka.IProducer
-	publishedKafkaProducer kafka.IProducer
+	config                    *config.Config
+	server                    HTTPServer
+	router                    *mux.Router
+	api                       *api.API
+	serviceList               *ExternalServiceList
+	healthCheck               HealthChecker
+	mongoDB                   api.MongoServer
+	uploadedKafkaProducer     kafka.IProducer
+	publishedKafkaProducer    kafka.IProducer
+	withdrawnKafkaProducer    kafka.IProducer
+	stateChangedKafkaProducer kafka.IProducer
+	publishScheduler          *PublishScheduler
+	deadLetterRetrier         *DeadLetterRetrier
+	readiness                 *Readiness
 }
 
 // Run the service
 func Run(ctx context.Context, cfg *config.Config, serviceList *ExternalServiceList, buildTime, gitCommit, version string, svcErrors chan error) (*Service, error) {
 	log.Info(ctx, "running service")
 
-	// Get HTTP Server with collectionID checkHeader middleware
+	// Get HTTP Server with collectionID checkHeader, rate limiting and request body size limiting middleware.
+	// Image imports stream many records in a single request, so they have their own body size limit.
 	r := mux.NewRouter()
-	middleware := alice.New(handlers.CheckHeader(handlers.CollectionID))
-	s := serviceList.GetHTTPServer(cfg.BindAddr, middleware.Then(r))
+	rateLimiter := middleware.NewRateLimiter(cfg.RateLimit, cfg.RateLimitBurst, cfg.RateLimitMaxClients, cfg.RateLimitTrustProxy, r)
+	chain := alice.New(
+		handlers.CheckHeader(handlers.CollectionID),
+		rateLimiter.Middleware,
+		middleware.BodyLimit(cfg.MaxRequestBodySize, map[string]int64{api.ImportPath: cfg.MaxImportBodySize}),
+	)
+	// Responses to the public site are compressed in web mode
+	if !cfg.IsPublishing && cfg.EnableCompression {
+		chain = chain.Append(middleware.Compress)
+	}
+	s := serviceList.GetHTTPServer(cfg.BindAddr, chain.Then(r))
 
 	// Get MongoDB client
 	mongoDB, err := serviceList.GetMongoDB(ctx, cfg.MongoConfig)
@@ -46,18 +64,38 @@ func Run(ctx context.Context, cfg *config.Config, serviceList *ExternalServiceLi
 		return nil, err
 	}
 
+	// Apply pending migrations before any request is served. Web instances never write to mongoDB, so they do not migrate.
+	if cfg.IsPublishing && cfg.MigrateOnStartup {
+		result, err := mongoDB.Migrate(ctx, false, cfg.MigrationLockTimeout)
+		if err != nil {
+			log.Fatal(ctx, "failed to apply mongo DB migrations", err)
+			return nil, err
+		}
+		log.Info(ctx, "mongo DB migrations applied", log.Data{"applied": result.Applied})
+	}
+
+	// Create any missing index. Queries still work without indexes, so failing to create them is reported by the index health check instead.
+	if cfg.IsPublishing {
+		if err := mongoDB.EnsureIndexes(ctx, cfg.DeletedImageTTL); err != nil {
+			log.Error(ctx, "failed to ensure mongo DB indexes", err)
+		}
+	}
+
 	var a *api.API
 
-	urlBuilder := url.NewBuilder(cfg.APIURL)
+	urlBuilder := url.NewBuilderFromConfig(cfg)
 	// The following dependencies will only be initialised if we are in publishing mode
 	var zc *health.Client
 	var auth api.AuthHandler
 	var uploadedKafkaProducer kafka.IProducer
 	var publishedKafkaProducer kafka.IProducer
+	var withdrawnKafkaProducer kafka.IProducer
+	var stateChangedKafkaProducer kafka.IProducer
 	if cfg.IsPublishing {
 		// Get Health client for Zebedee and permissions
 		zc = serviceList.GetHealthClient("Zebedee", cfg.ZebedeeURL)
-		auth = getAuthorisationHandlers(zc)
+		// Verified services and users are also rate limited by their identity
+		auth = rateLimiter.Authorised(getAuthorisationHandlers(zc))
 
 		// Get Uploaded Kafka producer
 		uploadedKafkaProducer, err = serviceList.GetKafkaProducer(ctx, cfg, KafkaProducerUploaded)
@@ -73,11 +111,25 @@ func Run(ctx context.Context, cfg *config.Config, serviceList *ExternalServiceLi
 			return nil, err
 		}
 
+		// Get Withdrawn Kafka producer
+		withdrawnKafkaProducer, err = serviceList.GetKafkaProducer(ctx, cfg, KafkaProducerWithdrawn)
+		if err != nil {
+			log.Fatal(ctx, "failed to create image-withdrawn kafka producer", err)
+			return nil, err
+		}
+
+		// Get State Changed Kafka producer
+		stateChangedKafkaProducer, err = serviceList.GetKafkaProducer(ctx, cfg, KafkaProducerStateChanged)
+		if err != nil {
+			log.Fatal(ctx, "failed to create image-state-changed kafka producer", err)
+			return nil, err
+		}
+
 		// Setup the API in publishing
-		a = api.Setup(ctx, cfg, r, auth, mongoDB, uploadedKafkaProducer, publishedKafkaProducer, urlBuilder)
+		a = api.Setup(ctx, cfg, r, auth, mongoDB, uploadedKafkaProducer, publishedKafkaProducer, withdrawnKafkaProducer, stateChangedKafkaProducer, urlBuilder)
 	} else {
 		// Setup the API in web mode
-		a = api.Setup(ctx, cfg, r, auth, mongoDB, nil, nil, urlBuilder)
+		a = api.Setup(ctx, cfg, r, auth, mongoDB, nil, nil, nil, nil, urlBuilder)
 	}
 
 	// Get HealthCheck
@@ -86,17 +138,33 @@ func Run(ctx context.Context, cfg *config.Config, serviceList *ExternalServiceLi
 		log.Fatal(ctx, "could not instantiate healthcheck", err)
 		return nil, err
 	}
-	if err := registerCheckers(ctx, cfg, hc, mongoDB, uploadedKafkaProducer, publishedKafkaProducer, zc); err != nil {
+	readiness := NewReadiness()
+	if err := registerCheckers(ctx, cfg, hc, readiness, mongoDB, uploadedKafkaProducer, publishedKafkaProducer, withdrawnKafkaProducer, stateChangedKafkaProducer, zc); err != nil {
 		return nil, errors.Wrap(err, "unable to register checkers")
 	}
 
 	r.StrictSlash(true).Path("/health").HandlerFunc(hc.Handler)
+	r.StrictSlash(true).Path("/live").HandlerFunc(LiveHandler)
+	r.StrictSlash(true).Path("/ready").HandlerFunc(readiness.Handler)
+	r.StrictSlash(true).Path("/debug/vars").Handler(expvar.Handler())
 	hc.Start(ctx)
 
+	var publishScheduler *PublishScheduler
+	var deadLetterRetrier *DeadLetterRetrier
 	if cfg.IsPublishing {
 		// kafka error channel logging go-routines
 		uploadedKafkaProducer.LogErrors(ctx)
 		publishedKafkaProducer.LogErrors(ctx)
+		withdrawnKafkaProducer.LogErrors(ctx)
+		stateChangedKafkaProducer.LogErrors(ctx)
+
+		// start the scheduler for embargoed publishes
+		publishScheduler = NewPublishScheduler(mongoDB, a, cfg.PublishSchedulerInterval)
+		publishScheduler.Start(ctx)
+
+		// start the retrier for events that could not be sent to kafka
+		deadLetterRetrier = NewDeadLetterRetrier(mongoDB, a, cfg.DeadLetterRetryInterval, cfg.DeadLetterMaxAttempts)
+		deadLetterRetrier.Start(ctx)
 	}
 
 	// Run the http server in a new go-routine
@@ -107,15 +175,20 @@ func Run(ctx context.Context, cfg *config.Config, serviceList *ExternalServiceLi
 	}()
 
 	return &Service{
-		config:                 cfg,
-		server:                 s,
-		router:                 r,
-		api:                    a,
-		serviceList:            serviceList,
-		healthCheck:            hc,
-		mongoDB:                mongoDB,
-		uploadedKafkaProducer:  uploadedKafkaProducer,
-		publishedKafkaProducer: publishedKafkaProducer,
+		config:                    cfg,
+		server:                    s,
+		router:                    r,
+		api:                       a,
+		serviceList:               serviceList,
+		healthCheck:               hc,
+		mongoDB:                   mongoDB,
+		uploadedKafkaProducer:     uploadedKafkaProducer,
+		publishedKafkaProducer:    publishedKafkaProducer,
+		withdrawnKafkaProducer:    withdrawnKafkaProducer,
+		stateChangedKafkaProducer: stateChangedKafkaProducer,
+		publishScheduler:          publishScheduler,
+		deadLetterRetrier:         deadLetterRetrier,
+		readiness:                 readiness,
 	}, nil
 }
 
@@ -125,6 +198,9 @@ func (svc *Service) Close(ctx context.Context) error {
 	log.Info(ctx, "commencing graceful shutdown", log.Data{"graceful_shutdown_timeout": timeout})
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 
+	// stop routing new traffic to this instance before draining the http server
+	svc.readiness.SetShuttingDown()
+
 	// track shutown gracefully closes up
 	var gracefulShutdown bool
 
@@ -143,6 +219,22 @@ func (svc *Service) Close(ctx context.Context) error {
 			hasShutdownError = true
 		}
 
+		// stop publish scheduler before closing its dependencies
+		if svc.publishScheduler != nil {
+			if err := svc.publishScheduler.Close(ctx); err != nil {
+				log.Error(ctx, "error closing publish scheduler", err)
+				hasShutdownError = true
+			}
+		}
+
+		// stop dead letter retrier before closing its dependencies
+		if svc.deadLetterRetrier != nil {
+			if err := svc.deadLetterRetrier.Close(ctx); err != nil {
+				log.Error(ctx, "error closing dead letter retrier", err)
+				hasShutdownError = true
+			}
+		}
+
 		// close API
 		if err := svc.api.Close(ctx); err != nil {
 			log.Error(ctx, "error closing API", err)
@@ -173,6 +265,22 @@ func (svc *Service) Close(ctx context.Context) error {
 			}
 		}
 
+		// close kafka withdrawn producer
+		if svc.serviceList.KafkaProducerWithdrawn {
+			if err := svc.withdrawnKafkaProducer.Close(ctx); err != nil {
+				log.Error(ctx, "error closing Withdrawn Kafka Producer", err)
+				hasShutdownError = true
+			}
+		}
+
+		// close kafka state changed producer
+		if svc.serviceList.KafkaProducerStateChanged {
+			if err := svc.stateChangedKafkaProducer.Close(ctx); err != nil {
+				log.Error(ctx, "error closing State Changed Kafka Producer", err)
+				hasShutdownError = true
+			}
+		}
+
 		if !hasShutdownError {
 			gracefulShutdown = true
 		}
@@ -191,31 +299,59 @@ func (svc *Service) Close(ctx context.Context) error {
 	return nil
 }
 
+// registerCheckers adds the checks of all the dependencies to the healthcheck, and subscribes the readiness to them,
+// so that the service is only ready when mongoDB and, in publishing mode, the kafka producers and zebedee are healthy
 func registerCheckers(ctx context.Context,
 	cfg *config.Config,
 	hc HealthChecker,
+	readiness *Readiness,
 	mongoDB api.MongoServer,
-	uploadedKafkaProducer, publishedKafkaProducer kafka.IProducer,
+	uploadedKafkaProducer, publishedKafkaProducer, withdrawnKafkaProducer, stateChangedKafkaProducer kafka.IProducer,
 	zebedeeClient *health.Client) (err error) {
 	hasErrors := false
 
-	if err = hc.AddCheck("Mongo DB", mongoDB.Checker); err != nil {
+	addCheck := func(name string, checker healthcheck.Checker) error {
+		check, err := hc.AddAndGetCheck(name, checker)
+		if err != nil {
+			return err
+		}
+		hc.Subscribe(readiness, check)
+		return nil
+	}
+
+	if err = addCheck("Mongo DB", mongoDB.Checker); err != nil {
 		hasErrors = true
 		log.Error(ctx, "error adding check for mongo db", err)
 	}
 
+	// Missing or building indexes only make queries slower, so they are reported without affecting the readiness
+	if _, err = hc.AddAndGetCheck("Mongo DB Indexes", mongoDB.IndexChecker); err != nil {
+		hasErrors = true
+		log.Error(ctx, "error adding check for mongo db indexes", err)
+	}
+
 	if cfg.IsPublishing {
-		if err = hc.AddCheck("Uploaded Kafka Producer", uploadedKafkaProducer.Checker); err != nil {
+		if err = addCheck("Uploaded Kafka Producer", uploadedKafkaProducer.Checker); err != nil {
 			hasErrors = true
 			log.Error(ctx, "error adding check for uploaded kafka producer", err, log.Data{"topic": cfg.ImageUploadedTopic})
 		}
 
-		if err = hc.AddCheck("Published Kafka Producer", publishedKafkaProducer.Checker); err != nil {
+		if err = addCheck("Published Kafka Producer", publishedKafkaProducer.Checker); err != nil {
 			hasErrors = true
 			log.Error(ctx, "error adding check for published kafka producer", err, log.Data{"topic": cfg.StaticFilePublishedTopic})
 		}
 
-		if err = hc.AddCheck("Zebedee", zebedeeClient.Checker); err != nil {
+		if err = addCheck("Withdrawn Kafka Producer", withdrawnKafkaProducer.Checker); err != nil {
+			hasErrors = true
+			log.Error(ctx, "error adding check for withdrawn kafka producer", err, log.Data{"topic": cfg.ImageWithdrawnTopic})
+		}
+
+		if err = addCheck("State Changed Kafka Producer", stateChangedKafkaProducer.Checker); err != nil {
+			hasErrors = true
+			log.Error(ctx, "error adding check for state changed kafka producer", err, log.Data{"topic": cfg.ImageStateChangedTopic})
+		}
+
+		if err = addCheck("Zebedee", zebedeeClient.Checker); err != nil {
 			hasErrors = true
 			log.Error(ctx, "error adding check for zebedee", err)
 		}
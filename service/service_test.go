@@ -4,14 +4,18 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/ONSdigital/dp-api-clients-go/v2/health"
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
 	"github.com/ONSdigital/dp-image-api/api"
 	apiMock "github.com/ONSdigital/dp-image-api/api/mock"
 	"github.com/ONSdigital/dp-image-api/config"
+	"github.com/ONSdigital/dp-image-api/event"
+	"github.com/ONSdigital/dp-image-api/models"
 	"github.com/ONSdigital/dp-image-api/service"
 	serviceMock "github.com/ONSdigital/dp-image-api/service/mock"
 	kafka "github.com/ONSdigital/dp-kafka/v3"
@@ -53,6 +57,11 @@ func TestRunPublishing(t *testing.T) {
 
 		mongoDBMock := &apiMock.MongoServerMock{
 			CheckerFunc: func(ctx context.Context, state *healthcheck.CheckState) error { return nil },
+			MigrateFunc: func(ctx context.Context, dryRun bool, lockTimeout time.Duration) (*models.MigrationResult, error) {
+				return &models.MigrationResult{}, nil
+			},
+			EnsureIndexesFunc: func(ctx context.Context, deletedImageTTL time.Duration) error { return nil },
+			IndexCheckerFunc:  func(ctx context.Context, state *healthcheck.CheckState) error { return nil },
 		}
 
 		kafkaProducerMock := &kafkatest.IProducerMock{
@@ -65,8 +74,11 @@ func TestRunPublishing(t *testing.T) {
 		}
 
 		hcMock := &serviceMock.HealthCheckerMock{
-			AddCheckFunc: func(name string, checker healthcheck.Checker) error { return nil },
-			StartFunc:    func(ctx context.Context) {},
+			AddAndGetCheckFunc: func(name string, checker healthcheck.Checker) (*healthcheck.Check, error) {
+				return &healthcheck.Check{}, nil
+			},
+			SubscribeFunc: func(s healthcheck.Subscriber, checks ...*healthcheck.Check) {},
+			StartFunc:     func(ctx context.Context) {},
 		}
 
 		serverWg := &sync.WaitGroup{}
@@ -140,6 +152,28 @@ func TestRunPublishing(t *testing.T) {
 			})
 		})
 
+		Convey("Given that mongoDB migrations fail to be applied", func() {
+			mongoDBMock.MigrateFunc = func(ctx context.Context, dryRun bool, lockTimeout time.Duration) (*models.MigrationResult, error) {
+				return nil, errMongoDB
+			}
+			initMock := &serviceMock.InitialiserMock{
+				DoGetHTTPServerFunc:    funcDoGetHTTPServerNil,
+				DoGetMongoDBFunc:       funcDoGetMongoDBOk,
+				DoGetKafkaProducerFunc: funcDoGetKafkaProducerOk,
+				DoGetHealthClientFunc:  funcDoGetHealthClientOk,
+			}
+			svcErrors := make(chan error, 1)
+			svcList := service.NewServiceList(initMock)
+			_, err := service.Run(ctx, cfg, svcList, testBuildTime, testGitCommit, testVersion, svcErrors)
+
+			Convey("Then service Run fails with the same error. No further initialisations are attempted", func() {
+				So(err, ShouldResemble, errMongoDB)
+				So(svcList.MongoDB, ShouldBeTrue)
+				So(svcList.KafkaProducerUploaded, ShouldBeFalse)
+				So(svcList.HealthCheck, ShouldBeFalse)
+			})
+		})
+
 		Convey("Given that initialising kafka image-uploaded producer returns an error", func() {
 			initMock := &serviceMock.InitialiserMock{
 				DoGetHTTPServerFunc:    funcDoGetHTTPServerNil,
@@ -180,6 +214,47 @@ func TestRunPublishing(t *testing.T) {
 			})
 		})
 
+		Convey("Given that initialising kafka image-withdrawn producer returns an error", func() {
+			initMock := &serviceMock.InitialiserMock{
+				DoGetHTTPServerFunc:    funcDoGetHTTPServerNil,
+				DoGetMongoDBFunc:       funcDoGetMongoDBOk,
+				DoGetKafkaProducerFunc: doGetKafkaProducerErrOnTopic(cfg.ImageWithdrawnTopic),
+				DoGetHealthClientFunc:  funcDoGetHealthClientOk,
+			}
+			svcErrors := make(chan error, 1)
+			svcList := service.NewServiceList(initMock)
+			_, err := service.Run(ctx, cfg, svcList, testBuildTime, testGitCommit, testVersion, svcErrors)
+
+			Convey("Then service Run fails with the same error and the flag is not set. No further initialisations are attempted", func() {
+				So(err, ShouldResemble, errKafkaProducer)
+				So(svcList.MongoDB, ShouldBeTrue)
+				So(svcList.KafkaProducerUploaded, ShouldBeTrue)
+				So(svcList.KafkaProducerPublished, ShouldBeTrue)
+				So(svcList.KafkaProducerWithdrawn, ShouldBeFalse)
+				So(svcList.HealthCheck, ShouldBeFalse)
+			})
+		})
+
+		Convey("Given that initialising kafka image-state-changed producer returns an error", func() {
+			initMock := &serviceMock.InitialiserMock{
+				DoGetHTTPServerFunc:    funcDoGetHTTPServerNil,
+				DoGetMongoDBFunc:       funcDoGetMongoDBOk,
+				DoGetKafkaProducerFunc: doGetKafkaProducerErrOnTopic(cfg.ImageStateChangedTopic),
+				DoGetHealthClientFunc:  funcDoGetHealthClientOk,
+			}
+			svcErrors := make(chan error, 1)
+			svcList := service.NewServiceList(initMock)
+			_, err := service.Run(ctx, cfg, svcList, testBuildTime, testGitCommit, testVersion, svcErrors)
+
+			Convey("Then service Run fails with the same error and the flag is not set. No further initialisations are attempted", func() {
+				So(err, ShouldResemble, errKafkaProducer)
+				So(svcList.MongoDB, ShouldBeTrue)
+				So(svcList.KafkaProducerWithdrawn, ShouldBeTrue)
+				So(svcList.KafkaProducerStateChanged, ShouldBeFalse)
+				So(svcList.HealthCheck, ShouldBeFalse)
+			})
+		})
+
 		Convey("Given that initialising healthcheck returns an error", func() {
 			initMock := &serviceMock.InitialiserMock{
 				DoGetHTTPServerFunc:    funcDoGetHTTPServerNil,
@@ -204,8 +279,10 @@ func TestRunPublishing(t *testing.T) {
 		Convey("Given that Checkers cannot be registered", func() {
 			errAddheckFail := errors.New("Error(s) registering checkers for healthcheck")
 			hcMockAddFail := &serviceMock.HealthCheckerMock{
-				AddCheckFunc: func(name string, checker healthcheck.Checker) error { return errAddheckFail },
-				StartFunc:    func(ctx context.Context) {},
+				AddAndGetCheckFunc: func(name string, checker healthcheck.Checker) (*healthcheck.Check, error) {
+					return nil, errAddheckFail
+				},
+				StartFunc: func(ctx context.Context) {},
 			}
 
 			initMock := &serviceMock.InitialiserMock{
@@ -226,11 +303,15 @@ func TestRunPublishing(t *testing.T) {
 				So(err.Error(), ShouldResemble, fmt.Sprintf("unable to register checkers: %s", errAddheckFail.Error()))
 				So(svcList.MongoDB, ShouldBeTrue)
 				So(svcList.HealthCheck, ShouldBeTrue)
-				So(hcMockAddFail.AddCheckCalls(), ShouldHaveLength, 4)
-				So(hcMockAddFail.AddCheckCalls()[0].Name, ShouldResemble, "Mongo DB")
-				So(hcMockAddFail.AddCheckCalls()[1].Name, ShouldResemble, "Uploaded Kafka Producer")
-				So(hcMockAddFail.AddCheckCalls()[2].Name, ShouldResemble, "Published Kafka Producer")
-				So(hcMockAddFail.AddCheckCalls()[3].Name, ShouldResemble, "Zebedee")
+				So(hcMockAddFail.AddAndGetCheckCalls(), ShouldHaveLength, 7)
+				So(hcMockAddFail.AddAndGetCheckCalls()[0].Name, ShouldResemble, "Mongo DB")
+				So(hcMockAddFail.AddAndGetCheckCalls()[1].Name, ShouldResemble, "Mongo DB Indexes")
+				So(hcMockAddFail.AddAndGetCheckCalls()[2].Name, ShouldResemble, "Uploaded Kafka Producer")
+				So(hcMockAddFail.AddAndGetCheckCalls()[3].Name, ShouldResemble, "Published Kafka Producer")
+				So(hcMockAddFail.AddAndGetCheckCalls()[4].Name, ShouldResemble, "Withdrawn Kafka Producer")
+				So(hcMockAddFail.AddAndGetCheckCalls()[5].Name, ShouldResemble, "State Changed Kafka Producer")
+				So(hcMockAddFail.AddAndGetCheckCalls()[6].Name, ShouldResemble, "Zebedee")
+				So(hcMockAddFail.SubscribeCalls(), ShouldHaveLength, 0)
 			})
 		})
 
@@ -249,18 +330,33 @@ func TestRunPublishing(t *testing.T) {
 
 			Convey("Then service Run succeeds and all the flags are set", func() {
 				So(err, ShouldBeNil)
+				So(mongoDBMock.MigrateCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.MigrateCalls()[0].DryRun, ShouldBeFalse)
+				So(mongoDBMock.MigrateCalls()[0].LockTimeout, ShouldEqual, cfg.MigrationLockTimeout)
+				So(mongoDBMock.EnsureIndexesCalls(), ShouldHaveLength, 1)
+				So(mongoDBMock.EnsureIndexesCalls()[0].DeletedImageTTL, ShouldEqual, cfg.DeletedImageTTL)
 				So(svcList.MongoDB, ShouldBeTrue)
 				So(svcList.KafkaProducerUploaded, ShouldBeTrue)
 				So(svcList.KafkaProducerPublished, ShouldBeTrue)
+				So(svcList.KafkaProducerWithdrawn, ShouldBeTrue)
+				So(svcList.KafkaProducerStateChanged, ShouldBeTrue)
 				So(svcList.HealthCheck, ShouldBeTrue)
 			})
 
 			Convey("The checkers are registered and the healthcheck and http server started", func() {
-				So(hcMock.AddCheckCalls(), ShouldHaveLength, 4)
-				So(hcMock.AddCheckCalls()[0].Name, ShouldResemble, "Mongo DB")
-				So(hcMock.AddCheckCalls()[1].Name, ShouldResemble, "Uploaded Kafka Producer")
-				So(hcMock.AddCheckCalls()[2].Name, ShouldResemble, "Published Kafka Producer")
-				So(hcMock.AddCheckCalls()[3].Name, ShouldEqual, "Zebedee")
+				So(hcMock.AddAndGetCheckCalls(), ShouldHaveLength, 7)
+				So(hcMock.AddAndGetCheckCalls()[0].Name, ShouldResemble, "Mongo DB")
+				So(hcMock.AddAndGetCheckCalls()[1].Name, ShouldResemble, "Mongo DB Indexes")
+				So(hcMock.AddAndGetCheckCalls()[2].Name, ShouldResemble, "Uploaded Kafka Producer")
+				So(hcMock.AddAndGetCheckCalls()[3].Name, ShouldResemble, "Published Kafka Producer")
+				So(hcMock.AddAndGetCheckCalls()[4].Name, ShouldResemble, "Withdrawn Kafka Producer")
+				So(hcMock.AddAndGetCheckCalls()[5].Name, ShouldResemble, "State Changed Kafka Producer")
+				So(hcMock.AddAndGetCheckCalls()[6].Name, ShouldEqual, "Zebedee")
+				So(hcMock.SubscribeCalls(), ShouldHaveLength, 6)
+				for _, call := range hcMock.SubscribeCalls() {
+					So(call.S, ShouldHaveSameTypeAs, &service.Readiness{})
+					So(call.Checks, ShouldHaveLength, 1)
+				}
 				So(initMock.DoGetHTTPServerCalls(), ShouldHaveLength, 1)
 				So(initMock.DoGetHTTPServerCalls()[0].BindAddr, ShouldEqual, "localhost:24700")
 				So(hcMock.StartCalls(), ShouldHaveLength, 1)
@@ -269,6 +365,77 @@ func TestRunPublishing(t *testing.T) {
 			})
 		})
 
+		Convey("Given that events are encoded in CloudEvents binary mode", func() {
+			cfg.EventEncoding = "cloudevents-binary"
+			producers := map[string]*kafkatest.IProducerMock{}
+			initMock := &serviceMock.InitialiserMock{
+				DoGetHTTPServerFunc: funcDoGetHTTPServer,
+				DoGetMongoDBFunc:    funcDoGetMongoDBOk,
+				DoGetKafkaProducerFunc: func(ctx context.Context, cfg *config.Config, topic string) (kafka.IProducer, error) {
+					producers[topic] = &kafkatest.IProducerMock{
+						ChannelsFunc:  func() *kafka.ProducerChannels { return &kafka.ProducerChannels{} },
+						LogErrorsFunc: func(ctx context.Context) {},
+						AddHeaderFunc: func(key, value string) {},
+					}
+					return producers[topic], nil
+				},
+				DoGetHealthCheckFunc:  funcDoGetHealthcheckOk,
+				DoGetHealthClientFunc: funcDoGetHealthClientOk,
+			}
+			svcErrors := make(chan error, 1)
+			svcList := service.NewServiceList(initMock)
+			serverWg.Add(1)
+			_, err := service.Run(ctx, cfg, svcList, testBuildTime, testGitCommit, testVersion, svcErrors)
+			So(err, ShouldBeNil)
+			serverWg.Wait() // Wait for HTTP server go-routine to finish
+
+			Convey("Then each kafka producer sends the CloudEvents headers of the events of its topic with every message", func() {
+				So(producers, ShouldHaveLength, 4)
+				for topic, eventType := range map[string]string{
+					cfg.ImageUploadedTopic:       event.TypeImageUploaded,
+					cfg.StaticFilePublishedTopic: event.TypeImagePublished,
+					cfg.ImageWithdrawnTopic:      event.TypeImageWithdrawn,
+					cfg.ImageStateChangedTopic:   event.TypeImageStateChanged,
+				} {
+					headers := map[string]string{}
+					for _, call := range producers[topic].AddHeaderCalls() {
+						headers[call.Key] = call.Value
+					}
+					So(producers[topic].AddHeaderCalls(), ShouldHaveLength, 4)
+					So(headers, ShouldResemble, map[string]string{
+						"ce_specversion": "1.0",
+						"ce_source":      "dp-image-api",
+						"ce_type":        eventType,
+						"content-type":   "application/json",
+					})
+				}
+			})
+		})
+
+		Convey("Given that mongoDB indexes fail to be created", func() {
+			mongoDBMock.EnsureIndexesFunc = func(ctx context.Context, deletedImageTTL time.Duration) error {
+				return errMongoDB
+			}
+			initMock := &serviceMock.InitialiserMock{
+				DoGetHTTPServerFunc:    funcDoGetHTTPServer,
+				DoGetMongoDBFunc:       funcDoGetMongoDBOk,
+				DoGetKafkaProducerFunc: funcDoGetKafkaProducerOk,
+				DoGetHealthCheckFunc:   funcDoGetHealthcheckOk,
+				DoGetHealthClientFunc:  funcDoGetHealthClientOk,
+			}
+			svcErrors := make(chan error, 1)
+			svcList := service.NewServiceList(initMock)
+			serverWg.Add(1)
+			_, err := service.Run(ctx, cfg, svcList, testBuildTime, testGitCommit, testVersion, svcErrors)
+
+			Convey("Then service Run succeeds, as the missing indexes are reported by the index health check", func() {
+				So(err, ShouldBeNil)
+				So(mongoDBMock.EnsureIndexesCalls(), ShouldHaveLength, 1)
+				So(svcList.HealthCheck, ShouldBeTrue)
+				serverWg.Wait() // Wait for HTTP server go-routine to finish
+			})
+		})
+
 		Convey("Given that all dependencies are successfully initialised but the http server fails", func() {
 			initMock := &serviceMock.InitialiserMock{
 				DoGetHTTPServerFunc:    funcDoGetFailingHTTPSerer,
@@ -302,8 +469,10 @@ func TestRunPublishing(t *testing.T) {
 			serverWg.Add(1)
 			_, err := service.Run(ctx, cfg, svcList, testBuildTime, testGitCommit, testVersion, svcErrors)
 
-			Convey("Then service Run succeeds but only the required flags are set", func() {
+			Convey("Then service Run succeeds but only the required flags are set, and no migration is applied nor index created", func() {
 				So(err, ShouldBeNil)
+				So(mongoDBMock.MigrateCalls(), ShouldHaveLength, 0)
+				So(mongoDBMock.EnsureIndexesCalls(), ShouldHaveLength, 0)
 				So(svcList.MongoDB, ShouldBeTrue)
 				So(svcList.KafkaProducerUploaded, ShouldBeFalse)
 				So(svcList.KafkaProducerPublished, ShouldBeFalse)
@@ -324,9 +493,12 @@ func TestClose(t *testing.T) {
 
 		// healthcheck Stop does not depend on any other service being closed/stopped
 		hcMock := &serviceMock.HealthCheckerMock{
-			AddCheckFunc: func(name string, checker healthcheck.Checker) error { return nil },
-			StartFunc:    func(ctx context.Context) {},
-			StopFunc:     func() { hcStopped = true },
+			AddAndGetCheckFunc: func(name string, checker healthcheck.Checker) (*healthcheck.Check, error) {
+				return &healthcheck.Check{}, nil
+			},
+			SubscribeFunc: func(s healthcheck.Subscriber, checks ...*healthcheck.Check) {},
+			StartFunc:     func(ctx context.Context) {},
+			StopFunc:      func() { hcStopped = true },
 		}
 
 		// server Shutdown will fail if healthcheck is not stopped
@@ -351,6 +523,11 @@ func TestClose(t *testing.T) {
 				mongoStopped = true
 				return nil
 			},
+			MigrateFunc: func(ctx context.Context, dryRun bool, lockTimeout time.Duration) (*models.MigrationResult, error) {
+				return &models.MigrationResult{}, nil
+			},
+			EnsureIndexesFunc: func(ctx context.Context, deletedImageTTL time.Duration) error { return nil },
+			IndexCheckerFunc:  func(ctx context.Context, state *healthcheck.CheckState) error { return nil },
 		}
 
 		// kafkaProducerMock (for any kafka producer) will fail if healthcheck, http server and mongo are not already closed
@@ -371,12 +548,18 @@ func TestClose(t *testing.T) {
 		}
 		kafkaUploadedProducerMock := createKafkaProducerMock()
 		kafkaPublishedProducerMock := createKafkaProducerMock()
+		kafkaWithdrawnProducerMock := createKafkaProducerMock()
+		kafkaStateChangedProducerMock := createKafkaProducerMock()
 		doGetKafkaProducerFunc := func(ctx context.Context, cfg *config.Config, topic string) (kafka.IProducer, error) {
 			switch topic {
 			case cfg.ImageUploadedTopic:
 				return kafkaUploadedProducerMock, nil
 			case cfg.StaticFilePublishedTopic:
 				return kafkaPublishedProducerMock, nil
+			case cfg.ImageWithdrawnTopic:
+				return kafkaWithdrawnProducerMock, nil
+			case cfg.ImageStateChangedTopic:
+				return kafkaStateChangedProducerMock, nil
 			default:
 				return nil, errors.New("wrong topic")
 			}
@@ -405,6 +588,52 @@ func TestClose(t *testing.T) {
 			So(mongoDBMock.CloseCalls(), ShouldHaveLength, 1)
 			So(kafkaUploadedProducerMock.CloseCalls(), ShouldHaveLength, 1)
 			So(kafkaPublishedProducerMock.CloseCalls(), ShouldHaveLength, 1)
+			So(kafkaWithdrawnProducerMock.CloseCalls(), ShouldHaveLength, 1)
+			So(kafkaStateChangedProducerMock.CloseCalls(), ShouldHaveLength, 1)
+		})
+
+		Convey("Closing the service flips the readiness to not-ready before the http server is shut down", func() {
+			var router http.Handler
+			readyOnShutdown := http.StatusOK
+			drainingServerMock := &serviceMock.HTTPServerMock{
+				ListenAndServeFunc: func() error { return nil },
+				ShutdownFunc: func(ctx context.Context) error {
+					w := httptest.NewRecorder()
+					router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ready", http.NoBody))
+					readyOnShutdown = w.Code
+					serverStopped = true
+					return nil
+				},
+			}
+			hcMock.SubscribeFunc = func(s healthcheck.Subscriber, checks ...*healthcheck.Check) {
+				s.OnHealthUpdate(healthcheck.StatusOK)
+			}
+
+			initMock := &serviceMock.InitialiserMock{
+				DoGetHTTPServerFunc: func(bindAddr string, r http.Handler) service.HTTPServer {
+					router = r
+					return drainingServerMock
+				},
+				DoGetMongoDBFunc:       func(ctx context.Context, cfg config.MongoConfig) (api.MongoServer, error) { return mongoDBMock, nil },
+				DoGetKafkaProducerFunc: doGetKafkaProducerFunc,
+				DoGetHealthCheckFunc: func(cfg *config.Config, buildTime string, gitCommit string, version string) (service.HealthChecker, error) {
+					return hcMock, nil
+				},
+				DoGetHealthClientFunc: func(name, url string) *health.Client { return &health.Client{} },
+			}
+
+			svcErrors := make(chan error, 1)
+			svcList := service.NewServiceList(initMock)
+			svc, err := service.Run(ctx, cfg, svcList, testBuildTime, testGitCommit, testVersion, svcErrors)
+			So(err, ShouldBeNil)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ready", http.NoBody))
+			So(w.Code, ShouldEqual, http.StatusOK)
+
+			So(svc.Close(context.Background()), ShouldBeNil)
+			So(drainingServerMock.ShutdownCalls(), ShouldHaveLength, 1)
+			So(readyOnShutdown, ShouldEqual, http.StatusServiceUnavailable)
 		})
 
 		Convey("If services fail to stop, the Close operation tries to close all dependencies and returns an error", func() {
@@ -437,6 +666,63 @@ func TestClose(t *testing.T) {
 			So(mongoDBMock.CloseCalls(), ShouldHaveLength, 1)
 			So(kafkaUploadedProducerMock.CloseCalls(), ShouldHaveLength, 1)
 			So(kafkaPublishedProducerMock.CloseCalls(), ShouldHaveLength, 1)
+			So(kafkaWithdrawnProducerMock.CloseCalls(), ShouldHaveLength, 1)
+			So(kafkaStateChangedProducerMock.CloseCalls(), ShouldHaveLength, 1)
+		})
+	})
+}
+
+func TestGetInitialisedKafkaProducer(t *testing.T) {
+	Convey("Given a service list that creates kafka producers", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+
+		kafkaProducerMock := &kafkatest.IProducerMock{
+			InitialiseFunc: func(ctx context.Context) error { return nil },
+			CloseFunc:      func(ctx context.Context) error { return nil },
+		}
+		initMock := &serviceMock.InitialiserMock{
+			DoGetKafkaProducerFunc: func(ctx context.Context, cfg *config.Config, topic string) (kafka.IProducer, error) {
+				return kafkaProducerMock, nil
+			},
+		}
+		svcList := service.NewServiceList(initMock)
+
+		Convey("Then an initialised producer for the requested topic is returned", func() {
+			producer, err := svcList.GetInitialisedKafkaProducer(ctx, cfg, service.KafkaProducerPublished)
+			So(err, ShouldBeNil)
+			So(producer, ShouldEqual, kafkaProducerMock)
+			So(initMock.DoGetKafkaProducerCalls(), ShouldHaveLength, 1)
+			So(initMock.DoGetKafkaProducerCalls()[0].Topic, ShouldEqual, cfg.StaticFilePublishedTopic)
+			So(kafkaProducerMock.InitialiseCalls(), ShouldHaveLength, 1)
+			So(kafkaProducerMock.CloseCalls(), ShouldHaveLength, 0)
+			So(svcList.KafkaProducerPublished, ShouldBeTrue)
+		})
+
+		Convey("Then a producer that fails to initialise is closed and the error is returned", func() {
+			kafkaProducerMock.InitialiseFunc = func(ctx context.Context) error { return errKafkaProducer }
+			producer, err := svcList.GetInitialisedKafkaProducer(ctx, cfg, service.KafkaProducerPublished)
+			So(err, ShouldResemble, errKafkaProducer)
+			So(producer, ShouldBeNil)
+			So(kafkaProducerMock.CloseCalls(), ShouldHaveLength, 1)
+		})
+	})
+
+	Convey("Given a service list that fails to create kafka producers", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+
+		initMock := &serviceMock.InitialiserMock{
+			DoGetKafkaProducerFunc: func(ctx context.Context, cfg *config.Config, topic string) (kafka.IProducer, error) {
+				return nil, errKafkaProducer
+			},
+		}
+		svcList := service.NewServiceList(initMock)
+
+		Convey("Then the error is returned", func() {
+			producer, err := svcList.GetInitialisedKafkaProducer(ctx, cfg, service.KafkaProducerUploaded)
+			So(err, ShouldResemble, errKafkaProducer)
+			So(producer, ShouldBeNil)
 		})
 	})
 }
@@ -0,0 +1,103 @@
+package signedurl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testURL = "http://localhost:23600/images/123/original/image%20name.png"
+
+var (
+	testKey     = []byte("0123456789abcdef0123456789abcdef")
+	testNow     = time.Date(2020, time.April, 26, 8, 5, 52, 0, time.UTC)
+	testExpires = testNow.Add(15 * time.Minute)
+)
+
+// newTestVerifier returns a verifier with the provided key, for which it is the provided time
+func newTestVerifier(key []byte, now time.Time) *Verifier {
+	v := NewVerifier(key)
+	v.now = func() time.Time { return now }
+	return v
+}
+
+// mustParse parses the provided URL, failing the test if it is not valid
+func mustParse(rawURL string) *url.URL {
+	u, err := url.Parse(rawURL)
+	So(err, ShouldBeNil)
+	return u
+}
+
+func TestSignedURL(t *testing.T) {
+	Convey("Given a URL signed with a key, expiring in 15 minutes", t, func() {
+		signed, err := NewSigner(testKey).Sign(testURL, testExpires)
+		So(err, ShouldBeNil)
+
+		Convey("Then the URL keeps its path, and has the expiry time and signature query parameters", func() {
+			u := mustParse(signed)
+			So(u.Host, ShouldEqual, "localhost:23600")
+			So(u.EscapedPath(), ShouldEqual, "/images/123/original/image%20name.png")
+			So(u.Query().Get(ExpiresParam), ShouldEqual, "1587889252")
+			So(u.Query().Get(SignatureParam), ShouldHaveLength, 64)
+		})
+
+		Convey("Then the URL is verified with the same key before it expires, regardless of its host", func() {
+			verifier := newTestVerifier(testKey, testNow)
+			So(verifier.Verify(mustParse(signed)), ShouldBeNil)
+			So(verifier.Verify(mustParse(strings.Replace(signed, "http://localhost:23600", "https://download.example.org", 1))), ShouldBeNil)
+
+			r := httptest.NewRequest(http.MethodGet, signed, http.NoBody)
+			So(verifier.VerifyRequest(r), ShouldBeNil)
+		})
+
+		Convey("Then the URL is expired once its expiry time is reached", func() {
+			So(newTestVerifier(testKey, testExpires.Add(-time.Second)).Verify(mustParse(signed)), ShouldBeNil)
+			So(newTestVerifier(testKey, testExpires).Verify(mustParse(signed)), ShouldEqual, ErrExpired)
+		})
+
+		Convey("Then the URL is not valid with another key", func() {
+			So(newTestVerifier([]byte("another key"), testNow).Verify(mustParse(signed)), ShouldEqual, ErrInvalidSignature)
+		})
+
+		Convey("Then the URL is not valid if its path, expiry time or signature is modified", func() {
+			verifier := newTestVerifier(testKey, testNow)
+			So(verifier.Verify(mustParse(strings.Replace(signed, "/original/", "/alternative/", 1))), ShouldEqual, ErrInvalidSignature)
+			So(verifier.Verify(mustParse(strings.Replace(signed, "expires=1587889252", "expires=1587892852", 1))), ShouldEqual, ErrInvalidSignature)
+			So(verifier.Verify(mustParse(strings.Replace(signed, "signature=", "signature=0", 1))), ShouldEqual, ErrInvalidSignature)
+			So(verifier.Verify(mustParse(signed+"&other=value")), ShouldEqual, ErrInvalidSignature)
+		})
+	})
+
+	Convey("Given a URL with query parameters signed with a key", t, func() {
+		signed, err := NewSigner(testKey).Sign(testURL+"?b=2&a=1", testExpires)
+		So(err, ShouldBeNil)
+
+		Convey("Then the URL is verified regardless of the order of its query parameters", func() {
+			u := mustParse(signed)
+			u.RawQuery = "signature=" + u.Query().Get(SignatureParam) + "&expires=1587889252&b=2&a=1"
+			So(newTestVerifier(testKey, testNow).Verify(u), ShouldBeNil)
+		})
+	})
+
+	Convey("Given a URL without signature or expiry time", t, func() {
+		verifier := newTestVerifier(testKey, testNow)
+
+		Convey("Then it is not signed", func() {
+			So(verifier.Verify(mustParse(testURL)), ShouldEqual, ErrUnsigned)
+			So(verifier.Verify(mustParse(testURL+"?expires=1587889252")), ShouldEqual, ErrUnsigned)
+			So(verifier.Verify(mustParse(testURL+"?signature=abc")), ShouldEqual, ErrUnsigned)
+		})
+	})
+
+	Convey("Given an invalid URL", t, func() {
+		Convey("Then it cannot be signed", func() {
+			_, err := NewSigner(testKey).Sign("http://host/%zz", testExpires)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -1,33 +1,177 @@
 package url
 
-import "fmt"
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/ONSdigital/dp-image-api/config"
+)
+
+// Placeholders that can be used in url templates, which are replaced by the corresponding values when building urls.
+// Image IDs, variants and filenames are escaped, whereas base urls are used as they are.
+const (
+	PlaceholderAPIURL             = "{api_url}"
+	PlaceholderDownloadServiceURL = "{download_service_url}"
+	PlaceholderImageID            = "{image_id}"
+	PlaceholderVariant            = "{variant}"
+	PlaceholderVersion            = "{version}"
+	PlaceholderFilename           = "{filename}"
+)
+
+// Templates defines the structure of the urls built by a Builder
+type Templates struct {
+	// Image is the template of the API link of an image, which the links of its downloads and versions are built under
+	Image string
+	// Download is the template of the download service href of the file of an image download variant, for a specific version
+	Download string
+	// CDN is the template of the href of published image download variants. Download hrefs are used if it is empty.
+	CDN string
+	// SlugifyFilenames defines whether filenames are slugified before being used in hrefs
+	SlugifyFilenames bool
+}
+
+// DefaultTemplates are the templates of the API links and download service hrefs, without any CDN
+var DefaultTemplates = Templates{
+	Image:    PlaceholderAPIURL + "/images/" + PlaceholderImageID,
+	Download: PlaceholderDownloadServiceURL + "/images/" + PlaceholderImageID + "/" + PlaceholderVariant + "/" + PlaceholderVersion + "/" + PlaceholderFilename,
+}
 
 // Builder encapsulates the building of urls in a central place, with knowledge of the url structures and base host names.
 type Builder struct {
-	apiURL string
+	apiURL             string
+	downloadServiceURL string
+	templates          Templates
+}
+
+// NewBuilder returns a new instance of url.Builder, building urls with the default templates
+func NewBuilder(apiURL, downloadServiceURL string) *Builder {
+	return NewBuilderWithTemplates(apiURL, downloadServiceURL, DefaultTemplates)
 }
 
-// NewBuilder returns a new instance of url.Builder
-func NewBuilder(apiURL string) *Builder {
+// NewBuilderWithTemplates returns a new instance of url.Builder, building urls with the provided templates
+func NewBuilderWithTemplates(apiURL, downloadServiceURL string, templates Templates) *Builder {
 	return &Builder{
-		apiURL: apiURL,
+		apiURL:             apiURL,
+		downloadServiceURL: downloadServiceURL,
+		templates:          templates,
 	}
 }
 
+// NewBuilderFromConfig returns a new instance of url.Builder, building urls with the base urls and templates of the provided config
+func NewBuilderFromConfig(cfg *config.Config) *Builder {
+	return NewBuilderWithTemplates(cfg.APIURL, cfg.DownloadServiceURL, Templates{
+		Image:            cfg.ImageLinkTemplate,
+		Download:         cfg.DownloadHrefTemplate,
+		CDN:              cfg.CDNHrefTemplate,
+		SlugifyFilenames: cfg.SlugifyFilenames,
+	})
+}
+
+// WithAPIURL returns a copy of the builder, building API links under the provided API url
+func (builder Builder) WithAPIURL(apiURL string) *Builder {
+	builder.apiURL = apiURL
+	return &builder
+}
+
 // BuildImageURL returns the website URL for a specific image
 func (builder Builder) BuildImageURL(imageID string) string {
-	return fmt.Sprintf("%s/images/%s",
-		builder.apiURL, imageID)
+	return builder.expand(builder.templates.Image, imageID, "", 0, "")
 }
 
 // BuildImageBuildImageDownloadsURL returns the website URL for a collection of downloads for this image
 func (builder Builder) BuildImageDownloadsURL(imageID string) string {
-	return fmt.Sprintf("%s/images/%s/downloads",
-		builder.apiURL, imageID)
+	return fmt.Sprintf("%s/downloads",
+		builder.BuildImageURL(imageID))
 }
 
 // BuildImageDownloadURL returns the website URL for a specific image dowload variant
 func (builder Builder) BuildImageDownloadURL(imageID, variant string) string {
-	return fmt.Sprintf("%s/images/%s/downloads/%s",
-		builder.apiURL, imageID, variant)
+	return fmt.Sprintf("%s/downloads/%s",
+		builder.BuildImageURL(imageID), url.PathEscape(variant))
+}
+
+// BuildImageVersionURL returns the website URL for a specific published version of an image
+func (builder Builder) BuildImageVersionURL(imageID string, version int) string {
+	return fmt.Sprintf("%s/versions/%d",
+		builder.BuildImageURL(imageID), version)
+}
+
+// BuildImageVersionDownloadURL returns the website URL for a specific download variant of a published version of an image
+func (builder Builder) BuildImageVersionDownloadURL(imageID string, version int, variant string) string {
+	return fmt.Sprintf("%s/downloads/%s",
+		builder.BuildImageVersionURL(imageID, version), url.PathEscape(variant))
+}
+
+// BuildDownloadURL returns the download service URL for the file of a specific version of an image download variant
+func (builder Builder) BuildDownloadURL(imageID, variant string, version int, filename string) string {
+	return builder.expand(builder.templates.Download, imageID, variant, version, filename)
+}
+
+// BuildPublicDownloadURL returns the URL for the file of a specific version of a published image download variant,
+// which is the CDN URL if a CDN template is defined, or the download service URL otherwise
+func (builder Builder) BuildPublicDownloadURL(imageID, variant string, version int, filename string) string {
+	if builder.templates.CDN == "" {
+		return builder.BuildDownloadURL(imageID, variant, version, filename)
+	}
+	return builder.expand(builder.templates.CDN, imageID, variant, version, filename)
+}
+
+// BuildFilePath returns the path that the file of a specific version of an image download variant is published under,
+// so that publishing a new version of an image does not replace the files of its previous versions
+func (builder Builder) BuildFilePath(imageID, variant string, version int, filename string) string {
+	return path.Join("images", imageID, variant, strconv.Itoa(version), builder.BuildFilename(filename))
+}
+
+// BuildFilename returns the filename of the published files of image download variants, which is slugified if configured to,
+// so that the files are published where their hrefs point to. Filenames without any ASCII letter or digit before their extension
+// are kept as they are, as their slug would be empty or only an extension.
+func (builder Builder) BuildFilename(filename string) string {
+	if !builder.templates.SlugifyFilenames {
+		return filename
+	}
+	slug := Slugify(filename)
+	if !strings.ContainsAny(strings.TrimSuffix(slug, path.Ext(slug)), slugAlphanumerics) {
+		return filename
+	}
+	return slug
+}
+
+// expand replaces the placeholders of the provided template with the base urls of the builder and the escaped provided values
+func (builder Builder) expand(template, imageID, variant string, version int, filename string) string {
+	return strings.NewReplacer(
+		PlaceholderAPIURL, builder.apiURL,
+		PlaceholderDownloadServiceURL, builder.downloadServiceURL,
+		PlaceholderImageID, url.PathEscape(imageID),
+		PlaceholderVariant, url.PathEscape(variant),
+		PlaceholderVersion, strconv.Itoa(version),
+		PlaceholderFilename, url.PathEscape(builder.BuildFilename(filename)),
+	).Replace(template)
+}
+
+// slugAlphanumerics are the characters that make a slug meaningful as a filename
+const slugAlphanumerics = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// Slugify returns the provided filename in lower case, with every sequence of characters other than ASCII letters, digits,
+// dots and underscores replaced by a single hyphen, unless it is at the start or end of the filename, or next to a dot
+func Slugify(filename string) string {
+	var slug strings.Builder
+	hyphen := false
+	last := rune(0)
+	for _, r := range strings.ToLower(filename) {
+		switch {
+		case (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '_':
+			if hyphen && last != 0 && last != '.' && r != '.' {
+				slug.WriteRune('-')
+			}
+			hyphen = false
+			slug.WriteRune(r)
+			last = r
+		default:
+			hyphen = true
+		}
+	}
+	return slug.String()
 }
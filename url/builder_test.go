@@ -10,13 +10,16 @@ import (
 
 const (
 	websiteURL      = "localhost:20000"
+	downloadURL     = "localhost:23600"
+	filename        = "some-image.png"
 	imageID         = "123"
 	downloadVariant = "640bw"
+	imageVersion    = 2
 )
 
 func TestBuilder_BuildWebsiteDatasetVersionURL(t *testing.T) {
 	Convey("Given a URL builder", t, func() {
-		urlBuilder := url.NewBuilder(websiteURL)
+		urlBuilder := url.NewBuilder(websiteURL, downloadURL)
 
 		Convey("When BuildImageURL is called", func() {
 			imageURL := urlBuilder.BuildImageURL(imageID)
@@ -50,5 +53,123 @@ func TestBuilder_BuildWebsiteDatasetVersionURL(t *testing.T) {
 				So(imageURL, ShouldEqual, expectedURL)
 			})
 		})
+
+		Convey("When BuildImageVersionURL is called", func() {
+			imageURL := urlBuilder.BuildImageVersionURL(imageID, imageVersion)
+
+			expectedURL := fmt.Sprintf("%s/images/%s/versions/%d",
+				websiteURL, imageID, imageVersion)
+
+			Convey("Then the expected URL is returned", func() {
+				So(imageURL, ShouldEqual, expectedURL)
+			})
+		})
+
+		Convey("When BuildImageVersionDownloadURL is called", func() {
+			imageURL := urlBuilder.BuildImageVersionDownloadURL(imageID, imageVersion, downloadVariant)
+
+			expectedURL := fmt.Sprintf("%s/images/%s/versions/%d/downloads/%s",
+				websiteURL, imageID, imageVersion, downloadVariant)
+
+			Convey("Then the expected URL is returned", func() {
+				So(imageURL, ShouldEqual, expectedURL)
+			})
+		})
+
+		Convey("When BuildDownloadURL is called", func() {
+			downloadFileURL := urlBuilder.BuildDownloadURL(imageID, downloadVariant, imageVersion, filename)
+
+			expectedURL := fmt.Sprintf("%s/images/%s/%s/%d/%s",
+				downloadURL, imageID, downloadVariant, imageVersion, filename)
+
+			Convey("Then the expected URL is returned", func() {
+				So(downloadFileURL, ShouldEqual, expectedURL)
+			})
+		})
+
+		Convey("When BuildFilePath is called", func() {
+			filePath := urlBuilder.BuildFilePath(imageID, downloadVariant, imageVersion, filename)
+
+			Convey("Then the versioned path that the download service href points to is returned", func() {
+				So(filePath, ShouldEqual, fmt.Sprintf("images/%s/%s/%d/%s", imageID, downloadVariant, imageVersion, filename))
+				So(urlBuilder.BuildDownloadURL(imageID, downloadVariant, imageVersion, filename), ShouldEqual, downloadURL+"/"+filePath)
+			})
+		})
+
+		Convey("When BuildDownloadURL is called for different versions", func() {
+			Convey("Then each version has its own URL", func() {
+				So(urlBuilder.BuildDownloadURL(imageID, downloadVariant, 1, filename), ShouldNotEqual, urlBuilder.BuildDownloadURL(imageID, downloadVariant, 2, filename))
+			})
+		})
+	})
+}
+
+func TestBuilderTemplates(t *testing.T) {
+	Convey("Given a URL builder with custom API link, download and CDN templates, slugifying filenames", t, func() {
+		urlBuilder := url.NewBuilderWithTemplates(websiteURL, downloadURL, url.Templates{
+			Image:            "{api_url}/v1/images/{image_id}",
+			Download:         "{download_service_url}/files/{variant}/{image_id}/v{version}/{filename}",
+			CDN:              "https://cdn.example.com/images/{image_id}/{variant}/{version}/{filename}",
+			SlugifyFilenames: true,
+		})
+
+		Convey("Then API links are built with the image link template", func() {
+			So(urlBuilder.BuildImageURL(imageID), ShouldEqual, websiteURL+"/v1/images/123")
+			So(urlBuilder.BuildImageDownloadsURL(imageID), ShouldEqual, websiteURL+"/v1/images/123/downloads")
+			So(urlBuilder.BuildImageDownloadURL(imageID, downloadVariant), ShouldEqual, websiteURL+"/v1/images/123/downloads/640bw")
+			So(urlBuilder.BuildImageVersionURL(imageID, imageVersion), ShouldEqual, websiteURL+"/v1/images/123/versions/2")
+			So(urlBuilder.BuildImageVersionDownloadURL(imageID, imageVersion, downloadVariant), ShouldEqual, websiteURL+"/v1/images/123/versions/2/downloads/640bw")
+		})
+
+		Convey("Then download and public hrefs are built with the download and CDN templates, and slugified filenames", func() {
+			So(urlBuilder.BuildDownloadURL(imageID, downloadVariant, imageVersion, "My Image (1).PNG"), ShouldEqual, downloadURL+"/files/640bw/123/v2/my-image-1.png")
+			So(urlBuilder.BuildPublicDownloadURL(imageID, downloadVariant, imageVersion, "My Image (1).PNG"), ShouldEqual, "https://cdn.example.com/images/123/640bw/2/my-image-1.png")
+			So(urlBuilder.BuildFilename("My Image (1).PNG"), ShouldEqual, "my-image-1.png")
+		})
+
+		Convey("Then filenames without any ASCII letter or digit before their extension are kept as they are, and escaped", func() {
+			for _, name := range []string{"写真.png", "(!).PNG", "¿?", "..."} {
+				So(urlBuilder.BuildFilename(name), ShouldEqual, name)
+			}
+			So(urlBuilder.BuildDownloadURL(imageID, downloadVariant, imageVersion, "写真.png"), ShouldEqual, downloadURL+"/files/640bw/123/v2/%E5%86%99%E7%9C%9F.png")
+			So(urlBuilder.BuildPublicDownloadURL(imageID, downloadVariant, imageVersion, "(!).PNG"), ShouldEqual, "https://cdn.example.com/images/123/640bw/2/%28%21%29.PNG")
+			So(urlBuilder.BuildFilename("写真 1.png"), ShouldEqual, "1.png")
+		})
+
+		Convey("Then a copy with another API url builds API links under that url, with the same templates", func() {
+			otherBuilder := urlBuilder.WithAPIURL("https://api.example.com")
+			So(otherBuilder.BuildImageURL(imageID), ShouldEqual, "https://api.example.com/v1/images/123")
+			So(otherBuilder.BuildDownloadURL(imageID, downloadVariant, imageVersion, filename), ShouldEqual, downloadURL+"/files/640bw/123/v2/some-image.png")
+			So(urlBuilder.BuildImageURL(imageID), ShouldEqual, websiteURL+"/v1/images/123")
+		})
+	})
+
+	Convey("Given a URL builder with the default templates", t, func() {
+		urlBuilder := url.NewBuilder(websiteURL, downloadURL)
+
+		Convey("Then public hrefs are download service hrefs", func() {
+			So(urlBuilder.BuildPublicDownloadURL(imageID, downloadVariant, imageVersion, filename), ShouldEqual, urlBuilder.BuildDownloadURL(imageID, downloadVariant, imageVersion, filename))
+		})
+
+		Convey("Then filenames are kept as they are, and path values are escaped", func() {
+			So(urlBuilder.BuildFilename("My Image.png"), ShouldEqual, "My Image.png")
+			So(urlBuilder.BuildDownloadURL("a/b", "c?d", 1, "My Image#1.png"), ShouldEqual, downloadURL+"/images/a%2Fb/c%3Fd/1/My%20Image%231.png")
+			So(urlBuilder.BuildImageDownloadURL("a b", "c/d"), ShouldEqual, websiteURL+"/images/a%20b/downloads/c%2Fd")
+		})
+	})
+}
+
+func TestSlugify(t *testing.T) {
+	Convey("Filenames are slugified", t, func() {
+		for filename, expected := range map[string]string{
+			"some-image.png":           "some-image.png",
+			"My Image (1).PNG":         "my-image-1.png",
+			"  --Leading/trailing--  ": "leading-trailing",
+			"snake_case.file.jpeg":     "snake_case.file.jpeg",
+			"café & crème.png":         "caf-cr-me.png",
+			"":                         "",
+		} {
+			So(url.Slugify(filename), ShouldEqual, expected)
+		}
 	})
 }